@@ -3,14 +3,14 @@ package backends
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	// #nosec G501 - MD5 used for checksums not security
 	"crypto/md5"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -41,8 +41,8 @@ func NewAzureBackend(cfg AzureConfig) (*AzureBackend, error) {
 		return nil, fmt.Errorf("azure container name is required")
 	}
 
-	// Parse connection string to extract account name and key
-	accountName, accountKey, err := parseConnectionString(cfg.ConnectionString)
+	// Parse connection string to extract account name, key and endpoint
+	accountName, accountKey, blobEndpoint, err := parseConnectionString(cfg.ConnectionString)
 	if err != nil {
 		return nil, fmt.Errorf("invalid connection string: %w", err)
 	}
@@ -57,7 +57,10 @@ func NewAzureBackend(cfg AzureConfig) (*AzureBackend, error) {
 	pipeline := azblob.NewPipeline(credential, azblob.PipelineOptions{})
 
 	// Create container URL
-	u, _ := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s", accountName, cfg.Container))
+	u, err := url.Parse(fmt.Sprintf("%s/%s", blobEndpoint, cfg.Container))
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob endpoint: %w", err)
+	}
 	containerURL := azblob.NewContainerURL(*u, pipeline)
 
 	ab := &AzureBackend{
@@ -90,22 +93,29 @@ func NewAzureBackend(cfg AzureConfig) (*AzureBackend, error) {
 	return ab, nil
 }
 
-// parseConnectionString extracts account name and key from connection string
-func parseConnectionString(connStr string) (accountName, accountKey string, err error) {
+// parseConnectionString extracts account name, key and blob endpoint from
+// connection string. The endpoint defaults to the account's public one; a
+// BlobEndpoint such as Azurite's overrides it.
+func parseConnectionString(connStr string) (accountName, accountKey, blobEndpoint string, err error) {
 	parts := bytes.Split([]byte(connStr), []byte(";"))
 	for _, part := range parts {
 		if bytes.HasPrefix(part, []byte("AccountName=")) {
 			accountName = string(bytes.TrimPrefix(part, []byte("AccountName=")))
 		} else if bytes.HasPrefix(part, []byte("AccountKey=")) {
 			accountKey = string(bytes.TrimPrefix(part, []byte("AccountKey=")))
+		} else if bytes.HasPrefix(part, []byte("BlobEndpoint=")) {
+			blobEndpoint = string(bytes.TrimPrefix(part, []byte("BlobEndpoint=")))
 		}
 	}
 
 	if accountName == "" || accountKey == "" {
-		return "", "", fmt.Errorf("connection string must contain AccountName and AccountKey")
+		return "", "", "", fmt.Errorf("connection string must contain AccountName and AccountKey")
+	}
+	if blobEndpoint == "" {
+		blobEndpoint = fmt.Sprintf("https://%s.blob.core.windows.net", accountName)
 	}
 
-	return accountName, accountKey, nil
+	return accountName, accountKey, strings.TrimSuffix(blobEndpoint, "/"), nil
 }
 
 // isAlreadyExistsError checks if error is because container already exists
@@ -148,27 +158,89 @@ func (ab *AzureBackend) WriteBatch(events []*core.LogEvent) error {
 	return nil
 }
 
-// Read reads events from Azure (not implemented for audit logs)
-func (ab *AzureBackend) Read(_, _ time.Time) ([]*core.LogEvent, error) {
-	// Audit logs are write-only for compliance
-	return nil, fmt.Errorf("reading from audit backend is not supported")
+// Read reads events within a time range, listing only the hourly
+// partitions the range covers
+func (ab *AzureBackend) Read(start, end time.Time) ([]*core.LogEvent, error) {
+	// Buffered events are read too
+	ab.mu.Lock()
+	err := ab.flushLocked()
+	ab.mu.Unlock()
+	if err != nil {
+		return nil, &BackendError{Backend: "azure", Op: "read", Err: err}
+	}
+
+	ctx := context.Background()
+	prefix := partitionListPrefix(ab.config.Prefix, start, end)
+
+	var events []*core.LogEvent
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		listBlob, err := ab.containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Prefix: prefix,
+		})
+		if err != nil {
+			return nil, &BackendError{Backend: "azure", Op: "list", Err: err}
+		}
+
+		marker = listBlob.NextMarker
+
+		for _, blobItem := range listBlob.Segment.BlobItems {
+			if !eventBlob(ab.config.Prefix, blobItem.Name) || !partitionInRange(ab.config.Prefix, blobItem.Name, start, end) {
+				continue
+			}
+
+			blobEvents, err := ab.downloadEvents(ctx, blobItem.Name)
+			if err != nil {
+				return nil, &BackendError{Backend: "azure", Op: "read", Err: err}
+			}
+			events = append(events, eventsInRange(blobEvents, start, end)...)
+		}
+	}
+
+	return events, nil
+}
+
+// downloadEvents streams the events of a blob
+func (ab *AzureBackend) downloadEvents(ctx context.Context, name string) ([]*core.LogEvent, error) {
+	body, err := ab.download(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	events, _, err := decodeEvents(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return events, nil
+}
+
+// download opens the stored bytes of a blob
+func (ab *AzureBackend) download(ctx context.Context, name string) (io.ReadCloser, error) {
+	blobURL := ab.containerURL.NewBlockBlobURL(name)
+	resp, err := blobURL.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	return resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3}), nil
 }
 
-// VerifyIntegrity verifies the integrity of stored data
+// VerifyIntegrity verifies the integrity of stored data, reading every
+// blob back to check its MD5, event count and events
 func (ab *AzureBackend) VerifyIntegrity() (*IntegrityReport, error) {
 	report := &IntegrityReport{
-		Valid:        true,
-		TotalRecords: 0,
-		Errors:       make([]string, 0),
+		Timestamp: time.Now(),
+		Backend:   "azure",
+		Valid:     true,
+		Errors:    make([]string, 0),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	ctx := context.Background()
 
-	// List all blobs and verify their MD5 hashes
+	// List all blobs and verify their contents
 	for marker := (azblob.Marker{}); marker.NotDone(); {
 		listBlob, err := ab.containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
-			Prefix: ab.config.Prefix,
+			Prefix:  ab.config.Prefix,
+			Details: azblob.BlobListingDetails{Metadata: true},
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to list blobs: %w", err)
@@ -177,30 +249,20 @@ func (ab *AzureBackend) VerifyIntegrity() (*IntegrityReport, error) {
 		marker = listBlob.NextMarker
 
 		for _, blobItem := range listBlob.Segment.BlobItems {
-			report.TotalRecords++
-
-			// Get blob properties to check MD5
-			blobURL := ab.containerURL.NewBlockBlobURL(blobItem.Name)
-			props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
-			if err != nil {
-				report.Errors = append(report.Errors, fmt.Sprintf("Failed to get properties for %s: %v", blobItem.Name, err))
-				report.Valid = false
+			if !eventBlob(ab.config.Prefix, blobItem.Name) {
 				continue
 			}
 
 			// Verify MD5 if we have it recorded
-			if storedHash, exists := ab.uploadedBlobs[blobItem.Name]; exists {
-				blobMD5 := base64.StdEncoding.EncodeToString(props.ContentMD5())
-				if blobMD5 != storedHash {
-					report.Errors = append(report.Errors, fmt.Sprintf("MD5 mismatch for %s: expected %s, got %s",
-						blobItem.Name, storedHash, blobMD5))
-					report.Valid = false
-				}
+			blobMD5 := base64.StdEncoding.EncodeToString(blobItem.Properties.ContentMD5)
+			if storedHash, exists := ab.uploadedBlobs[blobItem.Name]; exists && blobMD5 != storedHash {
+				report.Errors = append(report.Errors, fmt.Sprintf("MD5 mismatch for %s: expected %s, got %s",
+					blobItem.Name, storedHash, blobMD5))
+				report.Valid = false
 			}
 
-			// Check if blob is in immutable state if configured
-			if ab.config.Immutable && props.BlobCommittedBlockCount() == 0 {
-				report.Errors = append(report.Errors, fmt.Sprintf("Blob %s is not in committed state", blobItem.Name))
+			if err := ab.verifyBlob(ctx, report, blobItem.Name, blobItem.Properties.ContentMD5, blobItem.Metadata); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("Failed to read %s: %v", blobItem.Name, err))
 				report.Valid = false
 			}
 		}
@@ -209,6 +271,37 @@ func (ab *AzureBackend) VerifyIntegrity() (*IntegrityReport, error) {
 	return report, nil
 }
 
+// verifyBlob reads a blob back and checks its contents into report
+func (ab *AzureBackend) verifyBlob(ctx context.Context, report *IntegrityReport, name string, wantMD5 []byte, metadata map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	// Check if blob is in immutable state if configured
+	if ab.config.Immutable {
+		props, err := ab.containerURL.NewBlockBlobURL(name).GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return err
+		}
+		if props.BlobCommittedBlockCount() == 0 {
+			report.Errors = append(report.Errors, fmt.Sprintf("Blob %s is not in committed state", name))
+			report.Valid = false
+		}
+	}
+
+	body, err := ab.download(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	contents, err := readBlobContents(body)
+	if err != nil {
+		return err
+	}
+	contents.check(report, name, wantMD5, metadata)
+	return nil
+}
+
 // Close closes the Azure backend
 func (ab *AzureBackend) Close() error {
 	// Stop flush worker
@@ -224,7 +317,53 @@ func (ab *AzureBackend) Close() error {
 
 // Name returns the backend name
 func (ab *AzureBackend) Name() string {
-	return "azure"
+	return fmt.Sprintf("azure[%s/%s]", ab.config.Container, ab.config.Prefix)
+}
+
+// metadataBlob returns the blob holding the named object, under
+// <prefix>/metadata.
+func (ab *AzureBackend) metadataBlob(name string) azblob.BlockBlobURL {
+	blobName := "metadata/" + name
+	if ab.config.Prefix != "" {
+		blobName = fmt.Sprintf("%s/%s", ab.config.Prefix, blobName)
+	}
+	return ab.containerURL.NewBlockBlobURL(blobName)
+}
+
+// PutMetadata replaces the named object.
+func (ab *AzureBackend) PutMetadata(name string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	_, err := azblob.UploadBufferToBlockBlob(ctx, data, ab.metadataBlob(name), azblob.UploadToBlockBlobOptions{
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: "application/json"},
+	})
+	if err != nil {
+		return &BackendError{Backend: "azure", Op: "put_metadata", Err: err}
+	}
+	return nil
+}
+
+// GetMetadata reads the named object.
+func (ab *AzureBackend) GetMetadata(name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	resp, err := ab.metadataBlob(name).Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if storageErr, ok := err.(azblob.StorageError); ok && storageErr.ServiceCode() == azblob.ServiceCodeBlobNotFound {
+		return nil, fmt.Errorf("%s: %w", name, ErrMetadataNotFound)
+	}
+	if err != nil {
+		return nil, &BackendError{Backend: "azure", Op: "get_metadata", Err: err}
+	}
+
+	body := resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3})
+	defer func() { _ = body.Close() }()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, &BackendError{Backend: "azure", Op: "get_metadata", Err: err}
+	}
+	return data, nil
 }
 
 // flushWorker periodically flushes the buffer
@@ -252,31 +391,39 @@ func (ab *AzureBackend) flushLocked() error {
 		return nil
 	}
 
-	// Generate blob name with prefix if configured
-	blobName := fmt.Sprintf("audit-%d.json.gz", time.Now().UnixNano())
-	if ab.config.Prefix != "" {
-		blobName = fmt.Sprintf("%s/%s", ab.config.Prefix, blobName)
+	// One blob per hourly partition the buffered events fall in
+	partitions := partitionEvents(ab.buffer)
+	for i, partition := range partitions {
+		if err := ab.uploadPartition(partition); err != nil {
+			// Keep only what wasn't uploaded for the next flush
+			var remaining []*core.LogEvent
+			for _, rest := range partitions[i:] {
+				remaining = append(remaining, rest.events...)
+			}
+			ab.buffer = append(ab.buffer[:0], remaining...)
+			return err
+		}
 	}
 
-	// Compress the data
-	var buf bytes.Buffer
-	gw := gzip.NewWriter(&buf)
-	encoder := json.NewEncoder(gw)
+	// Clear buffer
+	ab.buffer = ab.buffer[:0]
+	ab.lastFlush = time.Now()
 
-	for _, event := range ab.buffer {
-		if err := encoder.Encode(event); err != nil {
-			return fmt.Errorf("failed to encode event: %w", err)
-		}
-	}
+	return nil
+}
+
+// uploadPartition uploads the events of one partition as a new blob
+func (ab *AzureBackend) uploadPartition(partition eventPartition) error {
+	blobName := partitionBlobName(ab.config.Prefix, partition.hour)
 
-	if err := gw.Close(); err != nil {
-		return fmt.Errorf("failed to compress data: %w", err)
+	// Compress the data
+	data, err := encodeEvents(partition.events)
+	if err != nil {
+		return err
 	}
 
 	// Calculate MD5 hash for integrity verification
 	// #nosec G401 - MD5 used for integrity verification not cryptographic security
-	data := buf.Bytes()
-	// #nosec G401 - MD5 used for integrity verification not cryptographic security
 	md5Hash := md5.Sum(data)
 	md5String := base64.StdEncoding.EncodeToString(md5Hash[:])
 
@@ -296,14 +443,23 @@ func (ab *AzureBackend) flushLocked() error {
 		Metadata: azblob.Metadata{
 			"audit":     "true",
 			"timestamp": fmt.Sprintf("%d", time.Now().Unix()),
-			"events":    fmt.Sprintf("%d", len(ab.buffer)),
+			"events":    fmt.Sprintf("%d", len(partition.events)),
 		},
 	}
 
+	// Note: Immutability policies require specific Azure configuration
+	// and are typically set at the container level, not per blob. The
+	// retention requirement is recorded with the blob's other metadata,
+	// which setting it afterwards would replace.
+	if ab.config.Immutable && ab.config.RetentionDays > 0 {
+		options.Metadata["retention-days"] = fmt.Sprintf("%d", ab.config.RetentionDays)
+		options.Metadata["immutable"] = "true"
+	}
+
 	// Note: Access tier is set separately after upload if needed
 
 	// Upload the blob
-	_, err := azblob.UploadBufferToBlockBlob(ctx, data, blobURL, options)
+	_, err = azblob.UploadBufferToBlockBlob(ctx, data, blobURL, options)
 	if err != nil {
 		return fmt.Errorf("failed to upload blob: %w", err)
 	}
@@ -332,24 +488,5 @@ func (ab *AzureBackend) flushLocked() error {
 		}
 	}
 
-	// Note: Immutability policies require specific Azure configuration
-	// and are typically set at the container level, not per blob
-	if ab.config.Immutable && ab.config.RetentionDays > 0 {
-		// Set metadata to indicate retention requirement
-		metadata := azblob.Metadata{
-			"retention-days": fmt.Sprintf("%d", ab.config.RetentionDays),
-			"immutable":      "true",
-		}
-		_, err = blobURL.SetMetadata(ctx, metadata, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
-		if err != nil {
-			// Log but don't fail
-			fmt.Printf("Warning: Failed to set retention metadata: %v\n", err)
-		}
-	}
-
-	// Clear buffer
-	ab.buffer = ab.buffer[:0]
-	ab.lastFlush = time.Now()
-
 	return nil
 }
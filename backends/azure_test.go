@@ -0,0 +1,134 @@
+package backends
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// azuriteKey is the well-known account key of the Azurite emulator.
+const azuriteKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+// newFakeAzure serves the parts of the Blob service REST API the backend
+// uses from store, and returns a connection string for it.
+func newFakeAzure(t *testing.T, store *fakeBlobStore) string {
+	t.Helper()
+	const base = "/devstoreaccount1/audit"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path == base {
+			switch {
+			case query.Get("comp") == "list":
+				writeAzureList(w, store, query.Get("prefix"), query.Get("include") == "metadata")
+			case r.Method == http.MethodPut:
+				w.WriteHeader(http.StatusCreated)
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, base+"/")
+		switch r.Method {
+		case http.MethodPut:
+			data, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			metadata := make(map[string]string)
+			for key, values := range r.Header {
+				if strings.HasPrefix(strings.ToLower(key), "x-ms-meta-") {
+					metadata[strings.ToLower(strings.TrimPrefix(strings.ToLower(key), "x-ms-meta-"))] = values[0]
+				}
+			}
+			store.put(name, data, metadata)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet, http.MethodHead:
+			blob, ok := store.get(name, r.Method == http.MethodGet)
+			if !ok {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", fmt.Sprint(len(blob.data)))
+			w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(blob.md5))
+			w.Header().Set("Last-Modified", blob.modified.UTC().Format(http.TimeFormat))
+			w.Header().Set("ETag", `"etag"`)
+			w.Header().Set("x-ms-blob-type", "BlockBlob")
+			w.WriteHeader(http.StatusOK)
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(blob.data)
+			}
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return "DefaultEndpointsProtocol=http;AccountName=devstoreaccount1;AccountKey=" + azuriteKey +
+		";BlobEndpoint=" + server.URL + "/devstoreaccount1;"
+}
+
+// writeAzureList writes a List Blobs response for the blobs under prefix.
+func writeAzureList(w http.ResponseWriter, store *fakeBlobStore, prefix string, metadata bool) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="audit"><Blobs>`)
+	for _, name := range store.list(prefix) {
+		blob, _ := store.get(name, false)
+		b.WriteString("<Blob><Name>")
+		_ = xml.EscapeText(&b, []byte(name))
+		fmt.Fprintf(&b, "</Name><Properties><Last-Modified>%s</Last-Modified><Etag>etag</Etag>"+
+			"<Content-Length>%d</Content-Length><Content-MD5>%s</Content-MD5><BlobType>BlockBlob</BlobType></Properties>",
+			blob.modified.UTC().Format(http.TimeFormat), len(blob.data), base64.StdEncoding.EncodeToString(blob.md5))
+		if metadata {
+			b.WriteString("<Metadata>")
+			for key, value := range blob.metadata {
+				fmt.Fprintf(&b, "<%s>%s</%s>", key, value, key)
+			}
+			b.WriteString("</Metadata>")
+		}
+		b.WriteString("</Blob>")
+	}
+	b.WriteString(`</Blobs><NextMarker/></EnumerationResults>`)
+
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = io.WriteString(w, b.String())
+}
+
+func TestAzureBackendRead(t *testing.T) {
+	store := newFakeBlobStore()
+	backend, err := NewAzureBackend(AzureConfig{
+		Container:        "audit",
+		ConnectionString: newFakeAzure(t, store),
+		Prefix:           "logs",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create Azure backend: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	testPartitionedBackend(t, backend, store)
+
+	data, err := backend.GetMetadata("audit.wal.hwm")
+	if err != nil || string(data) != `{"sequence": 9}` {
+		t.Errorf("Expected the metadata back, got %q: %v", data, err)
+	}
+}
+
+func TestParseConnectionString(t *testing.T) {
+	_, _, endpoint, err := parseConnectionString("AccountName=acct;AccountKey=" + azuriteKey)
+	if err != nil || endpoint != "https://acct.blob.core.windows.net" {
+		t.Errorf("Expected the public endpoint, got %q: %v", endpoint, err)
+	}
+	_, _, endpoint, err = parseConnectionString("AccountName=acct;AccountKey=" + azuriteKey + ";BlobEndpoint=http://127.0.0.1:10000/acct/")
+	if err != nil || endpoint != "http://127.0.0.1:10000/acct" {
+		t.Errorf("Expected the configured endpoint, got %q: %v", endpoint, err)
+	}
+}
@@ -1,6 +1,7 @@
 package backends
 
 import (
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -197,6 +198,39 @@ func TestFilesystemBackend(t *testing.T) {
 	}
 }
 
+func TestFilesystemMetadata(t *testing.T) {
+	backend, err := NewFilesystemBackend(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create filesystem backend: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	if _, err := backend.GetMetadata("test.hwm"); !errors.Is(err, ErrMetadataNotFound) {
+		t.Errorf("Expected ErrMetadataNotFound, got %v", err)
+	}
+	for _, data := range []string{"first", "second"} {
+		if err := backend.PutMetadata("test.hwm", []byte(data)); err != nil {
+			t.Fatalf("PutMetadata failed: %v", err)
+		}
+		got, err := backend.GetMetadata("test.hwm")
+		if err != nil {
+			t.Fatalf("GetMetadata failed: %v", err)
+		}
+		if string(got) != data {
+			t.Errorf("Expected %q, got %q", data, got)
+		}
+	}
+
+	// Metadata is not mistaken for stored events
+	report, err := backend.VerifyIntegrity()
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if !report.Valid {
+		t.Errorf("Expected a valid report with metadata present, got %+v", report)
+	}
+}
+
 func TestBackendTypes(t *testing.T) {
 	tests := []struct {
 		config Config
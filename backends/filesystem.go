@@ -182,13 +182,32 @@ func (fb *FilesystemBackend) WriteBatch(events []*core.LogEvent) error {
 
 // Read reads events within a time range
 func (fb *FilesystemBackend) Read(start, end time.Time) ([]*core.LogEvent, error) {
+	return fb.readDir(fb.config.Path, start, end)
+}
+
+// ReadShadow reads events within a time range from the shadow copy rather
+// than the primary files, so a damaged primary can be bypassed.
+func (fb *FilesystemBackend) ReadShadow(start, end time.Time) ([]*core.LogEvent, error) {
+	if !fb.config.Shadow {
+		return nil, &BackendError{Backend: "filesystem", Op: "read_shadow", Err: fmt.Errorf("shadow copy not enabled")}
+	}
+	return fb.readDir(fb.shadowPath, start, end)
+}
+
+// HasShadow reports whether the backend keeps a shadow copy.
+func (fb *FilesystemBackend) HasShadow() bool {
+	return fb.config.Shadow
+}
+
+// readDir reads events within a time range from the files in dir
+func (fb *FilesystemBackend) readDir(dir string, start, end time.Time) ([]*core.LogEvent, error) {
 	fb.mu.RLock()
 	defer fb.mu.RUnlock()
 
 	var events []*core.LogEvent
 
 	// Find relevant files
-	files, err := fb.findFiles(start, end)
+	files, err := fb.findFiles(dir, start, end)
 	if err != nil {
 		return nil, &BackendError{Backend: "filesystem", Op: "find_files", Err: err}
 	}
@@ -428,9 +447,9 @@ func (fb *FilesystemBackend) compressFile(path string) error {
 	return os.Remove(path)
 }
 
-// findFiles finds files within a time range
-func (fb *FilesystemBackend) findFiles(_, end time.Time) ([]string, error) {
-	pattern := filepath.Join(fb.config.Path, "audit-*.json*")
+// findFiles finds files in dir within a time range
+func (fb *FilesystemBackend) findFiles(dir string, _, end time.Time) ([]string, error) {
+	pattern := filepath.Join(dir, "audit-*.json*")
 	files, err := filepath.Glob(pattern)
 	if err != nil {
 		return nil, err
@@ -1,13 +1,11 @@
 package backends
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	//nolint:gosec // MD5 used for checksums not security
 	"crypto/md5"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
@@ -15,6 +13,7 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/willibrandon/mtlog/core"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
@@ -124,24 +123,84 @@ func (gb *GCSBackend) WriteBatch(events []*core.LogEvent) error {
 	return nil
 }
 
-// Read reads events from GCS (not implemented for audit logs)
-func (gb *GCSBackend) Read(_, _ time.Time) ([]*core.LogEvent, error) {
-	// Audit logs are write-only for compliance
-	return nil, fmt.Errorf("reading from audit backend is not supported")
+// Read reads events within a time range, listing only the hourly
+// partitions the range covers
+func (gb *GCSBackend) Read(start, end time.Time) ([]*core.LogEvent, error) {
+	// Buffered events are read too
+	gb.mu.Lock()
+	err := gb.flushLocked()
+	gb.mu.Unlock()
+	if err != nil {
+		return nil, &BackendError{Backend: "gcs", Op: "read", Err: err}
+	}
+
+	ctx := context.Background()
+	query := &storage.Query{
+		Prefix: partitionListPrefix(gb.config.Prefix, start, end),
+	}
+
+	var events []*core.LogEvent
+	it := gb.bucket.Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, &BackendError{Backend: "gcs", Op: "list", Err: err}
+		}
+		if !eventBlob(gb.config.Prefix, attrs.Name) || !partitionInRange(gb.config.Prefix, attrs.Name, start, end) {
+			continue
+		}
+
+		objEvents, err := gb.downloadEvents(ctx, attrs.Name)
+		if err != nil {
+			return nil, &BackendError{Backend: "gcs", Op: "read", Err: err}
+		}
+		events = append(events, eventsInRange(objEvents, start, end)...)
+	}
+
+	return events, nil
+}
+
+// downloadEvents streams the events of an object
+func (gb *GCSBackend) downloadEvents(ctx context.Context, name string) ([]*core.LogEvent, error) {
+	reader, err := gb.download(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	events, _, err := decodeEvents(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return events, nil
 }
 
-// VerifyIntegrity verifies the integrity of stored data
+// download opens the stored bytes of an object, as uploaded rather than
+// decompressed by GCS
+func (gb *GCSBackend) download(ctx context.Context, name string) (*storage.Reader, error) {
+	reader, err := gb.bucket.Object(name).ReadCompressed(true).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	return reader, nil
+}
+
+// VerifyIntegrity verifies the integrity of stored data, reading every
+// object back to check its checksums, event count and events
 func (gb *GCSBackend) VerifyIntegrity() (*IntegrityReport, error) {
 	report := &IntegrityReport{
-		Valid:        true,
-		TotalRecords: 0,
-		Errors:       make([]string, 0),
+		Timestamp: time.Now(),
+		Backend:   "gcs",
+		Valid:     true,
+		Errors:    make([]string, 0),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	ctx := context.Background()
 
-	// List all objects and verify their MD5 hashes
+	// List all objects and verify their contents
 	query := &storage.Query{
 		Prefix: gb.config.Prefix,
 	}
@@ -149,14 +208,15 @@ func (gb *GCSBackend) VerifyIntegrity() (*IntegrityReport, error) {
 	it := gb.bucket.Objects(ctx, query)
 	for {
 		attrs, err := it.Next()
-		if err == io.EOF {
+		if err == iterator.Done {
 			break
 		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to list objects: %w", err)
 		}
-
-		report.TotalRecords++
+		if !eventBlob(gb.config.Prefix, attrs.Name) {
+			continue
+		}
 
 		// Verify MD5 if we have it recorded
 		if storedHash, exists := gb.uploadedObjs[attrs.Name]; exists {
@@ -168,23 +228,9 @@ func (gb *GCSBackend) VerifyIntegrity() (*IntegrityReport, error) {
 			}
 		}
 
-		// Check CRC32C checksum
-		if attrs.CRC32C != 0 {
-			// Verify CRC32C is present (GCS always calculates it)
-			obj := gb.bucket.Object(attrs.Name)
-			reader, err := obj.NewReader(ctx)
-			if err != nil {
-				report.Errors = append(report.Errors, fmt.Sprintf("Failed to read object %s: %v", attrs.Name, err))
-				report.Valid = false
-				continue
-			}
-
-			// Verify checksums match
-			if reader.Attrs.CRC32C != attrs.CRC32C {
-				report.Errors = append(report.Errors, fmt.Sprintf("CRC32C mismatch for %s", attrs.Name))
-				report.Valid = false
-			}
-			_ = reader.Close()
+		if err := gb.verifyObject(ctx, report, attrs); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("Failed to read object %s: %v", attrs.Name, err))
+			report.Valid = false
 		}
 
 		// Check retention policy if configured
@@ -197,6 +243,31 @@ func (gb *GCSBackend) VerifyIntegrity() (*IntegrityReport, error) {
 	return report, nil
 }
 
+// verifyObject reads an object back and checks its contents into report
+func (gb *GCSBackend) verifyObject(ctx context.Context, report *IntegrityReport, attrs *storage.ObjectAttrs) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	reader, err := gb.download(ctx, attrs.Name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	contents, err := readBlobContents(reader)
+	if err != nil {
+		return err
+	}
+	contents.check(report, attrs.Name, attrs.MD5, attrs.Metadata)
+
+	// GCS always calculates CRC32C
+	if attrs.CRC32C != 0 && contents.crc32c != attrs.CRC32C {
+		report.Errors = append(report.Errors, fmt.Sprintf("CRC32C mismatch for %s", attrs.Name))
+		report.Valid = false
+	}
+	return nil
+}
+
 // Close closes the GCS backend
 func (gb *GCSBackend) Close() error {
 	// Stop flush worker
@@ -221,7 +292,55 @@ func (gb *GCSBackend) Close() error {
 
 // Name returns the backend name
 func (gb *GCSBackend) Name() string {
-	return "gcs"
+	return fmt.Sprintf("gcs[%s/%s]", gb.config.Bucket, gb.config.Prefix)
+}
+
+// metadataObject returns the object holding the named metadata, under
+// <prefix>/metadata.
+func (gb *GCSBackend) metadataObject(name string) *storage.ObjectHandle {
+	objectName := "metadata/" + name
+	if gb.config.Prefix != "" {
+		objectName = fmt.Sprintf("%s/%s", gb.config.Prefix, objectName)
+	}
+	return gb.bucket.Object(objectName)
+}
+
+// PutMetadata replaces the named object.
+func (gb *GCSBackend) PutMetadata(name string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	writer := gb.metadataObject(name).NewWriter(ctx)
+	writer.ContentType = "application/json"
+	if _, err := writer.Write(data); err != nil {
+		_ = writer.Close()
+		return &BackendError{Backend: "gcs", Op: "put_metadata", Err: err}
+	}
+	if err := writer.Close(); err != nil {
+		return &BackendError{Backend: "gcs", Op: "put_metadata", Err: err}
+	}
+	return nil
+}
+
+// GetMetadata reads the named object.
+func (gb *GCSBackend) GetMetadata(name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	reader, err := gb.metadataObject(name).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%s: %w", name, ErrMetadataNotFound)
+	}
+	if err != nil {
+		return nil, &BackendError{Backend: "gcs", Op: "get_metadata", Err: err}
+	}
+	defer func() { _ = reader.Close() }()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, &BackendError{Backend: "gcs", Op: "get_metadata", Err: err}
+	}
+	return data, nil
 }
 
 // flushWorker periodically flushes the buffer
@@ -249,29 +368,38 @@ func (gb *GCSBackend) flushLocked() error {
 		return nil
 	}
 
-	// Generate object name with prefix if configured
-	objectName := fmt.Sprintf("audit-%d.json.gz", time.Now().UnixNano())
-	if gb.config.Prefix != "" {
-		objectName = fmt.Sprintf("%s/%s", gb.config.Prefix, objectName)
+	// One object per hourly partition the buffered events fall in
+	partitions := partitionEvents(gb.buffer)
+	for i, partition := range partitions {
+		if err := gb.uploadPartition(partition); err != nil {
+			// Keep only what wasn't uploaded for the next flush
+			var remaining []*core.LogEvent
+			for _, rest := range partitions[i:] {
+				remaining = append(remaining, rest.events...)
+			}
+			gb.buffer = append(gb.buffer[:0], remaining...)
+			return err
+		}
 	}
 
-	// Compress the data
-	var buf bytes.Buffer
-	gw := gzip.NewWriter(&buf)
-	encoder := json.NewEncoder(gw)
+	// Clear buffer
+	gb.buffer = gb.buffer[:0]
+	gb.lastFlush = time.Now()
 
-	for _, event := range gb.buffer {
-		if err := encoder.Encode(event); err != nil {
-			return fmt.Errorf("failed to encode event: %w", err)
-		}
-	}
+	return nil
+}
+
+// uploadPartition uploads the events of one partition as a new object
+func (gb *GCSBackend) uploadPartition(partition eventPartition) error {
+	objectName := partitionBlobName(gb.config.Prefix, partition.hour)
 
-	if err := gw.Close(); err != nil {
-		return fmt.Errorf("failed to compress data: %w", err)
+	// Compress the data
+	data, err := encodeEvents(partition.events)
+	if err != nil {
+		return err
 	}
 
 	// Calculate MD5 hash for integrity verification
-	data := buf.Bytes()
 	// #nosec G401 - MD5 used for integrity verification not cryptographic security
 	md5Hash := md5.Sum(data)
 	md5String := base64.StdEncoding.EncodeToString(md5Hash[:])
@@ -290,7 +418,7 @@ func (gb *GCSBackend) flushLocked() error {
 	writer.Metadata = map[string]string{
 		"audit":     "true",
 		"timestamp": fmt.Sprintf("%d", time.Now().Unix()),
-		"events":    fmt.Sprintf("%d", len(gb.buffer)),
+		"events":    fmt.Sprintf("%d", len(partition.events)),
 	}
 
 	// Set storage class if configured
@@ -337,9 +465,5 @@ func (gb *GCSBackend) flushLocked() error {
 		}
 	}
 
-	// Clear buffer
-	gb.buffer = gb.buffer[:0]
-	gb.lastFlush = time.Now()
-
 	return nil
 }
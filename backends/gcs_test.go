@@ -0,0 +1,124 @@
+package backends
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeGCS serves the parts of the Cloud Storage JSON and XML APIs the
+// backend uses from store, and points the client at it as an emulator.
+func newFakeGCS(t *testing.T, store *fakeBlobStore) {
+	t.Helper()
+	const bucket = "audit"
+
+	object := func(name string, blob *fakeBlob) map[string]any {
+		crc := make([]byte, 4)
+		binary.BigEndian.PutUint32(crc, crc32.Checksum(blob.data, crc32.MakeTable(crc32.Castagnoli)))
+		return map[string]any{
+			"kind":       "storage#object",
+			"bucket":     bucket,
+			"name":       name,
+			"size":       strconv.Itoa(len(blob.data)),
+			"md5Hash":    base64.StdEncoding.EncodeToString(blob.md5),
+			"crc32c":     base64.StdEncoding.EncodeToString(crc),
+			"metadata":   blob.metadata,
+			"generation": "1",
+			"updated":    blob.modified.UTC().Format(time.RFC3339Nano),
+		}
+	}
+	writeJSON := func(w http.ResponseWriter, value any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(value)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/storage/v1/b/"+bucket:
+			writeJSON(w, map[string]any{"kind": "storage#bucket", "name": bucket})
+
+		case r.URL.Path == "/storage/v1/b/"+bucket+"/o":
+			items := []map[string]any{}
+			for _, name := range store.list(r.URL.Query().Get("prefix")) {
+				blob, _ := store.get(name, false)
+				items = append(items, object(name, blob))
+			}
+			writeJSON(w, map[string]any{"kind": "storage#objects", "items": items})
+
+		case r.URL.Path == "/upload/storage/v1/b/"+bucket+"/o" && r.Method == http.MethodPost:
+			_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			parts := multipart.NewReader(r.Body, params["boundary"])
+			var attrs struct {
+				Metadata map[string]string `json:"metadata"`
+				Name     string            `json:"name"`
+			}
+			part, err := parts.NextPart()
+			if err == nil {
+				err = json.NewDecoder(part).Decode(&attrs)
+			}
+			if err == nil {
+				part, err = parts.NextPart()
+			}
+			var data []byte
+			if err == nil {
+				data, err = io.ReadAll(part)
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, object(attrs.Name, store.put(attrs.Name, data, attrs.Metadata)))
+
+		case strings.HasPrefix(r.URL.Path, "/"+bucket+"/") && r.Method == http.MethodGet:
+			blob, ok := store.get(strings.TrimPrefix(r.URL.Path, "/"+bucket+"/"), true)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(blob.data)))
+			w.Header().Set("X-Goog-Generation", "1")
+			_, _ = w.Write(blob.data)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("STORAGE_EMULATOR_HOST", server.URL)
+}
+
+func TestGCSBackendRead(t *testing.T) {
+	store := newFakeBlobStore()
+	newFakeGCS(t, store)
+
+	backend, err := NewGCSBackend(GCSConfig{
+		Bucket:    "audit",
+		ProjectID: "test-project",
+		Prefix:    "logs",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create GCS backend: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	testPartitionedBackend(t, backend, store)
+
+	data, err := backend.GetMetadata("audit.wal.hwm")
+	if err != nil || string(data) != `{"sequence": 9}` {
+		t.Errorf("Expected the metadata back, got %q: %v", data, err)
+	}
+}
@@ -0,0 +1,74 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrMetadataNotFound is returned by GetMetadata when no object has the name.
+var ErrMetadataNotFound = errors.New("metadata not found")
+
+// MetadataStore is implemented by backends that can keep small named objects
+// beside the events they store, such as the sink's signed high-water mark.
+// PutMetadata replaces any object with the same name.
+type MetadataStore interface {
+	PutMetadata(name string, data []byte) error
+	GetMetadata(name string) ([]byte, error)
+}
+
+var (
+	_ MetadataStore = (*FilesystemBackend)(nil)
+	_ MetadataStore = (*S3Backend)(nil)
+	_ MetadataStore = (*AzureBackend)(nil)
+	_ MetadataStore = (*GCSBackend)(nil)
+)
+
+// metadataPath returns where the filesystem backend keeps the named object,
+// outside the event files it verifies.
+func (fb *FilesystemBackend) metadataPath(name string) string {
+	return filepath.Join(fb.config.Path, "metadata", filepath.Base(name))
+}
+
+// PutMetadata atomically replaces the named object.
+func (fb *FilesystemBackend) PutMetadata(name string, data []byte) error {
+	path := fb.metadataPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return &BackendError{Backend: "filesystem", Op: "put_metadata", Err: err}
+	}
+
+	tmpPath := path + ".tmp"
+	// #nosec G304 - metadata path derived from backend configuration
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return &BackendError{Backend: "filesystem", Op: "put_metadata", Err: err}
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return &BackendError{Backend: "filesystem", Op: "put_metadata", Err: err}
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return &BackendError{Backend: "filesystem", Op: "put_metadata", Err: err}
+	}
+	if err := tmp.Close(); err != nil {
+		return &BackendError{Backend: "filesystem", Op: "put_metadata", Err: err}
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return &BackendError{Backend: "filesystem", Op: "put_metadata", Err: err}
+	}
+	return nil
+}
+
+// GetMetadata reads the named object.
+func (fb *FilesystemBackend) GetMetadata(name string) ([]byte, error) {
+	data, err := os.ReadFile(fb.metadataPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", name, ErrMetadataNotFound)
+	}
+	if err != nil {
+		return nil, &BackendError{Backend: "filesystem", Op: "get_metadata", Err: err}
+	}
+	return data, nil
+}
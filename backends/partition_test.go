@@ -0,0 +1,206 @@
+package backends
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5" // #nosec G501 - matches the checksums the stores keep
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog/core"
+)
+
+func TestPartitionListPrefix(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		start, end time.Time
+		prefix     string
+		want       string
+	}{
+		{at("2026-10-16T10:30:00Z"), at("2026-10-16T10:50:00Z"), "logs", "logs/2026/10/16/10/"},
+		{at("2026-10-16T10:30:00Z"), at("2026-10-16T13:10:00Z"), "logs/", "logs/2026/10/16/"},
+		{at("2026-09-30T23:00:00Z"), at("2026-10-01T01:00:00Z"), "", "2026/"},
+		{time.Time{}, at("2026-10-16T10:30:00Z"), "logs", "logs/"},
+		{time.Time{}, at("2026-10-16T10:30:00Z"), "", ""},
+	}
+	for _, tt := range tests {
+		if got := partitionListPrefix(tt.prefix, tt.start, tt.end); got != tt.want {
+			t.Errorf("partitionListPrefix(%q, %v, %v) = %q, want %q", tt.prefix, tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	name := "logs/2026/10/16/11/audit-1.json.gz"
+	if !partitionInRange("logs", name, at("2026-10-16T11:59:00Z"), at("2026-10-16T13:00:00Z")) {
+		t.Errorf("Expected %s in a range starting within its hour", name)
+	}
+	if partitionInRange("logs", name, at("2026-10-16T12:00:00Z"), at("2026-10-16T13:00:00Z")) {
+		t.Errorf("Expected %s outside a range starting after its hour", name)
+	}
+	if !partitionInRange("logs", "logs/audit-1.json.gz", at("2026-10-16T12:00:00Z"), at("2026-10-16T13:00:00Z")) {
+		t.Error("Expected unpartitioned blobs to always be read")
+	}
+}
+
+// fakeBlob is a blob held by a fake object store.
+type fakeBlob struct {
+	modified time.Time
+	metadata map[string]string
+	data     []byte
+	md5      []byte
+}
+
+// fakeBlobStore holds the blobs of an in-process fake of a cloud store.
+type fakeBlobStore struct {
+	blobs     map[string]*fakeBlob
+	downloads int
+	mu        sync.Mutex
+}
+
+func newFakeBlobStore() *fakeBlobStore {
+	return &fakeBlobStore{blobs: make(map[string]*fakeBlob)}
+}
+
+func (s *fakeBlobStore) put(name string, data []byte, metadata map[string]string) *fakeBlob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum := md5.Sum(data) // #nosec G401 - as the store computes it
+	blob := &fakeBlob{data: data, md5: sum[:], metadata: metadata, modified: time.Now()}
+	s.blobs[name] = blob
+	return blob
+}
+
+func (s *fakeBlobStore) get(name string, download bool) (*fakeBlob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blob, ok := s.blobs[name]
+	if ok && download {
+		s.downloads++
+	}
+	return blob, ok
+}
+
+func (s *fakeBlobStore) list(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.blobs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *fakeBlobStore) downloaded() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.downloads
+	s.downloads = 0
+	return n
+}
+
+// testPartitionedBackend checks a backend writing to store names blobs by
+// the hour of their events, reads a time range from only the partitions it
+// covers, and verifies the contents of its blobs.
+func testPartitionedBackend(t *testing.T, backend Backend, store *fakeBlobStore) {
+	t.Helper()
+	base := time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC)
+	events := make([]*core.LogEvent, 9)
+	for i := range events {
+		events[i] = &core.LogEvent{
+			Timestamp:       base.Add(time.Duration(i) * 20 * time.Minute),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Transfer {id} settled",
+			Properties:      map[string]any{"id": float64(i)},
+		}
+	}
+	if err := backend.WriteBatch(events); err != nil {
+		t.Fatalf("WriteBatch failed: %v", err)
+	}
+	if err := backend.(MetadataStore).PutMetadata("audit.wal.hwm", []byte(`{"sequence": 9}`)); err != nil {
+		t.Fatalf("PutMetadata failed: %v", err)
+	}
+
+	// Read flushes what is buffered, one blob per hour
+	got, err := backend.Read(base.Add(50*time.Minute), base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(got) != 3 || got[0].Properties["id"] != float64(3) || got[2].Properties["id"] != float64(5) {
+		t.Errorf("Expected events 3-5, got %d events", len(got))
+	}
+	if n := store.downloaded(); n != 2 {
+		t.Errorf("Expected only the 2 partitions in range to be downloaded, got %d", n)
+	}
+
+	var partitions []string
+	for _, name := range store.list("logs/") {
+		if !strings.HasPrefix(name, "logs/metadata/") {
+			partitions = append(partitions, name[:len("logs/2026/10/16/10")])
+		}
+	}
+	want := []string{"logs/2026/10/16/10", "logs/2026/10/16/11", "logs/2026/10/16/12", "logs/2026/10/16/13"}
+	if strings.Join(partitions, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected blobs in partitions %v, got %v", want, partitions)
+	}
+
+	got, err = backend.Read(time.Time{}, base.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(got) != len(events) {
+		t.Fatalf("Expected all %d events, got %d", len(events), len(got))
+	}
+	for i, event := range got {
+		if !event.Timestamp.Equal(events[i].Timestamp) {
+			t.Errorf("Event %d out of order: %v", i, event.Timestamp)
+		}
+	}
+
+	report, err := backend.VerifyIntegrity()
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if !report.Valid || report.TotalRecords != 9 || report.VerifiedRecords != 9 {
+		t.Fatalf("Expected 9 verified events, got %+v", report)
+	}
+
+	// A blob whose bytes changed no longer matches its MD5
+	names := store.list("logs/2026/10/16/11/")
+	blob, _ := store.get(names[0], false)
+	blob.data = append([]byte{}, blob.data...)
+	blob.data[len(blob.data)/2] ^= 0xFF
+	report, err = backend.VerifyIntegrity()
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if report.Valid {
+		t.Error("Expected a changed blob to fail verification")
+	}
+	delete(store.blobs, names[0])
+
+	// A blob holding an event that doesn't parse
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, _ = gw.Write([]byte(`{"Timestamp": "2026-10-16T12:40:00Z"}` + "\n" + `{not json` + "\n"))
+	_ = gw.Close()
+	store.put("logs/2026/10/16/12/audit-1.json.gz", buf.Bytes(), map[string]string{"events": "2"})
+	report, err = backend.VerifyIntegrity()
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if report.Valid || report.CorruptedRecords != 1 || report.VerifiedRecords != 7 {
+		t.Errorf("Expected one corrupted event, got %+v", report)
+	}
+}
@@ -310,6 +310,28 @@ func (s *S3Backend) writeBatch(events []*core.LogEvent) error {
 		ContentType:  aws.String("application/json"),
 	}
 
+	s.protect(input)
+
+	// Add metadata
+	input.Metadata = map[string]string{
+		"EventCount": fmt.Sprintf("%d", len(events)),
+		"FirstEvent": events[0].Timestamp.Format(time.RFC3339),
+		"LastEvent":  events[len(events)-1].Timestamp.Format(time.RFC3339),
+		"Compressed": fmt.Sprintf("%v", s.compress),
+	}
+
+	// Upload with retry
+	_, err := s.uploadWithRetry(input, 3)
+	if err != nil {
+		return &BackendError{Backend: "s3", Op: "upload", Err: err}
+	}
+
+	return nil
+}
+
+// protect applies the backend's encryption and Object Lock retention to an
+// upload.
+func (s *S3Backend) protect(input *s3.PutObjectInput) {
 	// Add encryption
 	if s.encryption != "" {
 		input.ServerSideEncryption = types.ServerSideEncryption(s.encryption)
@@ -325,22 +347,45 @@ func (s *S3Backend) writeBatch(events []*core.LogEvent) error {
 		input.ObjectLockRetainUntilDate = aws.Time(retainUntil)
 		input.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOff
 	}
+}
 
-	// Add metadata
-	input.Metadata = map[string]string{
-		"EventCount": fmt.Sprintf("%d", len(events)),
-		"FirstEvent": events[0].Timestamp.Format(time.RFC3339),
-		"LastEvent":  events[len(events)-1].Timestamp.Format(time.RFC3339),
-		"Compressed": fmt.Sprintf("%v", s.compress),
+// PutMetadata replaces the named object under <prefix>/metadata. With
+// versioning and Object Lock every earlier version is retained.
+func (s *S3Backend) PutMetadata(name string, data []byte) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(path.Join(s.prefix, "metadata", name)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
 	}
+	s.protect(input)
 
-	// Upload with retry
-	_, err := s.uploadWithRetry(input, 3)
+	if _, err := s.uploadWithRetry(input, 3); err != nil {
+		return &BackendError{Backend: "s3", Op: "put_metadata", Err: err}
+	}
+	return nil
+}
+
+// GetMetadata reads the named object from <prefix>/metadata.
+func (s *S3Backend) GetMetadata(name string) ([]byte, error) {
+	output, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path.Join(s.prefix, "metadata", name)),
+	})
+	var noKey *types.NoSuchKey
+	if errors.As(err, &noKey) {
+		return nil, fmt.Errorf("%s: %w", name, ErrMetadataNotFound)
+	}
 	if err != nil {
-		return &BackendError{Backend: "s3", Op: "upload", Err: err}
+		return nil, &BackendError{Backend: "s3", Op: "get_metadata", Err: err}
 	}
+	defer func() { _ = output.Body.Close() }()
 
-	return nil
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, &BackendError{Backend: "s3", Op: "get_metadata", Err: err}
+	}
+	return data, nil
 }
 
 // uploadWithRetry uploads with exponential backoff retry
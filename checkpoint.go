@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/wal"
+)
+
+// errWitnessQuorum marks a checkpoint that was stored but not cosigned by
+// enough witnesses.
+var errWitnessQuorum = errors.New("checkpoint not witnessed")
+
+// checkpointer periodically signs the WAL's Merkle tree root into the
+// checkpoint log.
+type checkpointer struct {
+	wal       *wal.WAL
+	signer    compliance.Signer
+	witnesses *witnessPublisher
+	stop      chan struct{}
+	done      chan struct{}
+	path      string
+	interval  time.Duration
+	lastSize  uint64
+	mu        sync.Mutex
+}
+
+// newCheckpointer opens the checkpoint log at path and resumes from its
+// last checkpoint.
+func newCheckpointer(w *wal.WAL, signer compliance.Signer, path string, interval time.Duration) (*checkpointer, error) {
+	c := &checkpointer{
+		wal:      w,
+		signer:   signer,
+		path:     path,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	checkpoints, err := compliance.ReadCheckpoints(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(checkpoints) > 0 {
+		c.lastSize = checkpoints[len(checkpoints)-1].TreeSize
+	}
+	return c, nil
+}
+
+// run writes a checkpoint every interval while the log has grown.
+func (c *checkpointer) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if _, err := c.checkpoint(false); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: checkpoint failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// checkpoint signs and appends the current tree root. Unless force is set it
+// does nothing when the tree has not grown since the last checkpoint.
+func (c *checkpointer) checkpoint(force bool) (*compliance.Checkpoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	root, size, err := c.wal.SyncedMerkleRoot()
+	if err != nil {
+		return nil, err
+	}
+	if !force && size == c.lastSize {
+		return nil, nil
+	}
+
+	cp, err := compliance.SignCheckpoint(c.signer, size, root, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := compliance.AppendCheckpoint(c.path, cp); err != nil {
+		return nil, err
+	}
+	c.lastSize = size
+
+	if c.witnesses != nil {
+		if err := c.witnesses.publish(cp); err != nil {
+			return cp, fmt.Errorf("%w: %w", errWitnessQuorum, err)
+		}
+	}
+	return cp, nil
+}
+
+// close stops the ticker and writes a final checkpoint if the log grew. A
+// witness shortfall only warns.
+func (c *checkpointer) close() error {
+	close(c.stop)
+	<-c.done
+	_, err := c.checkpoint(false)
+	if errors.Is(err, errWitnessQuorum) {
+		fmt.Fprintf(os.Stderr, "Warning: final checkpoint: %v\n", err)
+		return nil
+	}
+	return err
+}
+
+// Checkpoint signs the WAL's current Merkle tree size and root hash and
+// appends the checkpoint to the checkpoint log. It requires WithCheckpoints.
+// With WithWitnesses it also collects cosignatures; if fewer than the quorum
+// cosign, the stored checkpoint is returned along with the error.
+func (s *Sink) Checkpoint() (*compliance.Checkpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrSinkClosed
+	}
+	if s.checkpoints == nil {
+		return nil, fmt.Errorf("checkpoints are not enabled")
+	}
+	return s.checkpoints.checkpoint(true)
+}
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/willibrandon/mtlog-audit/backends"
+	"github.com/willibrandon/mtlog-audit/wal"
+)
+
+// openBackend opens the backend described by a JSON configuration file. The
+// file's "type" field selects the backend; the other fields are those of its
+// configuration, such as backends.FilesystemConfig.
+func openBackend(path string) (backends.Backend, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - user-provided config path
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backend config: %w", err)
+	}
+
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid backend config %s: %w", path, err)
+	}
+
+	var config backends.Config
+	switch header.Type {
+	case "filesystem":
+		var cfg backends.FilesystemConfig
+		err = json.Unmarshal(data, &cfg)
+		config = cfg
+	case "s3":
+		var cfg backends.S3Config
+		err = json.Unmarshal(data, &cfg)
+		config = cfg
+	case "azure":
+		var cfg backends.AzureConfig
+		err = json.Unmarshal(data, &cfg)
+		config = cfg
+	case "gcs":
+		var cfg backends.GCSConfig
+		err = json.Unmarshal(data, &cfg)
+		config = cfg
+	default:
+		return nil, fmt.Errorf("unknown backend type %q in %s", header.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid backend config %s: %w", path, err)
+	}
+
+	backend, err := backends.Create(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backend %s: %w", path, err)
+	}
+	return backend, nil
+}
+
+// openBackends opens every backend in paths, closing those already opened
+// if one fails.
+func openBackends(paths []string) ([]backends.Backend, error) {
+	var opened []backends.Backend
+	for _, path := range paths {
+		backend, err := openBackend(path)
+		if err != nil {
+			closeBackends(opened)
+			return nil, err
+		}
+		opened = append(opened, backend)
+	}
+	return opened, nil
+}
+
+// closeBackends closes every backend in list.
+func closeBackends(list []backends.Backend) {
+	for _, backend := range list {
+		_ = backend.Close()
+	}
+}
+
+// backendReplicas returns the backends' copies of the WAL's events,
+// filesystem shadow copies first since they are local.
+func backendReplicas(list []backends.Backend) []wal.Replica {
+	var replicas []wal.Replica
+	for _, backend := range list {
+		if fs, ok := backend.(*backends.FilesystemBackend); ok && fs.HasShadow() {
+			replicas = append(replicas, wal.Replica{Name: backend.Name() + " shadow", Read: fs.ReadShadow})
+		}
+	}
+	for _, backend := range list {
+		replicas = append(replicas, wal.Replica{Name: backend.Name(), Read: backend.Read})
+	}
+	return replicas
+}
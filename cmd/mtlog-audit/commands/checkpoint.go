@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+	"github.com/willibrandon/mtlog-audit/wal"
+)
+
+// checkpointCmd creates the checkpoint command.
+func checkpointCmd() *cobra.Command {
+	var (
+		walPath         string
+		checkpointsPath string
+		keyPath         string
+		publicKeyPath   string
+		list            bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "checkpoint",
+		Short: "Sign or list Merkle tree checkpoints",
+		Long: `Sign the audit log's current Merkle tree size and root hash.
+
+A checkpoint commits to every record in the log at the time it is signed.
+Checkpoints are appended to <wal>.checkpoints, the same log the sink writes
+when checkpoints are enabled. Use the consistency command to prove the log
+was only appended to between two checkpoints.
+
+Examples:
+  # Sign a checkpoint with the compliance signing key
+  mtlog-audit checkpoint --wal /var/audit/app.wal --key /etc/audit/signing.pem
+
+  # List checkpoints and check their signatures
+  mtlog-audit checkpoint --wal /var/audit/app.wal --list --public-key /etc/audit/signing.pub`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if checkpointsPath == "" {
+				checkpointsPath = walPath + ".checkpoints"
+			}
+			if list {
+				return listCheckpoints(checkpointsPath, publicKeyPath)
+			}
+			if keyPath == "" {
+				return fmt.Errorf("--key is required to sign a checkpoint")
+			}
+
+			signer, err := compliance.LoadSigner(keyPath)
+			if err != nil {
+				return err
+			}
+			root, size, err := wal.ComputeMerkleRoot(walPath)
+			if err != nil {
+				return err
+			}
+
+			cp, err := compliance.SignCheckpoint(signer, size, root, time.Now())
+			if err != nil {
+				return err
+			}
+			if err := compliance.AppendCheckpoint(checkpointsPath, cp); err != nil {
+				return err
+			}
+
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(cp); err != nil {
+				return fmt.Errorf("failed to write checkpoint: %w", err)
+			}
+			logger.Log.Info("Signed checkpoint at size {size} with root {root} to {path}", cp.TreeSize, cp.RootHash, checkpointsPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&walPath, "wal", "/var/audit/app.wal", "Path to WAL file")
+	cmd.Flags().StringVar(&checkpointsPath, "checkpoints", "", "Checkpoint log path (default <wal>.checkpoints)")
+	cmd.Flags().StringVar(&keyPath, "key", "", "PEM private key to sign the checkpoint with")
+	cmd.Flags().StringVar(&publicKeyPath, "public-key", "", "PEM public key to check listed checkpoints with")
+	cmd.Flags().BoolVar(&list, "list", false, "List checkpoints instead of signing one")
+
+	return cmd
+}
+
+// listCheckpoints prints every checkpoint in the log, checking signatures
+// when a public key is given.
+func listCheckpoints(path, publicKeyPath string) error {
+	checkpoints, err := compliance.ReadCheckpoints(path)
+	if err != nil {
+		return err
+	}
+
+	var verifier compliance.Signer
+	if publicKeyPath != "" {
+		if verifier, err = compliance.LoadPublicKey(publicKeyPath); err != nil {
+			return err
+		}
+	}
+
+	invalid := 0
+	for _, cp := range checkpoints {
+		status := ""
+		if verifier != nil {
+			status = "✅"
+			if err := cp.Verify(verifier); err != nil {
+				status = "❌"
+				invalid++
+			}
+		}
+		logger.Log.Info("{status} {timestamp} size {size} root {root}",
+			status, cp.Timestamp.Format(time.RFC3339), cp.TreeSize, cp.RootHash)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d checkpoints have invalid signatures", invalid, len(checkpoints))
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+	"github.com/willibrandon/mtlog-audit/wal"
+)
+
+// consistencyCmd creates the consistency command.
+func consistencyCmd() *cobra.Command {
+	var (
+		walPath         string
+		checkpointsPath string
+		publicKeyPath   string
+		output          string
+		verifyPath      string
+		from            uint64
+		to              uint64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "consistency",
+		Short: "Prove the log was only appended to between two checkpoints",
+		Long: `Produce or check a Merkle consistency proof between two signed checkpoints.
+
+--from and --to select checkpoints by tree size; --to defaults to the latest
+checkpoint. Building the proof fails if the log no longer matches either
+checkpoint, which means records they cover were rewritten or removed.
+
+Examples:
+  # Prove the log at checkpoint 1000 is a prefix of the log at 5000
+  mtlog-audit consistency --wal /var/audit/app.wal --from 1000 --to 5000 \
+    --public-key /etc/audit/signing.pub --output proof.json
+
+  # Check a proof
+  mtlog-audit consistency --verify proof.json --public-key /etc/audit/signing.pub`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var verifier compliance.Signer
+			if publicKeyPath != "" {
+				var err error
+				if verifier, err = compliance.LoadPublicKey(publicKeyPath); err != nil {
+					return err
+				}
+			}
+
+			if verifyPath != "" {
+				return verifyConsistencyProof(verifyPath, verifier)
+			}
+			if !cmd.Flags().Changed("from") {
+				return fmt.Errorf("--from is required")
+			}
+
+			if checkpointsPath == "" {
+				checkpointsPath = walPath + ".checkpoints"
+			}
+			checkpoints, err := compliance.ReadCheckpoints(checkpointsPath)
+			if err != nil {
+				return err
+			}
+			if len(checkpoints) == 0 {
+				return fmt.Errorf("no checkpoints in %s", checkpointsPath)
+			}
+
+			fromCheckpoint, err := findCheckpoint(checkpoints, from)
+			if err != nil {
+				return err
+			}
+			toCheckpoint := checkpoints[len(checkpoints)-1]
+			if cmd.Flags().Changed("to") {
+				if toCheckpoint, err = findCheckpoint(checkpoints, to); err != nil {
+					return err
+				}
+			}
+
+			proof, err := wal.ProveConsistency(walPath, fromCheckpoint, toCheckpoint)
+			if err != nil {
+				logger.Log.Error("❌ Log is NOT consistent with its checkpoints: {error}", err)
+				return fmt.Errorf("failed to build consistency proof: %w", err)
+			}
+			if err := proof.Verify(verifier); err != nil {
+				return fmt.Errorf("consistency proof failed to verify: %w", err)
+			}
+
+			var writer io.Writer = os.Stdout
+			if output != "" {
+				file, err := os.Create(output) // #nosec G304 - user-specified output path
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer func() { _ = file.Close() }()
+				writer = file
+			}
+
+			encoder := json.NewEncoder(writer)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(proof); err != nil {
+				return fmt.Errorf("failed to write proof: %w", err)
+			}
+
+			if output != "" {
+				logger.Log.Info("Wrote consistency proof from size {from} to {to} to {path}",
+					proof.From.TreeSize, proof.To.TreeSize, output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&walPath, "wal", "/var/audit/app.wal", "Path to WAL file")
+	cmd.Flags().StringVar(&checkpointsPath, "checkpoints", "", "Checkpoint log path (default <wal>.checkpoints)")
+	cmd.Flags().StringVar(&publicKeyPath, "public-key", "", "PEM public key to check checkpoint signatures with")
+	cmd.Flags().Uint64Var(&from, "from", 0, "Tree size of the earlier checkpoint")
+	cmd.Flags().Uint64Var(&to, "to", 0, "Tree size of the later checkpoint (default latest)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default stdout)")
+	cmd.Flags().StringVar(&verifyPath, "verify", "", "Check the proof in this file instead of producing one")
+
+	return cmd
+}
+
+// findCheckpoint returns the latest checkpoint with the given tree size.
+func findCheckpoint(checkpoints []*compliance.Checkpoint, size uint64) (*compliance.Checkpoint, error) {
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		if checkpoints[i].TreeSize == size {
+			return checkpoints[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no checkpoint at tree size %d", size)
+}
+
+// verifyConsistencyProof checks a proof file produced by consistency.
+func verifyConsistencyProof(path string, verifier compliance.Signer) error {
+	data, err := os.ReadFile(path) // #nosec G304 - user-specified proof path
+	if err != nil {
+		return fmt.Errorf("failed to read proof: %w", err)
+	}
+
+	var proof wal.ConsistencyProof
+	if err := json.Unmarshal(data, &proof); err != nil {
+		return fmt.Errorf("failed to parse proof: %w", err)
+	}
+
+	if err := proof.Verify(verifier); err != nil {
+		logger.Log.Error("❌ Proof INVALID: {error}", err)
+		return fmt.Errorf("proof verification failed: %w", err)
+	}
+
+	if verifier == nil {
+		logger.Log.Warn("Checkpoint signatures not checked; pass --public-key to check them")
+	}
+	logger.Log.Info("✅ Log of {from} records (root {fromRoot}) is a prefix of log of {to} records (root {toRoot})",
+		proof.From.TreeSize, proof.From.RootHash, proof.To.TreeSize, proof.To.RootHash)
+	return nil
+}
@@ -23,12 +23,16 @@ func exportCmd() *cobra.Command {
 		startStr string
 		endStr   string
 		pretty   bool
+		keys     keyFlags
 	)
 
 	cmd := &cobra.Command{
 		Use:   "export",
 		Short: "Export WAL events to various formats",
 		Long: `Export WAL events to JSON, CSV, or other formats for analysis.
+
+If the WAL is self-describing, its genesis and metadata records are written
+beside the export as <output>.metadata.json.
 		
 Examples:
   # Export all events to JSON
@@ -39,7 +43,10 @@ Examples:
   
   # Export events in time range with pretty JSON
   mtlog-audit export --wal /var/audit/app.wal --output events.json --pretty \
-    --start "2024-01-01T00:00:00Z" --end "2024-01-31T23:59:59Z"`,
+    --start "2024-01-01T00:00:00Z" --end "2024-01-31T23:59:59Z"
+
+  # Export an encrypted WAL
+  mtlog-audit export --wal /var/audit/app.wal --output events.json --key-file /etc/audit/wal.key`,
 		RunE: func(_ *cobra.Command, _ []string) error {
 			// Parse time range
 			start, end, err := parseTimeRange(startStr, endStr)
@@ -47,12 +54,18 @@ Examples:
 				return fmt.Errorf("invalid time range: %w", err)
 			}
 
+			keyring, err := keys.keyring()
+			if err != nil {
+				return err
+			}
+
 			// Open WAL reader
 			reader, err := wal.NewReader(walPath)
 			if err != nil {
 				return fmt.Errorf("failed to open WAL: %w", err)
 			}
 			defer func() { _ = reader.Close() }()
+			reader.SetKeyring(keyring)
 
 			// Read events in range
 			logger.Log.Info("Reading events from WAL...")
@@ -63,6 +76,11 @@ Examples:
 
 			logger.Log.Info("Exporting {count} events to {format} format...", len(events), format)
 
+			// Keep the log's identity with the events
+			if err := exportMetadata(walPath, output+".metadata.json"); err != nil {
+				return err
+			}
+
 			// Export based on format
 			switch format {
 			case "json":
@@ -83,6 +101,7 @@ Examples:
 	cmd.Flags().StringVar(&startStr, "start", "", "Start time (RFC3339 or relative like '1h ago')")
 	cmd.Flags().StringVar(&endStr, "end", "", "End time (RFC3339 or relative like 'now')")
 	cmd.Flags().BoolVar(&pretty, "pretty", false, "Pretty print JSON output")
+	addKeyFlags(cmd, &keys)
 
 	_ = cmd.MarkFlagRequired("wal")
 	_ = cmd.MarkFlagRequired("output")
@@ -140,6 +159,29 @@ func parseTime(s string) (time.Time, error) {
 	}
 }
 
+// exportMetadata writes the WAL's genesis and metadata records to output as a
+// JSON array. A WAL without metadata writes nothing.
+func exportMetadata(walPath, output string) error {
+	history, err := wal.ReadMetadata(walPath)
+	if err != nil {
+		return fmt.Errorf("failed to read WAL metadata: %w", err)
+	}
+	if len(history) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	if err := os.WriteFile(output, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	logger.Log.Info("Exported {count} metadata records to {file}", len(history), output)
+	return nil
+}
+
 // exportJSON exports events to JSON format.
 func exportJSON(events []*core.LogEvent, output string, pretty bool) error {
 	file, err := os.Create(output) // #nosec G304 - user-specified output path
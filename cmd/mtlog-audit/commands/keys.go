@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+	"github.com/willibrandon/mtlog-audit/wal"
+)
+
+// keyringPassphraseEnv names the environment variable holding the keyring
+// passphrase, so it never appears on the command line.
+const keyringPassphraseEnv = "MTLOG_AUDIT_KEYRING_PASSPHRASE"
+
+// keyFlags holds the key material flags shared by commands that read
+// encrypted WAL records.
+type keyFlags struct {
+	keyFile     string
+	algorithm   string
+	keyringPath string
+	kekFile     string
+	localKMS    string
+}
+
+// addKeyFlags registers the key material flags on cmd.
+func addKeyFlags(cmd *cobra.Command, flags *keyFlags) {
+	cmd.Flags().StringVar(&flags.keyFile, "key-file", "", "File holding the 32-byte WAL encryption key (raw or hex)")
+	cmd.Flags().StringVar(&flags.algorithm, "key-algorithm", "AES-256-GCM", "Encryption algorithm (AES-256-GCM, ChaCha20-Poly1305)")
+	addKeyringFlags(cmd, flags)
+}
+
+// addKeyringFlags registers the persistent keyring flags on cmd.
+func addKeyringFlags(cmd *cobra.Command, flags *keyFlags) {
+	cmd.Flags().StringVar(&flags.keyringPath, "keyring", "", "Keyring file (passphrase from "+keyringPassphraseEnv+" unless --kek-file is set)")
+	cmd.Flags().StringVar(&flags.kekFile, "kek-file", "", "File holding the 32-byte keyring KEK (raw or hex)")
+	cmd.Flags().StringVar(&flags.localKMS, "local-kms", "", "Master key file of a local KMS protecting the keyring")
+}
+
+// keyring builds a keyring from the key flags, or returns nil when no key
+// material was given.
+func (f *keyFlags) keyring() (wal.Keyring, error) {
+	if f.keyringPath != "" {
+		return f.openKeyring()
+	}
+	if f.keyFile == "" {
+		return nil, nil
+	}
+
+	key, err := readKeyFile(f.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := compliance.NewKeyManager(key, f.algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return keys, nil
+}
+
+// openKeyring opens an existing keyring file.
+func (f *keyFlags) openKeyring() (*compliance.KeyManager, error) {
+	if _, err := os.Stat(f.keyringPath); err != nil {
+		return nil, fmt.Errorf("keyring not found: %w", err)
+	}
+
+	var opt compliance.KeyringOption
+	if f.localKMS != "" {
+		if _, err := os.Stat(f.localKMS); err != nil {
+			return nil, fmt.Errorf("local KMS master key not found: %w", err)
+		}
+		kms, err := compliance.NewLocalKMS(f.localKMS)
+		if err != nil {
+			return nil, err
+		}
+		opt = compliance.WithKeyProvider(kms)
+	} else if f.kekFile != "" {
+		kek, err := readKeyFile(f.kekFile)
+		if err != nil {
+			return nil, err
+		}
+		opt = compliance.WithKEK(kek)
+	} else {
+		passphrase := os.Getenv(keyringPassphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("keyring requires --local-kms, --kek-file or %s", keyringPassphraseEnv)
+		}
+		opt = compliance.WithPassphrase([]byte(passphrase))
+	}
+
+	keys, err := compliance.OpenKeyManager(f.keyringPath, opt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keyring: %w", err)
+	}
+	return keys, nil
+}
+
+// readKeyFile reads a 32-byte key stored raw or hex-encoded.
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - user-specified key path
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 64 {
+		if decoded, err := hex.DecodeString(string(trimmed)); err == nil {
+			return decoded, nil
+		}
+	}
+	return data, nil
+}
+
+// keysCmd creates the keys command.
+func keysCmd() *cobra.Command {
+	var (
+		flags  keyFlags
+		rotate bool
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "List or rotate keyring keys",
+		Long: `List the keys in a WAL encryption keyring by fingerprint, including
+retired keys kept for decryption. Key material is never printed.
+
+Examples:
+  # List key fingerprints
+  MTLOG_AUDIT_KEYRING_PASSPHRASE=... mtlog-audit keys --keyring /etc/audit/keyring.json
+
+  # Rotate to a new key
+  mtlog-audit keys --keyring /etc/audit/keyring.json --kek-file /etc/audit/kek --rotate`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			keys, err := flags.openKeyring()
+			if err != nil {
+				return err
+			}
+
+			if rotate {
+				if err := keys.Rotate(); err != nil {
+					return fmt.Errorf("rotation failed: %w", err)
+				}
+				logger.Log.Info("Rotated keyring {path}", flags.keyringPath)
+			}
+
+			fingerprints := keys.Fingerprints()
+			if asJSON {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(fingerprints)
+			}
+
+			for _, info := range fingerprints {
+				status := "retired " + formatKeyTime(info.Retired)
+				if info.Active {
+					status = "active"
+				}
+				logger.Log.Info("{id} {algorithm} {fingerprint} created {created}, {records} records, {status}",
+					info.ID, info.Algorithm, info.Fingerprint, info.Created.Format(time.RFC3339), info.Records, status)
+			}
+			return nil
+		},
+	}
+
+	addKeyringFlags(cmd, &flags)
+	cmd.Flags().BoolVar(&rotate, "rotate", false, "Rotate to a new key before listing")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Output as JSON")
+
+	_ = cmd.MarkFlagRequired("keyring")
+
+	return cmd
+}
+
+// formatKeyTime formats an optional key timestamp.
+func formatKeyTime(t *time.Time) string {
+	if t == nil {
+		return "unknown"
+	}
+	return t.Format(time.RFC3339)
+}
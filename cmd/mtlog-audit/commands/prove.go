@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+	"github.com/willibrandon/mtlog-audit/wal"
+)
+
+// proveCmd creates the prove command.
+func proveCmd() *cobra.Command {
+	var (
+		walPath    string
+		output     string
+		verifyPath string
+		sequence   uint64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prove",
+		Short: "Produce or check a Merkle inclusion proof for one record",
+		Long: `Produce a self-contained proof that a record belongs to the audit log.
+
+The proof embeds the record as stored, its position in the RFC 6962 Merkle
+tree over all records and over its segment, and the audit paths to both
+roots. It can be checked without access to the log.
+
+Examples:
+  # Prove record 42 is in the log
+  mtlog-audit prove --wal /var/audit/app.wal --seq 42 --output proof-42.json
+
+  # Check a proof
+  mtlog-audit prove --verify proof-42.json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if verifyPath != "" {
+				return verifyProof(verifyPath)
+			}
+			if !cmd.Flags().Changed("seq") {
+				return fmt.Errorf("--seq is required")
+			}
+
+			proof, err := wal.ProveInclusion(walPath, sequence)
+			if err != nil {
+				return fmt.Errorf("failed to build proof: %w", err)
+			}
+
+			var writer io.Writer = os.Stdout
+			if output != "" {
+				file, err := os.Create(output) // #nosec G304 - user-specified output path
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer func() { _ = file.Close() }()
+				writer = file
+			}
+
+			encoder := json.NewEncoder(writer)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(proof); err != nil {
+				return fmt.Errorf("failed to write proof: %w", err)
+			}
+
+			if output != "" {
+				logger.Log.Info("Wrote proof for record {seq} (leaf {index} of {size}, root {root}) to {path}",
+					sequence, proof.LeafIndex, proof.TreeSize, proof.RootHash, output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&walPath, "wal", "/var/audit/app.wal", "Path to WAL file")
+	cmd.Flags().Uint64Var(&sequence, "seq", 0, "Sequence number of the record to prove")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default stdout)")
+	cmd.Flags().StringVar(&verifyPath, "verify", "", "Check the proof in this file instead of producing one")
+
+	return cmd
+}
+
+// verifyProof checks a proof file produced by prove.
+func verifyProof(path string) error {
+	data, err := os.ReadFile(path) // #nosec G304 - user-specified proof path
+	if err != nil {
+		return fmt.Errorf("failed to read proof: %w", err)
+	}
+
+	var proof wal.InclusionProof
+	if err := json.Unmarshal(data, &proof); err != nil {
+		return fmt.Errorf("failed to parse proof: %w", err)
+	}
+
+	if err := proof.Verify(); err != nil {
+		logger.Log.Error("❌ Proof INVALID: {error}", err)
+		return fmt.Errorf("proof verification failed: %w", err)
+	}
+
+	logger.Log.Info("✅ Record {seq} is leaf {index} of the {size}-record tree with root {root}",
+		proof.Sequence, proof.LeafIndex, proof.TreeSize, proof.RootHash)
+	return nil
+}
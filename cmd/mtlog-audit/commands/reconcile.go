@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	audit "github.com/willibrandon/mtlog-audit"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+)
+
+func reconcileCmd() *cobra.Command {
+	var (
+		walPath     string
+		backendList []string
+		sinceStr    string
+		untilStr    string
+		keys        keyFlags
+		window      time.Duration
+		backfill    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Compare the WAL with what each backend holds",
+		Long: `Check that each backend holds exactly the records the WAL holds.
+
+Replicated events carry the sequence and content hash of their WAL record,
+so every backend copy is compared with the record it came from. The report
+lists, per backend:
+- gaps: WAL records the backend does not hold
+- duplicates: records the backend holds more than once
+- divergent records: copies whose content differs from the WAL
+- extra sequences the WAL does not hold
+
+Events replicated before they were stamped are matched by content alone.
+The WAL and backends are compared one --window of time at a time.
+With --backfill the gaps are written to the backend through WriteBatch.
+
+Each --backend names a JSON backend configuration whose "type" field is
+filesystem, s3, azure or gcs.
+
+Example:
+  mtlog-audit reconcile --wal /var/audit/audit.wal --backend s3.json --since "24h ago" --backfill`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if len(backendList) == 0 {
+				return fmt.Errorf("at least one --backend is required")
+			}
+
+			keyring, err := keys.keyring()
+			if err != nil {
+				return err
+			}
+			var since, until time.Time
+			if sinceStr != "" {
+				if since, err = parseTime(sinceStr); err != nil {
+					return fmt.Errorf("invalid since time: %w", err)
+				}
+			}
+			if untilStr != "" {
+				if until, err = parseTime(untilStr); err != nil {
+					return fmt.Errorf("invalid until time: %w", err)
+				}
+			}
+
+			opts := []audit.ReconcileOption{audit.WithReconcileRange(since, until), audit.WithReconcileWindow(window)}
+			if keyring != nil {
+				opts = append(opts, audit.WithReconcileKeyring(keyring))
+			}
+			if backfill {
+				opts = append(opts, audit.WithBackfill())
+			}
+			reconciler := audit.NewReconciler(walPath, opts...)
+
+			list, err := openBackends(backendList)
+			if err != nil {
+				return err
+			}
+			defer closeBackends(list)
+
+			inconsistent := 0
+			for _, backend := range list {
+				logger.Log.Info("Reconciling {path} with {backend}", walPath, backend.Name())
+				report, err := reconciler.Reconcile(backend)
+				if report == nil {
+					return fmt.Errorf("reconcile failed: %w", err)
+				}
+				printReconcileReport(report)
+				if err != nil {
+					return fmt.Errorf("reconcile failed: %w", err)
+				}
+				if !report.Consistent() {
+					inconsistent++
+				}
+			}
+
+			if inconsistent > 0 {
+				return fmt.Errorf("%d of %d backend(s) differ from the WAL", inconsistent, len(list))
+			}
+			logger.Log.Info("✅ All backends hold exactly the WAL's records!")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&walPath, "wal", "", "Path to the WAL file")
+	cmd.Flags().StringArrayVar(&backendList, "backend", nil, "Backend configuration file (JSON) to reconcile; repeatable")
+	cmd.Flags().StringVar(&sinceStr, "since", "", "Reconcile records from this time (RFC3339 or relative)")
+	cmd.Flags().StringVar(&untilStr, "until", "", "Reconcile records up to this time (RFC3339 or relative; default now)")
+	cmd.Flags().DurationVar(&window, "window", time.Hour, "Time range compared at once")
+	cmd.Flags().BoolVar(&backfill, "backfill", false, "Write records missing from a backend to it")
+	addKeyFlags(cmd, &keys)
+
+	_ = cmd.MarkFlagRequired("wal")
+
+	return cmd
+}
+
+// printReconcileReport logs a summary of report.
+func printReconcileReport(report *audit.ReconcileReport) {
+	logger.Log.Info("")
+	logger.Log.Info("=== RECONCILE REPORT: {backend} ===", report.Backend)
+	logger.Log.Info("WAL records: {count}", report.WALRecords)
+	logger.Log.Info("Backend events: {count}", report.BackendEvents)
+	logger.Log.Info("Matched: {count}", report.Matched)
+	if report.Unstamped > 0 {
+		logger.Log.Info("Unstamped events matched by content: {count}", report.Unstamped-report.Unknown)
+	}
+	if report.Unreadable > 0 {
+		logger.Log.Warn("⚠️  {count} WAL records could not be decoded; only their presence was checked", report.Unreadable)
+	}
+	for _, gap := range report.Gaps {
+		logger.Log.Error("  ❌ Missing records {first}-{last}", gap.First, gap.Last)
+	}
+	for _, extra := range report.Extra {
+		logger.Log.Error("  ❌ Records {first}-{last} are not in the WAL", extra.First, extra.Last)
+	}
+	for _, seq := range report.Duplicates {
+		logger.Log.Error("  ❌ Record {sequence} held more than once", seq)
+	}
+	for _, divergent := range report.Divergent {
+		logger.Log.Error("  ❌ Record {sequence}: {reason}", divergent.Sequence, divergent.Reason)
+	}
+	if report.Unknown > 0 {
+		logger.Log.Error("  ❌ {count} events match no WAL record", report.Unknown)
+	}
+	if report.Backfilled > 0 {
+		logger.Log.Info("Backfilled: {count}", report.Backfilled)
+	}
+}
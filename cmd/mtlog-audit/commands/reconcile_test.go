@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/backends"
+	"github.com/willibrandon/mtlog-audit/wal"
+	"github.com/willibrandon/mtlog/core"
+)
+
+func TestReconcileCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "audit.wal")
+
+	w, err := wal.New(walPath)
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	backend, err := backends.NewFilesystemBackend(backends.FilesystemConfig{Path: filepath.Join(tmpDir, "events")})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+
+	// The backend missed the last events, and holds the rest unstamped
+	for i := 0; i < 10; i++ {
+		event := &core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Payment {id} processed",
+			Properties:      map[string]any{"id": i},
+		}
+		if err := w.Write(event); err != nil {
+			t.Fatalf("Failed to write event: %v", err)
+		}
+		if i < 6 {
+			if err := backend.Write(event); err != nil {
+				t.Fatalf("Failed to write event to backend: %v", err)
+			}
+		}
+	}
+	_ = w.Close()
+	_ = backend.Close()
+
+	config := filepath.Join(tmpDir, "backend.json")
+	if err := os.WriteFile(config, []byte(`{"type": "filesystem", "path": "`+filepath.Join(tmpDir, "events")+`"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcile := func(args ...string) error {
+		cmd := reconcileCmd()
+		cmd.SetArgs(append([]string{"--wal", walPath, "--backend", config}, args...))
+		return cmd.Execute()
+	}
+
+	if err := reconcile(); err == nil {
+		t.Fatal("Expected the missing records to be reported")
+	}
+	if err := reconcile("--backfill"); err != nil {
+		t.Fatalf("Backfill failed: %v", err)
+	}
+	if err := reconcile(); err != nil {
+		t.Errorf("Expected the backfilled backend to reconcile, got %v", err)
+	}
+}
@@ -18,6 +18,7 @@ func recoverCmd() *cobra.Command {
 		skipCorrupted  bool
 		verifyChecksum bool
 		maxRecordSize  int64
+		keys           keyFlags
 	)
 
 	cmd := &cobra.Command{
@@ -30,10 +31,19 @@ This command can:
 - Skip over corrupted sections to recover remaining data
 - Repair WAL files by writing recovered records to a new file
 - Verify checksums during recovery
+- Rebuild damaged blocks exactly from a segment's parity sidecar
+  (<segment>.parity) when one exists
+- Decrypt encrypted records when given --key-file; without it they are
+  recovered and repaired as ciphertext
 
 Example:
   mtlog-audit recover --wal /var/audit/corrupted.wal --output /var/audit/repaired.wal`,
 		RunE: func(_ *cobra.Command, _ []string) error {
+			keyring, err := keys.keyring()
+			if err != nil {
+				return err
+			}
+
 			logger.Log.Info("Starting recovery of {path}", walPath)
 
 			// Create recovery engine
@@ -41,6 +51,7 @@ Example:
 				wal.WithSkipCorrupted(skipCorrupted),
 				wal.WithChecksumVerification(verifyChecksum),
 				wal.WithMaxRecordSize(maxRecordSize),
+				wal.WithRecoveryKeyring(keyring),
 			)
 
 			// If output path not specified, generate one
@@ -62,6 +73,13 @@ Example:
 			logger.Log.Info("Records recovered: {count}", report.RecoveredRecords)
 			logger.Log.Info("Corrupted records: {count}", report.CorruptedRecords)
 			logger.Log.Info("Bytes skipped: {bytes}", report.SkippedBytes)
+			if report.RepairedBlocks > 0 {
+				logger.Log.Info("Blocks rebuilt from parity: {count}", report.RepairedBlocks)
+			}
+			if report.DecryptedRecords > 0 || report.EncryptedRecords > 0 {
+				logger.Log.Info("Decrypted records: {count}", report.DecryptedRecords)
+				logger.Log.Info("Records left encrypted: {count}", report.EncryptedRecords)
+			}
 
 			if report.LastGoodSequence > 0 {
 				logger.Log.Info("Last good sequence: {seq}", report.LastGoodSequence)
@@ -107,6 +125,7 @@ Example:
 	cmd.Flags().BoolVar(&skipCorrupted, "skip-corrupted", true, "Skip corrupted records and continue recovery")
 	cmd.Flags().BoolVar(&verifyChecksum, "verify-checksum", true, "Verify CRC32 checksums during recovery")
 	cmd.Flags().Int64Var(&maxRecordSize, "max-record-size", 10*1024*1024, "Maximum expected record size in bytes")
+	addKeyFlags(cmd, &keys)
 
 	_ = cmd.MarkFlagRequired("wal")
 
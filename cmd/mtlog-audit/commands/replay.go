@@ -21,6 +21,7 @@ func replayCmd() *cobra.Command {
 		endTime   string
 		format    string
 		output    string
+		keys      keyFlags
 	)
 
 	cmd := &cobra.Command{
@@ -42,9 +43,16 @@ It can be used for debugging, analysis, or reprocessing of audit events.`,
   mtlog-audit replay --wal /var/audit/mtlog.wal --format json
 
   # Save output to file
-  mtlog-audit replay --wal /var/audit/mtlog.wal --output events.json`,
+  mtlog-audit replay --wal /var/audit/mtlog.wal --output events.json
+
+  # Replay an encrypted WAL
+  mtlog-audit replay --wal /var/audit/mtlog.wal --key-file /etc/audit/wal.key`,
 		RunE: func(_ *cobra.Command, _ []string) error {
-			return runReplay(walPath, startTime, endTime, format, output)
+			keyring, err := keys.keyring()
+			if err != nil {
+				return err
+			}
+			return runReplay(walPath, startTime, endTime, format, output, keyring)
 		},
 	}
 
@@ -53,13 +61,14 @@ It can be used for debugging, analysis, or reprocessing of audit events.`,
 	cmd.Flags().StringVar(&endTime, "end", "", "End time (RFC3339 format, e.g., 2023-01-01T23:59:59Z)")
 	cmd.Flags().StringVar(&format, "format", "text", "Output format (text, json, csv)")
 	cmd.Flags().StringVar(&output, "output", "", "Output file (default: stdout)")
+	addKeyFlags(cmd, &keys)
 
 	_ = cmd.MarkFlagRequired("wal")
 
 	return cmd
 }
 
-func runReplay(walPath, startTimeStr, endTimeStr, format, output string) error {
+func runReplay(walPath, startTimeStr, endTimeStr, format, output string, keys wal.Keyring) error {
 	// Validate WAL path
 	if walPath == "" {
 		return fmt.Errorf("WAL path is required")
@@ -154,6 +163,7 @@ func runReplay(walPath, startTimeStr, endTimeStr, format, output string) error {
 		return fmt.Errorf("failed to create reader: %w", err)
 	}
 	defer func() { _ = reader.Close() }()
+	reader.SetKeyring(keys)
 
 	var events []*core.LogEvent
 	if startTime.IsZero() && endTime.IsZero() {
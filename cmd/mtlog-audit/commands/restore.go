@@ -0,0 +1,284 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	audit "github.com/willibrandon/mtlog-audit"
+	"github.com/willibrandon/mtlog-audit/backends"
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+	"github.com/willibrandon/mtlog-audit/wal"
+)
+
+func restoreCmd() *cobra.Command {
+	var (
+		backendPath     string
+		outputDir       string
+		walName         string
+		walID           string
+		sealsPath       string
+		checkpointsPath string
+		publicKeyPath   string
+		reportPath      string
+		sinceStr        string
+		untilStr        string
+		checksum        string
+		hash            string
+		codec           string
+		compression     string
+		window          time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Rebuild a lost WAL from a backend",
+		Long: `Rebuild a WAL from the events a backend holds after the original was lost.
+
+Events are read from the backend a window at a time and written in order into
+a new WAL in --output. The restored hash chain is verified against whatever
+was signed while the original was written and survived it:
+- segment seals (--seals); segments are re-created at the sealed boundaries
+- Merkle tree checkpoints (--checkpoints)
+- the high-water mark the sink mirrors to the backend as <wal>.<id>.hwm,
+  when the original WAL's ID is given (--wal-id)
+
+Seals and checkpoints that match are kept beside the restored WAL, so it
+verifies as the original did. The checksum, hash, codec and compression must
+be those the original WAL was written with.
+
+A restore report for the incident file is written as JSON to --report.
+
+Example:
+  mtlog-audit restore --backend s3.json --output /var/audit/restored \
+    --seals /mnt/evidence/mtlog.wal.seals --public-key /etc/audit/signing.pub`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			opts, err := restoreFormat(checksum, hash, codec, compression)
+			if err != nil {
+				return err
+			}
+			restoreOpts := []wal.RestoreOption{wal.WithRestoreWALOptions(opts...), wal.WithRestoreWindow(window)}
+
+			var since, until time.Time
+			if sinceStr != "" {
+				if since, err = parseTime(sinceStr); err != nil {
+					return fmt.Errorf("invalid since time: %w", err)
+				}
+			}
+			if untilStr != "" {
+				if until, err = parseTime(untilStr); err != nil {
+					return fmt.Errorf("invalid until time: %w", err)
+				}
+			}
+			restoreOpts = append(restoreOpts, wal.WithRestoreRange(since, until))
+
+			if publicKeyPath != "" {
+				verifier, err := compliance.LoadPublicKey(publicKeyPath)
+				if err != nil {
+					return err
+				}
+				restoreOpts = append(restoreOpts, wal.WithRestoreVerifier(verifier))
+			}
+			if sealsPath != "" {
+				seals, err := compliance.ReadSeals(sealsPath)
+				if err != nil {
+					return err
+				}
+				restoreOpts = append(restoreOpts, wal.WithRestoreSeals(seals))
+			}
+			if checkpointsPath != "" {
+				checkpoints, err := compliance.ReadCheckpoints(checkpointsPath)
+				if err != nil {
+					return err
+				}
+				restoreOpts = append(restoreOpts, wal.WithRestoreCheckpoints(checkpoints))
+			}
+
+			backend, err := openBackend(backendPath)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = backend.Close() }()
+
+			if walID != "" {
+				mark, err := backendHighWaterMark(backend, audit.HighWaterMarkObject(walName, walID))
+				if err != nil {
+					logger.Log.Warn("Failed to read the high-water mark from {backend}: {error}", backend.Name(), err)
+				}
+				if mark != nil {
+					restoreOpts = append(restoreOpts, wal.WithRestoreHighWaterMark(mark))
+				}
+			}
+
+			walPath := filepath.Join(outputDir, walName)
+			if reportPath == "" {
+				reportPath = filepath.Join(outputDir, "restore-report.json")
+			}
+
+			logger.Log.Info("Restoring {path} from {backend}", walPath, backend.Name())
+
+			report, restoreErr := wal.Restore(walPath, wal.Replica{Name: backend.Name(), Read: backend.Read}, restoreOpts...)
+			if report == nil {
+				return fmt.Errorf("restore failed: %w", restoreErr)
+			}
+			if err := writeRestoreReport(reportPath, report); err != nil {
+				return err
+			}
+			printRestoreReport(report)
+			logger.Log.Info("Restore report written to {path}", reportPath)
+
+			if restoreErr != nil {
+				return fmt.Errorf("restore failed: %w", restoreErr)
+			}
+			if len(report.Checks) == 0 {
+				logger.Log.Warn("⚠️  Nothing to verify the restored chain against")
+				return nil
+			}
+			if !report.Verified {
+				return fmt.Errorf("restored WAL does not match %d of %d checks", len(report.Errors), len(report.Checks))
+			}
+			logger.Log.Info("✅ Restore complete and verified!")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&backendPath, "backend", "", "Backend configuration file (JSON) to restore from")
+	cmd.Flags().StringVar(&outputDir, "output", "", "Directory to restore the WAL into")
+	cmd.Flags().StringVar(&walName, "name", "mtlog.wal", "File name of the original WAL")
+	cmd.Flags().StringVar(&walID, "wal-id", "", "ID of the original WAL, as its high-water marks record it, to read its mark from the backend")
+	cmd.Flags().StringVar(&sealsPath, "seals", "", "Segment seal log of the original WAL")
+	cmd.Flags().StringVar(&checkpointsPath, "checkpoints", "", "Checkpoint log of the original WAL")
+	cmd.Flags().StringVar(&publicKeyPath, "public-key", "", "PEM public key to check seal, checkpoint and high-water mark signatures with")
+	cmd.Flags().StringVar(&reportPath, "report", "", "Restore report path (default <output>/restore-report.json)")
+	cmd.Flags().StringVar(&sinceStr, "since", "", "Restore events from this time (RFC3339 or relative; default the first seal, else everything)")
+	cmd.Flags().StringVar(&untilStr, "until", "", "Restore events up to this time (RFC3339 or relative; default now)")
+	cmd.Flags().DurationVar(&window, "window", time.Hour, "Time range read from the backend at once")
+	cmd.Flags().StringVar(&checksum, "checksum", "crc32", "Record checksum of the original WAL (crc32, crc32c, crc64, xxhash3)")
+	cmd.Flags().StringVar(&hash, "hash", "sha256", "Chain hash of the original WAL (sha256, sha512-256, blake3)")
+	cmd.Flags().StringVar(&codec, "codec", "json", "Event codec of the original WAL (json, binary)")
+	cmd.Flags().StringVar(&compression, "compression", "none", "Record compression of the original WAL (none, zstd, snappy)")
+
+	_ = cmd.MarkFlagRequired("backend")
+	_ = cmd.MarkFlagRequired("output")
+
+	return cmd
+}
+
+// restoreFormat returns the WAL options that write records in the named
+// format.
+func restoreFormat(checksum, hash, codec, compression string) ([]wal.Option, error) {
+	checksums := map[string]wal.ChecksumType{
+		"crc32":   wal.ChecksumCRC32,
+		"crc32c":  wal.ChecksumCRC32C,
+		"crc64":   wal.ChecksumCRC64,
+		"xxhash3": wal.ChecksumXXHash3,
+	}
+	hashes := map[string]wal.HashAlgorithm{
+		"sha256":     wal.HashSHA256,
+		"sha512-256": wal.HashSHA512t256,
+		"blake3":     wal.HashBLAKE3,
+	}
+	codecs := map[string]wal.Codec{
+		"json":   wal.CodecJSON,
+		"binary": wal.CodecBinary,
+	}
+	compressions := map[string]wal.Compression{
+		"none":   wal.CompressionNone,
+		"zstd":   wal.CompressionZstd,
+		"snappy": wal.CompressionSnappy,
+	}
+
+	c, ok := checksums[strings.ToLower(checksum)]
+	if !ok {
+		return nil, fmt.Errorf("unknown checksum: %s", checksum)
+	}
+	h, ok := hashes[strings.ToLower(hash)]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm: %s", hash)
+	}
+	e, ok := codecs[strings.ToLower(codec)]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec: %s", codec)
+	}
+	z, ok := compressions[strings.ToLower(compression)]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression: %s", compression)
+	}
+	return []wal.Option{wal.WithChecksum(c), wal.WithHashAlgorithm(h), wal.WithCodec(e), wal.WithCompression(z)}, nil
+}
+
+// backendHighWaterMark returns the high-water mark the sink mirrored to
+// backend, or nil if it has none.
+func backendHighWaterMark(backend backends.Backend, object string) (*compliance.HighWaterMark, error) {
+	store, ok := backend.(backends.MetadataStore)
+	if !ok {
+		return nil, nil
+	}
+	data, err := store.GetMetadata(object)
+	if errors.Is(err, backends.ErrMetadataNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mark compliance.HighWaterMark
+	if err := json.Unmarshal(data, &mark); err != nil {
+		return nil, fmt.Errorf("failed to parse high-water mark: %w", err)
+	}
+	return &mark, nil
+}
+
+// writeRestoreReport writes report as indented JSON to path.
+func writeRestoreReport(path string, report *wal.RestoreReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode restore report: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write restore report: %w", err)
+	}
+	return nil
+}
+
+// printRestoreReport logs a summary of report.
+func printRestoreReport(report *wal.RestoreReport) {
+	logger.Log.Info("")
+	logger.Log.Info("=== RESTORE REPORT ===")
+	logger.Log.Info("Source: {source}", report.Source)
+	logger.Log.Info("Format: {format}", report.Format)
+	logger.Log.Info("Events restored: {count}", report.Events)
+	if report.Duplicates > 0 {
+		logger.Log.Info("Duplicate events skipped: {count}", report.Duplicates)
+	}
+	if report.Events > 0 {
+		logger.Log.Info("Sequences: {first}-{last}", report.FirstSeq, report.LastSeq)
+		logger.Log.Info("Last hash: {hash}", report.LastHash)
+	}
+	for _, gap := range report.Gaps {
+		logger.Log.Warn("  Missing records {first}-{last}", gap.First, gap.Last)
+	}
+	for _, segment := range report.Segments {
+		if segment.Seal != "" {
+			logger.Log.Info("  {segment}: records {first}-{last}, sealed as {seal}",
+				filepath.Base(segment.Path), segment.FirstSeq, segment.LastSeq, segment.Seal)
+			continue
+		}
+		logger.Log.Info("  {segment}: records {first}-{last}", filepath.Base(segment.Path), segment.FirstSeq, segment.LastSeq)
+	}
+	for _, check := range report.Checks {
+		if check.Matched {
+			logger.Log.Info("  ✅ {kind} {name}", check.Kind, check.Name)
+			continue
+		}
+		logger.Log.Error("  ❌ {kind} {name}: {error}", check.Kind, check.Name, check.Error)
+	}
+}
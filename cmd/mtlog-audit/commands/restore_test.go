@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	audit "github.com/willibrandon/mtlog-audit"
+	"github.com/willibrandon/mtlog-audit/backends"
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/wal"
+	"github.com/willibrandon/mtlog/core"
+)
+
+func TestRestoreCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "lost", "audit.wal")
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := wal.New(walPath, wal.WithSegmentSize(4096), wal.WithSealing(signer))
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	backend, err := backends.NewFilesystemBackend(backends.FilesystemConfig{Path: filepath.Join(tmpDir, "events")})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+
+	for i := 0; i < 30; i++ {
+		event := &core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Payment {id} processed",
+			Properties: map[string]any{
+				"id":     i,
+				"filler": strings.Repeat("x", 200),
+			},
+		}
+		if err := w.Write(event); err != nil {
+			t.Fatalf("Failed to write event: %v", err)
+		}
+		if err := backend.Write(event); err != nil {
+			t.Fatalf("Failed to write event to backend: %v", err)
+		}
+	}
+
+	// The sink mirrors its high-water mark to the backend
+	seq, hash, err := w.SyncedHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := w.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mark, err := compliance.SignHighWaterMark(signer, id, seq, hash, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(mark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.PutMetadata(audit.HighWaterMarkObject("audit.wal", id), data); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	_ = backend.Close()
+
+	publicKey, err := compliance.MarshalPublicKey(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPath := filepath.Join(tmpDir, "signing.pub")
+	if err := os.WriteFile(publicKeyPath, publicKey, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(tmpDir, "backend.json")
+	if err := os.WriteFile(config, []byte(`{"type": "filesystem", "path": "`+filepath.Join(tmpDir, "events")+`"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(tmpDir, "restored")
+	cmd := restoreCmd()
+	cmd.SetArgs([]string{
+		"--backend", config,
+		"--output", output,
+		"--name", "audit.wal",
+		"--wal-id", id,
+		"--seals", walPath + ".seals",
+		"--public-key", publicKeyPath,
+	})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(output, "restore-report.json"))
+	if err != nil {
+		t.Fatalf("Failed to read restore report: %v", err)
+	}
+	var report wal.RestoreReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Invalid restore report: %v", err)
+	}
+	if !report.Verified || report.Events != 30 || report.Checks[len(report.Checks)-1].Kind != wal.RestoreCheckHighWaterMark {
+		t.Errorf("Expected a verified restore checked against the high-water mark, got %+v", report)
+	}
+
+	restored, err := wal.New(filepath.Join(output, "audit.wal"))
+	if err != nil {
+		t.Fatalf("Failed to open restored WAL: %v", err)
+	}
+	defer func() { _ = restored.Close() }()
+	if err := restored.VerifyIntegrity(); err != nil {
+		t.Errorf("Restored WAL failed integrity check: %v", err)
+	}
+}
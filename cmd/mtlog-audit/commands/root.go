@@ -28,11 +28,19 @@ func Execute(v string) error {
 		verifyCmd(),
 		tortureCmd(),
 		recoverCmd(),
+		repairCmd(),
+		restoreCmd(),
+		reconcileCmd(),
 		replayCmd(),
 		monitorCmd(),
 		exportCmd(),
 		compactCmd(),
 		statsCmd(),
+		keysCmd(),
+		proveCmd(),
+		checkpointCmd(),
+		consistencyCmd(),
+		witnessCmd(),
 	)
 
 	return rootCmd.Execute()
@@ -16,34 +16,39 @@ import (
 
 // WALStats contains comprehensive WAL statistics.
 type WALStats struct {
-	FirstEventTime   time.Time      `json:"first_event_time"`
-	CreatedAt        time.Time      `json:"created_at"`
-	ModifiedAt       time.Time      `json:"modified_at"`
-	LastEventTime    time.Time      `json:"last_event_time"`
-	Compression      string         `json:"compression"`
-	Path             string         `json:"path"`
-	Duration         string         `json:"duration"`
-	Segments         []SegmentStats `json:"segments"`
-	TotalRecords     int            `json:"total_records"`
-	SegmentCount     int            `json:"segment_count"`
-	InfoCount        int            `json:"info_count"`
-	DebugCount       int            `json:"debug_count"`
-	ErrorCount       int            `json:"error_count"`
-	LastSequence     uint64         `json:"last_sequence"`
-	FirstSequence    uint64         `json:"first_sequence"`
-	WarningCount     int            `json:"warning_count"`
-	FragmentationPct float64        `json:"fragmentation_pct"`
-	AvgSegmentSize   int64          `json:"avg_segment_size"`
-	TotalSize        int64          `json:"total_size"`
-	AvgRecordSize    int64          `json:"avg_record_size"`
-	IsSealed         bool           `json:"is_sealed"`
-	HasCorruption    bool           `json:"has_corruption"`
+	FirstEventTime   time.Time           `json:"first_event_time"`
+	CreatedAt        time.Time           `json:"created_at"`
+	ModifiedAt       time.Time           `json:"modified_at"`
+	LastEventTime    time.Time           `json:"last_event_time"`
+	Compression      string              `json:"compression"`
+	Path             string              `json:"path"`
+	Duration         string              `json:"duration"`
+	Segments         []SegmentStats      `json:"segments"`
+	Metadata         *wal.MetadataRecord `json:"metadata,omitempty"`
+	MetadataChanges  int                 `json:"metadata_changes"`
+	TotalRecords     int                 `json:"total_records"`
+	SegmentCount     int                 `json:"segment_count"`
+	InfoCount        int                 `json:"info_count"`
+	DebugCount       int                 `json:"debug_count"`
+	ErrorCount       int                 `json:"error_count"`
+	LastSequence     uint64              `json:"last_sequence"`
+	FirstSequence    uint64              `json:"first_sequence"`
+	WarningCount     int                 `json:"warning_count"`
+	FragmentationPct float64             `json:"fragmentation_pct"`
+	AvgSegmentSize   int64               `json:"avg_segment_size"`
+	TotalSize        int64               `json:"total_size"`
+	AvgRecordSize    int64               `json:"avg_record_size"`
+	IsSealed         bool                `json:"is_sealed"`
+	HasGenesis       bool                `json:"has_genesis"`
+	HasCorruption    bool                `json:"has_corruption"`
 }
 
 // SegmentStats contains statistics for a single segment.
 type SegmentStats struct {
 	CreatedAt     time.Time `json:"created_at"`
 	Path          string    `json:"path"`
+	Format        string    `json:"format"`
+	Codec         string    `json:"codec"`
 	Size          int64     `json:"size"`
 	StartSeq      uint64    `json:"start_seq"`
 	EndSeq        uint64    `json:"end_seq"`
@@ -117,6 +122,17 @@ func gatherStats(walPath string, includeSegments bool) (*WALStats, error) {
 	}
 	defer func() { _ = w.Close() }()
 
+	// Identify the log from its genesis and metadata records
+	history, err := wal.ReadMetadata(walPath)
+	if err != nil {
+		logger.Log.Warn("Failed to read WAL metadata: {error}", err)
+	}
+	if len(history) > 0 {
+		stats.Metadata = history[len(history)-1]
+		stats.MetadataChanges = len(history) - 1
+		stats.HasGenesis = history[0].Genesis()
+	}
+
 	// Get segments from WAL
 	segments := w.GetSegments()
 
@@ -144,6 +160,8 @@ func gatherStats(walPath string, includeSegments bool) (*WALStats, error) {
 
 			segStats := SegmentStats{
 				Path:        seg.Path,
+				Format:      seg.Format.String(),
+				Codec:       seg.Codec().String(),
 				Size:        seg.Size,
 				StartSeq:    seg.StartSeq,
 				EndSeq:      seg.EndSeq,
@@ -283,6 +301,25 @@ func outputTable(stats *WALStats, verbose bool) error {
 	_, _ = fmt.Fprintf(w, "Modified:\t%s\n", stats.ModifiedAt.Format(time.RFC3339))
 	_, _ = fmt.Fprintln(w)
 
+	// Identity from the genesis and metadata records
+	if m := stats.Metadata; m != nil {
+		_, _ = fmt.Fprintln(w, "IDENTITY")
+		_, _ = fmt.Fprintln(w, "--------")
+		_, _ = fmt.Fprintf(w, "Host:\t%s\n", m.Host)
+		_, _ = fmt.Fprintf(w, "Application:\t%s\n", m.Application)
+		_, _ = fmt.Fprintf(w, "Compliance Profile:\t%s\n", m.ComplianceProfile)
+		_, _ = fmt.Fprintf(w, "Algorithms:\t%s, %s\n", m.HashAlgorithm, m.ChecksumAlgorithm)
+		if m.SigningKey != "" {
+			_, _ = fmt.Fprintf(w, "Signing Key:\t%s %s\n", m.SigningAlgorithm, m.SigningKey)
+		}
+		if len(m.EncryptionKeys) > 0 {
+			_, _ = fmt.Fprintf(w, "Encryption Keys:\t%d\n", len(m.EncryptionKeys))
+		}
+		_, _ = fmt.Fprintf(w, "Genesis:\t%v\n", stats.HasGenesis)
+		_, _ = fmt.Fprintf(w, "Metadata Changes:\t%d\n", stats.MetadataChanges)
+		_, _ = fmt.Fprintln(w)
+	}
+
 	// Record statistics
 	_, _ = fmt.Fprintln(w, "RECORDS")
 	_, _ = fmt.Fprintln(w, "-------")
@@ -323,11 +360,13 @@ func outputTable(stats *WALStats, verbose bool) error {
 	if verbose && len(stats.Segments) > 0 {
 		_, _ = fmt.Fprintln(w, "SEGMENTS")
 		_, _ = fmt.Fprintln(w, "--------")
-		_, _ = fmt.Fprintln(w, "Path\tSize\tRecords\tSeq Range\tSealed\tCompaction%")
+		_, _ = fmt.Fprintln(w, "Path\tFormat\tCodec\tSize\tRecords\tSeq Range\tSealed\tCompaction%")
 
 		for _, seg := range stats.Segments {
-			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d-%d\t%v\t%.1f%%\n",
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d-%d\t%v\t%.1f%%\n",
 				seg.Path,
+				seg.Format,
+				seg.Codec,
 				formatBytes(seg.Size),
 				seg.RecordCount,
 				seg.StartSeq,
@@ -277,3 +277,37 @@ func TestStatsFragmentation(t *testing.T) {
 	t.Logf("Fragmentation: %.1f%% with %d segments",
 		stats.FragmentationPct, stats.SegmentCount)
 }
+
+func TestStatsMetadata(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "test.wal")
+
+	w, err := wal.New(walPath, wal.WithMetadata(wal.Metadata{Host: "host-a", Application: "billing"}))
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		event := &core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Test event {id}",
+			Properties:      map[string]any{"id": i},
+		}
+		if err := w.Write(event); err != nil {
+			t.Fatalf("Failed to write event: %v", err)
+		}
+	}
+	_ = w.Close()
+
+	stats, err := gatherStats(walPath, false)
+	if err != nil {
+		t.Fatalf("Failed to gather stats: %v", err)
+	}
+
+	// The genesis record identifies the log but is not an event
+	if stats.TotalRecords != 5 {
+		t.Errorf("Expected 5 records, got %d", stats.TotalRecords)
+	}
+	if !stats.HasGenesis || stats.Metadata == nil || stats.Metadata.Host != "host-a" || stats.Metadata.Application != "billing" {
+		t.Errorf("Expected the genesis identity, got %+v", stats.Metadata)
+	}
+}
@@ -1,15 +1,30 @@
 package commands
 
 import (
+	"crypto/x509"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	audit "github.com/willibrandon/mtlog-audit"
+	"github.com/willibrandon/mtlog-audit/compliance"
 	"github.com/willibrandon/mtlog-audit/internal/logger"
+	"github.com/willibrandon/mtlog-audit/wal"
 )
 
 func verifyCmd() *cobra.Command {
-	var walPath string
+	var (
+		walPath        string
+		publicKeyPath  string
+		signaturesPath string
+		timestampsPath string
+		tsaRootsPath   string
+		cosigsPath     string
+		witnessKeys    []string
+		witnessQuorum  int
+	)
 
 	cmd := &cobra.Command{
 		Use:   "verify",
@@ -20,7 +35,26 @@ This command checks:
 - CRC32 checksums for corruption detection
 - SHA256 hash chain for tamper detection
 - Record sequence numbers for completeness
-- Magic headers/footers for torn-write detection`,
+- Magic headers/footers for torn-write detection
+
+If the WAL is self-describing, its identity is printed from the genesis
+and metadata records, and a --public-key none of them names is reported.
+
+With --public-key it also verifies the signature log offline: every
+record must carry a valid signature from that key, chained across restarts.
+
+If the WAL has a seal log (<wal>.seals), every completed segment must have
+exactly one seal that still matches it, and no sealed segment may be
+missing. With --public-key the seal signatures are checked too.
+
+If the WAL has an RFC 3161 timestamp log (<wal>.timestamps), every token
+must be a valid timestamp over a tree head the WAL still has. With
+--tsa-roots the timestamp authority must also chain to a trusted
+certificate.
+
+With --witness name=key.pem (repeatable) the cosignature log
+(<wal>.cosignatures) must show at least --witness-quorum of those
+witnesses (default all) cosigned a tree head the WAL still has.`,
 		RunE: func(_ *cobra.Command, _ []string) error {
 			// Create sink to access the WAL
 			sink, err := audit.New(
@@ -61,6 +95,10 @@ This command checks:
 				}
 			}
 
+			if err := verifyMetadata(walPath, publicKeyPath); err != nil {
+				return err
+			}
+
 			if len(report.BackendErrors) > 0 {
 				logger.Log.Error("Backend errors:")
 				for _, err := range report.BackendErrors {
@@ -68,6 +106,57 @@ This command checks:
 				}
 			}
 
+			if publicKeyPath != "" {
+				if signaturesPath == "" {
+					signaturesPath = walPath + ".sig"
+				}
+				sigReport, err := verifySignatures(walPath, signaturesPath, publicKeyPath)
+				if err != nil {
+					return err
+				}
+				if !sigReport.Valid {
+					report.Valid = false
+				}
+			}
+
+			if _, err := os.Stat(walPath + ".seals"); err == nil {
+				sealReport, err := verifySeals(walPath, walPath+".seals", publicKeyPath)
+				if err != nil {
+					return err
+				}
+				if !sealReport.Valid {
+					report.Valid = false
+				}
+			}
+
+			if timestampsPath == "" {
+				if _, err := os.Stat(walPath + ".timestamps"); err == nil {
+					timestampsPath = walPath + ".timestamps"
+				}
+			}
+			if timestampsPath != "" {
+				tsReport, err := verifyTimestamps(walPath, timestampsPath, tsaRootsPath)
+				if err != nil {
+					return err
+				}
+				if !tsReport.Valid {
+					report.Valid = false
+				}
+			}
+
+			if len(witnessKeys) > 0 {
+				if cosigsPath == "" {
+					cosigsPath = walPath + ".cosignatures"
+				}
+				witnessReport, err := verifyCosignatures(walPath, cosigsPath, witnessKeys, witnessQuorum)
+				if err != nil {
+					return err
+				}
+				if !witnessReport.Valid {
+					report.Valid = false
+				}
+			}
+
 			if !report.Valid {
 				return fmt.Errorf("integrity check failed")
 			}
@@ -77,7 +166,247 @@ This command checks:
 	}
 
 	cmd.Flags().StringVar(&walPath, "wal", "/var/audit/app.wal", "Path to WAL file")
+	cmd.Flags().StringVar(&publicKeyPath, "public-key", "", "PEM public key to verify record signatures with")
+	cmd.Flags().StringVar(&signaturesPath, "signatures", "", "Signature log path (default <wal>.sig)")
+	cmd.Flags().StringVar(&timestampsPath, "timestamps", "", "RFC 3161 timestamp log path (default <wal>.timestamps if present)")
+	cmd.Flags().StringVar(&tsaRootsPath, "tsa-roots", "", "PEM certificates of trusted timestamp authorities")
+	cmd.Flags().StringVar(&cosigsPath, "cosignatures", "", "Witness cosignature log path (default <wal>.cosignatures)")
+	cmd.Flags().StringArrayVar(&witnessKeys, "witness", nil, "Trusted witness as name=public-key.pem (repeatable)")
+	cmd.Flags().IntVar(&witnessQuorum, "witness-quorum", 0, "Witnesses that must cosign (default all)")
 	_ = cmd.MarkFlagRequired("wal")
 
 	return cmd
 }
+
+// verifyMetadata prints the identity the WAL's genesis and metadata records
+// carry, warning when the public key is not one of the signing keys they name.
+func verifyMetadata(walPath, publicKeyPath string) error {
+	history, err := wal.ReadMetadata(walPath)
+	if err != nil {
+		return fmt.Errorf("failed to read WAL metadata: %w", err)
+	}
+	if len(history) == 0 {
+		return nil
+	}
+
+	current := history[len(history)-1]
+	logger.Log.Info("")
+	logger.Log.Info("Identity:")
+	logger.Log.Info("  Host: {host}", current.Host)
+	logger.Log.Info("  Application: {application}", current.Application)
+	if current.ComplianceProfile != "" {
+		logger.Log.Info("  Compliance profile: {profile}", current.ComplianceProfile)
+	}
+	logger.Log.Info("  Algorithms: {hash}, {checksum}", current.HashAlgorithm, current.ChecksumAlgorithm)
+	if history[0].Genesis() {
+		logger.Log.Info("  Created: {time}", history[0].Timestamp.Format(time.RFC3339))
+	} else {
+		logger.Log.Warn("  No genesis record; metadata starts at sequence {seq}", history[0].Sequence)
+	}
+	if len(history) > 1 {
+		logger.Log.Info("  Metadata changes: {count}", len(history)-1)
+	}
+
+	if publicKeyPath == "" {
+		return nil
+	}
+	verifier, err := compliance.LoadPublicKey(publicKeyPath)
+	if err != nil {
+		return err
+	}
+	fingerprint, err := compliance.LogID(verifier)
+	if err != nil {
+		return err
+	}
+	var named bool
+	for _, m := range history {
+		if m.SigningKey == "SHA256:"+fingerprint {
+			return nil
+		}
+		named = named || m.SigningKey != ""
+	}
+	if named {
+		logger.Log.Warn("  Public key is not a signing key named in the WAL's metadata")
+	}
+	return nil
+}
+
+// verifySignatures checks the WAL's signature log against a public key and
+// prints the result.
+func verifySignatures(walPath, signaturesPath, publicKeyPath string) (*wal.SignatureReport, error) {
+	verifier, err := compliance.LoadPublicKey(publicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := wal.VerifySignatures(walPath, signaturesPath, verifier)
+	if err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	logger.Log.Info("")
+	logger.Log.Info("Signatures ({algorithm}):", verifier.Algorithm())
+	if report.Valid {
+		logger.Log.Info("  ✅ {signed} records signed", report.Signed)
+		return report, nil
+	}
+
+	logger.Log.Error("  ❌ Signature check FAILED")
+	if report.Error != "" {
+		logger.Log.Error("  Signature log: {error}", report.Error)
+	}
+	logger.Log.Info("  Signed records: {count}", report.Signed)
+	if report.Unsigned > 0 {
+		logger.Log.Error("  Unsigned records: {count}", report.Unsigned)
+	}
+	if report.Mismatched > 0 {
+		logger.Log.Error("  Records not matching their signature: {count}", report.Mismatched)
+	}
+	if report.Orphaned > 0 {
+		logger.Log.Error("  Signatures without a record: {count}", report.Orphaned)
+	}
+	if report.FirstInvalidSequence > 0 {
+		logger.Log.Error("  First invalid sequence: {seq}", report.FirstInvalidSequence)
+	}
+	return report, nil
+}
+
+// verifySeals checks the WAL's segments against their seals and prints the
+// result.
+func verifySeals(walPath, sealsPath, publicKeyPath string) (*wal.SealReport, error) {
+	var verifier compliance.Signer
+	if publicKeyPath != "" {
+		var err error
+		if verifier, err = compliance.LoadPublicKey(publicKeyPath); err != nil {
+			return nil, err
+		}
+	}
+
+	report, err := wal.VerifySeals(walPath, sealsPath, verifier)
+	if err != nil {
+		return nil, fmt.Errorf("seal verification failed: %w", err)
+	}
+
+	logger.Log.Info("")
+	logger.Log.Info("Segment seals:")
+	if report.Valid {
+		logger.Log.Info("  ✅ {sealed} segments sealed", report.Sealed)
+		if verifier == nil {
+			logger.Log.Warn("  Seal signatures not checked; pass --public-key to check them")
+		}
+		return report, nil
+	}
+
+	logger.Log.Error("  ❌ Seal check FAILED")
+	if report.Error != "" {
+		logger.Log.Error("  Seal log: {error}", report.Error)
+	}
+	logger.Log.Info("  Sealed segments: {count}", report.Sealed)
+	if report.Unsealed > 0 {
+		logger.Log.Error("  Unsealed segments: {count}", report.Unsealed)
+	}
+	if report.Resealed > 0 {
+		logger.Log.Error("  Resealed segments: {count}", report.Resealed)
+	}
+	if report.Missing > 0 {
+		logger.Log.Error("  Missing segments: {count}", report.Missing)
+	}
+	if report.Invalid > 0 {
+		logger.Log.Error("  Segments not matching their seal: {count}", report.Invalid)
+	}
+	return report, nil
+}
+
+// verifyTimestamps checks the WAL's RFC 3161 timestamp log and prints the
+// result.
+func verifyTimestamps(walPath, timestampsPath, rootsPath string) (*wal.TimestampReport, error) {
+	var roots *x509.CertPool
+	if rootsPath != "" {
+		data, err := os.ReadFile(rootsPath) // #nosec G304 - user-specified certificate path
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TSA roots: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", rootsPath)
+		}
+	}
+
+	report, err := wal.VerifyTimestamps(walPath, timestampsPath, roots)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp verification failed: %w", err)
+	}
+
+	logger.Log.Info("")
+	logger.Log.Info("Trusted timestamps (RFC 3161):")
+	if report.Valid {
+		logger.Log.Info("  ✅ {verified} tokens verified", report.Verified)
+		if report.CoveredRecords > 0 {
+			logger.Log.Info("  {covered} of {total} records existed by {time}",
+				report.CoveredRecords, report.TotalRecords, report.LatestTime.Format(time.RFC3339))
+		}
+		if roots == nil {
+			logger.Log.Warn("  Timestamp authority not checked; pass --tsa-roots to check it")
+		}
+		return report, nil
+	}
+
+	logger.Log.Error("  ❌ Timestamp check FAILED")
+	if report.Error != "" {
+		logger.Log.Error("  Timestamp log: {error}", report.Error)
+	}
+	logger.Log.Info("  Verified tokens: {count}", report.Verified)
+	if report.Invalid > 0 {
+		logger.Log.Error("  Invalid tokens: {count}", report.Invalid)
+	}
+	if report.Mismatched > 0 {
+		logger.Log.Error("  Tokens over a tree head the WAL no longer has: {count}", report.Mismatched)
+	}
+	return report, nil
+}
+
+// verifyCosignatures checks the WAL's witness cosignature log against the
+// given name=key witnesses and prints the result.
+func verifyCosignatures(walPath, cosigsPath string, witnessKeys []string, quorum int) (*wal.WitnessReport, error) {
+	witnesses := make(map[string]compliance.Signer, len(witnessKeys))
+	for _, spec := range witnessKeys {
+		name, keyPath, ok := strings.Cut(spec, "=")
+		if !ok || name == "" || keyPath == "" {
+			return nil, fmt.Errorf("invalid --witness %q, expected name=public-key.pem", spec)
+		}
+		verifier, err := compliance.LoadPublicKey(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("witness %s: %w", name, err)
+		}
+		witnesses[name] = verifier
+	}
+	if quorum == 0 {
+		quorum = len(witnesses)
+	}
+
+	report, err := wal.VerifyCosignatures(walPath, cosigsPath, witnesses, quorum)
+	if err != nil {
+		return nil, fmt.Errorf("witness verification failed: %w", err)
+	}
+
+	logger.Log.Info("")
+	logger.Log.Info("Witnesses ({quorum} of {count}):", report.Quorum, len(report.Witnesses))
+	if report.Valid {
+		logger.Log.Info("  ✅ {verified} cosignatures verified", report.Verified)
+		logger.Log.Info("  {witnessed} of {total} records witnessed", report.WitnessedRecords, report.TotalRecords)
+		return report, nil
+	}
+
+	logger.Log.Error("  ❌ Witness check FAILED")
+	if report.Error != "" {
+		logger.Log.Error("  Cosignature log: {error}", report.Error)
+	}
+	logger.Log.Info("  Verified cosignatures: {count}", report.Verified)
+	if report.Invalid > 0 {
+		logger.Log.Error("  Invalid cosignatures: {count}", report.Invalid)
+	}
+	if report.Mismatched > 0 {
+		logger.Log.Error("  Cosignatures over a tree head the WAL no longer has: {count}", report.Mismatched)
+	}
+	return report, nil
+}
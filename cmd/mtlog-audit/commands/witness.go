@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+)
+
+// witnessCmd creates the witness command.
+func witnessCmd() *cobra.Command {
+	var (
+		listen    string
+		name      string
+		keyPath   string
+		algorithm string
+		statePath string
+		logKeys   []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "witness",
+		Short: "Run a witness that cosigns checkpoints",
+		Long: `Serve the witness protocol over HTTP.
+
+A witness cosigns a log's checkpoints only after checking the checkpoint's
+signature and a consistency proof from the last tree head it cosigned for
+that log, so a log can never show different histories to different
+witnesses. The last cosigned head of each log is kept in --state.
+
+Sinks publish to witnesses with audit.WithWitnesses, and verify --witness
+checks the cosignatures they return. GET on the listen address returns the
+witness's name and public key.
+
+Examples:
+  # Witness a log, generating the witness key on first run
+  mtlog-audit witness --name witness-a --key /etc/witness/key.pem \
+    --log-key /etc/audit/signing.pub --state /var/witness/state.json`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if len(logKeys) == 0 {
+				return fmt.Errorf("at least one --log-key is required")
+			}
+
+			signer, err := compliance.OpenSigner(keyPath, algorithm)
+			if err != nil {
+				return err
+			}
+			var keys []compliance.Signer
+			for _, path := range logKeys {
+				key, err := compliance.LoadPublicKey(path)
+				if err != nil {
+					return err
+				}
+				keys = append(keys, key)
+			}
+
+			witness, err := compliance.NewWitness(name, signer, statePath, keys...)
+			if err != nil {
+				return err
+			}
+
+			server := &http.Server{
+				Addr:              listen,
+				Handler:           witness,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			errCh := make(chan error, 1)
+			go func() { errCh <- server.ListenAndServe() }()
+
+			logger.Log.Info("Witness {name} ({algorithm}) listening on {address} for {count} logs",
+				name, signer.Algorithm(), listen, len(keys))
+
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+			select {
+			case err := <-errCh:
+				return fmt.Errorf("witness server failed: %w", err)
+			case sig := <-sigCh:
+				logger.Log.Info("Received signal: {signal}", sig)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("failed to stop witness: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", ":8420", "Address to serve the witness protocol on")
+	cmd.Flags().StringVar(&name, "name", "", "Witness name recorded in its cosignatures")
+	cmd.Flags().StringVar(&keyPath, "key", "", "PEM private key to cosign with (generated if missing)")
+	cmd.Flags().StringVar(&algorithm, "algorithm", "Ed25519", "Algorithm for a generated key: Ed25519 or RSA")
+	cmd.Flags().StringVar(&statePath, "state", "", "File recording the last tree head cosigned for each log")
+	cmd.Flags().StringArrayVar(&logKeys, "log-key", nil, "PEM public key of a log to witness (repeatable)")
+	_ = cmd.MarkFlagRequired("name")
+	_ = cmd.MarkFlagRequired("key")
+	_ = cmd.MarkFlagRequired("state")
+
+	return cmd
+}
@@ -0,0 +1,93 @@
+package compliance
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Checkpoint is a signed statement of a log's Merkle tree size and root hash
+// at a point in time. A consistency proof between two checkpoints shows the
+// log only grew by appending in between.
+type Checkpoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	RootHash  string    `json:"root_hash"`
+	Algorithm string    `json:"algorithm"`
+	Signature []byte    `json:"signature"`
+	TreeSize  uint64    `json:"tree_size"`
+}
+
+// SignCheckpoint creates a checkpoint for the tree of size with root, signed
+// by signer.
+func SignCheckpoint(signer Signer, size uint64, root [32]byte, timestamp time.Time) (*Checkpoint, error) {
+	cp := &Checkpoint{
+		Timestamp: timestamp.UTC(),
+		RootHash:  hex.EncodeToString(root[:]),
+		Algorithm: signer.Algorithm(),
+		TreeSize:  size,
+	}
+
+	signature, err := signer.Sign(cp.signedData())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign checkpoint: %w", err)
+	}
+	cp.Signature = signature
+	return cp, nil
+}
+
+// Verify checks the checkpoint's signature with verifier, which needs only
+// the public key.
+func (c *Checkpoint) Verify(verifier Signer) error {
+	if c.Algorithm != verifier.Algorithm() {
+		return fmt.Errorf("checkpoint signed with %s, not %s", c.Algorithm, verifier.Algorithm())
+	}
+	if err := verifier.Verify(c.signedData(), c.Signature); err != nil {
+		return fmt.Errorf("checkpoint at size %d: %w", c.TreeSize, err)
+	}
+	return nil
+}
+
+// Root returns the decoded root hash.
+func (c *Checkpoint) Root() ([32]byte, error) {
+	var root [32]byte
+	decoded, err := hex.DecodeString(c.RootHash)
+	if err != nil || len(decoded) != len(root) {
+		return root, fmt.Errorf("invalid checkpoint root hash %q", c.RootHash)
+	}
+	copy(root[:], decoded)
+	return root, nil
+}
+
+// signedData is the text a checkpoint signature covers.
+func (c *Checkpoint) signedData() []byte {
+	return []byte(fmt.Sprintf("mtlog-audit checkpoint v1\n%d\n%s\n%s\n",
+		c.TreeSize, c.RootHash, c.Timestamp.UTC().Format(time.RFC3339Nano)))
+}
+
+// AppendCheckpoint durably appends a checkpoint to the log at path as one
+// JSON line, creating the log if needed.
+func AppendCheckpoint(path string, cp *Checkpoint) error {
+	if err := appendJSONLine(path, cp); err != nil {
+		return fmt.Errorf("failed to append checkpoint: %w", err)
+	}
+	return nil
+}
+
+// ReadCheckpoints reads every checkpoint in the log at path, oldest first.
+// An unterminated final line is a torn append and is ignored.
+func ReadCheckpoints(path string) ([]*Checkpoint, error) {
+	var checkpoints []*Checkpoint
+	err := readJSONLines(path, func(line []byte) error {
+		var cp Checkpoint
+		if err := json.Unmarshal(line, &cp); err != nil {
+			return err
+		}
+		checkpoints = append(checkpoints, &cp)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint log: %w", err)
+	}
+	return checkpoints, nil
+}
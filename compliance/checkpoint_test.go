@@ -0,0 +1,84 @@
+package compliance
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckpointSigning(t *testing.T) {
+	signer, err := NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.wal.checkpoints")
+	for size := uint64(1); size <= 3; size++ {
+		cp, err := SignCheckpoint(signer, size*10, sha256.Sum256([]byte{byte(size)}), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AppendCheckpoint(path, cp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A torn final append is ignored
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.WriteString(`{"timestamp":"20`)
+	_ = file.Close()
+
+	checkpoints, err := ReadCheckpoints(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoints) != 3 {
+		t.Fatalf("Expected 3 checkpoints, got %d", len(checkpoints))
+	}
+
+	// Checkpoints verify with only the public key
+	pemKey, err := MarshalPublicKey(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := ParsePublicKey(pemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cp := range checkpoints {
+		if err := cp.Verify(verifier); err != nil {
+			t.Errorf("Checkpoint at size %d failed to verify: %v", cp.TreeSize, err)
+		}
+		if err := cp.Verify(other); err == nil {
+			t.Errorf("Checkpoint at size %d verified with the wrong key", cp.TreeSize)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(cp *Checkpoint)
+	}{
+		{"tree size", func(cp *Checkpoint) { cp.TreeSize++ }},
+		{"root hash", func(cp *Checkpoint) { cp.RootHash = strings.Repeat("ab", 32) }},
+		{"timestamp", func(cp *Checkpoint) { cp.Timestamp = cp.Timestamp.Add(time.Second) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := *checkpoints[0]
+			tt.tamper(&cp)
+			if err := cp.Verify(verifier); err == nil {
+				t.Error("Tampered checkpoint verified")
+			}
+		})
+	}
+}
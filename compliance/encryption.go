@@ -7,7 +7,9 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
+	"time"
 
 	"golang.org/x/crypto/chacha20poly1305"
 	"golang.org/x/crypto/scrypt"
@@ -210,28 +212,20 @@ type EncryptedRecord struct {
 // KeyManager manages encryption keys with rotation support
 type KeyManager struct {
 	encryptor   Encryptor
+	store       *keyringStore
 	keys        map[string][]byte
+	info        map[string]*KeyInfo
 	currentID   string
 	currentKey  []byte
 	rotateAfter int64
+	maxAge      time.Duration
 	counter     int64
 	mu          sync.RWMutex
 }
 
 // NewKeyManager creates a new key manager
 func NewKeyManager(initialKey []byte, algorithm string) (*KeyManager, error) {
-	var encryptor Encryptor
-	var err error
-
-	switch algorithm {
-	case "AES-256-GCM":
-		encryptor, err = NewAESGCMEncryptor(initialKey)
-	case "ChaCha20-Poly1305":
-		encryptor, err = NewChaCha20Poly1305Encryptor(initialKey)
-	default:
-		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
-	}
-
+	encryptor, err := newEncryptor(initialKey, algorithm)
 	if err != nil {
 		return nil, err
 	}
@@ -242,6 +236,7 @@ func NewKeyManager(initialKey []byte, algorithm string) (*KeyManager, error) {
 		currentKey:  initialKey,
 		currentID:   keyID,
 		keys:        map[string][]byte{keyID: initialKey},
+		info:        map[string]*KeyInfo{keyID: newKeyInfo(initialKey, algorithm)},
 		encryptor:   encryptor,
 		rotateAfter: 1000000, // Rotate after 1M encryptions
 	}, nil
@@ -253,27 +248,45 @@ func (km *KeyManager) Encrypt(plaintext []byte) (*EncryptedRecord, error) {
 	defer km.mu.Unlock()
 
 	// Check if rotation is needed
-	km.counter++
-	if km.counter >= km.rotateAfter {
+	if km.counter >= km.rotateAfter || km.expired() {
 		if err := km.rotateKey(); err != nil {
 			return nil, fmt.Errorf("key rotation failed: %w", err)
 		}
 	}
+	km.counter++
 
 	ciphertext, err := km.encryptor.Encrypt(plaintext)
 	if err != nil {
 		return nil, err
 	}
 
-	return &EncryptedRecord{
+	// Checkpoint the usage count so rotation by count survives restarts
+	if km.store != nil && km.counter%keyringCheckpointInterval == 0 {
+		_ = km.save() // Best effort; the count is re-saved at the next checkpoint
+	}
+
+	record := &EncryptedRecord{
 		Algorithm:  km.encryptor.Algorithm(),
 		Ciphertext: ciphertext,
 		KeyID:      km.currentID,
-	}, nil
+	}
+
+	// Envelope encryption: carry the wrapped data key with the ciphertext
+	if km.store != nil {
+		record.WrappedKey = km.store.wrapped[km.currentID]
+	}
+
+	return record, nil
 }
 
-// Decrypt decrypts data using the appropriate key
+// Decrypt decrypts data using the appropriate key. A key missing from the
+// keyring is recovered from the record's wrapped key when the keyring's
+// provider can unwrap it.
 func (km *KeyManager) Decrypt(record *EncryptedRecord) ([]byte, error) {
+	if err := km.importWrappedKey(record); err != nil {
+		return nil, err
+	}
+
 	km.mu.RLock()
 	defer km.mu.RUnlock()
 
@@ -284,19 +297,10 @@ func (km *KeyManager) Decrypt(record *EncryptedRecord) ([]byte, error) {
 	}
 
 	// Create encryptor for this key if different from current
-	var encryptor Encryptor
-	if record.KeyID == km.currentID {
-		encryptor = km.encryptor
-	} else {
+	encryptor := km.encryptor
+	if record.KeyID != km.currentID {
 		var err error
-		switch record.Algorithm {
-		case "AES-256-GCM":
-			encryptor, err = NewAESGCMEncryptor(key)
-		case "ChaCha20-Poly1305":
-			encryptor, err = NewChaCha20Poly1305Encryptor(key)
-		default:
-			return nil, fmt.Errorf("unsupported algorithm: %s", record.Algorithm)
-		}
+		encryptor, err = newEncryptor(key, record.Algorithm)
 		if err != nil {
 			return nil, err
 		}
@@ -305,37 +309,134 @@ func (km *KeyManager) Decrypt(record *EncryptedRecord) ([]byte, error) {
 	return encryptor.Decrypt(record.Ciphertext)
 }
 
-// rotateKey generates a new key and updates the current key
-func (km *KeyManager) rotateKey() error {
-	newKey, err := GenerateKey(256)
+// importWrappedKey adds the record's data key to the keyring if it is missing
+// and the record carries a wrapped copy.
+func (km *KeyManager) importWrappedKey(record *EncryptedRecord) error {
+	km.mu.RLock()
+	_, exists := km.keys[record.KeyID]
+	km.mu.RUnlock()
+	if exists || km.store == nil || len(record.WrappedKey) == 0 {
+		return nil
+	}
+
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	if _, exists := km.keys[record.KeyID]; exists {
+		return nil
+	}
+
+	key, err := km.store.unwrap(record.KeyID, record.WrappedKey)
 	if err != nil {
 		return err
 	}
 
-	var newEncryptor Encryptor
-	switch km.encryptor.Algorithm() {
-	case "AES-256-GCM":
-		newEncryptor, err = NewAESGCMEncryptor(newKey)
-	case "ChaCha20-Poly1305":
-		newEncryptor, err = NewChaCha20Poly1305Encryptor(newKey)
-	default:
-		return fmt.Errorf("unsupported algorithm: %s", km.encryptor.Algorithm())
+	info := newKeyInfo(key, record.Algorithm)
+	now := time.Now()
+	info.Retired = &now
+	km.keys[record.KeyID] = key
+	km.info[record.KeyID] = info
+	return nil
+}
+
+// Rotate retires the current key and starts encrypting with a new one.
+// Retired keys remain available for decryption.
+func (km *KeyManager) Rotate() error {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	return km.rotateKey()
+}
+
+// Fingerprints lists every key in the keyring, oldest first, for audits.
+// Key material is never included.
+func (km *KeyManager) Fingerprints() []KeyInfo {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	list := make([]KeyInfo, 0, len(km.info))
+	for id, info := range km.info {
+		entry := *info
+		entry.Active = id == km.currentID
+		if entry.Active {
+			entry.Records = km.counter
+		}
+		list = append(list, entry)
 	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+// expired reports whether the current key has outlived the maximum key age.
+func (km *KeyManager) expired() bool {
+	if km.maxAge <= 0 {
+		return false
+	}
+	info := km.info[km.currentID]
+	return info != nil && time.Since(info.Created) >= km.maxAge
+}
 
+// rotateKey generates a new key and updates the current key. A persistent
+// keyring saves the new key before it is used; if that fails, the current key
+// stays in use.
+func (km *KeyManager) rotateKey() error {
+	newKey, err := GenerateKey(256)
+	if err != nil {
+		return err
+	}
+
+	algorithm := km.encryptor.Algorithm()
+	newEncryptor, err := newEncryptor(newKey, algorithm)
 	if err != nil {
 		return err
 	}
 
 	keyID := generateKeyID(newKey)
+	previousKey, previousID := km.currentKey, km.currentID
+	previousEncryptor, previousCounter := km.encryptor, km.counter
+	retired := km.info[km.currentID]
+
+	now := time.Now()
+	if retired != nil {
+		retired.Records = km.counter
+		retired.Retired = &now
+	}
+
+	km.keys[keyID] = newKey
+	km.info[keyID] = newKeyInfo(newKey, algorithm)
 	km.currentKey = newKey
 	km.currentID = keyID
-	km.keys[keyID] = newKey
 	km.encryptor = newEncryptor
 	km.counter = 0
 
+	if km.store != nil {
+		if err := km.save(); err != nil {
+			delete(km.keys, keyID)
+			delete(km.info, keyID)
+			delete(km.store.wrapped, keyID)
+			if retired != nil {
+				retired.Retired = nil
+			}
+			km.currentKey, km.currentID = previousKey, previousID
+			km.encryptor, km.counter = previousEncryptor, previousCounter
+			return fmt.Errorf("failed to persist rotated key: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// newEncryptor creates an encryptor for the named algorithm
+func newEncryptor(key []byte, algorithm string) (Encryptor, error) {
+	switch algorithm {
+	case "AES-256-GCM":
+		return NewAESGCMEncryptor(key)
+	case "ChaCha20-Poly1305":
+		return NewChaCha20Poly1305Encryptor(key)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+}
+
 // generateKeyID generates a unique ID for a key
 func generateKeyID(key []byte) string {
 	hash := sha256.Sum256(key)
@@ -18,6 +18,7 @@ type Engine struct {
 	signer          Signer
 	keyManager      *KeyManager
 	signatureChain  *SignatureChain
+	signatureLog    string
 	profile         Profile
 	sequence        uint64
 	mu              sync.RWMutex
@@ -46,7 +47,7 @@ func New(profileName string, opts ...Option) (*Engine, error) {
 	}
 
 	// Initialize encryption if required
-	if profile.EncryptionRequired && engine.encryptor == nil {
+	if profile.EncryptionRequired && engine.keyManager == nil {
 		key, err := GenerateKey(256)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate encryption key: %w", err)
@@ -81,6 +82,15 @@ func New(profileName string, opts ...Option) (*Engine, error) {
 		engine.signatureChain = NewSignatureChain(signer)
 	}
 
+	// Persist the signature chain if requested
+	if engine.signer != nil && engine.signatureLog != "" {
+		chain, err := OpenSignatureChain(engine.signatureLog, engine.signer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open signature log: %w", err)
+		}
+		engine.signatureChain = chain
+	}
+
 	return engine, nil
 }
 
@@ -103,6 +113,21 @@ func WithEncryptionKey(key []byte) Option {
 	}
 }
 
+// WithKeyManager uses an existing key manager, such as a persistent one from
+// OpenKeyManager, so encrypted data stays readable across restarts.
+func WithKeyManager(keyManager *KeyManager) Option {
+	return func(e *Engine) error {
+		if keyManager == nil {
+			return fmt.Errorf("key manager cannot be nil")
+		}
+		if !e.profile.EncryptionRequired {
+			return nil // Ignore if not required
+		}
+		e.keyManager = keyManager
+		return nil
+	}
+}
+
 // WithSigner sets a specific signer
 func WithSigner(signer Signer) Option {
 	return func(e *Engine) error {
@@ -116,6 +141,22 @@ func WithSigner(signer Signer) Option {
 	}
 }
 
+// WithSignatureLog persists the signature chain to the log at path so it can
+// be verified across restarts. The signer must stay the same between runs, so
+// pair it with WithSigner and a key from OpenSigner.
+func WithSignatureLog(path string) Option {
+	return func(e *Engine) error {
+		if path == "" {
+			return fmt.Errorf("signature log path cannot be empty")
+		}
+		if !e.profile.SigningRequired {
+			return nil // Ignore if not required
+		}
+		e.signatureLog = path
+		return nil
+	}
+}
+
 // WithMaskingDisabled disables sensitive data masking
 func WithMaskingDisabled() Option {
 	return func(e *Engine) error {
@@ -208,6 +249,48 @@ func (e *Engine) ProcessForStorage(event *core.LogEvent) (*ComplianceRecord, err
 	return record, nil
 }
 
+// KeyManager returns the engine's key manager, or nil when the profile does
+// not require encryption.
+func (e *Engine) KeyManager() *KeyManager {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.keyManager
+}
+
+// SignatureChain returns the engine's signature chain, or nil when the
+// profile does not require signing.
+func (e *Engine) SignatureChain() *SignatureChain {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.signatureChain
+}
+
+// Signer returns the engine's signer, or nil when the profile does not
+// require signing.
+func (e *Engine) Signer() Signer {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.signer
+}
+
+// Close closes the signature log, if any, and the key manager, saving a
+// persistent key's usage count.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var firstErr error
+	if e.signatureChain != nil {
+		firstErr = e.signatureChain.Close()
+	}
+	if e.keyManager != nil {
+		if err := e.keyManager.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close key manager: %w", err)
+		}
+	}
+	return firstErr
+}
+
 // VerifyRecord verifies a compliance record
 func (e *Engine) VerifyRecord(record *ComplianceRecord) error {
 	e.mu.RLock()
@@ -274,8 +357,8 @@ func (e *Engine) VerifyChain() (*ChainVerificationReport, error) {
 		report.Error = err.Error()
 	} else {
 		report.Valid = true
-		report.TotalSignatures = len(e.signatureChain.signatures)
-		report.LastSequence = e.sequence
+		report.TotalSignatures = e.signatureChain.Len()
+		report.LastSequence = e.signatureChain.LastSequence()
 	}
 
 	return report, nil
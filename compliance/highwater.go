@@ -0,0 +1,80 @@
+package compliance
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// HighWaterMark is a signed statement of the latest record a log had written:
+// its sequence and chain hash. A log found behind its high-water mark, or
+// with a different record at that sequence, was truncated or rolled back.
+// Log identifies the log the mark belongs to, so a mark can't be presented
+// for another one.
+type HighWaterMark struct {
+	Timestamp time.Time `json:"timestamp"`
+	Log       string    `json:"log"`
+	Hash      string    `json:"hash"`
+	Algorithm string    `json:"algorithm"`
+	Signature []byte    `json:"signature"`
+	Sequence  uint64    `json:"sequence"`
+}
+
+// SignHighWaterMark creates a high-water mark for the record of log with
+// sequence and chain hash, signed by signer.
+func SignHighWaterMark(signer Signer, log string, sequence uint64, hash [32]byte, timestamp time.Time) (*HighWaterMark, error) {
+	mark := &HighWaterMark{
+		Timestamp: timestamp.UTC(),
+		Log:       log,
+		Hash:      hex.EncodeToString(hash[:]),
+		Algorithm: signer.Algorithm(),
+		Sequence:  sequence,
+	}
+
+	signature, err := signer.Sign(mark.signedData())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign high-water mark: %w", err)
+	}
+	mark.Signature = signature
+	return mark, nil
+}
+
+// Verify checks the high-water mark's signature with verifier.
+func (m *HighWaterMark) Verify(verifier Signer) error {
+	if m.Algorithm != verifier.Algorithm() {
+		return fmt.Errorf("high-water mark signed with %s, not %s", m.Algorithm, verifier.Algorithm())
+	}
+	if err := verifier.Verify(m.signedData(), m.Signature); err != nil {
+		return fmt.Errorf("high-water mark at sequence %d: %w", m.Sequence, err)
+	}
+	return nil
+}
+
+// signedData is the text a high-water mark signature covers.
+func (m *HighWaterMark) signedData() []byte {
+	return []byte(fmt.Sprintf("mtlog-audit high-water mark v1\n%s\n%d\n%s\n%s\n",
+		m.Log, m.Sequence, m.Hash, m.Timestamp.UTC().Format(time.RFC3339Nano)))
+}
+
+// WriteHighWaterMark atomically replaces the high-water mark at path.
+func WriteHighWaterMark(path string, mark *HighWaterMark) error {
+	if err := writeJSONFile(path, mark); err != nil {
+		return fmt.Errorf("failed to write high-water mark: %w", err)
+	}
+	return nil
+}
+
+// ReadHighWaterMark reads the high-water mark at path.
+func ReadHighWaterMark(path string) (*HighWaterMark, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - high-water mark path from user configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read high-water mark: %w", err)
+	}
+	var mark HighWaterMark
+	if err := json.Unmarshal(data, &mark); err != nil {
+		return nil, fmt.Errorf("failed to parse high-water mark: %w", err)
+	}
+	return &mark, nil
+}
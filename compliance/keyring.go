@@ -0,0 +1,410 @@
+package compliance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// keyringCheckpointInterval is how many encryptions pass between saves of the
+// current key's usage count.
+const keyringCheckpointInterval = 1000
+
+// keyringVersion is the keyring file format version.
+const keyringVersion = 1
+
+// KeyInfo describes a key for audits. It never holds key material.
+type KeyInfo struct {
+	Created     time.Time  `json:"created"`
+	Retired     *time.Time `json:"retired,omitempty"`
+	ID          string     `json:"id"`
+	Algorithm   string     `json:"algorithm"`
+	Fingerprint string     `json:"fingerprint"`
+	Records     int64      `json:"records"`
+	Active      bool       `json:"active"`
+}
+
+// newKeyInfo describes a freshly created key.
+func newKeyInfo(key []byte, algorithm string) *KeyInfo {
+	sum := sha256.Sum256(key)
+	return &KeyInfo{
+		ID:          generateKeyID(key),
+		Algorithm:   algorithm,
+		Fingerprint: "SHA256:" + hex.EncodeToString(sum[:]),
+		Created:     time.Now(),
+	}
+}
+
+// keyringFile is the on-disk keyring. Data keys are stored wrapped with a
+// key-encryption key (KEK) that is supplied directly, derived from a
+// passphrase with scrypt, or held by a KeyProvider.
+type keyringFile struct {
+	KDF       string         `json:"kdf"`
+	Provider  string         `json:"provider,omitempty"`
+	Algorithm string         `json:"algorithm"`
+	Current   string         `json:"current"`
+	Salt      []byte         `json:"salt,omitempty"`
+	Keys      []keyringEntry `json:"keys"`
+	Version   int            `json:"version"`
+}
+
+// keyringEntry is one wrapped data key.
+type keyringEntry struct {
+	KeyInfo
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// keyringStore persists a KeyManager's keys.
+type keyringStore struct {
+	provider KeyProvider
+	wrapped  map[string][]byte
+	path     string
+	kdf      string
+	salt     []byte
+}
+
+// KeyringOption configures a persistent key manager.
+type KeyringOption func(*keyringConfig) error
+
+type keyringConfig struct {
+	provider    KeyProvider
+	passphrase  []byte
+	kek         []byte
+	algorithm   string
+	rotateAfter int64
+	maxAge      time.Duration
+}
+
+// WithPassphrase protects the keyring with a key derived from passphrase.
+func WithPassphrase(passphrase []byte) KeyringOption {
+	return func(c *keyringConfig) error {
+		if len(passphrase) == 0 {
+			return fmt.Errorf("passphrase cannot be empty")
+		}
+		c.passphrase = passphrase
+		return nil
+	}
+}
+
+// WithKEK protects the keyring with a 32-byte key-encryption key.
+func WithKEK(kek []byte) KeyringOption {
+	return func(c *keyringConfig) error {
+		if len(kek) != 32 {
+			return fmt.Errorf("KEK must be 32 bytes, got %d bytes", len(kek))
+		}
+		c.kek = kek
+		return nil
+	}
+}
+
+// WithKeyAlgorithm sets the encryption algorithm for a new keyring.
+// An existing keyring keeps the algorithm it was created with.
+func WithKeyAlgorithm(algorithm string) KeyringOption {
+	return func(c *keyringConfig) error {
+		c.algorithm = algorithm
+		return nil
+	}
+}
+
+// WithRotation rotates the current key after records encryptions or once it
+// is older than maxAge. Zero leaves that limit at its default (1M records, no
+// age limit).
+func WithRotation(records int64, maxAge time.Duration) KeyringOption {
+	return func(c *keyringConfig) error {
+		if records < 0 || maxAge < 0 {
+			return fmt.Errorf("rotation limits cannot be negative")
+		}
+		if records > 0 {
+			c.rotateAfter = records
+		}
+		c.maxAge = maxAge
+		return nil
+	}
+}
+
+// OpenKeyManager opens the keyring at path, creating it with a new key if it
+// does not exist. Keys survive restarts, rotated keys are saved before use and
+// retired keys stay available for decryption. Exactly one of WithPassphrase,
+// WithKEK or WithKeyProvider is required.
+func OpenKeyManager(path string, opts ...KeyringOption) (*KeyManager, error) {
+	cfg := &keyringConfig{
+		algorithm:   "AES-256-GCM",
+		rotateAfter: 1000000,
+	}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+	protections := 0
+	for _, set := range []bool{cfg.passphrase != nil, cfg.kek != nil, cfg.provider != nil} {
+		if set {
+			protections++
+		}
+	}
+	if protections != 1 {
+		return nil, fmt.Errorf("keyring requires exactly one of a passphrase, a KEK or a key provider")
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 - keyring path from user configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return createKeyring(path, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring: %w", err)
+	}
+	if file.Version != keyringVersion {
+		return nil, fmt.Errorf("unsupported keyring version: %d", file.Version)
+	}
+
+	store, err := newKeyringStore(path, cfg, file.KDF, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if file.Provider != "" && file.Provider != store.provider.Name() {
+		return nil, fmt.Errorf("keyring is wrapped by %s, not %s", file.Provider, store.provider.Name())
+	}
+
+	km := &KeyManager{
+		store:       store,
+		keys:        make(map[string][]byte),
+		info:        make(map[string]*KeyInfo),
+		rotateAfter: cfg.rotateAfter,
+		maxAge:      cfg.maxAge,
+	}
+
+	for _, entry := range file.Keys {
+		key, err := store.unwrap(entry.ID, entry.WrappedKey)
+		if err != nil {
+			return nil, err
+		}
+
+		info := entry.KeyInfo
+		info.Active = false
+		km.keys[entry.ID] = key
+		km.info[entry.ID] = &info
+	}
+
+	current, ok := km.keys[file.Current]
+	if !ok {
+		return nil, fmt.Errorf("keyring current key %s not found", file.Current)
+	}
+
+	km.encryptor, err = newEncryptor(current, km.info[file.Current].Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	km.currentID = file.Current
+	km.currentKey = current
+	km.counter = km.info[file.Current].Records
+
+	return km, nil
+}
+
+// createKeyring creates a new keyring file holding one fresh key.
+func createKeyring(path string, cfg *keyringConfig) (*KeyManager, error) {
+	kdf := "kek"
+	var salt []byte
+	switch {
+	case cfg.provider != nil:
+		kdf = "kms"
+	case cfg.passphrase != nil:
+		kdf = "scrypt"
+		var err error
+		if salt, err = GenerateSalt(); err != nil {
+			return nil, err
+		}
+	}
+
+	store, err := newKeyringStore(path, cfg, kdf, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := GenerateKey(256)
+	if err != nil {
+		return nil, err
+	}
+
+	km, err := NewKeyManager(key, cfg.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	km.store = store
+	km.rotateAfter = cfg.rotateAfter
+	km.maxAge = cfg.maxAge
+
+	if err := km.save(); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+// newKeyringStore resolves the key provider for a keyring, deriving a local
+// KEK when the keyring is not protected by an external provider.
+func newKeyringStore(path string, cfg *keyringConfig, kdf string, salt []byte) (*keyringStore, error) {
+	store := &keyringStore{
+		wrapped: make(map[string][]byte),
+		path:    path,
+		kdf:     kdf,
+		salt:    salt,
+	}
+
+	var kek []byte
+	switch kdf {
+	case "kms":
+		if cfg.provider == nil {
+			return nil, fmt.Errorf("keyring is protected by a key provider")
+		}
+		store.provider = cfg.provider
+		return store, nil
+	case "scrypt":
+		if cfg.passphrase == nil {
+			return nil, fmt.Errorf("keyring is protected by a passphrase")
+		}
+		derived, err := DeriveKey(cfg.passphrase, salt, 32)
+		if err != nil {
+			return nil, err
+		}
+		kek = derived
+	case "kek":
+		if cfg.kek == nil {
+			return nil, fmt.Errorf("keyring is protected by a KEK")
+		}
+		kek = cfg.kek
+	default:
+		return nil, fmt.Errorf("unsupported keyring KDF: %s", kdf)
+	}
+
+	wrapper, err := NewAESGCMEncryptor(kek)
+	if err != nil {
+		return nil, err
+	}
+	store.provider = &kekProvider{kek: wrapper}
+	return store, nil
+}
+
+// wrap wraps a data key and caches the result.
+func (s *keyringStore) wrap(keyID string, key []byte) ([]byte, error) {
+	if wrapped, ok := s.wrapped[keyID]; ok {
+		return wrapped, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyProviderTimeout)
+	defer cancel()
+
+	wrapped, err := s.provider.WrapKey(ctx, keyID, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap key %s: %w", keyID, err)
+	}
+	s.wrapped[keyID] = wrapped
+	return wrapped, nil
+}
+
+// unwrap unwraps a data key and checks it matches its ID.
+func (s *keyringStore) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyProviderTimeout)
+	defer cancel()
+
+	key, err := s.provider.UnwrapKey(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap key %s (wrong passphrase or KEK?): %w", keyID, err)
+	}
+	if generateKeyID(key) != keyID {
+		return nil, fmt.Errorf("key %s does not match its ID", keyID)
+	}
+	s.wrapped[keyID] = wrapped
+	return key, nil
+}
+
+// save writes the keyring atomically. Callers must hold km.mu.
+func (km *KeyManager) save() error {
+	store := km.store
+
+	file := keyringFile{
+		Version:   keyringVersion,
+		KDF:       store.kdf,
+		Provider:  store.providerName(),
+		Salt:      store.salt,
+		Algorithm: km.encryptor.Algorithm(),
+		Current:   km.currentID,
+	}
+
+	for id, key := range km.keys {
+		wrapped, err := store.wrap(id, key)
+		if err != nil {
+			return err
+		}
+
+		info := *km.info[id]
+		info.Active = id == km.currentID
+		if info.Active {
+			info.Records = km.counter
+		}
+		file.Keys = append(file.Keys, keyringEntry{KeyInfo: info, WrappedKey: wrapped})
+	}
+	sort.Slice(file.Keys, func(i, j int) bool { return file.Keys[i].Created.Before(file.Keys[j].Created) })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keyring: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(store.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create keyring directory: %w", err)
+	}
+
+	tmpPath := store.path + ".tmp"
+	// #nosec G304 - keyring path from user configuration
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync keyring: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close keyring: %w", err)
+	}
+	if err := os.Rename(tmpPath, store.path); err != nil {
+		return fmt.Errorf("failed to replace keyring: %w", err)
+	}
+	return nil
+}
+
+// Close saves the current key's usage count. It is a no-op for in-memory key
+// managers.
+func (km *KeyManager) Close() error {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	if km.store == nil {
+		return nil
+	}
+	return km.save()
+}
+
+// providerName returns the external provider name recorded in the keyring.
+func (s *keyringStore) providerName() string {
+	if s.kdf != "kms" {
+		return ""
+	}
+	return s.provider.Name()
+}
@@ -0,0 +1,177 @@
+package compliance
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog/core"
+)
+
+func TestKeyringPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	passphrase := []byte("correct horse battery staple")
+
+	km, err := OpenKeyManager(path, WithPassphrase(passphrase))
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+
+	record, err := km.Encrypt([]byte("audit event"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	// The keyring file never holds key material in the clear
+	data, err := os.ReadFile(path) // #nosec G304 - test file path
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, km.currentKey) {
+		t.Fatal("Keyring file contains an unwrapped key")
+	}
+	if err := km.Close(); err != nil {
+		t.Fatalf("Failed to close keyring: %v", err)
+	}
+
+	// After a restart the same key decrypts
+	reopened, err := OpenKeyManager(path, WithPassphrase(passphrase))
+	if err != nil {
+		t.Fatalf("Failed to reopen keyring: %v", err)
+	}
+	plaintext, err := reopened.Decrypt(record)
+	if err != nil {
+		t.Fatalf("Failed to decrypt after reopen: %v", err)
+	}
+	if string(plaintext) != "audit event" {
+		t.Errorf("Decrypted %q", plaintext)
+	}
+	if got := reopened.Fingerprints()[0].Records; got != 1 {
+		t.Errorf("Expected persisted usage count 1, got %d", got)
+	}
+
+	if _, err := OpenKeyManager(path, WithPassphrase([]byte("wrong"))); err == nil {
+		t.Error("Expected wrong passphrase to fail")
+	}
+	if _, err := OpenKeyManager(path, WithKEK(make([]byte, 32))); err == nil {
+		t.Error("Expected KEK to fail on a passphrase keyring")
+	}
+}
+
+func TestEngineCloseSavesKeyUsage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	passphrase := []byte("correct horse battery staple")
+
+	km, err := OpenKeyManager(path, WithPassphrase(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := New("HIPAA", WithKeyManager(km))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := engine.ProcessForStorage(&core.LogEvent{Timestamp: time.Now(), MessageTemplate: "Test event"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := engine.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenKeyManager(path, WithPassphrase(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Fingerprints()[0].Records; got != 3 {
+		t.Errorf("Expected closing the engine to save usage count 3, got %d", got)
+	}
+}
+
+func TestKeyringRotationByCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	kek := []byte("kek-for-tests-32-bytes-exactly!!")
+
+	km, err := OpenKeyManager(path, WithKEK(kek), WithRotation(3, 0))
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+
+	var records []*EncryptedRecord
+	for i := 0; i < 7; i++ {
+		record, err := km.Encrypt([]byte{byte(i)})
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	fingerprints := km.Fingerprints()
+	if len(fingerprints) != 3 {
+		t.Fatalf("Expected 3 keys after rotation, got %d", len(fingerprints))
+	}
+	wantRecords := []int64{3, 3, 1}
+	for i, info := range fingerprints {
+		if info.Records != wantRecords[i] {
+			t.Errorf("Key %d: expected %d records, got %d", i, wantRecords[i], info.Records)
+		}
+		if active := i == len(fingerprints)-1; info.Active != active || (info.Retired == nil) != active {
+			t.Errorf("Key %d: active=%v retired=%v", i, info.Active, info.Retired)
+		}
+	}
+
+	// Retired keys are persisted and still decrypt
+	reopened, err := OpenKeyManager(path, WithKEK(kek))
+	if err != nil {
+		t.Fatalf("Failed to reopen keyring: %v", err)
+	}
+	for i, record := range records {
+		plaintext, err := reopened.Decrypt(record)
+		if err != nil || len(plaintext) != 1 || plaintext[0] != byte(i) {
+			t.Errorf("Record %d: decrypt failed: %v", i, err)
+		}
+	}
+}
+
+func TestKeyringRotationByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	kek := []byte("kek-for-tests-32-bytes-exactly!!")
+
+	km, err := OpenKeyManager(path, WithKEK(kek), WithRotation(0, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+
+	first, err := km.Encrypt([]byte("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	second, err := km.Encrypt([]byte("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.KeyID == second.KeyID {
+		t.Error("Expected the key to rotate once it aged out")
+	}
+	if _, err := km.Decrypt(first); err != nil {
+		t.Errorf("Retired key failed to decrypt: %v", err)
+	}
+}
+
+func TestKeyringRequiresProtection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+
+	if _, err := OpenKeyManager(path); err == nil {
+		t.Error("Expected error without a passphrase or KEK")
+	}
+	if _, err := OpenKeyManager(path, WithPassphrase([]byte("p")), WithKEK(make([]byte, 32))); err == nil {
+		t.Error("Expected error with both a passphrase and a KEK")
+	}
+	if _, err := OpenKeyManager(path, WithKEK([]byte("short"))); err == nil {
+		t.Error("Expected error for a short KEK")
+	}
+}
@@ -0,0 +1,150 @@
+package compliance
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// keyProviderTimeout bounds each call to an external key provider.
+const keyProviderTimeout = 30 * time.Second
+
+// KeyProvider wraps data encryption keys (DEKs) with a key-encryption key
+// (KEK) held outside the keyring, typically in a KMS, so raw DEKs are never
+// stored next to the audit log. keyID is the DEK's ID and is bound to the
+// wrapped key as additional authenticated data where the KMS supports it.
+type KeyProvider interface {
+	WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+	// Name identifies the provider and its KEK, e.g. "aws-kms:<key ARN>".
+	Name() string
+}
+
+// WithKeyProvider protects the keyring by wrapping every data key with
+// provider. The keyring records the provider name and refuses to open with a
+// different one.
+func WithKeyProvider(provider KeyProvider) KeyringOption {
+	return func(c *keyringConfig) error {
+		if provider == nil {
+			return fmt.Errorf("key provider cannot be nil")
+		}
+		c.provider = provider
+		return nil
+	}
+}
+
+// kekProvider wraps data keys with a local KEK, as used by passphrase- and
+// KEK-protected keyrings.
+type kekProvider struct {
+	kek Encryptor
+}
+
+// WrapKey implements KeyProvider.
+func (p *kekProvider) WrapKey(_ context.Context, _ string, dataKey []byte) ([]byte, error) {
+	return p.kek.Encrypt(dataKey)
+}
+
+// UnwrapKey implements KeyProvider.
+func (p *kekProvider) UnwrapKey(_ context.Context, _ string, wrapped []byte) ([]byte, error) {
+	return p.kek.Decrypt(wrapped)
+}
+
+// Name implements KeyProvider.
+func (p *kekProvider) Name() string {
+	return "kek"
+}
+
+// LocalKMS is a file-based KeyProvider. Its master key lives in its own file,
+// which should sit on a different volume, or behind different access
+// controls, than the audit log and keyring.
+type LocalKMS struct {
+	aead cipher.AEAD
+	id   string
+}
+
+// NewLocalKMS opens the master key file at path, creating it with a random
+// 256-bit key if it does not exist.
+func NewLocalKMS(path string) (*LocalKMS, error) {
+	key, err := os.ReadFile(path) // #nosec G304 - master key path from user configuration
+	if errors.Is(err, os.ErrNotExist) {
+		if key, err = GenerateKey(256); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create master key directory: %w", err)
+		}
+		// O_EXCL so a concurrently created master key is never overwritten
+		// #nosec G304 - master key path from user configuration
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create master key: %w", err)
+		}
+		if _, err := file.Write(key); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to write master key: %w", err)
+		}
+		if err := file.Sync(); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to sync master key: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close master key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read master key: %w", err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, got %d bytes", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return &LocalKMS{aead: aead, id: generateKeyID(key)}, nil
+}
+
+// WrapKey implements KeyProvider.
+func (k *LocalKMS) WrapKey(_ context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return k.aead.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+// UnwrapKey implements KeyProvider.
+func (k *LocalKMS) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	nonceSize := k.aead.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+	dataKey, err := k.aead.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap failed: %w", err)
+	}
+	return dataKey, nil
+}
+
+// Name implements KeyProvider.
+func (k *LocalKMS) Name() string {
+	return "local-kms:" + k.id
+}
+
+// keyIDContext is the encryption context binding a wrapped key to its ID.
+func keyIDContext(keyID string) map[string]string {
+	return map[string]string{"mtlog-audit:key-id": keyID}
+}
@@ -0,0 +1,90 @@
+package compliance
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// AzureKeyVaultClient is the subset of the Key Vault keys API used to wrap
+// data keys. A thin shim over the SDK's azkeys.Client WrapKey and UnwrapKey
+// calls satisfies it, as does a local stand-in. An empty version selects the
+// latest key version; WrapKey returns the full key ID (kid) that was used.
+type AzureKeyVaultClient interface {
+	WrapKey(ctx context.Context, name, version, algorithm string, value []byte) (kid string, result []byte, err error)
+	UnwrapKey(ctx context.Context, name, version, algorithm string, value []byte) ([]byte, error)
+}
+
+// AzureKeyVault wraps data keys with a Key Vault key. The key version used is
+// stored with each wrapped key, so wrapped keys still unwrap after the vault
+// key is rotated.
+type AzureKeyVault struct {
+	client    AzureKeyVaultClient
+	name      string
+	algorithm string
+}
+
+// NewAzureKeyVault creates a KeyProvider for the named Key Vault key using
+// algorithm, such as "RSA-OAEP-256" (the default when empty) or "A256KW".
+func NewAzureKeyVault(client AzureKeyVaultClient, name, algorithm string) (*AzureKeyVault, error) {
+	if client == nil {
+		return nil, fmt.Errorf("azure Key Vault client cannot be nil")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("azure Key Vault key name is required")
+	}
+	if algorithm == "" {
+		algorithm = "RSA-OAEP-256"
+	}
+	return &AzureKeyVault{client: client, name: name, algorithm: algorithm}, nil
+}
+
+// WrapKey implements KeyProvider. The result is kidLength(2) + kid + wrapped.
+func (k *AzureKeyVault) WrapKey(ctx context.Context, _ string, dataKey []byte) ([]byte, error) {
+	kid, result, err := k.client.WrapKey(ctx, k.name, "", k.algorithm, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("azure Key Vault wrap failed: %w", err)
+	}
+	if len(kid) > 0xFFFF {
+		return nil, fmt.Errorf("azure Key Vault key ID too long")
+	}
+
+	wrapped := make([]byte, 2, 2+len(kid)+len(result))
+	// #nosec G115 - kid length bounded above
+	binary.LittleEndian.PutUint16(wrapped, uint16(len(kid)))
+	wrapped = append(wrapped, kid...)
+	return append(wrapped, result...), nil
+}
+
+// UnwrapKey implements KeyProvider.
+func (k *AzureKeyVault) UnwrapKey(ctx context.Context, _ string, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 2 {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+	kidEnd := 2 + int(binary.LittleEndian.Uint16(wrapped))
+	if len(wrapped) < kidEnd {
+		return nil, fmt.Errorf("wrapped key truncated in key ID")
+	}
+
+	dataKey, err := k.client.UnwrapKey(ctx, k.name, azureKeyVersion(string(wrapped[2:kidEnd])), k.algorithm, wrapped[kidEnd:])
+	if err != nil {
+		return nil, fmt.Errorf("azure Key Vault unwrap failed: %w", err)
+	}
+	return dataKey, nil
+}
+
+// Name implements KeyProvider.
+func (k *AzureKeyVault) Name() string {
+	return "azure-keyvault:" + k.name
+}
+
+// azureKeyVersion extracts the version from a kid of the form
+// https://<vault>/keys/<name>/<version>.
+func azureKeyVersion(kid string) string {
+	for i := len(kid) - 1; i >= 0; i-- {
+		if kid[i] == '/' {
+			return kid[i+1:]
+		}
+	}
+	return kid
+}
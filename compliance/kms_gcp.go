@@ -0,0 +1,58 @@
+package compliance
+
+import (
+	"context"
+	"fmt"
+)
+
+// GCPKMSClient is the subset of the Cloud KMS API used to wrap data keys. A
+// thin shim over the SDK's KeyManagementClient Encrypt and Decrypt calls
+// satisfies it, as does a local stand-in. name is the CryptoKey resource
+// name; Cloud KMS picks the primary version to encrypt and records it in the
+// ciphertext.
+type GCPKMSClient interface {
+	Encrypt(ctx context.Context, name string, plaintext, additionalAuthenticatedData []byte) ([]byte, error)
+	Decrypt(ctx context.Context, name string, ciphertext, additionalAuthenticatedData []byte) ([]byte, error)
+}
+
+// GCPKMS wraps data keys with a Cloud KMS CryptoKey. The data key ID is passed
+// as additional authenticated data.
+type GCPKMS struct {
+	client GCPKMSClient
+	name   string
+}
+
+// NewGCPKMS creates a KeyProvider for the CryptoKey resource name, of the
+// form projects/*/locations/*/keyRings/*/cryptoKeys/*.
+func NewGCPKMS(client GCPKMSClient, name string) (*GCPKMS, error) {
+	if client == nil {
+		return nil, fmt.Errorf("GCP KMS client cannot be nil")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("GCP KMS key name is required")
+	}
+	return &GCPKMS{client: client, name: name}, nil
+}
+
+// WrapKey implements KeyProvider.
+func (k *GCPKMS) WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	wrapped, err := k.client.Encrypt(ctx, k.name, dataKey, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("GCP KMS encrypt failed: %w", err)
+	}
+	return wrapped, nil
+}
+
+// UnwrapKey implements KeyProvider.
+func (k *GCPKMS) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	dataKey, err := k.client.Decrypt(ctx, k.name, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("GCP KMS decrypt failed: %w", err)
+	}
+	return dataKey, nil
+}
+
+// Name implements KeyProvider.
+func (k *GCPKMS) Name() string {
+	return "gcp-kms:" + k.name
+}
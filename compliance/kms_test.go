@@ -0,0 +1,232 @@
+package compliance
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// standInKMS is an in-process stand-in for a cloud KMS: named keys with
+// numbered versions, authenticated encryption and key-version tagging.
+type standInKMS struct {
+	versions map[string][]cipher.AEAD
+}
+
+func newStandInKMS() *standInKMS {
+	return &standInKMS{versions: make(map[string][]cipher.AEAD)}
+}
+
+// rotate adds a new primary version to the named key.
+func (s *standInKMS) rotate(name string) {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	s.versions[name] = append(s.versions[name], aead)
+}
+
+func (s *standInKMS) seal(name string, plaintext, aad []byte) ([]byte, error) {
+	versions := s.versions[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("key %s not found", name)
+	}
+	aead := versions[len(versions)-1]
+	nonce := make([]byte, aead.NonceSize())
+	_, _ = rand.Read(nonce)
+	// The version is recorded in the ciphertext, as real KMSes do
+	out := append([]byte{byte(len(versions) - 1)}, nonce...)
+	return aead.Seal(out, nonce, plaintext, aad), nil
+}
+
+func (s *standInKMS) open(name string, version int, ciphertext, aad []byte) ([]byte, error) {
+	versions := s.versions[name]
+	if version >= len(versions) {
+		return nil, fmt.Errorf("key %s version %d not found", name, version)
+	}
+	aead := versions[version]
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], aad)
+}
+
+// awsStandIn shapes the stand-in like AWS KMS.
+type awsStandIn struct{ *standInKMS }
+
+func (a awsStandIn) Encrypt(_ context.Context, keyID string, plaintext []byte, encryptionContext map[string]string) ([]byte, error) {
+	aad, _ := json.Marshal(encryptionContext)
+	return a.seal(keyID, plaintext, aad)
+}
+
+func (a awsStandIn) Decrypt(_ context.Context, keyID string, ciphertext []byte, encryptionContext map[string]string) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	aad, _ := json.Marshal(encryptionContext)
+	return a.open(keyID, int(ciphertext[0]), ciphertext[1:], aad)
+}
+
+// gcpStandIn shapes the stand-in like Cloud KMS.
+type gcpStandIn struct{ *standInKMS }
+
+func (g gcpStandIn) Encrypt(_ context.Context, name string, plaintext, aad []byte) ([]byte, error) {
+	return g.seal(name, plaintext, aad)
+}
+
+func (g gcpStandIn) Decrypt(_ context.Context, name string, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	return g.open(name, int(ciphertext[0]), ciphertext[1:], aad)
+}
+
+// azureStandIn shapes the stand-in like Key Vault, which returns the kid of
+// the version used and needs it back to unwrap.
+type azureStandIn struct{ *standInKMS }
+
+func (a azureStandIn) WrapKey(_ context.Context, name, version, _ string, value []byte) (string, []byte, error) {
+	if version != "" {
+		return "", nil, fmt.Errorf("stand-in only wraps with the latest version")
+	}
+	sealed, err := a.seal(name, value, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("https://vault.test/keys/%s/%d", name, sealed[0]), sealed[1:], nil
+}
+
+func (a azureStandIn) UnwrapKey(_ context.Context, name, version, _ string, value []byte) ([]byte, error) {
+	var v int
+	if _, err := fmt.Sscanf(version, "%d", &v); err != nil {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+	return a.open(name, v, value, nil)
+}
+
+func TestKeyProviders(t *testing.T) {
+	kms := newStandInKMS()
+	kms.rotate("audit-kek")
+
+	local, err := NewLocalKMS(filepath.Join(t.TempDir(), "master.key"))
+	if err != nil {
+		t.Fatalf("Failed to create local KMS: %v", err)
+	}
+	aws, err := NewAWSKMS(awsStandIn{kms}, "audit-kek")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcp, err := NewGCPKMS(gcpStandIn{kms}, "audit-kek")
+	if err != nil {
+		t.Fatal(err)
+	}
+	azure, err := NewAzureKeyVault(azureStandIn{kms}, "audit-kek", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	providers := []KeyProvider{local, aws, gcp, azure}
+	for _, provider := range providers {
+		t.Run(provider.Name(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keyring.json")
+
+			km, err := OpenKeyManager(path, WithKeyProvider(provider))
+			if err != nil {
+				t.Fatalf("Failed to create keyring: %v", err)
+			}
+			record, err := km.Encrypt([]byte("audit event"))
+			if err != nil {
+				t.Fatalf("Failed to encrypt: %v", err)
+			}
+			if len(record.WrappedKey) == 0 {
+				t.Error("Encrypted record does not carry its wrapped key")
+			}
+
+			data, err := os.ReadFile(path) // #nosec G304 - test file path
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(data, km.currentKey) {
+				t.Fatal("Keyring file contains a raw data key")
+			}
+
+			// A KMS-side key rotation must not strand existing wrapped keys
+			kms.rotate("audit-kek")
+
+			reopened, err := OpenKeyManager(path, WithKeyProvider(provider))
+			if err != nil {
+				t.Fatalf("Failed to reopen keyring: %v", err)
+			}
+			if plaintext, err := reopened.Decrypt(record); err != nil || string(plaintext) != "audit event" {
+				t.Errorf("Decrypt after reopen = %q, %v", plaintext, err)
+			}
+
+			// A separate keyring recovers the data key from the record envelope
+			other, err := OpenKeyManager(filepath.Join(t.TempDir(), "other.json"), WithKeyProvider(provider))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plaintext, err := other.Decrypt(record); err != nil || string(plaintext) != "audit event" {
+				t.Errorf("Envelope decrypt = %q, %v", plaintext, err)
+			}
+		})
+	}
+}
+
+func TestKeyProviderBindsKeyID(t *testing.T) {
+	local, err := NewLocalKMS(filepath.Join(t.TempDir(), "master.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	wrapped, err := local.WrapKey(ctx, "key-a", make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := local.UnwrapKey(ctx, "key-a", wrapped); err != nil {
+		t.Errorf("Unwrap under the same ID failed: %v", err)
+	}
+	if _, err := local.UnwrapKey(ctx, "key-b", wrapped); err == nil {
+		t.Error("Expected unwrap under a different key ID to fail")
+	}
+}
+
+func TestKeyringProviderMismatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keyring.json")
+
+	first, err := NewLocalKMS(filepath.Join(dir, "first.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewLocalKMS(filepath.Join(dir, "second.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenKeyManager(path, WithKeyProvider(first)); err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	if _, err := OpenKeyManager(path, WithKeyProvider(second)); err == nil {
+		t.Error("Expected error opening a keyring with a different provider")
+	}
+	if _, err := OpenKeyManager(path, WithKEK(make([]byte, 32))); err == nil {
+		t.Error("Expected error opening a KMS keyring with a KEK")
+	}
+
+	// The master key survives reopening
+	reopened, err := NewLocalKMS(filepath.Join(dir, "first.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Name() != first.Name() {
+		t.Errorf("Master key changed on reopen: %s != %s", reopened.Name(), first.Name())
+	}
+}
@@ -0,0 +1,301 @@
+package compliance
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/bits"
+)
+
+// MerkleAlgorithm names the tree construction used for proofs: RFC 6962
+// Merkle tree hashing with SHA-256.
+const MerkleAlgorithm = "RFC6962-SHA256"
+
+// Domain separation prefixes from RFC 6962 section 2.1, so a leaf can never be
+// passed off as an interior node.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleLeafHash returns the RFC 6962 leaf hash of data.
+func MerkleLeafHash(data []byte) [32]byte {
+	buf := make([]byte, 1+len(data))
+	buf[0] = merkleLeafPrefix
+	copy(buf[1:], data)
+	return sha256.Sum256(buf)
+}
+
+// merkleNodeHash returns the RFC 6962 interior node hash of two children.
+func merkleNodeHash(left, right [32]byte) [32]byte {
+	var buf [65]byte
+	buf[0] = merkleNodePrefix
+	copy(buf[1:], left[:])
+	copy(buf[33:], right[:])
+	return sha256.Sum256(buf[:])
+}
+
+// MerkleTree is an append-only RFC 6962 Merkle tree. It keeps every leaf
+// hash so it can produce proofs for any tree size it has seen.
+type MerkleTree struct {
+	leaves [][32]byte
+}
+
+// NewMerkleTree creates a tree over the given leaf hashes.
+func NewMerkleTree(leafHashes ...[32]byte) *MerkleTree {
+	return &MerkleTree{leaves: append([][32]byte(nil), leafHashes...)}
+}
+
+// Append adds a leaf hash and returns its index.
+func (t *MerkleTree) Append(leafHash [32]byte) uint64 {
+	t.leaves = append(t.leaves, leafHash)
+	return uint64(len(t.leaves) - 1)
+}
+
+// Size returns the number of leaves.
+func (t *MerkleTree) Size() uint64 {
+	return uint64(len(t.leaves))
+}
+
+// Root returns the root hash of the whole tree.
+func (t *MerkleTree) Root() [32]byte {
+	return t.subtreeHash(0, t.Size())
+}
+
+// RootAt returns the root hash the tree had when it held size leaves.
+func (t *MerkleTree) RootAt(size uint64) ([32]byte, error) {
+	if size > t.Size() {
+		return [32]byte{}, fmt.Errorf("tree size %d exceeds %d leaves", size, t.Size())
+	}
+	return t.subtreeHash(0, size), nil
+}
+
+// InclusionProof returns the audit path proving the leaf at index is in the
+// tree of the given size (RFC 6962 section 2.1.1).
+func (t *MerkleTree) InclusionProof(index, size uint64) ([][32]byte, error) {
+	if size > t.Size() {
+		return nil, fmt.Errorf("tree size %d exceeds %d leaves", size, t.Size())
+	}
+	if index >= size {
+		return nil, fmt.Errorf("leaf index %d out of range for tree size %d", index, size)
+	}
+	return t.path(index, 0, size), nil
+}
+
+// path computes PATH(m, D[lo:hi]).
+func (t *MerkleTree) path(m, lo, hi uint64) [][32]byte {
+	if hi-lo <= 1 {
+		return nil
+	}
+	k := largestPowerOfTwoBelow(hi - lo)
+	if m < lo+k {
+		return append(t.path(m, lo, lo+k), t.subtreeHash(lo+k, hi))
+	}
+	return append(t.path(m, lo+k, hi), t.subtreeHash(lo, lo+k))
+}
+
+// subtreeHash computes MTH(D[lo:hi]).
+func (t *MerkleTree) subtreeHash(lo, hi uint64) [32]byte {
+	switch hi - lo {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return t.leaves[lo]
+	}
+	k := largestPowerOfTwoBelow(hi - lo)
+	return merkleNodeHash(t.subtreeHash(lo, lo+k), t.subtreeHash(lo+k, hi))
+}
+
+// largestPowerOfTwoBelow returns the largest power of two less than n (n > 1).
+func largestPowerOfTwoBelow(n uint64) uint64 {
+	k := uint64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// VerifyInclusion checks that proof shows the leaf hash at index is included
+// in the tree of the given size with the given root (RFC 9162 section 2.1.3.2).
+func VerifyInclusion(leafHash [32]byte, index, size uint64, proof [][32]byte, root [32]byte) error {
+	if index >= size {
+		return fmt.Errorf("leaf index %d out of range for tree size %d", index, size)
+	}
+
+	fn, sn := index, size-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return fmt.Errorf("inclusion proof too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			r = merkleNodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return fmt.Errorf("inclusion proof too short")
+	}
+	if r != root {
+		return fmt.Errorf("inclusion proof does not match root hash")
+	}
+	return nil
+}
+
+// ConsistencyProof returns the proof that the tree of size from is a prefix
+// of the tree of size to (RFC 6962 section 2.1.2).
+func (t *MerkleTree) ConsistencyProof(from, to uint64) ([][32]byte, error) {
+	if to > t.Size() {
+		return nil, fmt.Errorf("tree size %d exceeds %d leaves", to, t.Size())
+	}
+	if from > to {
+		return nil, fmt.Errorf("tree size %d is larger than %d", from, to)
+	}
+	if from == 0 || from == to {
+		return nil, nil
+	}
+	return t.subproof(from, 0, to, true), nil
+}
+
+// subproof computes SUBPROOF(m, D[lo:hi], b).
+func (t *MerkleTree) subproof(m, lo, hi uint64, b bool) [][32]byte {
+	if m == hi-lo {
+		if b {
+			return nil
+		}
+		return [][32]byte{t.subtreeHash(lo, hi)}
+	}
+	k := largestPowerOfTwoBelow(hi - lo)
+	if m <= k {
+		return append(t.subproof(m, lo, lo+k, b), t.subtreeHash(lo+k, hi))
+	}
+	return append(t.subproof(m-k, lo+k, hi, false), t.subtreeHash(lo, lo+k))
+}
+
+// VerifyConsistency checks that proof shows the tree of size from with root
+// fromRoot is a prefix of the tree of size to with root toRoot, i.e. the log
+// only grew by appending (RFC 9162 section 2.1.4.2).
+func VerifyConsistency(from, to uint64, fromRoot, toRoot [32]byte, proof [][32]byte) error {
+	switch {
+	case from > to:
+		return fmt.Errorf("tree size %d is larger than %d", from, to)
+	case from == to:
+		if len(proof) != 0 {
+			return fmt.Errorf("consistency proof for equal sizes must be empty")
+		}
+		if fromRoot != toRoot {
+			return fmt.Errorf("root hashes differ for the same tree size")
+		}
+		return nil
+	case from == 0:
+		return nil // The empty tree is a prefix of every tree
+	case len(proof) == 0:
+		return fmt.Errorf("consistency proof is empty")
+	}
+
+	// A power-of-two first tree is a complete subtree and is left implicit
+	if from&(from-1) == 0 {
+		proof = append([][32]byte{fromRoot}, proof...)
+	}
+
+	fn, sn := from-1, to-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return fmt.Errorf("consistency proof too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = merkleNodeHash(c, fr)
+			sr = merkleNodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = merkleNodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return fmt.Errorf("consistency proof too short")
+	}
+	if fr != fromRoot {
+		return fmt.Errorf("consistency proof does not match the earlier root hash")
+	}
+	if sr != toRoot {
+		return fmt.Errorf("consistency proof does not match the later root hash")
+	}
+	return nil
+}
+
+// MerkleFrontier maintains the root of an append-only RFC 6962 tree in
+// O(log n) space by keeping only the roots of its perfect subtrees.
+type MerkleFrontier struct {
+	nodes [][32]byte // Perfect subtree roots, largest first
+	size  uint64
+}
+
+// Append adds a leaf hash.
+func (f *MerkleFrontier) Append(leafHash [32]byte) {
+	f.nodes = append(f.nodes, leafHash)
+	// Merge equal-sized subtrees, like carrying in a binary counter
+	for s := f.size; s&1 == 1; s >>= 1 {
+		n := len(f.nodes)
+		f.nodes = append(f.nodes[:n-2], merkleNodeHash(f.nodes[n-2], f.nodes[n-1]))
+	}
+	f.size++
+}
+
+// Size returns the number of leaves.
+func (f *MerkleFrontier) Size() uint64 {
+	return f.size
+}
+
+// Root returns the current root hash.
+func (f *MerkleFrontier) Root() [32]byte {
+	if len(f.nodes) == 0 {
+		return sha256.Sum256(nil)
+	}
+	root := f.nodes[len(f.nodes)-1]
+	for i := len(f.nodes) - 2; i >= 0; i-- {
+		root = merkleNodeHash(f.nodes[i], root)
+	}
+	return root
+}
+
+// Reset empties the frontier.
+func (f *MerkleFrontier) Reset() {
+	f.nodes = nil
+	f.size = 0
+}
+
+// Nodes returns the roots of the frontier's perfect subtrees, largest first.
+// With Size they are all that is needed to restore the frontier.
+func (f *MerkleFrontier) Nodes() [][32]byte {
+	return append([][32]byte(nil), f.nodes...)
+}
+
+// Restore replaces the frontier with the state of a tree of size leaves
+// whose perfect subtree roots are nodes, as returned by Nodes.
+func (f *MerkleFrontier) Restore(size uint64, nodes [][32]byte) error {
+	if want := bits.OnesCount64(size); len(nodes) != want {
+		return fmt.Errorf("frontier of %d leaves needs %d nodes, got %d", size, want, len(nodes))
+	}
+	f.nodes = append([][32]byte(nil), nodes...)
+	f.size = size
+	return nil
+}
@@ -0,0 +1,136 @@
+package compliance
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// RFC 6962 test vectors from the Certificate Transparency reference
+// implementation.
+var merkleTestLeaves = [][]byte{
+	{},
+	{0x00},
+	{0x10},
+	{0x20, 0x21},
+	{0x30, 0x31},
+	{0x40, 0x41, 0x42, 0x43},
+	{0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57},
+	{0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d, 0x6e, 0x6f},
+}
+
+var merkleTestRoots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+func TestMerkleTreeRoots(t *testing.T) {
+	tree := NewMerkleTree()
+	var frontier MerkleFrontier
+
+	for i, data := range merkleTestLeaves {
+		leaf := MerkleLeafHash(data)
+		tree.Append(leaf)
+		frontier.Append(leaf)
+
+		root := tree.Root()
+		if got := hex.EncodeToString(root[:]); got != merkleTestRoots[i] {
+			t.Errorf("Size %d: root %s, want %s", i+1, got, merkleTestRoots[i])
+		}
+		if frontier.Root() != root {
+			t.Errorf("Size %d: frontier root differs from tree root", i+1)
+		}
+	}
+
+	for size := range merkleTestRoots {
+		root, err := tree.RootAt(uint64(size + 1))
+		if err != nil || hex.EncodeToString(root[:]) != merkleTestRoots[size] {
+			t.Errorf("RootAt(%d) = %x, %v", size+1, root, err)
+		}
+	}
+}
+
+func TestMerkleInclusionProofs(t *testing.T) {
+	tree := NewMerkleTree()
+	for i := 0; i < 37; i++ {
+		tree.Append(MerkleLeafHash([]byte{byte(i)}))
+	}
+
+	for size := uint64(1); size <= tree.Size(); size++ {
+		root, err := tree.RootAt(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for index := uint64(0); index < size; index++ {
+			proof, err := tree.InclusionProof(index, size)
+			if err != nil {
+				t.Fatalf("InclusionProof(%d, %d): %v", index, size, err)
+			}
+			leaf := tree.leaves[index]
+			if err := VerifyInclusion(leaf, index, size, proof, root); err != nil {
+				t.Fatalf("VerifyInclusion(%d, %d): %v", index, size, err)
+			}
+
+			// The proof does not carry over to another leaf or position
+			if index > 0 {
+				if err := VerifyInclusion(tree.leaves[index-1], index, size, proof, root); err == nil {
+					t.Fatalf("Proof for %d accepted the wrong leaf", index)
+				}
+				if err := VerifyInclusion(leaf, index-1, size, proof, root); err == nil {
+					t.Fatalf("Proof for %d accepted the wrong index", index)
+				}
+			}
+		}
+	}
+
+	if _, err := tree.InclusionProof(5, 5); err == nil {
+		t.Error("Expected error for index outside the tree")
+	}
+	if _, err := tree.InclusionProof(0, 100); err == nil {
+		t.Error("Expected error for size beyond the tree")
+	}
+}
+
+func TestMerkleConsistencyProofs(t *testing.T) {
+	tree := NewMerkleTree()
+	for i := 0; i < 37; i++ {
+		tree.Append(MerkleLeafHash([]byte{byte(i)}))
+	}
+
+	for to := uint64(1); to <= tree.Size(); to++ {
+		toRoot, _ := tree.RootAt(to)
+		for from := uint64(0); from <= to; from++ {
+			fromRoot, _ := tree.RootAt(from)
+			proof, err := tree.ConsistencyProof(from, to)
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d): %v", from, to, err)
+			}
+			if err := VerifyConsistency(from, to, fromRoot, toRoot, proof); err != nil {
+				t.Fatalf("VerifyConsistency(%d, %d): %v", from, to, err)
+			}
+		}
+	}
+
+	// A rewritten prefix is not consistent with the original
+	rewritten := NewMerkleTree(tree.leaves...)
+	rewritten.leaves[3] = MerkleLeafHash([]byte("rewritten"))
+	for _, from := range []uint64{4, 5, 8, 20} {
+		oldRoot, _ := tree.RootAt(from)
+		proof, err := rewritten.ConsistencyProof(from, rewritten.Size())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyConsistency(from, rewritten.Size(), oldRoot, rewritten.Root(), proof); err == nil {
+			t.Errorf("Rewrite inside the first %d leaves went undetected", from)
+		}
+	}
+
+	if _, err := tree.ConsistencyProof(5, 4); err == nil {
+		t.Error("Expected error for a shrinking tree")
+	}
+}
@@ -0,0 +1,92 @@
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SegmentSeal is a signed statement that a WAL segment was completed. It
+// fixes the segment's sequence range, record count, record time range, final
+// chain hash and Merkle root, so a truncated, extended or swapped segment no
+// longer matches its seal.
+type SegmentSeal struct {
+	SealedAt   time.Time `json:"sealed_at"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	Segment    string    `json:"segment"`
+	LastHash   string    `json:"last_hash"`
+	MerkleRoot string    `json:"merkle_root"`
+	Algorithm  string    `json:"algorithm"`
+	Signature  []byte    `json:"signature"`
+	FirstSeq   uint64    `json:"first_seq"`
+	LastSeq    uint64    `json:"last_seq"`
+	Records    uint64    `json:"records"`
+}
+
+// Sign signs the seal with signer, setting its algorithm and signature.
+func (s *SegmentSeal) Sign(signer Signer) error {
+	s.Algorithm = signer.Algorithm()
+	signature, err := signer.Sign(s.signedData())
+	if err != nil {
+		return fmt.Errorf("failed to sign segment seal: %w", err)
+	}
+	s.Signature = signature
+	return nil
+}
+
+// Verify checks the seal's signature with verifier, which needs only the
+// public key.
+func (s *SegmentSeal) Verify(verifier Signer) error {
+	if s.Algorithm != verifier.Algorithm() {
+		return fmt.Errorf("segment seal signed with %s, not %s", s.Algorithm, verifier.Algorithm())
+	}
+	if err := verifier.Verify(s.signedData(), s.Signature); err != nil {
+		return fmt.Errorf("seal of %s: %w", s.Segment, err)
+	}
+	return nil
+}
+
+// Matches reports whether other describes the same segment contents.
+func (s *SegmentSeal) Matches(other *SegmentSeal) bool {
+	return s.FirstSeq == other.FirstSeq &&
+		s.LastSeq == other.LastSeq &&
+		s.Records == other.Records &&
+		s.StartTime.Equal(other.StartTime) &&
+		s.EndTime.Equal(other.EndTime) &&
+		s.LastHash == other.LastHash &&
+		s.MerkleRoot == other.MerkleRoot
+}
+
+// signedData is the text a seal signature covers.
+func (s *SegmentSeal) signedData() []byte {
+	return []byte(fmt.Sprintf("mtlog-audit segment seal v1\n%s\n%d\n%d\n%d\n%s\n%s\n%s\n%s\n%s\n",
+		s.Segment, s.FirstSeq, s.LastSeq, s.Records,
+		s.StartTime.UTC().Format(time.RFC3339Nano), s.EndTime.UTC().Format(time.RFC3339Nano),
+		s.LastHash, s.MerkleRoot, s.SealedAt.UTC().Format(time.RFC3339Nano)))
+}
+
+// AppendSeal durably appends a segment seal to the log at path.
+func AppendSeal(path string, seal *SegmentSeal) error {
+	if err := appendJSONLine(path, seal); err != nil {
+		return fmt.Errorf("failed to append segment seal: %w", err)
+	}
+	return nil
+}
+
+// ReadSeals reads every segment seal in the log at path, oldest first.
+func ReadSeals(path string) ([]*SegmentSeal, error) {
+	var seals []*SegmentSeal
+	err := readJSONLines(path, func(line []byte) error {
+		var seal SegmentSeal
+		if err := json.Unmarshal(line, &seal); err != nil {
+			return err
+		}
+		seals = append(seals, &seal)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seal log: %w", err)
+	}
+	return seals, nil
+}
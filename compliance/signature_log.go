@@ -0,0 +1,357 @@
+package compliance
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// signatureLogVersion is the signature log format version.
+const signatureLogVersion = 1
+
+// signatureLogHeader is the first line of a signature log. It records the
+// public key so auditors can match the log to the key they were given.
+type signatureLogHeader struct {
+	Created   time.Time `json:"created"`
+	Algorithm string    `json:"algorithm"`
+	PublicKey string    `json:"public_key"`
+	Version   int       `json:"version"`
+}
+
+// OpenSignatureChain opens the signature log at path, creating it if it does
+// not exist, and resumes the chain from its last signature. Every signature is
+// appended to the log as one JSON line, so the chain can be verified after a
+// restart, or years later, with only the public key. The log refuses signers
+// whose public key differs from the one it was created with.
+func OpenSignatureChain(path string, signer Signer) (*SignatureChain, error) {
+	publicKey, err := MarshalPublicKey(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := NewSignatureChain(signer)
+	sc.path = path
+
+	header, tail, validSize, err := readSignatureLogTail(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := createSignatureLog(path, signer.Algorithm(), publicKey); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if header.PublicKey != string(publicKey) {
+			return nil, fmt.Errorf("signature log %s was created with a different signing key", path)
+		}
+		// Drop a torn final line left by a crash mid-append
+		if err := os.Truncate(path, validSize); err != nil {
+			return nil, fmt.Errorf("failed to truncate signature log: %w", err)
+		}
+		if tail != nil {
+			sc.tail = tail
+			sc.lastHash = tail.DataHash
+			sc.lastSeq = tail.Sequence
+			sc.count = -1
+		}
+	}
+
+	// #nosec G304 - signature log path from user configuration
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open signature log: %w", err)
+	}
+	sc.file = file
+
+	return sc, nil
+}
+
+// LastSequence returns the sequence of the most recent signature.
+func (sc *SignatureChain) LastSequence() uint64 {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.lastSeq
+}
+
+// Len returns the number of signatures in the chain. A reopened chain counts
+// the signatures in its log the first time it is asked.
+func (sc *SignatureChain) Len() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.count < 0 {
+		count := 0
+		if _, _, err := scanSignatureLog(sc.path, nil, func(*ChainedSignature) error {
+			count++
+			return nil
+		}); err != nil {
+			return count
+		}
+		sc.count = count
+	}
+	return sc.count
+}
+
+// Sync flushes the signature log to stable storage. It is a no-op for
+// in-memory chains.
+func (sc *SignatureChain) Sync() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.file == nil {
+		return nil
+	}
+	if err := sc.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync signature log: %w", err)
+	}
+	return nil
+}
+
+// Close syncs and closes the signature log. It is a no-op for in-memory
+// chains.
+func (sc *SignatureChain) Close() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.file == nil {
+		return nil
+	}
+	file := sc.file
+	sc.file = nil
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to sync signature log: %w", err)
+	}
+	return file.Close()
+}
+
+// VerifySignatureLog verifies every signature in the log at path against
+// verifier, which needs only the public key (see LoadPublicKey). It checks the
+// log was made with that key, that sequences increase and that each signature
+// chains to the previous one. The signatures verified before any failure are
+// returned along with the error.
+func VerifySignatureLog(path string, verifier Signer) ([]ChainedSignature, error) {
+	var signatures []ChainedSignature
+	_, err := WalkSignatureLog(path, verifier, func(sig *ChainedSignature) error {
+		signatures = append(signatures, *sig)
+		return nil
+	})
+	return signatures, err
+}
+
+// WalkSignatureLog verifies the log at path as VerifySignatureLog does, but
+// reads it a line at a time, passing each verified signature to visit in
+// sequence order rather than holding them all. An error from visit stops the
+// walk and is returned. It returns the number of signatures verified.
+func WalkSignatureLog(path string, verifier Signer, visit func(*ChainedSignature) error) (int, error) {
+	publicKey, err := MarshalPublicKey(verifier)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		count    int
+		lastSeq  uint64
+		prevHash = make([]byte, 32)
+	)
+	checkKey := func(header *signatureLogHeader) error {
+		if header.PublicKey != string(publicKey) {
+			return fmt.Errorf("signature log was not created with the given public key")
+		}
+		return nil
+	}
+	_, _, err = scanSignatureLog(path, checkKey, func(sig *ChainedSignature) error {
+		if count > 0 && sig.Sequence <= lastSeq {
+			return fmt.Errorf("sequence %d follows %d at position %d", sig.Sequence, lastSeq, count)
+		}
+		if !bytes.Equal(sig.PrevHash, prevHash) {
+			return fmt.Errorf("chain broken at sequence %d", sig.Sequence)
+		}
+		if err := verifier.Verify(chainData(prevHash, sig.DataHash, sig.Sequence), sig.Signature); err != nil {
+			return fmt.Errorf("invalid signature for sequence %d: %w", sig.Sequence, err)
+		}
+		if err := visit(sig); err != nil {
+			return err
+		}
+		prevHash = sig.DataHash
+		lastSeq = sig.Sequence
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// createSignatureLog writes a new signature log holding only its header.
+func createSignatureLog(path, algorithm string, publicKey []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create signature log directory: %w", err)
+	}
+
+	header, err := json.Marshal(signatureLogHeader{
+		Version:   signatureLogVersion,
+		Algorithm: algorithm,
+		PublicKey: string(publicKey),
+		Created:   time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal signature log header: %w", err)
+	}
+
+	// #nosec G304 - signature log path from user configuration
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create signature log: %w", err)
+	}
+	if _, err := file.Write(append(header, '\n')); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write signature log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to sync signature log: %w", err)
+	}
+	return file.Close()
+}
+
+// appendSignature writes one signature as a JSON line.
+func appendSignature(file *os.File, sig *ChainedSignature) error {
+	line, err := json.Marshal(sig)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signature: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	return nil
+}
+
+// parseSignatureLogHeader parses the first line of a signature log.
+func parseSignatureLogHeader(line []byte) (*signatureLogHeader, error) {
+	header := &signatureLogHeader{}
+	if err := json.Unmarshal(line, header); err != nil {
+		return nil, fmt.Errorf("failed to parse signature log header: %w", err)
+	}
+	if header.Version != signatureLogVersion {
+		return nil, fmt.Errorf("unsupported signature log version: %d", header.Version)
+	}
+	return header, nil
+}
+
+// scanSignatureLog reads a signature log a line at a time, passing its header
+// to check, when given, and each signature to visit, and returns the header
+// and the size of the valid prefix. An unterminated final line is a torn
+// append and is ignored; any other unreadable line is an error, as is any
+// error from check or visit.
+func scanSignatureLog(path string, check func(*signatureLogHeader) error, visit func(*ChainedSignature) error) (*signatureLogHeader, int64, error) {
+	file, err := os.Open(path) // #nosec G304 - signature log path from user configuration
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReader(file)
+	var (
+		header *signatureLogHeader
+		size   int64
+	)
+
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break // Any partial line is a torn append
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read signature log: %w", err)
+		}
+
+		if header == nil {
+			if header, err = parseSignatureLogHeader(line); err != nil {
+				return nil, 0, err
+			}
+			if check != nil {
+				if err := check(header); err != nil {
+					return nil, 0, err
+				}
+			}
+		} else {
+			var sig ChainedSignature
+			if err := json.Unmarshal(line, &sig); err != nil {
+				return header, 0, fmt.Errorf("corrupt signature log at line %d: %w", lineNum, err)
+			}
+			if err := visit(&sig); err != nil {
+				return header, 0, err
+			}
+		}
+		size += int64(len(line))
+	}
+
+	if header == nil {
+		return nil, 0, fmt.Errorf("signature log %s has no header", path)
+	}
+	return header, size, nil
+}
+
+// readSignatureLogTail reads the header and last signature of a signature
+// log, reading back from its end rather than through every signature, and
+// returns them with the size of the valid prefix. The tail is nil for a log
+// holding only its header. An unterminated final line is a torn append and
+// is ignored.
+func readSignatureLogTail(path string) (*signatureLogHeader, *ChainedSignature, int64, error) {
+	file, err := os.Open(path) // #nosec G304 - signature log path from user configuration
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err == io.EOF {
+		return nil, nil, 0, fmt.Errorf("signature log %s has no header", path)
+	}
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to read signature log: %w", err)
+	}
+	header, err := parseSignatureLogHeader(line)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	headerSize := int64(len(line))
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to stat signature log: %w", err)
+	}
+
+	// Read back from the end until the buffer holds the last complete line
+	for chunk := int64(4096); ; chunk *= 2 {
+		start := max(headerSize, stat.Size()-chunk)
+		buf := make([]byte, stat.Size()-start)
+		if _, err := file.ReadAt(buf, start); err != nil {
+			return nil, nil, 0, fmt.Errorf("failed to read signature log: %w", err)
+		}
+
+		end := bytes.LastIndexByte(buf, '\n')
+		if end < 0 && start > headerSize {
+			continue
+		}
+		if end < 0 {
+			return header, nil, headerSize, nil // Only the header, and any torn append
+		}
+		begin := bytes.LastIndexByte(buf[:end], '\n') + 1
+		if begin == 0 && start > headerSize {
+			continue
+		}
+
+		var tail ChainedSignature
+		if err := json.Unmarshal(buf[begin:end+1], &tail); err != nil {
+			return nil, nil, 0, fmt.Errorf("corrupt signature log at offset %d: %w", start+int64(begin), err)
+		}
+		return header, &tail, start + int64(end) + 1, nil
+	}
+}
@@ -0,0 +1,201 @@
+package compliance
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignatureChainPersistence(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "audit.sig")
+
+	signer, err := OpenSigner(filepath.Join(dir, "signing.pem"), "Ed25519")
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	chain, err := OpenSignatureChain(logPath, signer)
+	if err != nil {
+		t.Fatalf("Failed to open signature log: %v", err)
+	}
+	for seq := uint64(1); seq <= 3; seq++ {
+		if _, err := chain.Sign(seq, []byte{byte(seq)}); err != nil {
+			t.Fatalf("Failed to sign %d: %v", seq, err)
+		}
+	}
+	if err := chain.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// After a restart the same key resumes the chain
+	signer, err = OpenSigner(filepath.Join(dir, "signing.pem"), "Ed25519")
+	if err != nil {
+		t.Fatalf("Failed to reload signer: %v", err)
+	}
+	chain, err = OpenSignatureChain(logPath, signer)
+	if err != nil {
+		t.Fatalf("Failed to reopen signature log: %v", err)
+	}
+	if chain.LastSequence() != 3 {
+		t.Errorf("Expected last sequence 3, got %d", chain.LastSequence())
+	}
+	if _, err := chain.Sign(3, []byte("again")); err == nil {
+		t.Error("Expected error re-signing an earlier sequence")
+	}
+	if _, err := chain.Sign(4, []byte{4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Verify(); err != nil {
+		t.Errorf("Chain failed to verify after restart: %v", err)
+	}
+	if err := chain.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An auditor needs only the public key
+	verifier, err := LoadPublicKey(filepath.Join(dir, "signing.pem"))
+	if err != nil {
+		t.Fatalf("Failed to load public key: %v", err)
+	}
+	signatures, err := VerifySignatureLog(logPath, verifier)
+	if err != nil {
+		t.Fatalf("Offline verification failed: %v", err)
+	}
+	if len(signatures) != 4 {
+		t.Errorf("Expected 4 signatures, got %d", len(signatures))
+	}
+
+	other, err := NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifySignatureLog(logPath, other); err == nil {
+		t.Error("Expected verification with another key to fail")
+	}
+	if _, err := OpenSignatureChain(logPath, other); err == nil {
+		t.Error("Expected opening the log with another key to fail")
+	}
+}
+
+func TestSignatureLogTamperDetection(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.sig")
+
+	signer, err := NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := OpenSignatureChain(logPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seq := uint64(1); seq <= 3; seq++ {
+		if _, err := chain.Sign(seq, []byte{byte(seq)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := chain.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(logPath) // #nosec G304 - test file path
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+
+	// Dropping a signature breaks the chain
+	dropped := bytes.Join([][]byte{lines[0], lines[1], lines[3]}, nil)
+	if err := os.WriteFile(logPath, dropped, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	signatures, err := VerifySignatureLog(logPath, signer)
+	if err == nil {
+		t.Fatal("Expected a dropped signature to break the chain")
+	}
+	if len(signatures) != 1 {
+		t.Errorf("Expected 1 verified signature before the break, got %d", len(signatures))
+	}
+
+	// A torn final line is discarded on open
+	torn := append(append([]byte{}, data...), lines[1][:20]...)
+	if err := os.WriteFile(logPath, torn, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	chain, err = OpenSignatureChain(logPath, signer)
+	if err != nil {
+		t.Fatalf("Failed to open log with torn tail: %v", err)
+	}
+	if _, err := chain.Sign(4, []byte{4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if signatures, err := VerifySignatureLog(logPath, signer); err != nil || len(signatures) != 4 {
+		t.Errorf("Expected 4 valid signatures after torn tail, got %d: %v", len(signatures), err)
+	}
+}
+
+func TestSignatureChainReadsLog(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.sig")
+
+	signer, err := NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := OpenSignatureChain(logPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Enough signatures that the tail is found past the first read back
+	for seq := uint64(1); seq <= 100; seq++ {
+		if _, err := chain.Sign(seq, []byte{byte(seq)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := chain.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err = OpenSignatureChain(logPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = chain.Close() }()
+	if chain.LastSequence() != 100 || chain.Len() != 100 {
+		t.Errorf("Expected 100 signatures up to sequence 100, got %d up to %d", chain.Len(), chain.LastSequence())
+	}
+	if _, err := chain.Sign(101, []byte{101}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Verify(); err != nil || chain.Len() != 101 {
+		t.Errorf("Expected 101 verified signatures, got %d: %v", chain.Len(), err)
+	}
+
+	// Verify reads the signatures back from the log, so tampering shows
+	data, err := os.ReadFile(logPath) // #nosec G304 - test file path
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	tampered := bytes.Join(append(append([][]byte{}, lines[:50]...), lines[51:]...), nil)
+	if err := os.WriteFile(logPath, tampered, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Verify(); err == nil {
+		t.Error("Expected a dropped signature in the log to fail verification")
+	}
+}
+
+func TestOpenSignerAlgorithmMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.pem")
+
+	if _, err := OpenSigner(path, "Ed25519"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSigner(path, "RSA-PSS"); err == nil {
+		t.Error("Expected error loading an Ed25519 key as RSA-PSS")
+	}
+}
@@ -1,6 +1,7 @@
 package compliance
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ed25519"
 	"crypto/rand"
@@ -9,9 +10,11 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sync"
 )
 
@@ -80,6 +83,9 @@ func LoadEd25519Signer(privateKeyPath string) (*Ed25519Signer, error) {
 
 // Sign signs data using Ed25519
 func (s *Ed25519Signer) Sign(data []byte) ([]byte, error) {
+	if s.privateKey == nil {
+		return nil, fmt.Errorf("signer holds only a public key")
+	}
 	return ed25519.Sign(s.privateKey, data), nil
 }
 
@@ -96,6 +102,11 @@ func (s *Ed25519Signer) Algorithm() string {
 	return "Ed25519"
 }
 
+// PublicKey returns the Ed25519 public key
+func (s *Ed25519Signer) PublicKey() crypto.PublicKey {
+	return s.publicKey
+}
+
 // RSASigner implements signing using RSA-PSS
 type RSASigner struct {
 	privateKey *rsa.PrivateKey
@@ -117,6 +128,9 @@ func NewRSASigner(bits int) (*RSASigner, error) {
 
 // Sign signs data using RSA-PSS
 func (s *RSASigner) Sign(data []byte) ([]byte, error) {
+	if s.privateKey == nil {
+		return nil, fmt.Errorf("signer holds only a public key")
+	}
 	hash := sha256.Sum256(data)
 	signature, err := rsa.SignPSS(rand.Reader, s.privateKey, crypto.SHA256, hash[:], nil)
 	if err != nil {
@@ -140,12 +154,24 @@ func (s *RSASigner) Algorithm() string {
 	return "RSA-PSS"
 }
 
-// SignatureChain maintains a chain of signatures for tamper detection
+// PublicKey returns the RSA public key
+func (s *RSASigner) PublicKey() crypto.PublicKey {
+	return s.publicKey
+}
+
+// SignatureChain maintains a chain of signatures for tamper detection. It
+// holds only the tail of the chain in memory; a chain opened with
+// OpenSignatureChain reads its earlier signatures back from its log when
+// verifying.
 type SignatureChain struct {
-	signer     Signer
-	lastHash   []byte
-	signatures []ChainedSignature
-	mu         sync.RWMutex
+	signer   Signer
+	file     *os.File
+	tail     *ChainedSignature
+	path     string
+	lastHash []byte
+	count    int // -1 until the log has been counted
+	lastSeq  uint64
+	mu       sync.RWMutex
 }
 
 // ChainedSignature represents a signature in the chain
@@ -160,31 +186,27 @@ type ChainedSignature struct {
 // NewSignatureChain creates a new signature chain
 func NewSignatureChain(signer Signer) *SignatureChain {
 	return &SignatureChain{
-		signer:     signer,
-		lastHash:   make([]byte, 32), // Initialize with zeros
-		signatures: make([]ChainedSignature, 0),
+		signer:   signer,
+		lastHash: make([]byte, 32), // Initialize with zeros
 	}
 }
 
-// Sign adds a new signature to the chain
+// Sign adds a new signature to the chain. A chain opened with
+// OpenSignatureChain appends the signature to its log before returning and
+// requires sequences to increase.
 func (sc *SignatureChain) Sign(sequence uint64, data []byte) (*ChainedSignature, error) {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
+	if sc.file != nil && sc.tail != nil && sequence <= sc.lastSeq {
+		return nil, fmt.Errorf("sequence %d is not after last signed sequence %d", sequence, sc.lastSeq)
+	}
+
 	// Calculate data hash
 	dataHash := sha256.Sum256(data)
 
-	// Create chain data: prevHash + dataHash + sequence
-	chainData := make([]byte, len(sc.lastHash)+len(dataHash)+8)
-	copy(chainData, sc.lastHash)
-	copy(chainData[len(sc.lastHash):], dataHash[:])
-	// Add sequence number
-	for i := 0; i < 8; i++ {
-		chainData[len(sc.lastHash)+len(dataHash)+i] = byte(sequence >> (8 * i))
-	}
-
-	// Sign the chain data
-	signature, err := sc.signer.Sign(chainData)
+	// Sign the chain data: prevHash + dataHash + sequence
+	signature, err := sc.signer.Sign(chainData(sc.lastHash, dataHash[:], sequence))
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign chain data: %w", err)
 	}
@@ -198,41 +220,68 @@ func (sc *SignatureChain) Sign(sequence uint64, data []byte) (*ChainedSignature,
 		Algorithm: sc.signer.Algorithm(),
 	}
 
+	// Persist before the signature becomes part of the chain
+	if sc.file != nil {
+		if err := appendSignature(sc.file, &chainedSig); err != nil {
+			return nil, err
+		}
+	}
+
 	// Update last hash
 	sc.lastHash = dataHash[:]
-	sc.signatures = append(sc.signatures, chainedSig)
+	sc.lastSeq = sequence
+	sc.tail = &chainedSig
+	if sc.count >= 0 {
+		sc.count++
+	}
 
 	return &chainedSig, nil
 }
 
-// Verify verifies the entire signature chain
+// Verify verifies the signature chain. A chain with a log verifies every
+// signature in it, reading them back from disk; an in-memory chain keeps only
+// its tail, so only the most recent signature is checked.
 func (sc *SignatureChain) Verify() error {
-	sc.mu.RLock()
-	defer sc.mu.RUnlock()
-
-	prevHash := make([]byte, 32) // Start with zeros
-
-	for i, sig := range sc.signatures {
-		// Recreate chain data
-		chainData := make([]byte, len(prevHash)+len(sig.DataHash)+8)
-		copy(chainData, prevHash)
-		copy(chainData[len(prevHash):], sig.DataHash)
-		// Add sequence number
-		for j := 0; j < 8; j++ {
-			chainData[len(prevHash)+len(sig.DataHash)+j] = byte(sig.Sequence >> (8 * j))
-		}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
 
-		// Verify signature
-		if err := sc.signer.Verify(chainData, sig.Signature); err != nil {
-			return fmt.Errorf("chain verification failed at position %d: %w", i, err)
+	if sc.path == "" {
+		if sc.tail == nil {
+			return nil
 		}
-
-		prevHash = sig.DataHash
+		if err := sc.signer.Verify(chainData(sc.tail.PrevHash, sc.tail.DataHash, sc.tail.Sequence), sc.tail.Signature); err != nil {
+			return fmt.Errorf("chain verification failed at sequence %d: %w", sc.tail.Sequence, err)
+		}
+		return nil
 	}
 
+	var last *ChainedSignature
+	count, err := WalkSignatureLog(sc.path, sc.signer, func(sig *ChainedSignature) error {
+		last = sig
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("chain verification failed at position %d: %w", count, err)
+	}
+	if (last == nil) != (sc.tail == nil) || (last != nil && last.Sequence != sc.lastSeq) {
+		return fmt.Errorf("signature log does not end at sequence %d", sc.lastSeq)
+	}
+	sc.count = count
 	return nil
 }
 
+// chainData builds the signed chain data: prevHash + dataHash + sequence
+// (little-endian).
+func chainData(prevHash, dataHash []byte, sequence uint64) []byte {
+	data := make([]byte, len(prevHash)+len(dataHash)+8)
+	copy(data, prevHash)
+	copy(data[len(prevHash):], dataHash)
+	for i := 0; i < 8; i++ {
+		data[len(prevHash)+len(dataHash)+i] = byte(sequence >> (8 * i))
+	}
+	return data
+}
+
 // SignatureRecord wraps audit data with signature information
 type SignatureRecord struct {
 	Signature string `json:"signature"`
@@ -349,3 +398,141 @@ func GenerateKeyPair(algorithm string, writer io.Writer) error {
 
 	return nil
 }
+
+// PublicKeySigner is implemented by signers whose public key can be published
+// so signatures are verifiable without the private key.
+type PublicKeySigner interface {
+	Signer
+	PublicKey() crypto.PublicKey
+}
+
+// MarshalPublicKey returns the PEM-encoded public key of signer.
+func MarshalPublicKey(signer Signer) ([]byte, error) {
+	pks, ok := signer.(PublicKeySigner)
+	if !ok {
+		return nil, fmt.Errorf("signer %s does not expose a public key", signer.Algorithm())
+	}
+
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(pks.PublicKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubKeyBytes}), nil
+}
+
+// ParsePublicKey returns a verify-only signer for the first PEM-encoded
+// public key in data.
+func ParsePublicKey(data []byte) (Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no PUBLIC KEY PEM block found")
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+
+		switch key := publicKey.(type) {
+		case ed25519.PublicKey:
+			return &Ed25519Signer{publicKey: key}, nil
+		case *rsa.PublicKey:
+			return &RSASigner{publicKey: key}, nil
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+		}
+	}
+}
+
+// LoadPublicKey loads a verify-only signer from a PEM file. The file may be a
+// bare public key or a key pair written by GenerateKeyPair.
+func LoadPublicKey(path string) (Signer, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - configured key path
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	return ParsePublicKey(data)
+}
+
+// LoadSigner loads an Ed25519 or RSA-PSS signer from a PKCS#8 PEM file, such
+// as one written by GenerateKeyPair.
+func LoadSigner(privateKeyPath string) (Signer, error) {
+	keyData, err := os.ReadFile(privateKeyPath) // #nosec G304 - configured key path
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse PEM block")
+	}
+
+	if block.Type != "PRIVATE KEY" && block.Type != "RSA PRIVATE KEY" {
+		return nil, fmt.Errorf("invalid key type: %s", block.Type)
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	switch key := privateKey.(type) {
+	case ed25519.PrivateKey:
+		pubKey, ok := key.Public().(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is not an Ed25519 public key")
+		}
+		return &Ed25519Signer{privateKey: key, publicKey: pubKey}, nil
+	case *rsa.PrivateKey:
+		return &RSASigner{privateKey: key, publicKey: &key.PublicKey}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+}
+
+// OpenSigner loads the signer key pair at path, generating it with algorithm
+// if it does not exist, so signatures stay verifiable across restarts with a
+// single public key.
+func OpenSigner(path, algorithm string) (Signer, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		var pair bytes.Buffer
+		if err := GenerateKeyPair(algorithm, &pair); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create key directory: %w", err)
+		}
+
+		// O_EXCL so a concurrently created key is never overwritten
+		// #nosec G304 - configured key path
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create signing key: %w", err)
+		}
+		if _, err := file.Write(pair.Bytes()); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to write signing key: %w", err)
+		}
+		if err := file.Sync(); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to sync signing key: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close signing key: %w", err)
+		}
+	}
+
+	signer, err := LoadSigner(path)
+	if err != nil {
+		return nil, err
+	}
+	if signer.Algorithm() != algorithm && !(algorithm == "RSA" && signer.Algorithm() == "RSA-PSS") {
+		return nil, fmt.Errorf("signing key is %s, not %s", signer.Algorithm(), algorithm)
+	}
+	return signer, nil
+}
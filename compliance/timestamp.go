@@ -0,0 +1,523 @@
+package compliance
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"time"
+
+	// Register the digests timestamp tokens may use
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// tsaTimeout bounds each request to a timestamp authority.
+const tsaTimeout = 30 * time.Second
+
+// maxTimestampResponse bounds the size of a timestamp authority's reply.
+const maxTimestampResponse = 1 << 20
+
+var (
+	oidSHA256            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidSignedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidAttrContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningCertV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidRSAEncryption     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA256WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidECPublicKey       = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAWithSHA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidEd25519Signature  = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// PKIStatus values from RFC 3161 section 2.4.2.
+const (
+	timestampStatusGranted  = 0
+	timestampStatusWithMods = 1
+	timestampStatusRejected = 2
+)
+
+// RFC 3161 and RFC 5652 structures. Field order is the ASN.1 encoding order.
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional,default:false"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time        `asn1:"generalized"`
+	Accuracy       accuracy         `asn1:"optional"`
+	Ordering       bool             `asn1:"optional,default:false"`
+	Nonce          *big.Int         `asn1:"optional"`
+	TSA            asn1.RawValue    `asn1:"optional,tag:0"`
+	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
+}
+
+// TimestampInfo describes a verified RFC 3161 timestamp token.
+type TimestampInfo struct {
+	// GenTime is when the timestamp authority saw the data.
+	GenTime      time.Time
+	Certificate  *x509.Certificate
+	SerialNumber *big.Int
+	Policy       asn1.ObjectIdentifier
+}
+
+// TSAClient requests RFC 3161 timestamp tokens from a timestamp authority
+// over HTTP.
+type TSAClient struct {
+	client *http.Client
+	url    string
+}
+
+// NewTSAClient creates a client for the timestamp authority at url.
+func NewTSAClient(url string) *TSAClient {
+	return &TSAClient{url: url, client: &http.Client{Timeout: tsaTimeout}}
+}
+
+// URL returns the timestamp authority's URL.
+func (c *TSAClient) URL() string {
+	return c.url
+}
+
+// Timestamp obtains a token proving data existed no later than the token's
+// GenTime. Only the SHA-256 digest of data is sent to the authority.
+func (c *TSAClient) Timestamp(ctx context.Context, data []byte) ([]byte, *TimestampInfo, error) {
+	digest := crypto.SHA256.New()
+	digest.Write(data)
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	req, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest.Sum(nil),
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode timestamp request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(req))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create timestamp request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/timestamp-query")
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("timestamp request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTimestampResponse))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read timestamp response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("timestamp authority returned HTTP %d", resp.StatusCode)
+	}
+
+	var tsResp timeStampResp
+	if rest, err := asn1.Unmarshal(body, &tsResp); err != nil || len(rest) > 0 {
+		return nil, nil, fmt.Errorf("malformed timestamp response")
+	}
+	status := tsResp.Status.Status
+	if status != timestampStatusGranted && status != timestampStatusWithMods {
+		return nil, nil, fmt.Errorf("timestamp request rejected (status %d): %v", status, tsResp.Status.StatusString)
+	}
+
+	token := tsResp.TimeStampToken.FullBytes
+	info, tst, err := verifyTimestampToken(token, data, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tst.Nonce == nil || tst.Nonce.Cmp(nonce) != 0 {
+		return nil, nil, fmt.Errorf("timestamp token nonce does not match the request")
+	}
+	return token, info, nil
+}
+
+// VerifyTimestampToken checks that token is a validly signed RFC 3161
+// timestamp token over data. With roots, the authority's certificate must
+// also chain to one of them; without, the caller must trust the certificate
+// in the returned info some other way.
+func VerifyTimestampToken(token, data []byte, roots *x509.CertPool) (*TimestampInfo, error) {
+	info, _, err := verifyTimestampToken(token, data, roots)
+	return info, err
+}
+
+func verifyTimestampToken(token, data []byte, roots *x509.CertPool) (*TimestampInfo, *tstInfo, error) {
+	var ci contentInfo
+	if rest, err := asn1.Unmarshal(token, &ci); err != nil || len(rest) > 0 {
+		return nil, nil, fmt.Errorf("malformed timestamp token")
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, nil, fmt.Errorf("timestamp token is not CMS signed data")
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, nil, fmt.Errorf("malformed timestamp signed data: %w", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, nil, fmt.Errorf("timestamp token does not contain TSTInfo")
+	}
+
+	var tst tstInfo
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent, &tst); err != nil {
+		return nil, nil, fmt.Errorf("malformed TSTInfo: %w", err)
+	}
+
+	// The token must be over this data
+	imprintHash, err := hashForOID(tst.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := imprintHash.New()
+	h.Write(data)
+	if !bytes.Equal(h.Sum(nil), tst.MessageImprint.HashedMessage) {
+		return nil, nil, fmt.Errorf("timestamp token is over different data")
+	}
+
+	if len(sd.SignerInfos) != 1 {
+		return nil, nil, fmt.Errorf("timestamp token has %d signers, want 1", len(sd.SignerInfos))
+	}
+	si := sd.SignerInfos[0]
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed timestamp certificates: %w", err)
+	}
+	cert, err := findSigner(certs, si.SID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !slices.Contains(cert.ExtKeyUsage, x509.ExtKeyUsageTimeStamping) {
+		return nil, nil, fmt.Errorf("certificate %q is not authorized for timestamping", cert.Subject.CommonName)
+	}
+
+	if err := verifySignerInfo(si, cert, sd.EncapContentInfo.EContent); err != nil {
+		return nil, nil, err
+	}
+
+	if roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, c := range certs {
+			intermediates.AddCert(c)
+		}
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   tst.GenTime,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("timestamp authority not trusted: %w", err)
+		}
+	}
+
+	return &TimestampInfo{
+		GenTime:      tst.GenTime,
+		SerialNumber: tst.SerialNumber,
+		Policy:       tst.Policy,
+		Certificate:  cert,
+	}, &tst, nil
+}
+
+// findSigner returns the certificate identified by a SignerInfo's sid.
+func findSigner(certs []*x509.Certificate, sid asn1.RawValue) (*x509.Certificate, error) {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c, nil
+			}
+		}
+		return nil, fmt.Errorf("timestamp signer certificate not in token")
+	}
+
+	var ias issuerAndSerialNumber
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil, fmt.Errorf("malformed timestamp signer identifier: %w", err)
+	}
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("timestamp signer certificate not in token")
+}
+
+// verifySignerInfo checks the signed attributes bind content and carry a
+// valid signature from cert.
+func verifySignerInfo(si signerInfo, cert *x509.Certificate, content []byte) error {
+	if len(si.SignedAttrs.Bytes) == 0 {
+		return fmt.Errorf("timestamp token has no signed attributes")
+	}
+
+	// The signature covers the attributes DER-encoded as a SET OF
+	signedAttrs, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: si.SignedAttrs.Bytes})
+	if err != nil {
+		return fmt.Errorf("failed to encode signed attributes: %w", err)
+	}
+	var attrs []attribute
+	if _, err := asn1.UnmarshalWithParams(signedAttrs, &attrs, "set"); err != nil {
+		return fmt.Errorf("malformed signed attributes: %w", err)
+	}
+
+	digestHash, err := hashForOID(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	h := digestHash.New()
+	h.Write(content)
+	contentDigest := h.Sum(nil)
+
+	var sawType, sawDigest bool
+	for _, attr := range attrs {
+		if len(attr.Values) != 1 {
+			continue
+		}
+		switch {
+		case attr.Type.Equal(oidAttrContentType):
+			var contentType asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &contentType); err != nil || !contentType.Equal(oidTSTInfo) {
+				return fmt.Errorf("signed content type is not TSTInfo")
+			}
+			sawType = true
+		case attr.Type.Equal(oidAttrMessageDigest):
+			var digest []byte
+			if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &digest); err != nil || !bytes.Equal(digest, contentDigest) {
+				return fmt.Errorf("signed digest does not match TSTInfo")
+			}
+			sawDigest = true
+		}
+	}
+	if !sawType || !sawDigest {
+		return fmt.Errorf("timestamp token is missing required signed attributes")
+	}
+
+	algorithm, err := signatureAlgorithm(digestHash, si.SignatureAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	if err := cert.CheckSignature(algorithm, signedAttrs, si.Signature); err != nil {
+		return fmt.Errorf("invalid timestamp signature: %w", err)
+	}
+	return nil
+}
+
+// hashForOID maps a digest algorithm identifier to its hash.
+func hashForOID(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported digest algorithm %v", oid)
+}
+
+// signatureAlgorithm maps a CMS signature algorithm identifier, which may
+// name only the key type, and its digest to an x509 signature algorithm.
+func signatureAlgorithm(digest crypto.Hash, oid asn1.ObjectIdentifier) (x509.SignatureAlgorithm, error) {
+	byDigest := func(sha256, sha384, sha512 x509.SignatureAlgorithm) (x509.SignatureAlgorithm, error) {
+		switch digest {
+		case crypto.SHA256:
+			return sha256, nil
+		case crypto.SHA384:
+			return sha384, nil
+		case crypto.SHA512:
+			return sha512, nil
+		}
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported digest %v", digest)
+	}
+
+	switch {
+	case oid.Equal(oidRSAEncryption):
+		return byDigest(x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA)
+	case oid.Equal(oidECPublicKey):
+		return byDigest(x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512)
+	case oid.Equal(oidSHA256WithRSA):
+		return x509.SHA256WithRSA, nil
+	case oid.Equal(oidSHA384WithRSA):
+		return x509.SHA384WithRSA, nil
+	case oid.Equal(oidSHA512WithRSA):
+		return x509.SHA512WithRSA, nil
+	case oid.Equal(oidECDSAWithSHA256):
+		return x509.ECDSAWithSHA256, nil
+	case oid.Equal(oidECDSAWithSHA384):
+		return x509.ECDSAWithSHA384, nil
+	case oid.Equal(oidECDSAWithSHA512):
+		return x509.ECDSAWithSHA512, nil
+	case oid.Equal(oidEd25519Signature):
+		return x509.PureEd25519, nil
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signature algorithm %v", oid)
+}
+
+// TimestampRecord is an RFC 3161 timestamp token over a tree head: the
+// Merkle tree size and root hash of the log when the token was requested.
+// It proves every record in that tree existed by Time, whatever the host
+// clock said.
+type TimestampRecord struct {
+	Time     time.Time `json:"time"`
+	TSA      string    `json:"tsa"`
+	RootHash string    `json:"root_hash"`
+	Token    []byte    `json:"token"`
+	TreeSize uint64    `json:"tree_size"`
+}
+
+// TimestampTreeHead obtains a timestamp token over the tree head of the
+// given size and root.
+func (c *TSAClient) TimestampTreeHead(ctx context.Context, size uint64, root [32]byte) (*TimestampRecord, error) {
+	record := &TimestampRecord{
+		TSA:      c.url,
+		RootHash: fmt.Sprintf("%x", root[:]),
+		TreeSize: size,
+	}
+
+	token, info, err := c.Timestamp(ctx, record.treeHead())
+	if err != nil {
+		return nil, err
+	}
+	record.Token = token
+	record.Time = info.GenTime.UTC()
+	return record, nil
+}
+
+// Verify checks the record's token is a valid timestamp over its tree head
+// issued at Time. See VerifyTimestampToken for roots.
+func (r *TimestampRecord) Verify(roots *x509.CertPool) (*TimestampInfo, error) {
+	info, err := VerifyTimestampToken(r.Token, r.treeHead(), roots)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp at size %d: %w", r.TreeSize, err)
+	}
+	if !info.GenTime.Equal(r.Time) {
+		return nil, fmt.Errorf("timestamp at size %d: token time %s does not match %s", r.TreeSize, info.GenTime, r.Time)
+	}
+	return info, nil
+}
+
+// treeHead is the data a timestamp token covers.
+func (r *TimestampRecord) treeHead() []byte {
+	return []byte(fmt.Sprintf("mtlog-audit tree head v1\n%d\n%s\n", r.TreeSize, r.RootHash))
+}
+
+// AppendTimestamp durably appends a timestamp record to the log at path.
+func AppendTimestamp(path string, record *TimestampRecord) error {
+	if err := appendJSONLine(path, record); err != nil {
+		return fmt.Errorf("failed to append timestamp: %w", err)
+	}
+	return nil
+}
+
+// ReadTimestamps reads every timestamp record in the log at path, oldest
+// first.
+func ReadTimestamps(path string) ([]*TimestampRecord, error) {
+	var records []*TimestampRecord
+	err := readJSONLines(path, func(line []byte) error {
+		var record TimestampRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		records = append(records, &record)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timestamp log: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,124 @@
+package compliance
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimestampTokens(t *testing.T) {
+	tsa, err := NewLocalTSA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	genTime := time.Now().UTC().Truncate(time.Second).Add(-time.Minute)
+	tsa.SetClock(func() time.Time { return genTime })
+	server := httptest.NewServer(tsa)
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(tsa.Certificate())
+
+	client := NewTSAClient(server.URL)
+	root := sha256.Sum256([]byte("tree head"))
+	record, err := client.TimestampTreeHead(context.Background(), 42, root)
+	if err != nil {
+		t.Fatalf("TimestampTreeHead: %v", err)
+	}
+	if !record.Time.Equal(genTime) || record.TreeSize != 42 || record.TSA != server.URL {
+		t.Errorf("Unexpected timestamp record: %+v", record)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.wal.timestamps")
+	if err := AppendTimestamp(path, record); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadTimestamps(path)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("ReadTimestamps = %d records, %v", len(records), err)
+	}
+
+	info, err := records[0].Verify(roots)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !info.GenTime.Equal(genTime) || info.Certificate.Subject.CommonName != "mtlog-audit local TSA" {
+		t.Errorf("Unexpected token info: %+v", info)
+	}
+
+	// Without roots the token is checked against its own certificate only
+	if _, err := records[0].Verify(nil); err != nil {
+		t.Errorf("Verify without roots: %v", err)
+	}
+
+	other, err := NewLocalTSA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(other.Certificate())
+
+	tests := []struct {
+		name   string
+		tamper func(r *TimestampRecord)
+		roots  *x509.CertPool
+	}{
+		{"untrusted authority", func(*TimestampRecord) {}, otherRoots},
+		{"tree size", func(r *TimestampRecord) { r.TreeSize++ }, roots},
+		{"root hash", func(r *TimestampRecord) { r.RootHash = strings.Repeat("ab", 32) }, roots},
+		{"time", func(r *TimestampRecord) { r.Time = r.Time.Add(-time.Hour) }, roots},
+		{"token", func(r *TimestampRecord) {
+			r.Token = append([]byte(nil), r.Token...)
+			r.Token[len(r.Token)-10] ^= 0xFF
+		}, roots},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := *records[0]
+			tt.tamper(&r)
+			if _, err := r.Verify(tt.roots); err == nil {
+				t.Error("Tampered timestamp verified")
+			}
+		})
+	}
+}
+
+func TestTimestampAuthorityErrors(t *testing.T) {
+	// A rejection is reported, not mistaken for a token
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		reply, _ := rejectTimestamp("policy not supported")
+		_, _ = w.Write(reply)
+	}))
+	defer rejecting.Close()
+	_, _, err := NewTSAClient(rejecting.URL).Timestamp(context.Background(), []byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "policy not supported") {
+		t.Errorf("Expected the rejection reason, got %v", err)
+	}
+
+	// A token over other data is refused
+	tsa, err := NewLocalTSA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(tsa)
+	defer server.Close()
+	token, _, err := NewTSAClient(server.URL).Timestamp(context.Background(), []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyTimestampToken(token, []byte("other data"), nil); err == nil {
+		t.Error("Token verified over different data")
+	}
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	if _, _, err := NewTSAClient(down.URL).Timestamp(context.Background(), []byte("data")); err == nil {
+		t.Error("Expected error from an unreachable authority")
+	}
+}
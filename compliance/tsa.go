@@ -0,0 +1,254 @@
+package compliance
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// localTSAPolicy is the policy LocalTSA issues tokens under, in the arc IANA
+// reserves for documentation (RFC 5612).
+var localTSAPolicy = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 32473, 1}
+
+// essCertIDv2 and signingCertificateV2 bind a token to its signing
+// certificate (RFC 5816). The hash algorithm defaults to SHA-256.
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// LocalTSA is a minimal RFC 3161 timestamp authority with a self-signed
+// certificate, for development and tests. It serves timestamp requests over
+// HTTP. Its tokens prove nothing to a third party that does not trust its
+// certificate, so production deployments should use an independent TSA.
+type LocalTSA struct {
+	key    *ecdsa.PrivateKey
+	cert   *x509.Certificate
+	now    func() time.Time
+	serial *big.Int
+	mu     sync.Mutex
+}
+
+// NewLocalTSA creates a timestamp authority with a fresh key and
+// certificate.
+func NewLocalTSA() (*LocalTSA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TSA key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate certificate serial: %w", err)
+	}
+	// RFC 3161 requires the timestamping key usage to be critical
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 8}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key usage: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    serial,
+		Subject:         pkix.Name{CommonName: "mtlog-audit local TSA"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().AddDate(10, 0, 0),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{2, 5, 29, 37}, Critical: true, Value: eku}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TSA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TSA certificate: %w", err)
+	}
+
+	return &LocalTSA{key: key, cert: cert, now: time.Now, serial: big.NewInt(0)}, nil
+}
+
+// Certificate returns the authority's certificate, the trust root for its
+// tokens.
+func (t *LocalTSA) Certificate() *x509.Certificate {
+	return t.cert
+}
+
+// SetClock replaces the clock tokens are issued with.
+func (t *LocalTSA) SetClock(now func() time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.now = now
+}
+
+// ServeHTTP answers an application/timestamp-query request.
+func (t *LocalTSA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxTimestampResponse))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := t.respond(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	_, _ = w.Write(resp)
+}
+
+// respond builds the DER timestamp response to a DER request.
+func (t *LocalTSA) respond(reqDER []byte) ([]byte, error) {
+	var req timeStampReq
+	if rest, err := asn1.Unmarshal(reqDER, &req); err != nil || len(rest) > 0 {
+		return rejectTimestamp("malformed request")
+	}
+	imprintHash, err := hashForOID(req.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return rejectTimestamp(err.Error())
+	}
+	if len(req.MessageImprint.HashedMessage) != imprintHash.Size() {
+		return rejectTimestamp("message imprint has the wrong length")
+	}
+
+	token, err := t.issue(req)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: timestampStatusGranted},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// rejectTimestamp builds a rejection response.
+func rejectTimestamp(reason string) ([]byte, error) {
+	return asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{Status: timestampStatusRejected, StatusString: []string{reason}},
+	})
+}
+
+// issue signs a timestamp token for req.
+func (t *LocalTSA) issue(req timeStampReq) ([]byte, error) {
+	t.mu.Lock()
+	t.serial.Add(t.serial, big.NewInt(1))
+	serial := new(big.Int).Set(t.serial)
+	genTime := t.now().UTC().Truncate(time.Second)
+	t.mu.Unlock()
+
+	content, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         localTSAPolicy,
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   serial,
+		GenTime:        genTime,
+		Nonce:          req.Nonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode TSTInfo: %w", err)
+	}
+
+	contentDigest := sha256.Sum256(content)
+	certHash := sha256.Sum256(t.cert.Raw)
+	values := []struct {
+		value interface{}
+		oid   asn1.ObjectIdentifier
+	}{
+		{oidTSTInfo, oidAttrContentType},
+		{contentDigest[:], oidAttrMessageDigest},
+		{signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}}, oidAttrSigningCertV2},
+	}
+	attrs := make([]attribute, 0, len(values))
+	for _, v := range values {
+		der, err := asn1.Marshal(v.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode attribute %v: %w", v.oid, err)
+		}
+		attrs = append(attrs, attribute{Type: v.oid, Values: []asn1.RawValue{{FullBytes: der}}})
+	}
+	signedAttrs, err := marshalAttributes(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign the attributes as a SET OF, then store them [0] IMPLICIT
+	attrsDigest := sha256.Sum256(signedAttrs.FullBytes)
+	signature, err := t.key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign timestamp: %w", err)
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: t.cert.RawIssuer},
+		SerialNumber: t.cert.SerialNumber,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signer identifier: %w", err)
+	}
+
+	sha256ID := pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+	sd, err := asn1.Marshal(signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256ID},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidTSTInfo, EContent: content},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: t.cert.Raw},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    sha256ID,
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedAttrs.Bytes},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+			Signature:          signature,
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signed data: %w", err)
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
+
+// marshalAttributes encodes attributes as a DER SET OF, which must be sorted
+// by encoding.
+func marshalAttributes(attrs []attribute) (asn1.RawValue, error) {
+	encoded := make([][]byte, 0, len(attrs))
+	for _, attr := range attrs {
+		der, err := asn1.Marshal(attr)
+		if err != nil {
+			return asn1.RawValue{}, fmt.Errorf("failed to encode attribute: %w", err)
+		}
+		encoded = append(encoded, der)
+	}
+	sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+
+	set := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(encoded, nil)}
+	der, err := asn1.Marshal(set)
+	if err != nil {
+		return asn1.RawValue{}, fmt.Errorf("failed to encode attributes: %w", err)
+	}
+	set.FullBytes = der
+	return set, nil
+}
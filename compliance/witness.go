@@ -0,0 +1,411 @@
+package compliance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// witnessTimeout bounds each request to a witness.
+const witnessTimeout = 10 * time.Second
+
+// maxWitnessMessage bounds the size of witness requests and responses.
+const maxWitnessMessage = 1 << 20
+
+var (
+	// ErrUnknownLog is returned by a witness asked to cosign for a log whose
+	// key it was not configured with.
+	ErrUnknownLog = errors.New("witness does not know this log")
+
+	// ErrInconsistent is returned by a witness when a checkpoint's signature
+	// or consistency proof does not verify.
+	ErrInconsistent = errors.New("checkpoint rejected")
+)
+
+// WitnessConflictError is returned when a request's old tree size is not the
+// size the witness last cosigned. The caller should retry with a consistency
+// proof from Size.
+type WitnessConflictError struct {
+	Size uint64 `json:"tree_size"`
+}
+
+func (e *WitnessConflictError) Error() string {
+	return fmt.Sprintf("witness last cosigned tree size %d", e.Size)
+}
+
+// Cosignature is a witness's signature over a tree head. By cosigning, the
+// witness attests that it verified the log's checkpoint and that the tree
+// is consistent with every earlier tree head it cosigned for that log.
+type Cosignature struct {
+	Timestamp time.Time `json:"timestamp"`
+	Witness   string    `json:"witness"`
+	Algorithm string    `json:"algorithm"`
+	RootHash  string    `json:"root_hash"`
+	Signature []byte    `json:"signature"`
+	TreeSize  uint64    `json:"tree_size"`
+}
+
+// Verify checks the cosignature with the witness's verifier.
+func (c *Cosignature) Verify(verifier Signer) error {
+	if c.Algorithm != verifier.Algorithm() {
+		return fmt.Errorf("cosignature by %s uses %s, not %s", c.Witness, c.Algorithm, verifier.Algorithm())
+	}
+	if err := verifier.Verify(c.signedData(), c.Signature); err != nil {
+		return fmt.Errorf("cosignature by %s at size %d: %w", c.Witness, c.TreeSize, err)
+	}
+	return nil
+}
+
+// signedData is the text a cosignature covers.
+func (c *Cosignature) signedData() []byte {
+	return []byte(fmt.Sprintf("mtlog-audit cosignature v1\n%s\n%s\n%d\n%s\n",
+		c.Witness, c.Timestamp.UTC().Format(time.RFC3339Nano), c.TreeSize, c.RootHash))
+}
+
+// AppendCosignature durably appends a cosignature to the log at path.
+func AppendCosignature(path string, cosig *Cosignature) error {
+	if err := appendJSONLine(path, cosig); err != nil {
+		return fmt.Errorf("failed to append cosignature: %w", err)
+	}
+	return nil
+}
+
+// ReadCosignatures reads every cosignature in the log at path, oldest first.
+func ReadCosignatures(path string) ([]*Cosignature, error) {
+	var cosigs []*Cosignature
+	err := readJSONLines(path, func(line []byte) error {
+		var cosig Cosignature
+		if err := json.Unmarshal(line, &cosig); err != nil {
+			return err
+		}
+		cosigs = append(cosigs, &cosig)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cosignature log: %w", err)
+	}
+	return cosigs, nil
+}
+
+// LogID returns the identifier witnesses know a log by: the hex SHA-256 of
+// its signer's DER-encoded public key.
+func LogID(signer Signer) (string, error) {
+	pemKey, err := MarshalPublicKey(signer)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(pemKey)
+	sum := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// WitnessRequest asks a witness to cosign a checkpoint. Proof is the
+// consistency proof from OldSize, the size the witness last cosigned, to the
+// checkpoint's size.
+type WitnessRequest struct {
+	Checkpoint *Checkpoint `json:"checkpoint"`
+	LogID      string      `json:"log_id"`
+	Proof      []string    `json:"proof"`
+	OldSize    uint64      `json:"old_size"`
+}
+
+// WitnessInfo identifies a witness and its public key.
+type WitnessInfo struct {
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+}
+
+// witnessedHead is the latest tree head a witness cosigned for a log.
+type witnessedHead struct {
+	RootHash string `json:"root_hash"`
+	TreeSize uint64 `json:"tree_size"`
+}
+
+// Witness cosigns checkpoints for the logs it is configured with, refusing
+// any checkpoint that is not an append-only extension of the last one it
+// cosigned. It serves the protocol over HTTP.
+type Witness struct {
+	signer    Signer
+	logs      map[string]Signer
+	heads     map[string]witnessedHead
+	name      string
+	statePath string
+	mu        sync.Mutex
+}
+
+// NewWitness creates a witness that signs as name with signer and accepts
+// checkpoints from logs signed by logKeys. The last tree head cosigned for
+// each log is kept in statePath so the witness stays consistent across
+// restarts; an empty statePath keeps it in memory.
+func NewWitness(name string, signer Signer, statePath string, logKeys ...Signer) (*Witness, error) {
+	if name == "" {
+		return nil, fmt.Errorf("witness name cannot be empty")
+	}
+	if len(logKeys) == 0 {
+		return nil, fmt.Errorf("witness needs at least one log key")
+	}
+
+	w := &Witness{
+		signer:    signer,
+		name:      name,
+		statePath: statePath,
+		logs:      make(map[string]Signer, len(logKeys)),
+		heads:     make(map[string]witnessedHead),
+	}
+	for _, key := range logKeys {
+		id, err := LogID(key)
+		if err != nil {
+			return nil, err
+		}
+		w.logs[id] = key
+	}
+
+	if statePath != "" {
+		data, err := os.ReadFile(statePath) // #nosec G304 - witness state path from user configuration
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read witness state: %w", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &w.heads); err != nil {
+				return nil, fmt.Errorf("failed to parse witness state: %w", err)
+			}
+		}
+	}
+	return w, nil
+}
+
+// Info returns the witness's name and public key.
+func (w *Witness) Info() (*WitnessInfo, error) {
+	pemKey, err := MarshalPublicKey(w.signer)
+	if err != nil {
+		return nil, err
+	}
+	return &WitnessInfo{Name: w.name, Algorithm: w.signer.Algorithm(), PublicKey: string(pemKey)}, nil
+}
+
+// Cosign verifies req and cosigns its checkpoint.
+func (w *Witness) Cosign(req *WitnessRequest) (*Cosignature, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	logKey, ok := w.logs[req.LogID]
+	if !ok {
+		return nil, ErrUnknownLog
+	}
+	cp := req.Checkpoint
+	if cp == nil {
+		return nil, fmt.Errorf("%w: no checkpoint", ErrInconsistent)
+	}
+	if err := cp.Verify(logKey); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInconsistent, err)
+	}
+
+	prev := w.heads[req.LogID]
+	if req.OldSize != prev.TreeSize {
+		return nil, &WitnessConflictError{Size: prev.TreeSize}
+	}
+
+	root, err := cp.Root()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInconsistent, err)
+	}
+	var prevRoot [32]byte
+	if prev.TreeSize > 0 {
+		if prevRoot, err = (&Checkpoint{RootHash: prev.RootHash}).Root(); err != nil {
+			return nil, fmt.Errorf("corrupt witness state: %w", err)
+		}
+	}
+	proof := make([][32]byte, len(req.Proof))
+	for i, h := range req.Proof {
+		decoded, err := hex.DecodeString(h)
+		if err != nil || len(decoded) != 32 {
+			return nil, fmt.Errorf("%w: invalid proof hash %q", ErrInconsistent, h)
+		}
+		copy(proof[i][:], decoded)
+	}
+	if err := VerifyConsistency(prev.TreeSize, cp.TreeSize, prevRoot, root, proof); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInconsistent, err)
+	}
+
+	cosig := &Cosignature{
+		Witness:   w.name,
+		Algorithm: w.signer.Algorithm(),
+		Timestamp: time.Now().UTC(),
+		RootHash:  cp.RootHash,
+		TreeSize:  cp.TreeSize,
+	}
+	if cosig.Signature, err = w.signer.Sign(cosig.signedData()); err != nil {
+		return nil, fmt.Errorf("failed to cosign: %w", err)
+	}
+
+	// Remember the head before answering, so a restart never cosigns a fork
+	w.heads[req.LogID] = witnessedHead{TreeSize: cp.TreeSize, RootHash: cp.RootHash}
+	if err := w.save(); err != nil {
+		w.heads[req.LogID] = prev
+		return nil, err
+	}
+	return cosig, nil
+}
+
+// save persists the witnessed heads. The caller must hold w.mu.
+func (w *Witness) save() error {
+	if w.statePath == "" {
+		return nil
+	}
+	if err := writeJSONFile(w.statePath, w.heads); err != nil {
+		return fmt.Errorf("failed to save witness state: %w", err)
+	}
+	return nil
+}
+
+// ServeHTTP answers GET with the witness's WitnessInfo and POST with a
+// cosignature for the WitnessRequest in the body.
+func (w *Witness) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		info, err := w.Info()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(rw, http.StatusOK, info)
+
+	case http.MethodPost:
+		var req WitnessRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxWitnessMessage)).Decode(&req); err != nil {
+			http.Error(rw, "malformed witness request", http.StatusBadRequest)
+			return
+		}
+
+		cosig, err := w.Cosign(&req)
+		var conflict *WitnessConflictError
+		switch {
+		case err == nil:
+			writeJSON(rw, http.StatusOK, cosig)
+		case errors.As(err, &conflict):
+			writeJSON(rw, http.StatusConflict, conflict)
+		case errors.Is(err, ErrUnknownLog):
+			http.Error(rw, err.Error(), http.StatusForbidden)
+		case errors.Is(err, ErrInconsistent):
+			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
+
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+// WitnessClient submits checkpoints to a witness over HTTP.
+type WitnessClient struct {
+	client *http.Client
+	url    string
+}
+
+// NewWitnessClient creates a client for the witness at url.
+func NewWitnessClient(url string) *WitnessClient {
+	return &WitnessClient{url: url, client: &http.Client{Timeout: witnessTimeout}}
+}
+
+// URL returns the witness's URL.
+func (c *WitnessClient) URL() string {
+	return c.url
+}
+
+// Cosign submits req and returns the witness's cosignature. A
+// *WitnessConflictError means the witness expects a proof from another size.
+func (c *WitnessClient) Cosign(ctx context.Context, req *WitnessRequest) (*Cosignature, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode witness request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create witness request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("witness request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxWitnessMessage))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read witness response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var cosig Cosignature
+		if err := json.Unmarshal(data, &cosig); err != nil {
+			return nil, fmt.Errorf("malformed cosignature: %w", err)
+		}
+		if cosig.TreeSize != req.Checkpoint.TreeSize || cosig.RootHash != req.Checkpoint.RootHash {
+			return nil, fmt.Errorf("witness cosigned a different tree head")
+		}
+		return &cosig, nil
+	case http.StatusConflict:
+		var conflict WitnessConflictError
+		if err := json.Unmarshal(data, &conflict); err != nil {
+			return nil, fmt.Errorf("malformed witness conflict: %w", err)
+		}
+		return nil, &conflict
+	default:
+		return nil, fmt.Errorf("witness returned HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+}
+
+// Info fetches the witness's name and public key.
+func (c *WitnessClient) Info(ctx context.Context) (*WitnessInfo, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create witness request: %w", err)
+	}
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("witness request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("witness returned HTTP %d", resp.StatusCode)
+	}
+	var info WitnessInfo
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxWitnessMessage)).Decode(&info); err != nil {
+		return nil, fmt.Errorf("malformed witness info: %w", err)
+	}
+	return &info, nil
+}
+
+// ParseWitnessKey returns a verify-only signer for a witness's PEM public
+// key, as published in WitnessInfo.
+func ParseWitnessKey(info *WitnessInfo) (Signer, error) {
+	key, err := ParsePublicKey([]byte(info.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("witness %s: %w", info.Name, err)
+	}
+	if key.Algorithm() != info.Algorithm {
+		return nil, fmt.Errorf("witness %s key is %s, not %s", info.Name, key.Algorithm(), info.Algorithm)
+	}
+	return key, nil
+}
@@ -0,0 +1,144 @@
+package compliance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// witnessedTree returns a tree of n leaves.
+func witnessedTree(n int) *MerkleTree {
+	tree := NewMerkleTree()
+	for i := 0; i < n; i++ {
+		tree.Append(MerkleLeafHash([]byte{byte(i)}))
+	}
+	return tree
+}
+
+func TestWitnessCosigning(t *testing.T) {
+	logSigner, err := NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnessSigner, err := NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID, err := LogID(logSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statePath := filepath.Join(t.TempDir(), "witness.json")
+	witness, err := NewWitness("witness-a", witnessSigner, statePath, logSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(witness)
+	defer server.Close()
+	client := NewWitnessClient(server.URL)
+
+	tree := witnessedTree(7)
+	request := func(oldSize, size uint64, root [32]byte) *WitnessRequest {
+		t.Helper()
+		cp, err := SignCheckpoint(logSigner, size, root, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := tree.ConsistencyProof(oldSize, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var path []string
+		for _, h := range proof {
+			path = append(path, hex.EncodeToString(h[:]))
+		}
+		return &WitnessRequest{LogID: logID, Checkpoint: cp, OldSize: oldSize, Proof: path}
+	}
+	rootAt := func(size uint64) [32]byte {
+		root, err := tree.RootAt(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+
+	ctx := context.Background()
+	first, err := client.Cosign(ctx, request(0, 3, rootAt(3)))
+	if err != nil {
+		t.Fatalf("First cosign: %v", err)
+	}
+	second, err := client.Cosign(ctx, request(3, 7, rootAt(7)))
+	if err != nil {
+		t.Fatalf("Consistent cosign: %v", err)
+	}
+
+	// Cosignatures verify with the witness's published key
+	info, err := client.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := ParseWitnessKey(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cosig := range []*Cosignature{first, second} {
+		if err := cosig.Verify(verifier); err != nil {
+			t.Errorf("Cosignature at size %d: %v", cosig.TreeSize, err)
+		}
+	}
+	tampered := *second
+	tampered.TreeSize = 6
+	if err := tampered.Verify(verifier); err == nil {
+		t.Error("Tampered cosignature verified")
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.wal.cosignatures")
+	for _, cosig := range []*Cosignature{first, second} {
+		if err := AppendCosignature(path, cosig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cosigs, err := ReadCosignatures(path); err != nil || len(cosigs) != 2 || cosigs[1].Witness != "witness-a" {
+		t.Errorf("ReadCosignatures = %v, %v", cosigs, err)
+	}
+
+	// A stale old size is answered with the size the witness holds
+	var conflict *WitnessConflictError
+	if _, err := client.Cosign(ctx, request(3, 7, rootAt(7))); !errors.As(err, &conflict) || conflict.Size != 7 {
+		t.Errorf("Expected a conflict at size 7, got %v", err)
+	}
+
+	// A fork of the witnessed history is refused, including after a restart
+	restarted, err := NewWitness("witness-a", witnessSigner, statePath, logSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forked := request(7, 7, sha256.Sum256([]byte("fork")))
+	if _, err := restarted.Cosign(forked); !errors.Is(err, ErrInconsistent) {
+		t.Errorf("Expected a fork to be refused, got %v", err)
+	}
+
+	// Checkpoints from logs the witness does not know are refused
+	stranger, err := NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := request(7, 7, rootAt(7))
+	unknown.LogID, _ = LogID(stranger)
+	if _, err := restarted.Cosign(unknown); !errors.Is(err, ErrUnknownLog) {
+		t.Errorf("Expected an unknown log error, got %v", err)
+	}
+
+	// So are checkpoints not signed by the log's key
+	unsigned := request(7, 7, rootAt(7))
+	unsigned.Checkpoint.Signature[0] ^= 0xFF
+	if _, err := restarted.Cosign(unsigned); !errors.Is(err, ErrInconsistent) {
+		t.Errorf("Expected a bad checkpoint signature to be refused, got %v", err)
+	}
+}
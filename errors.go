@@ -16,6 +16,14 @@ var (
 	// ErrIntegrityFailed indicates an integrity check failed.
 	ErrIntegrityFailed = errors.New("integrity check failed")
 
+	// ErrNoBackends is returned when a backend durability level is requested
+	// from a sink without backends.
+	ErrNoBackends = errors.New("no backends configured")
+
+	// ErrRollbackDetected indicates the WAL was truncated or rolled back
+	// behind its signed high-water mark.
+	ErrRollbackDetected = errors.New("audit log rollback detected")
+
 	// ErrComplianceViolation indicates a compliance requirement was violated.
 	ErrComplianceViolation = errors.New("compliance violation")
 )
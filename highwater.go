@@ -0,0 +1,312 @@
+package audit
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/backends"
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/wal"
+	"github.com/willibrandon/mtlog/core"
+)
+
+// markStore is somewhere the high-water mark of the WAL with a given ID is
+// mirrored.
+type markStore interface {
+	// load returns the recorded mark, or nil if none was recorded yet.
+	load(id string) (*compliance.HighWaterMark, error)
+	save(id string, mark *compliance.HighWaterMark) error
+	String() string
+}
+
+// fileMarkStore keeps the high-water mark in a local file.
+type fileMarkStore struct {
+	path string
+}
+
+func (s fileMarkStore) load(string) (*compliance.HighWaterMark, error) {
+	mark, err := compliance.ReadHighWaterMark(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return mark, err
+}
+
+func (s fileMarkStore) save(_ string, mark *compliance.HighWaterMark) error {
+	return compliance.WriteHighWaterMark(s.path, mark)
+}
+
+func (s fileMarkStore) String() string {
+	return s.path
+}
+
+// backendMarkStore keeps the high-water mark as backend metadata, named
+// after the WAL and its ID so WALs sharing a backend keep their own marks.
+type backendMarkStore struct {
+	store   backends.MetadataStore
+	name    string
+	walName string
+}
+
+// object returns the name of the metadata object holding the mark of the
+// WAL with id.
+func (s backendMarkStore) object(id string) string {
+	return HighWaterMarkObject(s.walName, id)
+}
+
+func (s backendMarkStore) load(id string) (*compliance.HighWaterMark, error) {
+	if id == "" {
+		return nil, nil
+	}
+	data, err := s.store.GetMetadata(s.object(id))
+	if errors.Is(err, backends.ErrMetadataNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mark compliance.HighWaterMark
+	if err := json.Unmarshal(data, &mark); err != nil {
+		return nil, fmt.Errorf("failed to parse high-water mark: %w", err)
+	}
+	return &mark, nil
+}
+
+func (s backendMarkStore) save(id string, mark *compliance.HighWaterMark) error {
+	data, err := json.Marshal(mark)
+	if err != nil {
+		return fmt.Errorf("failed to marshal high-water mark: %w", err)
+	}
+	return s.store.PutMetadata(s.object(id), data)
+}
+
+// HighWaterMarkObject returns the name of the backend metadata object the
+// sink mirrors the high-water mark of the WAL at walPath with ID id to.
+func HighWaterMarkObject(walPath, id string) string {
+	return filepath.Base(walPath) + "." + id + ".hwm"
+}
+
+func (s backendMarkStore) String() string {
+	return "backend " + s.name
+}
+
+// highWaterMarker periodically signs the WAL head into every mark store.
+// Once a rollback is found it stops writing marks, so the highest one stays
+// on record as evidence.
+type highWaterMarker struct {
+	wal    *wal.WAL
+	signer compliance.Signer
+	stop   chan struct{}
+	done   chan struct{}
+	// highest is the highest mark recorded, by any store or by this marker
+	highest *compliance.HighWaterMark
+	// rollback is the violation check found, reported on every record
+	rollback error
+	id       string
+	stores   []markStore
+	interval time.Duration
+	lastSeq  uint64
+	mu       sync.Mutex
+}
+
+// newHighWaterMarker mirrors the high-water mark of w to the local file at
+// path, if set, and to every backend that stores metadata.
+func newHighWaterMarker(w *wal.WAL, signer compliance.Signer, walPath, path string, sinks []backends.Backend, interval time.Duration) (*highWaterMarker, error) {
+	h := &highWaterMarker{
+		wal:      w,
+		signer:   signer,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if path != "" {
+		h.stores = append(h.stores, fileMarkStore{path: path})
+	}
+	for _, backend := range sinks {
+		if store, ok := backend.(backends.MetadataStore); ok {
+			h.stores = append(h.stores, backendMarkStore{store: store, name: backend.Name(), walName: walPath})
+		}
+	}
+	if len(h.stores) == 0 {
+		return nil, fmt.Errorf("high-water mark needs a local path or a backend that stores metadata")
+	}
+	return h, nil
+}
+
+// walID returns the WAL's ID, which is empty until its first record.
+func (h *highWaterMarker) walID() (string, error) {
+	if h.id == "" {
+		id, err := h.wal.ID()
+		if err != nil {
+			return "", fmt.Errorf("failed to identify WAL: %w", err)
+		}
+		h.id = id
+	}
+	return h.id, nil
+}
+
+// check fails with ErrRollbackDetected when the WAL is behind the highest
+// recorded mark, has a different record at its sequence, or is not the WAL
+// the mark was signed for. A store that cannot be read only warns; a mark
+// that does not verify is a violation.
+func (h *highWaterMarker) check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.rollback = h.findRollback()
+	return h.rollback
+}
+
+// findRollback compares the WAL with every store's mark. The caller must
+// hold h.mu.
+func (h *highWaterMarker) findRollback() error {
+	id, err := h.walID()
+	if err != nil {
+		return err
+	}
+
+	var forged error
+	var source markStore
+	for _, store := range h.stores {
+		mark, err := store.load(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read high-water mark from %s: %v\n", store, err)
+			continue
+		}
+		if mark == nil {
+			continue
+		}
+		if err := mark.Verify(h.signer); err != nil {
+			if forged == nil {
+				forged = fmt.Errorf("%w: %s holds a forged high-water mark: %w", ErrRollbackDetected, store, err)
+			}
+			continue
+		}
+		if h.highest == nil || mark.Sequence > h.highest.Sequence {
+			h.highest, source = mark, store
+		}
+	}
+	if forged != nil {
+		return forged
+	}
+	if h.highest == nil {
+		return nil
+	}
+	highest := h.highest
+
+	if highest.Log != id {
+		return fmt.Errorf("%w: %s recorded sequence %d of WAL %s, not of this WAL %s",
+			ErrRollbackDetected, source, highest.Sequence, highest.Log, id)
+	}
+	head, _, err := h.wal.SyncedHead()
+	if err != nil {
+		return err
+	}
+	if head < highest.Sequence {
+		return fmt.Errorf("%w: WAL ends at sequence %d but %s recorded sequence %d at %s",
+			ErrRollbackDetected, head, source, highest.Sequence, highest.Timestamp.Format(time.RFC3339))
+	}
+	hash, err := h.wal.RecordHash(highest.Sequence)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRollbackDetected, err)
+	}
+	if hex.EncodeToString(hash[:]) != highest.Hash {
+		return fmt.Errorf("%w: record %d differs from the one %s recorded", ErrRollbackDetected, highest.Sequence, source)
+	}
+	h.lastSeq = highest.Sequence
+	return nil
+}
+
+// run records the high-water mark every interval while the log grows.
+func (h *highWaterMarker) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			if err := h.record(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: high-water mark failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// record signs the WAL head and saves it to every store. It does nothing
+// when the log has not grown since the last mark every store accepted, and
+// never replaces a higher mark: after a rollback it only reports it again.
+func (h *highWaterMarker) record() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.rollback != nil {
+		return h.rollback
+	}
+	seq, hash, err := h.wal.SyncedHead()
+	if err != nil {
+		return err
+	}
+	if seq == 0 || seq == h.lastSeq {
+		return nil
+	}
+	if h.highest != nil && seq < h.highest.Sequence {
+		h.rollback = fmt.Errorf("%w: WAL ends at sequence %d below its high-water mark at sequence %d",
+			ErrRollbackDetected, seq, h.highest.Sequence)
+		return h.rollback
+	}
+	id, err := h.walID()
+	if err != nil {
+		return err
+	}
+
+	mark, err := compliance.SignHighWaterMark(h.signer, id, seq, hash, time.Now())
+	if err != nil {
+		return err
+	}
+	var failed int
+	var firstErr error
+	for _, store := range h.stores {
+		if err := store.save(id, mark); err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", store, err)
+			}
+		}
+	}
+	h.highest = mark
+	if failed > 0 {
+		return fmt.Errorf("failed to save high-water mark to %d of %d stores: %w", failed, len(h.stores), firstErr)
+	}
+	h.lastSeq = seq
+	return nil
+}
+
+// close stops the ticker and records the final high-water mark. A store
+// that cannot be written only warns.
+func (h *highWaterMarker) close() {
+	close(h.stop)
+	<-h.done
+	if err := h.record(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: final high-water mark failed: %v\n", err)
+	}
+}
+
+// rollbackEvent describes a detected rollback for the FailureHandler.
+func rollbackEvent(err error) *core.LogEvent {
+	return &core.LogEvent{
+		Timestamp:       time.Now(),
+		Level:           core.FatalLevel,
+		MessageTemplate: "Audit log rollback detected: {Error}",
+		Properties:      map[string]interface{}{"Error": err.Error()},
+	}
+}
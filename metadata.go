@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/wal"
+)
+
+// walMetadata describes how the sink writes its WAL. The WAL adds its own
+// algorithms and encryption keys.
+func walMetadata(config *Config, engine *compliance.Engine) (wal.Metadata, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return wal.Metadata{}, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	metadata := wal.Metadata{
+		Host:              host,
+		Application:       config.Application,
+		ComplianceProfile: config.ComplianceProfile,
+	}
+
+	if engine != nil && engine.Signer() != nil {
+		signer := engine.Signer()
+		fingerprint, err := compliance.LogID(signer)
+		if err != nil {
+			return wal.Metadata{}, fmt.Errorf("failed to fingerprint signing key: %w", err)
+		}
+		metadata.SigningAlgorithm = signer.Algorithm()
+		metadata.SigningKey = "SHA256:" + fingerprint
+	}
+	return metadata, nil
+}
@@ -52,9 +52,11 @@ type Monitor struct {
 	windowSize     int
 	windowIndex    int
 	updateInterval time.Duration
+	walIntegrity   float64
 	mu             sync.RWMutex
 	started        atomic.Bool
 	enableProfiler bool
+	walScrubbed    bool
 }
 
 // Option configures the monitor
@@ -269,15 +271,8 @@ func (m *Monitor) updateMetrics(lastEventCount *int64) {
 	}
 	UpdateErrorRate("sink", errorRate)
 
-	// Update integrity score (simplified calculation)
-	integrityScore := 100.0
-	if errorRate > 0 {
-		integrityScore = 100.0 * (1.0 - errorRate)
-		if integrityScore < 0 {
-			integrityScore = 0
-		}
-	}
-	UpdateIntegrityScore(integrityScore)
+	// Update integrity score
+	UpdateIntegrityScore(m.integrityScore())
 
 	// Update memory usage if profiler is enabled
 	if m.enableProfiler {
@@ -287,6 +282,40 @@ func (m *Monitor) updateMetrics(lastEventCount *int64) {
 	}
 }
 
+// UpdateWALIntegrity records the percentage of sealed WAL segments the last
+// scrub found intact or repaired. The published integrity score is the lower
+// of it and the score derived from the error rate.
+func (m *Monitor) UpdateWALIntegrity(score float64) {
+	m.mu.Lock()
+	m.walIntegrity = score
+	m.walScrubbed = true
+	m.mu.Unlock()
+
+	UpdateIntegrityScore(m.integrityScore())
+}
+
+// integrityScore derives the integrity score from the error rate and the
+// last WAL scrub.
+func (m *Monitor) integrityScore() float64 {
+	events := atomic.LoadInt64(&m.eventCount)
+	errors := atomic.LoadInt64(&m.errorCount)
+
+	score := 100.0
+	if events > 0 && errors > 0 {
+		score = 100.0 * (1.0 - float64(errors)/float64(events))
+		if score < 0 {
+			score = 0
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.walScrubbed && m.walIntegrity < score {
+		score = m.walIntegrity
+	}
+	return score
+}
+
 // Stats contains monitor statistics
 type Stats struct {
 	LastEventTime time.Time
@@ -2,6 +2,8 @@ package audit
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/willibrandon/mtlog-audit/backends"
@@ -15,19 +17,40 @@ type Option func(*Config) error
 
 // Config holds the audit sink configuration.
 type Config struct {
-	FailureHandler        FailureHandler
-	ComplianceProfile     string
-	WALPath               string
-	MetricsOptions        []interface{}
-	WALOptions            []wal.Option
-	ComplianceOptions     []compliance.Option
-	BackendConfigs        []backends.Config
-	CircuitBreakerOptions []interface{}
-	RetryPolicy           RetryPolicy
-	GroupCommitSize       int
-	GroupCommitDelay      time.Duration
-	GroupCommit           bool
-	PanicOnFailure        bool
+	FailureHandler           FailureHandler
+	Encryption               wal.Keyring
+	ComplianceProfile        string
+	Application              string
+	WALPath                  string
+	TimestampURL             string
+	MetricsOptions           []interface{}
+	WitnessURLs              []string
+	HighWaterMarkPath        string
+	WALOptions               []wal.Option
+	ComplianceOptions        []compliance.Option
+	BackendConfigs           []backends.Config
+	CircuitBreakerOptions    []interface{}
+	RetryPolicy              RetryPolicy
+	ReplicationRetryInterval time.Duration
+	ReplicationDrainTimeout  time.Duration
+	ReplicationBatchDelay    time.Duration
+	ReplicationBlockTimeout  time.Duration
+	CheckpointInterval       time.Duration
+	TimestampInterval        time.Duration
+	HighWaterMarkInterval    time.Duration
+	ScrubInterval            time.Duration
+	ScrubRate                int64
+	ReplicationQueueSize     int
+	ReplicationBatchSize     int
+	ReplicationOverflow      OverflowPolicy
+	WitnessQuorum            int
+	GroupCommitSize          int
+	GroupCommitDelay         time.Duration
+	GroupCommit              bool
+	ComplianceEncryption     bool
+	ComplianceSigning        bool
+	Metadata                 bool
+	PanicOnFailure           bool
 }
 
 // FailureHandler is called when audit write fails.
@@ -41,11 +64,13 @@ type RetryPolicy struct {
 	Multiplier   float64
 }
 
-// WithWAL configures the write-ahead log path.
+// WithWAL configures the write-ahead log path. Its WAL options are appended
+// to those set by earlier options, such as WithWALSyncPolicy, and apply in
+// order, so a later option overrides an earlier one.
 func WithWAL(path string, opts ...wal.Option) Option {
 	return func(c *Config) error {
 		c.WALPath = path
-		c.WALOptions = opts
+		c.WALOptions = append(c.WALOptions, opts...)
 		return nil
 	}
 }
@@ -66,6 +91,152 @@ func WithComplianceOptions(opts ...compliance.Option) Option {
 	}
 }
 
+// WithEncryption encrypts WAL records at rest with keys, typically a
+// *compliance.KeyManager. Sequence numbers, CRCs and the hash chain stay in
+// the clear, so integrity can be verified without the keys.
+func WithEncryption(keys wal.Keyring) Option {
+	return func(c *Config) error {
+		if keys == nil {
+			return fmt.Errorf("keyring cannot be nil")
+		}
+		c.Encryption = keys
+		return nil
+	}
+}
+
+// WithComplianceEncryption encrypts WAL records with the compliance engine's
+// KeyManager. The profile must require encryption. Supply a persistent key
+// manager with compliance.WithKeyManager(compliance.OpenKeyManager(...)) or a
+// key with compliance.WithEncryptionKey, otherwise a key is generated per
+// process and records written before a restart cannot be decrypted.
+func WithComplianceEncryption() Option {
+	return func(c *Config) error {
+		c.ComplianceEncryption = true
+		return nil
+	}
+}
+
+// WithComplianceSigning signs every WAL record with the compliance engine's
+// signer and persists the signature chain to <wal>.sig, unless
+// compliance.WithSignatureLog names another path. Each completed segment is
+// also sealed into <wal>.seals. The profile must require signing. Supply a
+// persistent key with compliance.WithSigner(compliance.OpenSigner(...)); a
+// per-process key cannot reopen an existing signature log.
+func WithComplianceSigning() Option {
+	return func(c *Config) error {
+		c.ComplianceSigning = true
+		return nil
+	}
+}
+
+// WithCheckpoints signs the WAL's Merkle tree size and root hash with the
+// compliance engine's signer every interval, appending the checkpoints to
+// <wal>.checkpoints. A final checkpoint is written on Close. Consistency
+// proofs between checkpoints show the log was only appended to. The profile
+// must require signing.
+func WithCheckpoints(interval time.Duration) Option {
+	return func(c *Config) error {
+		if interval <= 0 {
+			return fmt.Errorf("checkpoint interval must be positive")
+		}
+		c.CheckpointInterval = interval
+		return nil
+	}
+}
+
+// WithTimestamping obtains an RFC 3161 timestamp token from the timestamp
+// authority at tsaURL every interval, over the WAL's Merkle tree size and
+// root hash, and appends it to <wal>.timestamps. The tokens prove when
+// records existed independently of the host clock. A timestamp authority
+// outage is reported but never blocks writes.
+func WithTimestamping(tsaURL string, interval time.Duration) Option {
+	return func(c *Config) error {
+		if tsaURL == "" {
+			return fmt.Errorf("timestamp authority URL cannot be empty")
+		}
+		if interval <= 0 {
+			return fmt.Errorf("timestamp interval must be positive")
+		}
+		c.TimestampURL = tsaURL
+		c.TimestampInterval = interval
+		return nil
+	}
+}
+
+// WithWitnesses publishes every checkpoint to the witnesses at urls. Each
+// witness checks the checkpoint is consistent with the last one it saw and
+// returns a cosignature, which is appended to <wal>.cosignatures. Fewer than
+// quorum cosignatures is reported but never blocks writes. It requires
+// WithCheckpoints.
+func WithWitnesses(quorum int, urls ...string) Option {
+	return func(c *Config) error {
+		if len(urls) == 0 {
+			return fmt.Errorf("at least one witness URL is required")
+		}
+		if quorum < 1 || quorum > len(urls) {
+			return fmt.Errorf("witness quorum must be between 1 and %d", len(urls))
+		}
+		c.WitnessURLs = urls
+		c.WitnessQuorum = quorum
+		return nil
+	}
+}
+
+// WithHighWaterMark signs the WAL's ID, last sequence and chain hash every
+// interval and on Close, mirroring the mark to every backend that stores
+// metadata, as <wal>.<id>.hwm, and to localPath when it is set. New refuses to
+// open a WAL that is behind its mark, has a different record at its sequence
+// or is not the WAL the mark was signed for, unless a FailureHandler is set,
+// which is then told of the violation. A recorded mark is never lowered: after
+// a rollback no more marks are written and each attempt warns again. The
+// profile must require signing.
+func WithHighWaterMark(interval time.Duration, localPath string) Option {
+	return func(c *Config) error {
+		if interval <= 0 {
+			return fmt.Errorf("high-water mark interval must be positive")
+		}
+		c.HighWaterMarkInterval = interval
+		c.HighWaterMarkPath = localPath
+		return nil
+	}
+}
+
+// WithScrubbing re-verifies every sealed WAL segment every interval, reading
+// at no more than bytesPerSecond so the scrub doesn't compete with writes; 0
+// reads unthrottled. Damaged records are rebuilt from the segment's parity
+// sidecar, then from a filesystem backend's shadow copy, then from any backend
+// that holds them. The share of intact segments is reported as the integrity
+// score. Records of an encrypted WAL can only be repaired from parity.
+func WithScrubbing(interval time.Duration, bytesPerSecond int64) Option {
+	return func(c *Config) error {
+		if interval <= 0 {
+			return fmt.Errorf("scrub interval must be positive")
+		}
+		if bytesPerSecond < 0 {
+			return fmt.Errorf("scrub rate cannot be negative")
+		}
+		c.ScrubInterval = interval
+		c.ScrubRate = bytesPerSecond
+		return nil
+	}
+}
+
+// WithMetadata makes the WAL self-describing. A new WAL starts with a genesis
+// record naming the host, application, compliance profile, algorithms and
+// key fingerprints it is written with. A metadata record is chained when the
+// sink opens with any of these changed, and as soon as a record is encrypted
+// with a rotated key. An empty application defaults to the executable's name.
+func WithMetadata(application string) Option {
+	return func(c *Config) error {
+		if application == "" {
+			application = filepath.Base(os.Args[0])
+		}
+		c.Application = application
+		c.Metadata = true
+		return nil
+	}
+}
+
 // WithCircuitBreakerOptions adds circuit breaker configuration options.
 func WithCircuitBreakerOptions(opts ...interface{}) Option {
 	return func(c *Config) error {
@@ -106,6 +277,27 @@ func WithWALSyncMode(mode wal.SyncMode) Option {
 	}
 }
 
+// WithWALSyncPolicy sets an adaptive sync policy for the WAL, such as
+// wal.SyncEveryBytes, wal.SyncEveryRecords or wal.SyncLatencyTarget.
+func WithWALSyncPolicy(policy wal.SyncPolicy) Option {
+	return func(c *Config) error {
+		if policy == nil {
+			return fmt.Errorf("sync policy cannot be nil")
+		}
+		c.WALOptions = append(c.WALOptions, wal.WithSyncPolicy(policy))
+		return nil
+	}
+}
+
+// WithPrioritySync forces an immediate WAL sync for events at or above level
+// and for events flagged with the wal.CriticalProperty property.
+func WithPrioritySync(level core.LogEventLevel) Option {
+	return func(c *Config) error {
+		c.WALOptions = append(c.WALOptions, wal.WithPrioritySync(level))
+		return nil
+	}
+}
+
 // WithBackend adds a backend configuration.
 func WithBackend(backend backends.Config) Option {
 	return func(c *Config) error {
@@ -118,7 +310,11 @@ func WithBackend(backend backends.Config) Option {
 }
 
 // WithGroupCommit enables group commit for better throughput.
-// This automatically sets the WAL to use batch sync mode for performance.
+// Concurrent writes are committed together in batches of up to size events,
+// or after delay, with one journal write and one fsync per batch. Each write
+// still returns only once its own batch is durable: the committer syncs each
+// batch itself, whatever the WAL's sync policy. The WAL is set to batch sync
+// mode so writes outside the committer don't fsync individually.
 func WithGroupCommit(size int, delay time.Duration) Option {
 	return func(c *Config) error {
 		if size <= 0 {
@@ -156,6 +352,75 @@ func WithRetryPolicy(policy RetryPolicy) Option {
 	}
 }
 
+// WithReplicationRetryInterval sets how often a lagging backend is retried
+// from its replication cursor.
+func WithReplicationRetryInterval(interval time.Duration) Option {
+	return func(c *Config) error {
+		if interval <= 0 {
+			return fmt.Errorf("replication retry interval must be positive")
+		}
+		c.ReplicationRetryInterval = interval
+		return nil
+	}
+}
+
+// WithReplicationDrainTimeout bounds how long Close waits for backends to catch up.
+// Records not delivered in time are replicated on the next start.
+func WithReplicationDrainTimeout(timeout time.Duration) Option {
+	return func(c *Config) error {
+		if timeout < 0 {
+			return fmt.Errorf("replication drain timeout cannot be negative")
+		}
+		c.ReplicationDrainTimeout = timeout
+		return nil
+	}
+}
+
+// WithReplicationQueue bounds the number of events buffered per backend and
+// sets what happens when the buffer is full. Overflowing events are never
+// dropped from the audit trail; they stay in the WAL until the backend catches up.
+func WithReplicationQueue(size int, policy OverflowPolicy) Option {
+	return func(c *Config) error {
+		if size <= 0 {
+			return fmt.Errorf("replication queue size must be positive")
+		}
+		if policy != OverflowSpill && policy != OverflowBlock {
+			return fmt.Errorf("invalid replication overflow policy: %d", policy)
+		}
+		c.ReplicationQueueSize = size
+		c.ReplicationOverflow = policy
+		return nil
+	}
+}
+
+// WithReplicationBlockTimeout bounds how long Emit blocks on a full queue
+// under OverflowBlock before spilling the event to WAL catch-up.
+func WithReplicationBlockTimeout(timeout time.Duration) Option {
+	return func(c *Config) error {
+		if timeout <= 0 {
+			return fmt.Errorf("replication block timeout must be positive")
+		}
+		c.ReplicationBlockTimeout = timeout
+		return nil
+	}
+}
+
+// WithReplicationBatch sets how many events are coalesced into one backend
+// WriteBatch call and how long to wait for a batch to fill.
+func WithReplicationBatch(size int, delay time.Duration) Option {
+	return func(c *Config) error {
+		if size <= 0 {
+			return fmt.Errorf("replication batch size must be positive")
+		}
+		if delay <= 0 {
+			return fmt.Errorf("replication batch delay must be positive")
+		}
+		c.ReplicationBatchSize = size
+		c.ReplicationBatchDelay = delay
+		return nil
+	}
+}
+
 // defaultConfig returns the default configuration.
 func defaultConfig() *Config {
 	return &Config{
@@ -166,8 +431,15 @@ func defaultConfig() *Config {
 			MaxDelay:     5 * time.Second,
 			Multiplier:   2.0,
 		},
-		GroupCommitSize:  100,
-		GroupCommitDelay: 10 * time.Millisecond,
+		ReplicationRetryInterval: 5 * time.Second,
+		ReplicationDrainTimeout:  5 * time.Second,
+		ReplicationQueueSize:     10000,
+		ReplicationBatchSize:     100,
+		ReplicationBatchDelay:    100 * time.Millisecond,
+		ReplicationBlockTimeout:  time.Second,
+		ReplicationOverflow:      OverflowSpill,
+		GroupCommitSize:          100,
+		GroupCommitDelay:         10 * time.Millisecond,
 	}
 }
 
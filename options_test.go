@@ -6,6 +6,8 @@ import (
 
 	"github.com/willibrandon/mtlog-audit/backends"
 	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/wal"
+	"github.com/willibrandon/mtlog/core"
 )
 
 func TestWithBackend(t *testing.T) {
@@ -282,8 +284,53 @@ func TestCombinedOptions(t *testing.T) {
 	}
 }
 
+func TestWithWALKeepsEarlierOptions(t *testing.T) {
+	cfg := defaultConfig()
+
+	opts := []Option{
+		WithWALSyncPolicy(wal.SyncEveryRecords(5)),
+		WithPrioritySync(core.ErrorLevel),
+		WithWAL("/custom/path/audit.wal", wal.WithSyncMode(wal.SyncInterval)),
+	}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			t.Fatalf("Failed to apply option: %v", err)
+		}
+	}
+	if len(cfg.WALOptions) != 3 {
+		t.Errorf("Expected WithWAL to keep the earlier WAL options, got %d options", len(cfg.WALOptions))
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && s[:len(substr)] == substr ||
 		len(s) >= len(substr) && contains(s[1:], substr)
 }
+
+func TestWithReplicationQueue(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		policy  OverflowPolicy
+		wantErr bool
+	}{
+		{name: "spill", size: 100, policy: OverflowSpill},
+		{name: "block", size: 100, policy: OverflowBlock},
+		{name: "zero size", size: 0, policy: OverflowSpill, wantErr: true},
+		{name: "unknown policy", size: 100, policy: OverflowPolicy(42), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			err := WithReplicationQueue(tt.size, tt.policy)(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithReplicationQueue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (cfg.ReplicationQueueSize != tt.size || cfg.ReplicationOverflow != tt.policy) {
+				t.Errorf("Queue not configured: size=%d policy=%d", cfg.ReplicationQueueSize, cfg.ReplicationOverflow)
+			}
+		})
+	}
+}
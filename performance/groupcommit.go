@@ -19,7 +19,7 @@ type GroupCommitter struct {
 	errorChan    chan error
 	flushChan    chan struct{}
 	pending      []*core.LogEvent
-	waiters      []chan error
+	waiters      []chan commitResult
 	wg           sync.WaitGroup
 	maxDelay     time.Duration
 	batchSize    int
@@ -33,6 +33,12 @@ type GroupCommitter struct {
 	closed       atomic.Bool
 }
 
+// commitResult reports the outcome of a group commit to a single waiter.
+type commitResult struct {
+	err error
+	seq uint64
+}
+
 // NewGroupCommitter creates a new group committer
 func NewGroupCommitter(w *wal.WAL, batchSize int, maxDelay time.Duration) *GroupCommitter {
 	if batchSize <= 0 {
@@ -47,7 +53,7 @@ func NewGroupCommitter(w *wal.WAL, batchSize int, maxDelay time.Duration) *Group
 		batchSize: batchSize,
 		maxDelay:  maxDelay,
 		pending:   make([]*core.LogEvent, 0, batchSize),
-		waiters:   make([]chan error, 0, batchSize),
+		waiters:   make([]chan commitResult, 0, batchSize),
 		flushChan: make(chan struct{}, 1),
 		errorChan: make(chan error, batchSize),
 	}
@@ -61,16 +67,27 @@ func NewGroupCommitter(w *wal.WAL, batchSize int, maxDelay time.Duration) *Group
 
 // Add adds an event to the batch
 func (gc *GroupCommitter) Add(event *core.LogEvent) error {
+	_, err := gc.Append(event)
+	return err
+}
+
+// Append adds an event to the batch and waits for the batch to be committed,
+// returning the WAL sequence assigned to the event.
+func (gc *GroupCommitter) Append(event *core.LogEvent) (uint64, error) {
 	if gc.closed.Load() {
-		return fmt.Errorf("group committer is closed")
+		return 0, fmt.Errorf("group committer is closed")
 	}
 
 	startTime := time.Now()
 
 	// Create waiter channel for this event
-	waiter := make(chan error, 1)
+	waiter := make(chan commitResult, 1)
 
 	gc.mu.Lock()
+	if gc.closed.Load() {
+		gc.mu.Unlock()
+		return 0, fmt.Errorf("group committer is closed")
+	}
 	gc.pending = append(gc.pending, event)
 	gc.waiters = append(gc.waiters, waiter)
 
@@ -105,16 +122,16 @@ func (gc *GroupCommitter) Add(event *core.LogEvent) error {
 	gc.mu.Unlock()
 
 	// Wait for flush to complete
-	err := <-waiter
+	result := <-waiter
 
 	// Record latency
 	latency := time.Since(startTime)
 	atomic.AddInt64(&gc.totalLatency, latency.Nanoseconds())
 	atomic.AddInt64(&gc.eventCount, 1)
 
-	monitoring.RecordWriteLatency("groupcommit", latency, err == nil)
+	monitoring.RecordWriteLatency("groupcommit", latency, result.err == nil)
 
-	return err
+	return result.seq, result.err
 }
 
 // AddBatch adds multiple events efficiently
@@ -130,12 +147,16 @@ func (gc *GroupCommitter) AddBatch(events []*core.LogEvent) error {
 	startTime := time.Now()
 
 	// Create waiters for all events
-	waiters := make([]chan error, len(events))
+	waiters := make([]chan commitResult, len(events))
 	for i := range waiters {
-		waiters[i] = make(chan error, 1)
+		waiters[i] = make(chan commitResult, 1)
 	}
 
 	gc.mu.Lock()
+	if gc.closed.Load() {
+		gc.mu.Unlock()
+		return fmt.Errorf("group committer is closed")
+	}
 
 	// Add all events
 	gc.pending = append(gc.pending, events...)
@@ -168,8 +189,8 @@ func (gc *GroupCommitter) AddBatch(events []*core.LogEvent) error {
 	// Wait for all events to be flushed
 	var firstErr error
 	for _, waiter := range waiters {
-		if err := <-waiter; err != nil && firstErr == nil {
-			firstErr = err
+		if result := <-waiter; result.err != nil && firstErr == nil {
+			firstErr = result.err
 		}
 	}
 
@@ -230,25 +251,16 @@ func (gc *GroupCommitter) flush() {
 	batch := gc.pending
 	waiters := gc.waiters
 	gc.pending = make([]*core.LogEvent, 0, gc.batchSize)
-	gc.waiters = make([]chan error, 0, gc.batchSize)
+	gc.waiters = make([]chan commitResult, 0, gc.batchSize)
 
 	gc.mu.Unlock()
 
-	// Write batch to WAL
+	// Write batch to WAL with a single journal entry, then make it durable
+	// before any waiter is acknowledged, whatever the WAL's sync policy
 	startTime := time.Now()
-	var writeErr error
-
-	// Write all events to WAL
-	for _, event := range batch {
-		if err := gc.wal.Write(event); err != nil {
-			writeErr = err
-			break
-		}
-	}
-
-	// Single fsync for the entire batch
+	sequences, writeErr := gc.wal.WriteBatch(batch)
 	if writeErr == nil {
-		writeErr = gc.wal.Flush()
+		writeErr = gc.wal.SyncTo(sequences[len(sequences)-1])
 	}
 
 	// Record metrics
@@ -264,8 +276,12 @@ func (gc *GroupCommitter) flush() {
 	}
 
 	// Notify all waiters
-	for _, waiter := range waiters {
-		waiter <- writeErr
+	for i, waiter := range waiters {
+		result := commitResult{err: writeErr}
+		if writeErr == nil {
+			result.seq = sequences[i]
+		}
+		waiter <- result
 		close(waiter)
 	}
 
@@ -275,7 +291,11 @@ func (gc *GroupCommitter) flush() {
 
 // Close closes the group committer
 func (gc *GroupCommitter) Close() error {
-	if !gc.closed.CompareAndSwap(false, true) {
+	// Set under the lock so no event can be queued after the final flush
+	gc.mu.Lock()
+	swapped := gc.closed.CompareAndSwap(false, true)
+	gc.mu.Unlock()
+	if !swapped {
 		return nil
 	}
 
@@ -288,10 +308,6 @@ func (gc *GroupCommitter) Close() error {
 	// Wait for flusher to exit
 	gc.wg.Wait()
 
-	// Close channels
-	close(gc.flushChan)
-	close(gc.errorChan)
-
 	return nil
 }
 
@@ -433,15 +449,12 @@ func (ogc *OptimizedGroupCommitter) processLoop() {
 
 // flushBatch writes a batch to WAL
 func (ogc *OptimizedGroupCommitter) flushBatch(batch []*core.LogEvent) {
-	for _, event := range batch {
-		if err := ogc.wal.Write(event); err != nil {
-			atomic.AddInt64(&ogc.errorCount, 1)
-			return
-		}
+	// Single journal entry for the batch, synced whatever the WAL's sync policy
+	sequences, err := ogc.wal.WriteBatch(batch)
+	if err == nil {
+		err = ogc.wal.SyncTo(sequences[len(sequences)-1])
 	}
-
-	// Single fsync for the batch
-	if err := ogc.wal.Flush(); err != nil {
+	if err != nil {
 		atomic.AddInt64(&ogc.errorCount, 1)
 	}
 }
@@ -0,0 +1,514 @@
+package audit
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/backends"
+	"github.com/willibrandon/mtlog-audit/wal"
+	"github.com/willibrandon/mtlog/core"
+)
+
+// reconcileBatchSize bounds the events backfilled in one WriteBatch.
+const reconcileBatchSize = 1000
+
+// SequenceRange is an inclusive range of WAL sequences.
+type SequenceRange = wal.SequenceRange
+
+// DivergentRecord is a backend copy that differs from its WAL record.
+type DivergentRecord struct {
+	Reason      string `json:"reason"`
+	WALHash     string `json:"wal_hash"`
+	BackendHash string `json:"backend_hash"`
+	Sequence    uint64 `json:"sequence"`
+}
+
+// ReconcileReport compares a backend's copy of the WAL with the WAL.
+type ReconcileReport struct {
+	Backend string `json:"backend"`
+	// Gaps are WAL records the backend does not hold.
+	Gaps []SequenceRange `json:"gaps,omitempty"`
+	// Extra are sequences the backend holds that the WAL does not.
+	Extra []SequenceRange `json:"extra,omitempty"`
+	// Duplicates are sequences the backend holds more than once.
+	Duplicates []uint64          `json:"duplicates,omitempty"`
+	Divergent  []DivergentRecord `json:"divergent,omitempty"`
+	// WALRecords counts the WAL records compared; Unreadable those that
+	// could not be decoded, whose backend copies are only counted.
+	WALRecords    int `json:"wal_records"`
+	Unreadable    int `json:"unreadable"`
+	BackendEvents int `json:"backend_events"`
+	Matched       int `json:"matched"`
+	// Unstamped counts backend events delivered without their WAL sequence,
+	// which are matched by content alone; Unknown those matching no record.
+	Unstamped  int `json:"unstamped"`
+	Unknown    int `json:"unknown"`
+	Backfilled int `json:"backfilled"`
+}
+
+// Consistent reports whether the backend holds exactly the WAL's records,
+// counting gaps that were backfilled.
+func (r *ReconcileReport) Consistent() bool {
+	missing := 0
+	for _, gap := range r.Gaps {
+		missing += int(gap.Last - gap.First + 1) // #nosec G115 - bounded by the WAL records read
+	}
+	return missing == r.Backfilled && len(r.Extra) == 0 && len(r.Duplicates) == 0 &&
+		len(r.Divergent) == 0 && r.Unknown == 0
+}
+
+// ReconcileOption configures a Reconciler.
+type ReconcileOption func(*Reconciler)
+
+// WithReconcileKeyring decrypts encrypted WAL records so they can be
+// compared. Without it their backend copies are only counted.
+func WithReconcileKeyring(keys wal.Keyring) ReconcileOption {
+	return func(r *Reconciler) {
+		r.keys = keys
+	}
+}
+
+// WithReconcileRange limits reconciliation to records timestamped from since
+// up to and including until.
+func WithReconcileRange(since, until time.Time) ReconcileOption {
+	return func(r *Reconciler) {
+		r.since = since
+		r.until = until
+	}
+}
+
+// WithReconcileWindow sets how much time each comparison covers once the
+// WAL's earliest record is known. Only one window of WAL records and backend
+// events is held at a time. The default is one hour.
+func WithReconcileWindow(window time.Duration) ReconcileOption {
+	return func(r *Reconciler) {
+		r.window = window
+	}
+}
+
+// WithBackfill writes the WAL records a backend is missing to it.
+func WithBackfill() ReconcileOption {
+	return func(r *Reconciler) {
+		r.backfill = true
+	}
+}
+
+// Reconciler compares backends with the WAL they replicate. Backend events
+// carry the sequence and content hash of their WAL record, so each copy is
+// compared with exactly the record it came from.
+type Reconciler struct {
+	since    time.Time
+	until    time.Time
+	keys     wal.Keyring
+	walPath  string
+	window   time.Duration
+	backfill bool
+}
+
+// NewReconciler creates a reconciler for the WAL at walPath.
+func NewReconciler(walPath string, opts ...ReconcileOption) *Reconciler {
+	r := &Reconciler{walPath: walPath, window: time.Hour}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// walEntry is what reconciliation keeps of a WAL record in the current
+// window. The event is kept only for backfilling.
+type walEntry struct {
+	event    *core.LogEvent
+	hash     [32]byte
+	sequence uint64
+	held     int
+	readable bool
+}
+
+// walSegment is a WAL segment and the time range of its records in the
+// reconciled range.
+type walSegment struct {
+	first time.Time
+	last  time.Time
+	path  string
+}
+
+// reconciliation gathers the findings of every window.
+type reconciliation struct {
+	report     *ReconcileReport
+	duplicates map[uint64]bool
+	// unreadable are the sequences hidden by corrupt WAL records.
+	unreadable []SequenceRange
+	missing    []uint64
+	extra      []uint64
+}
+
+// Reconcile compares backend with the WAL, backfilling its gaps when
+// enabled. The range is compared a window at a time, as Restore reads it.
+func (r *Reconciler) Reconcile(backend backends.Backend) (*ReconcileReport, error) {
+	if r.window <= 0 {
+		return nil, fmt.Errorf("reconcile window must be positive")
+	}
+	until := r.until
+	if until.IsZero() {
+		until = time.Now()
+	}
+	rc := &reconciliation{
+		report:     &ReconcileReport{Backend: backend.Name()},
+		duplicates: make(map[uint64]bool),
+	}
+
+	segments, earliest, err := r.scanWAL(until, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first window reaches back to since, so backend events older than
+	// the WAL's earliest record are compared too
+	lower, end := r.since, until.Add(time.Nanosecond)
+	next := end
+	if !earliest.IsZero() && earliest.Add(r.window).Before(end) {
+		next = earliest.Add(r.window)
+	}
+	for {
+		missing, err := r.compareWindow(backend, segments, lower, next, rc)
+		if err != nil {
+			return nil, err
+		}
+		if r.backfill && len(missing) > 0 {
+			if err := r.backfillGaps(backend, missing, rc.report); err != nil {
+				return rc.finish(), err
+			}
+		}
+		if !next.Before(end) {
+			break
+		}
+		lower, next = next, next.Add(r.window)
+		if next.After(end) {
+			next = end
+		}
+	}
+	return rc.finish(), nil
+}
+
+// compareWindow compares the WAL records and backend events timestamped from
+// lower up to but excluding upper, returning the records the backend lacks.
+func (r *Reconciler) compareWindow(backend backends.Backend, segments []walSegment, lower, upper time.Time, rc *reconciliation) ([]*walEntry, error) {
+	report := rc.report
+	entries := make(map[uint64]*walEntry)
+	byHash := make(map[[32]byte][]uint64)
+	err := r.readWAL(segments, lower, upper, func(record *wal.Record, event *core.LogEvent) error {
+		entry := &walEntry{sequence: record.Sequence}
+		entries[record.Sequence] = entry
+		report.WALRecords++
+		if event == nil {
+			report.Unreadable++
+			return nil
+		}
+		if r.backfill {
+			entry.event = event
+		}
+		hash, err := wal.EventHash(event)
+		if err != nil {
+			report.Unreadable++
+			return nil
+		}
+		entry.hash, entry.readable = hash, true
+		byHash[hash] = append(byHash[hash], record.Sequence)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Backends exclude both ends of a range; keep [lower, upper)
+	events, err := backend.Read(lower.Add(-time.Nanosecond), upper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backend %s: %w", backend.Name(), err)
+	}
+
+	var unstamped []*core.LogEvent
+	for _, event := range events {
+		if event.Timestamp.Before(lower) || !event.Timestamp.Before(upper) {
+			continue
+		}
+		report.BackendEvents++
+		plain, seq, stampHash, ok := wal.UnstampEvent(event)
+		if !ok {
+			unstamped = append(unstamped, plain)
+			continue
+		}
+		entry, known := entries[seq]
+		if !known {
+			// A copy of a corrupt record can't be compared, only counted
+			if !inSequenceRanges(rc.unreadable, seq) {
+				rc.extra = append(rc.extra, seq)
+			}
+			continue
+		}
+		entry.held++
+		if entry.held > 1 {
+			rc.duplicates[seq] = true
+		}
+		if !entry.readable {
+			continue
+		}
+
+		hash, err := wal.EventHash(plain)
+		switch {
+		case err != nil || hash != entry.hash:
+			report.Divergent = append(report.Divergent, DivergentRecord{
+				Sequence:    seq,
+				Reason:      "content differs from the WAL record",
+				WALHash:     hex.EncodeToString(entry.hash[:]),
+				BackendHash: hex.EncodeToString(hash[:]),
+			})
+		case stampHash != hex.EncodeToString(entry.hash[:]):
+			report.Divergent = append(report.Divergent, DivergentRecord{
+				Sequence:    seq,
+				Reason:      "stamped hash differs from the WAL record",
+				WALHash:     hex.EncodeToString(entry.hash[:]),
+				BackendHash: stampHash,
+			})
+		case entry.held == 1:
+			report.Matched++
+		}
+	}
+
+	// Events delivered before stamping are matched by content to records
+	// not otherwise held
+	for _, event := range unstamped {
+		report.Unstamped++
+		hash, err := wal.EventHash(event)
+		if err != nil {
+			report.Unknown++
+			continue
+		}
+		matched := false
+		for _, seq := range byHash[hash] {
+			if entries[seq].held == 0 {
+				entries[seq].held++
+				report.Matched++
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			if seqs := byHash[hash]; len(seqs) > 0 {
+				rc.duplicates[seqs[0]] = true
+				continue
+			}
+			report.Unknown++
+		}
+	}
+
+	var missing []*walEntry
+	for _, entry := range entries {
+		if entry.held == 0 {
+			missing = append(missing, entry)
+			rc.missing = append(rc.missing, entry.sequence)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].sequence < missing[j].sequence })
+	return missing, nil
+}
+
+// finish completes the report from the findings of every window.
+func (rc *reconciliation) finish() *ReconcileReport {
+	report := rc.report
+	report.Duplicates = report.Duplicates[:0]
+	for seq := range rc.duplicates {
+		report.Duplicates = append(report.Duplicates, seq)
+	}
+	sort.Slice(report.Duplicates, func(i, j int) bool { return report.Duplicates[i] < report.Duplicates[j] })
+	report.Gaps = sequenceRanges(rc.missing)
+	report.Extra = sequenceRanges(rc.extra)
+	return report
+}
+
+// backfillGaps writes the missing WAL records to backend, stamped as
+// replication stamps them.
+func (r *Reconciler) backfillGaps(backend backends.Backend, missing []*walEntry, report *ReconcileReport) error {
+	var batch []*core.LogEvent
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := backend.WriteBatch(batch); err != nil {
+			return fmt.Errorf("failed to backfill %s: %w", backend.Name(), err)
+		}
+		report.Backfilled += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, entry := range missing {
+		if entry.event == nil {
+			continue
+		}
+		stamped, err := wal.StampEvent(entry.event, entry.sequence)
+		if err != nil {
+			continue
+		}
+		batch = append(batch, stamped)
+		if len(batch) >= reconcileBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// scanWAL finds the time range of each segment's records in the reconciled
+// range, and the earliest of them, without decoding events. Corrupt records
+// are counted as unreadable, and the sequences they hide are noted so their
+// backend copies aren't taken for extra records.
+func (r *Reconciler) scanWAL(until time.Time, rc *reconciliation) ([]walSegment, time.Time, error) {
+	paths, err := r.segmentPaths()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var (
+		segments []walSegment
+		earliest time.Time
+		lastSeq  uint64
+		corrupt  int
+	)
+	for _, path := range paths {
+		segment := walSegment{path: path}
+		err := readWALSegment(path, func(record *wal.Record) error {
+			if corrupt > 0 && record.Sequence > lastSeq+1 {
+				rc.unreadable = append(rc.unreadable, SequenceRange{First: lastSeq + 1, Last: record.Sequence - 1})
+			}
+			corrupt = 0
+			lastSeq = record.Sequence
+
+			if record.IsMetadata() {
+				return nil
+			}
+			timestamp := time.Unix(0, record.Timestamp)
+			if timestamp.Before(r.since) || timestamp.After(until) {
+				return nil
+			}
+			if segment.first.IsZero() || timestamp.Before(segment.first) {
+				segment.first = timestamp
+			}
+			if timestamp.After(segment.last) {
+				segment.last = timestamp
+			}
+			if earliest.IsZero() || timestamp.Before(earliest) {
+				earliest = timestamp
+			}
+			return nil
+		}, func() {
+			corrupt++
+			rc.report.WALRecords++
+			rc.report.Unreadable++
+		})
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if !segment.first.IsZero() {
+			segments = append(segments, segment)
+		}
+	}
+	if corrupt > 0 {
+		rc.unreadable = append(rc.unreadable, SequenceRange{First: lastSeq + 1, Last: lastSeq + uint64(corrupt)}) // #nosec G115 - count of records read
+	}
+	return segments, earliest, nil
+}
+
+// readWAL calls visit with every event record timestamped from lower up to
+// but excluding upper, and its decoded event, or nil if it can't be decoded.
+// Only the segments holding such records are read.
+func (r *Reconciler) readWAL(segments []walSegment, lower, upper time.Time, visit func(*wal.Record, *core.LogEvent) error) error {
+	for _, segment := range segments {
+		if !segment.first.Before(upper) || segment.last.Before(lower) {
+			continue
+		}
+		err := readWALSegment(segment.path, func(record *wal.Record) error {
+			if record.IsMetadata() {
+				return nil
+			}
+			timestamp := time.Unix(0, record.Timestamp)
+			if timestamp.Before(lower) || !timestamp.Before(upper) {
+				return nil
+			}
+			event, err := record.DecodeEvent(r.keys)
+			if err != nil {
+				event = nil
+			}
+			return visit(record, event)
+		}, func() {}) // Counted by scanWAL
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segmentPaths returns the paths of the WAL's segments, oldest first.
+func (r *Reconciler) segmentPaths() ([]string, error) {
+	segments, err := wal.NewSegmentManager(r.walPath, 64*1024*1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	var paths []string
+	for _, segment := range segments.GetSegments() {
+		paths = append(paths, segment.Path)
+	}
+	return paths, nil
+}
+
+// readWALSegment calls visit with each record of the segment at path, and
+// corrupt for each record that fails its checks. A record whose framing is
+// damaged hides the rest of its segment and counts as one corrupt record.
+func readWALSegment(path string, visit func(*wal.Record) error, corrupt func()) error {
+	reader, err := wal.NewReader(path)
+	if err != nil {
+		return nil // Never written
+	}
+	defer func() { _ = reader.Close() }()
+
+	for {
+		record, err := reader.ReadNextRecord()
+		switch {
+		case err == io.EOF:
+			return nil
+		case errors.Is(err, wal.ErrRecordCorrupted):
+			corrupt()
+			continue
+		case err != nil:
+			corrupt()
+			return nil
+		}
+		if err := visit(record); err != nil {
+			return err
+		}
+	}
+}
+
+// inSequenceRanges reports whether seq falls in one of the sorted ranges.
+func inSequenceRanges(ranges []SequenceRange, seq uint64) bool {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].Last >= seq })
+	return i < len(ranges) && ranges[i].First <= seq
+}
+
+// sequenceRanges collapses sequences into sorted inclusive ranges.
+func sequenceRanges(sequences []uint64) []SequenceRange {
+	sort.Slice(sequences, func(i, j int) bool { return sequences[i] < sequences[j] })
+	var ranges []SequenceRange
+	for _, seq := range sequences {
+		if n := len(ranges); n > 0 && (seq == ranges[n-1].Last || seq == ranges[n-1].Last+1) {
+			ranges[n-1].Last = seq
+			continue
+		}
+		ranges = append(ranges, SequenceRange{First: seq, Last: seq})
+	}
+	return ranges
+}
@@ -0,0 +1,200 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/backends"
+	"github.com/willibrandon/mtlog-audit/wal"
+	"github.com/willibrandon/mtlog/core"
+)
+
+func TestReconcile(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "test.wal")
+	backendPath := filepath.Join(tmpDir, "backend")
+
+	sink, err := New(
+		WithWAL(walPath),
+		WithBackend(backends.FilesystemConfig{Path: backendPath}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		sink.Emit(&core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Reconciled event {Index}",
+			Properties:      map[string]interface{}{"Index": i},
+		})
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	// Replicated events carry their WAL sequence, so the backend reconciles
+	backend, err := backends.NewFilesystemBackend(backends.FilesystemConfig{Path: backendPath})
+	if err != nil {
+		t.Fatalf("Failed to open backend: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	report, err := NewReconciler(walPath).Reconcile(backend)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if !report.Consistent() || report.WALRecords != 20 || report.Matched != 20 || report.Unstamped != 0 {
+		t.Fatalf("Expected the replicated backend to be consistent, got %+v", report)
+	}
+
+	// A copy that lost records, holds one twice and altered another
+	events, err := backend.Read(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to read backend: %v", err)
+	}
+	damaged, err := backends.NewFilesystemBackend(backends.FilesystemConfig{Path: filepath.Join(tmpDir, "damaged")})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer func() { _ = damaged.Close() }()
+
+	held := append(append([]*core.LogEvent{}, events[:4]...), events[8:]...)
+	held = append(held, events[10])
+	altered := *events[15]
+	altered.Properties = map[string]interface{}{
+		"Index":              999,
+		wal.SequenceProperty: events[15].Properties[wal.SequenceProperty],
+		wal.HashProperty:     events[15].Properties[wal.HashProperty],
+	}
+	held[11] = &altered
+	if err := damaged.WriteBatch(held); err != nil {
+		t.Fatalf("Failed to write backend: %v", err)
+	}
+
+	report, err = NewReconciler(walPath).Reconcile(damaged)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if report.Consistent() {
+		t.Fatal("Expected the damaged backend to be inconsistent")
+	}
+	if len(report.Gaps) != 1 || report.Gaps[0] != (SequenceRange{First: 5, Last: 8}) {
+		t.Errorf("Expected a gap at 5-8, got %+v", report.Gaps)
+	}
+	if len(report.Duplicates) != 1 || report.Duplicates[0] != 11 {
+		t.Errorf("Expected sequence 11 duplicated, got %+v", report.Duplicates)
+	}
+	if len(report.Divergent) != 1 || report.Divergent[0].Sequence != 16 {
+		t.Errorf("Expected sequence 16 to diverge, got %+v", report.Divergent)
+	}
+
+	// Comparing a window at a time finds the same
+	first, last := events[0].Timestamp, events[len(events)-1].Timestamp
+	windowed, err := NewReconciler(walPath,
+		WithReconcileRange(first, last),
+		WithReconcileWindow(last.Sub(first)/5+time.Nanosecond),
+	).Reconcile(damaged)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if windowed.WALRecords != 20 || windowed.BackendEvents != report.BackendEvents ||
+		!reflect.DeepEqual(windowed.Gaps, report.Gaps) || !reflect.DeepEqual(windowed.Duplicates, report.Duplicates) ||
+		!reflect.DeepEqual(windowed.Divergent, report.Divergent) {
+		t.Errorf("Expected windows to find %+v, got %+v", report, windowed)
+	}
+
+	// Backfilling fills the gap, leaving what can't be fixed by writing
+	report, err = NewReconciler(walPath, WithBackfill()).Reconcile(damaged)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if report.Backfilled != 4 {
+		t.Errorf("Expected 4 records backfilled, got %d", report.Backfilled)
+	}
+	report, err = NewReconciler(walPath).Reconcile(damaged)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(report.Gaps) != 0 || len(report.Duplicates) != 1 || len(report.Divergent) != 1 {
+		t.Errorf("Expected only the gap to be fixed, got %+v", report)
+	}
+}
+
+func TestReconcileCorruptRecord(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "test.wal")
+	backendPath := filepath.Join(tmpDir, "backend")
+
+	sink, err := New(
+		WithWAL(walPath),
+		WithBackend(backends.FilesystemConfig{Path: backendPath}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		sink.Emit(&core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Reconciled event {Index}",
+			Properties:      map[string]interface{}{"Index": i},
+		})
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	// Damage the record holding sequence 5
+	segments, err := wal.NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := segments.GetSegments()[0].Path
+	reader, err := wal.NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offset int
+	for {
+		record, err := reader.ReadNextRecord()
+		if err != nil {
+			t.Fatalf("Failed to find record 5: %v", err)
+		}
+		if record.Sequence == 5 {
+			break
+		}
+		data, err := record.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset += len(data)
+	}
+	_ = reader.Close()
+	data, err := os.ReadFile(path) // #nosec G304 - test file path
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[offset+40] ^= 0xFF
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err := backends.NewFilesystemBackend(backends.FilesystemConfig{Path: backendPath})
+	if err != nil {
+		t.Fatalf("Failed to open backend: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	// The corrupt record is counted, and its backend copy isn't extra
+	report, err := NewReconciler(walPath).Reconcile(backend)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if !report.Consistent() || report.Unreadable != 1 || report.WALRecords != 20 || report.Matched != 19 {
+		t.Errorf("Expected one unreadable record and the rest matched, got %+v", report)
+	}
+}
@@ -0,0 +1,649 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/backends"
+	"github.com/willibrandon/mtlog-audit/monitoring"
+	"github.com/willibrandon/mtlog-audit/resilience"
+	"github.com/willibrandon/mtlog-audit/wal"
+	"github.com/willibrandon/mtlog/core"
+)
+
+// cursorSaveInterval bounds how often delivery cursors are written to disk.
+// A crash may redeliver records acknowledged within the last interval.
+const cursorSaveInterval = time.Second
+
+// cursorStore persists the last WAL sequence delivered to each backend so
+// replication can resume where it left off after a restart.
+type cursorStore struct {
+	cursors map[string]uint64
+	changed chan struct{}
+	path    string
+	dirty   bool
+	closed  bool
+	mu      sync.Mutex
+}
+
+// loadCursorStore opens the cursor file at path, starting empty if it does not exist.
+func loadCursorStore(path string) (*cursorStore, error) {
+	store := &cursorStore{
+		path:    path,
+		cursors: make(map[string]uint64),
+		changed: make(chan struct{}),
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path derived from WAL configuration
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, fmt.Errorf("failed to read replication cursors: %w", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &store.cursors); err != nil {
+			return nil, fmt.Errorf("failed to parse replication cursors: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// get returns the last sequence delivered to the named backend.
+func (c *cursorStore) get(name string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cursors[name]
+}
+
+// advance moves the cursor for the named backend forward in memory.
+func (c *cursorStore) advance(name string, seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if seq > c.cursors[name] {
+		c.cursors[name] = seq
+		c.dirty = true
+
+		// Wake anyone waiting for delivery
+		if !c.closed {
+			close(c.changed)
+			c.changed = make(chan struct{})
+		}
+	}
+}
+
+// waitFor blocks until at least need of the named cursors reach seq.
+func (c *cursorStore) waitFor(ctx context.Context, names []string, seq uint64, need int) error {
+	for {
+		c.mu.Lock()
+		reached := 0
+		for _, name := range names {
+			if c.cursors[name] >= seq {
+				reached++
+			}
+		}
+		changed, closed := c.changed, c.closed
+		c.mu.Unlock()
+
+		if reached >= need {
+			return nil
+		}
+		if closed {
+			return ErrSinkClosed
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// close releases all waiters; cursors no longer advance after this.
+func (c *cursorStore) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.changed)
+	}
+}
+
+// snapshot returns a copy of all cursors.
+func (c *cursorStore) snapshot() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cursors := make(map[string]uint64, len(c.cursors))
+	for name, seq := range c.cursors {
+		cursors[name] = seq
+	}
+	return cursors
+}
+
+// save writes the cursors to disk atomically if they changed since the last save.
+func (c *cursorStore) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(c.cursors)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replication cursors: %w", err)
+	}
+
+	tmpPath := c.path + ".tmp"
+	// #nosec G304 - path derived from WAL configuration
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create cursor file: %w", err)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write cursor file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to sync cursor file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close cursor file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("failed to replace cursor file: %w", err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// OverflowPolicy determines what Emit does when a backend's replication queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowSpill leaves the event in the WAL; the backend catches up from its cursor.
+	OverflowSpill OverflowPolicy = iota
+	// OverflowBlock makes Emit wait for queue space, up to the block timeout, before spilling.
+	OverflowBlock
+)
+
+// queuedEvent is an event awaiting replication along with its WAL sequence.
+type queuedEvent struct {
+	event *core.LogEvent
+	seq   uint64
+}
+
+// replicator delivers events to a single backend in sequence order. Fresh
+// events arrive through a bounded queue and are coalesced into batches; when
+// the backend falls behind (spilled events, failures, restarts) it tails the
+// WAL from the persisted cursor until it reaches the head again.
+type replicator struct {
+	lastSave time.Time
+	sink     *Sink
+	backend  backends.Backend
+	reader   *wal.Reader
+	queue    chan queuedEvent
+	notify   chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	pending  []*wal.Record
+	name     string
+	segment  string
+	behind   bool
+	// spilled is set by enqueue when an event is left to catch-up
+	spilled atomic.Bool
+}
+
+// newReplicator creates a replicator for a backend identified by name.
+func newReplicator(sink *Sink, backend backends.Backend, name string) *replicator {
+	return &replicator{
+		sink:    sink,
+		backend: backend,
+		name:    name,
+		queue:   make(chan queuedEvent, sink.config.ReplicationQueueSize),
+		notify:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		behind:  true, // always check the WAL for missed records on startup
+	}
+}
+
+// enqueue hands an event to the replicator, applying the overflow policy
+// when the queue is full. Spilled events are never lost: they remain in the
+// WAL and are delivered by catch-up.
+func (r *replicator) enqueue(event *core.LogEvent, seq uint64) {
+	item := queuedEvent{event: event, seq: seq}
+
+	select {
+	case r.queue <- item:
+		r.reportDepth()
+		return
+	default:
+	}
+
+	if r.sink.config.ReplicationOverflow == OverflowBlock {
+		timer := time.NewTimer(r.sink.config.ReplicationBlockTimeout)
+		defer timer.Stop()
+
+		select {
+		case r.queue <- item:
+			r.reportDepth()
+			return
+		case <-timer.C:
+		case <-r.stop:
+		}
+	}
+
+	monitoring.RecordBackendOperation(r.name, "spill", true)
+	r.spilled.Store(true)
+	r.wake()
+}
+
+// wake signals the replicator to retry catch-up.
+func (r *replicator) wake() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// onBreakerStateChange triggers a catch-up as soon as the backend's circuit closes.
+func (r *replicator) onBreakerStateChange(_, to resilience.State) {
+	if to == resilience.StateClosed {
+		r.wake()
+	}
+}
+
+// run delivers events until stopped.
+func (r *replicator) run() {
+	defer close(r.done)
+	defer r.closeReader()
+
+	ticker := time.NewTicker(r.sink.config.ReplicationRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		if r.spilled.Swap(false) {
+			r.behind = true
+		}
+		if r.behind {
+			r.catchUp(r.stop)
+		}
+
+		// While behind, leave the queue alone so a full queue pushes back on Emit
+		queue := r.queue
+		if r.behind {
+			queue = nil
+		}
+
+		select {
+		case item := <-queue:
+			r.deliverQueued(r.collect(item))
+		case <-r.notify:
+			r.checkHead()
+		case <-ticker.C:
+			r.checkHead()
+		case <-r.stop:
+			// Best-effort drain from the WAL, which also covers anything still
+			// queued; whatever is left is delivered on the next start
+			deadline := make(chan struct{})
+			timer := time.AfterFunc(r.sink.config.ReplicationDrainTimeout, func() { close(deadline) })
+			r.catchUp(deadline)
+			timer.Stop()
+			r.saveCursor()
+			return
+		}
+	}
+}
+
+// checkHead switches to catch-up when the WAL holds records past the cursor
+// that are not waiting in the queue, such as events written while the
+// backend's circuit was open.
+func (r *replicator) checkHead() {
+	if len(r.queue) == 0 && r.sink.cursors.get(r.name) < r.sink.wal.LastSequence() {
+		r.behind = true
+	}
+}
+
+// shutdown stops the replicator and waits for it to persist its cursor.
+func (r *replicator) shutdown() {
+	close(r.stop)
+	<-r.done
+}
+
+// collect gathers queued events into a batch until it is full or the batch delay expires.
+func (r *replicator) collect(first queuedEvent) []queuedEvent {
+	batch := []queuedEvent{first}
+
+	timer := time.NewTimer(r.sink.config.ReplicationBatchDelay)
+	defer timer.Stop()
+
+	for len(batch) < r.sink.config.ReplicationBatchSize {
+		select {
+		case item := <-r.queue:
+			batch = append(batch, item)
+		case <-timer.C:
+			return batch
+		case <-r.stop:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+// deliverQueued writes a batch of queued events. Any gap between the cursor
+// and the batch switches the replicator to WAL catch-up.
+func (r *replicator) deliverQueued(batch []queuedEvent) {
+	r.reportDepth()
+
+	cursor := r.sink.cursors.get(r.name)
+	events := make([]*core.LogEvent, 0, len(batch))
+	var last uint64
+	for _, item := range batch {
+		if item.seq <= cursor {
+			continue // Already delivered by catch-up
+		}
+		if item.seq != cursor+uint64(len(events))+1 {
+			r.behind = true
+			return
+		}
+		events = append(events, r.stamp(item.event, item.seq))
+		last = item.seq
+	}
+
+	if len(events) == 0 {
+		return
+	}
+
+	if err := r.write(events); err != nil {
+		r.behind = true
+		return
+	}
+
+	r.sink.cursors.advance(r.name, last)
+	r.maybeSaveCursor()
+}
+
+// catchUp delivers WAL records past the cursor in batches until the WAL head
+// is reached, a delivery fails, or cancel fires.
+func (r *replicator) catchUp(cancel <-chan struct{}) {
+	defer r.saveCursor()
+
+	for {
+		select {
+		case <-cancel:
+			return
+		default:
+		}
+
+		records, err := r.nextBatch()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Backend %s replication read error: %v\n", r.name, err)
+			return
+		}
+		if len(records) == 0 {
+			r.behind = false
+			return
+		}
+
+		events := make([]*core.LogEvent, 0, len(records))
+		for _, record := range records {
+			if record.IsMetadata() {
+				continue
+			}
+			event, err := record.DecodeEvent(r.sink.keys)
+			if err != nil {
+				// An undecodable record can never be delivered; skip it rather than stall
+				r.skip(fmt.Errorf("record %d: %w", record.Sequence, err))
+				continue
+			}
+			events = append(events, r.stamp(event, record.Sequence))
+		}
+
+		if len(events) > 0 {
+			if err := r.write(events); err != nil {
+				return
+			}
+		}
+
+		r.pending = nil
+		r.sink.cursors.advance(r.name, records[len(records)-1].Sequence)
+		r.maybeSaveCursor()
+	}
+}
+
+// nextBatch returns up to a batch of WAL records not yet delivered. A batch
+// that failed to deliver is returned again until it succeeds.
+func (r *replicator) nextBatch() ([]*wal.Record, error) {
+	if len(r.pending) > 0 {
+		return r.pending, nil
+	}
+
+	cursor := r.sink.cursors.get(r.name)
+	for len(r.pending) < r.sink.config.ReplicationBatchSize {
+		record, err := r.next(cursor)
+		if err != nil {
+			r.pending = nil
+			return nil, err
+		}
+		if record == nil {
+			break
+		}
+		r.pending = append(r.pending, record)
+		cursor = record.Sequence
+	}
+
+	return r.pending, nil
+}
+
+// next returns the next WAL record after cursor, or nil at the WAL head.
+func (r *replicator) next(cursor uint64) (*wal.Record, error) {
+	for {
+		if r.reader == nil {
+			ok, err := r.open(cursor)
+			if err != nil || !ok {
+				return nil, err
+			}
+		}
+
+		record, err := r.reader.ReadNextRecord()
+		if err == io.EOF {
+			ok, err := r.advance()
+			if err != nil || !ok {
+				return nil, err
+			}
+			continue
+		}
+		if errors.Is(err, wal.ErrRecordCorrupted) {
+			// The reader is past the damaged record; the cursor passes it
+			// with the next record delivered
+			r.skip(fmt.Errorf("%w: segment %s: %w", ErrWALCorrupted, filepath.Base(r.segment), err))
+			continue
+		}
+		if err != nil {
+			// Restart from the segment list on the next pass
+			segment := filepath.Base(r.segment)
+			r.closeReader()
+			return nil, fmt.Errorf("segment %s: %w", segment, err)
+		}
+
+		if record.Sequence <= cursor {
+			continue
+		}
+
+		return record, nil
+	}
+}
+
+// open positions the reader at the first segment that may contain records after cursor.
+func (r *replicator) open(cursor uint64) (bool, error) {
+	segments := r.sink.wal.SegmentsSnapshot()
+	for i, seg := range segments {
+		// Skip segments known to be fully delivered, but never the active one
+		if i < len(segments)-1 && seg.EndSeq != 0 && seg.EndSeq <= cursor {
+			continue
+		}
+		return true, r.openSegment(seg.Path)
+	}
+	return false, nil
+}
+
+// advance moves the reader to the segment following the current one.
+// It returns false when the reader is already at the active segment.
+func (r *replicator) advance() (bool, error) {
+	segments := r.sink.wal.SegmentsSnapshot()
+	for i, seg := range segments {
+		if seg.Path != r.segment {
+			continue
+		}
+		if i == len(segments)-1 {
+			return false, nil
+		}
+		r.closeReader()
+		return true, r.openSegment(segments[i+1].Path)
+	}
+
+	// Current segment was compacted away; rescan from the cursor
+	r.closeReader()
+	return true, nil
+}
+
+// openSegment opens a reader on the segment at path.
+func (r *replicator) openSegment(path string) error {
+	reader, err := wal.NewReader(path)
+	if err != nil {
+		return err
+	}
+	r.reader = reader
+	r.segment = path
+	return nil
+}
+
+// closeReader releases the current segment reader.
+func (r *replicator) closeReader() {
+	if r.reader != nil {
+		_ = r.reader.Close()
+		r.reader = nil
+		r.segment = ""
+	}
+}
+
+// write sends a batch of events to the backend through its circuit breaker.
+func (r *replicator) write(events []*core.LogEvent) error {
+	start := time.Now()
+
+	var err error
+	if r.sink.resilience != nil {
+		err = r.sink.resilience.ExecuteWithBreaker(r.name, func() error {
+			return r.backend.WriteBatch(events)
+		})
+	} else {
+		err = r.backend.WriteBatch(events)
+	}
+
+	monitoring.RecordBackendLatency(r.name, "write_batch", time.Since(start))
+
+	if err != nil {
+		if r.sink.monitoring != nil {
+			r.sink.monitoring.RecordBackendFailure(r.name, err)
+		}
+		fmt.Fprintf(os.Stderr, "Backend %s replication error: %v\n", r.name, err)
+		return err
+	}
+
+	if r.sink.monitoring != nil {
+		r.sink.monitoring.RecordBackendSuccess(r.name)
+	}
+	return nil
+}
+
+// stamp tags event with its WAL sequence and content hash so the backend's
+// copy can be reconciled with the WAL. An event that can't be hashed is
+// delivered as it is rather than held back.
+func (r *replicator) stamp(event *core.LogEvent, seq uint64) *core.LogEvent {
+	stamped, err := wal.StampEvent(event, seq)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Backend %s delivering record %d unstamped: %v\n", r.name, seq, err)
+		return event
+	}
+	return stamped
+}
+
+// skip reports a WAL record that can never be delivered and is passed over,
+// to the metrics and to the FailureHandler when one is set.
+func (r *replicator) skip(err error) {
+	monitoring.RecordBackendOperation(r.name, "skip", false)
+	if errors.Is(err, ErrWALCorrupted) {
+		monitoring.RecordWALCorruption()
+	}
+	fmt.Fprintf(os.Stderr, "Backend %s skipping undeliverable record: %v\n", r.name, err)
+	if handler := r.sink.config.FailureHandler; handler != nil {
+		handler(skippedRecordEvent(r.name, err), err)
+	}
+}
+
+// skippedRecordEvent describes a record replication passed over for the
+// FailureHandler.
+func skippedRecordEvent(backend string, err error) *core.LogEvent {
+	return &core.LogEvent{
+		Timestamp:       time.Now(),
+		Level:           core.ErrorLevel,
+		MessageTemplate: "Audit record not replicated to {Backend}: {Error}",
+		Properties:      map[string]interface{}{"Backend": backend, "Error": err.Error()},
+	}
+}
+
+// reportDepth publishes the current queue depth.
+func (r *replicator) reportDepth() {
+	monitoring.UpdateQueueDepth("replication:"+r.name, len(r.queue))
+}
+
+// maybeSaveCursor persists the cursor if the save interval has elapsed.
+func (r *replicator) maybeSaveCursor() {
+	if time.Since(r.lastSave) >= cursorSaveInterval {
+		r.saveCursor()
+	}
+}
+
+// saveCursor persists the replication cursors, logging rather than failing on error.
+func (r *replicator) saveCursor() {
+	r.lastSave = time.Now()
+	if err := r.sink.cursors.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to persist replication cursor for %s: %v\n", r.name, err)
+	}
+}
+
+// replicatorNames assigns each backend its cursor name: its Name, which
+// names the destination it writes to, so a cursor follows its backend when
+// backends are added, removed or reordered. Backends writing to the same
+// destination are told apart by their position.
+func replicatorNames(list []backends.Backend) []string {
+	names := make([]string, len(list))
+	seen := make(map[string]int)
+	for i, backend := range list {
+		name := backend.Name()
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, seen[name])
+		}
+		names[i] = name
+	}
+	return names
+}
@@ -1,6 +1,7 @@
 package audit
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/willibrandon/mtlog-audit/backends"
 	"github.com/willibrandon/mtlog-audit/compliance"
 	"github.com/willibrandon/mtlog-audit/monitoring"
+	"github.com/willibrandon/mtlog-audit/performance"
 	"github.com/willibrandon/mtlog-audit/resilience"
 	"github.com/willibrandon/mtlog-audit/wal"
 	"github.com/willibrandon/mtlog/core"
@@ -27,6 +29,20 @@ const (
 	SyncBatch = wal.SyncBatch
 )
 
+// Durability selects how far an event must progress before EmitSync returns.
+type Durability int
+
+const (
+	// DurabilityWritten returns once the record is written to the WAL file
+	DurabilityWritten Durability = iota
+	// DurabilitySynced returns once the record is fsynced to disk
+	DurabilitySynced
+	// DurabilityOneBackend returns once at least one backend has the record
+	DurabilityOneBackend
+	// DurabilityAllBackends returns once every backend has the record
+	DurabilityAllBackends
+)
+
 // IntegrityReport contains the results of an integrity check.
 type IntegrityReport struct {
 	Timestamp           time.Time
@@ -42,14 +58,22 @@ type IntegrityReport struct {
 // Sink implements a bulletproof audit sink that guarantees delivery.
 // It implements the core.LogEventSink interface from mtlog.
 type Sink struct {
-	wal        *wal.WAL
-	config     *Config
-	compliance *compliance.Engine
-	resilience *resilience.Manager
-	monitoring *monitoring.Monitor
-	backends   []backends.Backend
-	mu         sync.RWMutex
-	closed     bool
+	wal         *wal.WAL
+	committer   *performance.GroupCommitter
+	config      *Config
+	compliance  *compliance.Engine
+	resilience  *resilience.Manager
+	monitoring  *monitoring.Monitor
+	cursors     *cursorStore
+	checkpoints *checkpointer
+	timestamps  *timestamper
+	highWater   *highWaterMarker
+	scrubber    *scrubber
+	keys        wal.Keyring
+	backends    []backends.Backend
+	replicators []*replicator
+	mu          sync.RWMutex
+	closed      bool
 }
 
 // Ensure we implement the interface
@@ -70,44 +94,179 @@ func New(opts ...Option) (*Sink, error) {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
 
+	// Initialize compliance engine if configured
+	var complianceEngine *compliance.Engine
+	if config.ComplianceProfile != "" {
+		complianceOptions := config.ComplianceOptions
+		if config.ComplianceSigning {
+			// Default the signature log next to the WAL; an explicit option still wins
+			complianceOptions = append([]compliance.Option{compliance.WithSignatureLog(config.WALPath + ".sig")}, complianceOptions...)
+		}
+
+		var err error
+		complianceEngine, err = compliance.New(config.ComplianceProfile, complianceOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("compliance init failed: %w", err)
+		}
+	}
+
+	// Release everything created so far if the sink fails to start
+	var (
+		walInstance *wal.WAL
+		sink        *Sink
+		err         error
+	)
+	started := false
+	defer func() {
+		if started {
+			return
+		}
+		if sink != nil && sink.committer != nil {
+			_ = sink.committer.Close()
+		}
+		if walInstance != nil {
+			_ = walInstance.Close()
+		}
+		if complianceEngine != nil {
+			_ = complianceEngine.Close()
+		}
+		if sink != nil {
+			for _, backend := range sink.backends {
+				_ = backend.Close()
+			}
+		}
+	}()
+
+	// Resolve the keyring used to encrypt WAL records at rest
+	keys := config.Encryption
+	if config.ComplianceEncryption {
+		if complianceEngine == nil || complianceEngine.KeyManager() == nil {
+			return nil, fmt.Errorf("compliance encryption requires a profile that mandates encryption")
+		}
+		keys = complianceEngine.KeyManager()
+	}
+
+	walOptions := config.WALOptions[:len(config.WALOptions):len(config.WALOptions)]
+	if keys != nil {
+		walOptions = append(walOptions, wal.WithEncryption(keys))
+	}
+
+	// Sign every WAL record into the persisted signature chain and seal
+	// each segment as it is completed
+	if config.ComplianceSigning {
+		if complianceEngine == nil || complianceEngine.SignatureChain() == nil {
+			return nil, fmt.Errorf("compliance signing requires a profile that mandates signing")
+		}
+		walOptions = append(walOptions, wal.WithSigning(complianceEngine.SignatureChain()))
+		walOptions = append(walOptions, wal.WithSealing(complianceEngine.Signer()))
+	}
+
+	// Identify the WAL so merged logs can be told apart and verified
+	if config.Metadata {
+		metadata, err := walMetadata(config, complianceEngine)
+		if err != nil {
+			return nil, err
+		}
+		walOptions = append(walOptions, wal.WithMetadata(metadata))
+	}
+
 	// Initialize WAL - this MUST succeed
-	walInstance, err := wal.New(config.WALPath, config.WALOptions...)
+	walInstance, err = wal.New(config.WALPath, walOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize WAL: %w", err)
 	}
 
 	// Verify WAL integrity on startup
 	if err := walInstance.VerifyIntegrity(); err != nil {
-		_ = walInstance.Close()
 		return nil, fmt.Errorf("WAL integrity check failed: %w", err)
 	}
 
-	sink := &Sink{
-		wal:    walInstance,
-		config: config,
+	sink = &Sink{
+		wal:        walInstance,
+		config:     config,
+		compliance: complianceEngine,
+		keys:       keys,
 	}
 
-	// Initialize compliance engine if configured
-	if config.ComplianceProfile != "" {
-		sink.compliance, err = compliance.New(config.ComplianceProfile, config.ComplianceOptions...)
-		if err != nil {
-			_ = walInstance.Close()
-			return nil, fmt.Errorf("compliance init failed: %w", err)
-		}
+	// Route writes through a group committer when enabled
+	if config.GroupCommit {
+		sink.committer = performance.NewGroupCommitter(walInstance, config.GroupCommitSize, config.GroupCommitDelay)
 	}
 
 	// Initialize backends
 	for _, backendConfig := range config.BackendConfigs {
 		backend, err := backends.Create(backendConfig)
 		if err != nil {
-			_ = walInstance.Close()
 			return nil, fmt.Errorf("failed to create backend: %w", err)
 		}
 		sink.backends = append(sink.backends, backend)
 	}
 
-	// Initialize resilience manager
+	// Load replication cursors so backends resume from their last delivered record
+	sink.cursors, err = loadCursorStore(config.WALPath + ".cursors")
+	if err != nil {
+		return nil, err
+	}
+
+	// Periodically sign the Merkle root so later rewrites are provable
+	if config.CheckpointInterval > 0 {
+		if complianceEngine == nil || complianceEngine.Signer() == nil {
+			return nil, fmt.Errorf("checkpoints require a profile that mandates signing")
+		}
+		sink.checkpoints, err = newCheckpointer(walInstance, complianceEngine.Signer(), config.WALPath+".checkpoints", config.CheckpointInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Have independent witnesses cosign each checkpoint
+	if len(config.WitnessURLs) > 0 {
+		if sink.checkpoints == nil {
+			return nil, fmt.Errorf("witnesses require checkpoints")
+		}
+		sink.checkpoints.witnesses, err = newWitnessPublisher(walInstance, complianceEngine.Signer(), config.WALPath+".cosignatures", config.WitnessQuorum, config.WitnessURLs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Periodically timestamp the Merkle root with an independent authority
+	if config.TimestampURL != "" {
+		client := compliance.NewTSAClient(config.TimestampURL)
+		sink.timestamps, err = newTimestamper(walInstance, client, config.WALPath+".timestamps", config.TimestampInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Refuse to append to a WAL that is behind its signed high-water mark
+	if config.HighWaterMarkInterval > 0 {
+		if complianceEngine == nil || complianceEngine.Signer() == nil {
+			return nil, fmt.Errorf("high-water mark requires a profile that mandates signing")
+		}
+		sink.highWater, err = newHighWaterMarker(walInstance, complianceEngine.Signer(), config.WALPath, config.HighWaterMarkPath, sink.backends, config.HighWaterMarkInterval)
+		if err != nil {
+			return nil, err
+		}
+		if err := sink.highWater.check(); err != nil {
+			if config.FailureHandler == nil {
+				return nil, err
+			}
+			config.FailureHandler(rollbackEvent(err), fmt.Errorf("%w: %w", ErrComplianceViolation, err))
+		}
+	}
+
+	// Each backend gets its own circuit breaker; a closing breaker triggers catch-up
 	resilienceOpts := []resilience.Option{}
+	for i, name := range replicatorNames(sink.backends) {
+		rep := newReplicator(sink, sink.backends[i], name)
+		sink.replicators = append(sink.replicators, rep)
+		resilienceOpts = append(resilienceOpts, resilience.WithCircuitBreaker(name, resilience.CircuitBreakerConfig{
+			OnStateChange: rep.onBreakerStateChange,
+		}))
+	}
+
+	// Initialize resilience manager
 	if config.CircuitBreakerOptions != nil {
 		// Apply circuit breaker options if provided
 		for _, opt := range config.CircuitBreakerOptions {
@@ -132,6 +291,29 @@ func New(opts ...Option) (*Sink, error) {
 	sink.monitoring = monitoring.NewMonitor(monitorConfig)
 	sink.monitoring.Start()
 
+	// Re-verify sealed segments in the background, repairing from backends
+	if config.ScrubInterval > 0 {
+		sink.scrubber = newScrubber(walInstance, sink.monitoring, scrubSources(sink.backends), config.ScrubInterval, config.ScrubRate)
+	}
+
+	// Start replication; each backend first catches up on anything it missed
+	for _, rep := range sink.replicators {
+		go rep.run()
+	}
+	if sink.checkpoints != nil {
+		go sink.checkpoints.run()
+	}
+	if sink.timestamps != nil {
+		go sink.timestamps.run()
+	}
+	if sink.highWater != nil {
+		go sink.highWater.run()
+	}
+	if sink.scrubber != nil {
+		go sink.scrubber.run()
+	}
+
+	started = true
 	return sink, nil
 }
 
@@ -152,6 +334,63 @@ func (s *Sink) Emit(event *core.LogEvent) {
 	}
 	s.mu.RUnlock()
 
+	if _, err := s.emit(event); err != nil {
+		// This should NEVER happen, but if it does...
+		s.handleCriticalFailure(event, err)
+	}
+}
+
+// EmitSync writes a log event and blocks until it reaches the requested
+// durability level or ctx is done. It returns the WAL sequence assigned to the
+// event; a non-zero sequence with an error means the event is in the WAL but
+// did not reach the requested level in time.
+func (s *Sink) EmitSync(ctx context.Context, event *core.LogEvent, durability Durability) (uint64, error) {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return 0, ErrSinkClosed
+	}
+	s.mu.RUnlock()
+
+	if durability >= DurabilityOneBackend && len(s.replicators) == 0 {
+		return 0, ErrNoBackends
+	}
+
+	seq, err := s.emit(event)
+	if err != nil {
+		if s.monitoring != nil {
+			s.monitoring.RecordCriticalFailure(err)
+		}
+		return 0, fmt.Errorf("%w: %w", ErrWriteFailed, err)
+	}
+
+	if durability >= DurabilitySynced {
+		if err := s.wal.SyncTo(seq); err != nil {
+			return seq, fmt.Errorf("WAL sync failed: %w", err)
+		}
+	}
+
+	if durability >= DurabilityOneBackend {
+		names := make([]string, len(s.replicators))
+		for i, rep := range s.replicators {
+			names[i] = rep.name
+		}
+
+		need := 1
+		if durability == DurabilityAllBackends {
+			need = len(names)
+		}
+
+		if err := s.cursors.waitFor(ctx, names, seq, need); err != nil {
+			return seq, fmt.Errorf("waiting for backend replication: %w", err)
+		}
+	}
+
+	return seq, nil
+}
+
+// emit transforms, writes and queues an event for replication.
+func (s *Sink) emit(event *core.LogEvent) (uint64, error) {
 	// Apply compliance transformations if needed
 	if s.compliance != nil {
 		event = s.compliance.Transform(event)
@@ -163,16 +402,17 @@ func (s *Sink) Emit(event *core.LogEvent) {
 	}
 
 	// Write to WAL with guaranteed durability
-	if err := s.writeToWAL(event); err != nil {
-		// This should NEVER happen, but if it does...
-		s.handleCriticalFailure(event, err)
-		return
+	seq, err := s.writeToWAL(event)
+	if err != nil {
+		return 0, err
 	}
 
-	// Asynchronously replicate to backends
-	for _, backend := range s.backends {
-		go s.replicateToBackend(backend, event)
+	// Hand off to the per-backend replication queues
+	for _, rep := range s.replicators {
+		rep.enqueue(event, seq)
 	}
+
+	return seq, nil
 }
 
 // Close gracefully shuts down the audit sink.
@@ -186,8 +426,36 @@ func (s *Sink) Close() error {
 
 	s.closed = true
 
-	// Give background goroutines a moment to see the closed flag
-	time.Sleep(100 * time.Millisecond)
+	// A scrub in progress is abandoned; the next one starts over
+	if s.scrubber != nil {
+		s.scrubber.close()
+	}
+
+	// Commit anything still batched before replication drains
+	if s.committer != nil {
+		if err := s.committer.Close(); err != nil {
+			return fmt.Errorf("group commit close: %w", err)
+		}
+	}
+
+	// Stop replication and persist delivery cursors
+	for _, rep := range s.replicators {
+		rep.shutdown()
+	}
+	s.cursors.close()
+
+	// Commit to everything written before the WAL closes
+	if s.timestamps != nil {
+		s.timestamps.close()
+	}
+	if s.checkpoints != nil {
+		if err := s.checkpoints.close(); err != nil {
+			return fmt.Errorf("final checkpoint: %w", err)
+		}
+	}
+	if s.highWater != nil {
+		s.highWater.close()
+	}
 
 	// Flush any pending writes
 	if err := s.wal.Flush(); err != nil {
@@ -199,6 +467,13 @@ func (s *Sink) Close() error {
 		return fmt.Errorf("WAL close: %w", err)
 	}
 
+	// Close the signature log once the WAL can no longer sign
+	if s.compliance != nil {
+		if err := s.compliance.Close(); err != nil {
+			return fmt.Errorf("compliance close: %w", err)
+		}
+	}
+
 	// Close backends gracefully
 	for _, backend := range s.backends {
 		if err := backend.Close(); err != nil {
@@ -229,6 +504,7 @@ func (s *Sink) VerifyIntegrity() (*IntegrityReport, error) {
 		return nil, fmt.Errorf("WAL verification failed: %w", err)
 	}
 	report.WALIntegrity = walReport
+	report.Valid = walReport.Valid
 
 	// Verify compliance chain if enabled
 	if s.compliance != nil {
@@ -255,7 +531,6 @@ func (s *Sink) VerifyIntegrity() (*IntegrityReport, error) {
 		}
 	}
 
-	report.Valid = walReport.Valid
 	report.TotalRecords = walReport.TotalRecords
 	report.CorruptedSegments = walReport.CorruptedSegments
 
@@ -267,16 +542,31 @@ func (s *Sink) WALPath() string {
 	return s.config.WALPath
 }
 
+// ReplicationCursors returns the last WAL sequence delivered to each backend.
+func (s *Sink) ReplicationCursors() map[string]uint64 {
+	return s.cursors.snapshot()
+}
+
 // Private methods
 
-func (s *Sink) writeToWAL(event *core.LogEvent) error {
+func (s *Sink) writeToWAL(event *core.LogEvent) (uint64, error) {
+	write := s.wal.Append
+	if s.committer != nil {
+		// Blocks until the batch containing this event is committed
+		write = s.committer.Append
+	}
+
 	// Add resilience wrapper if configured
 	if s.resilience != nil {
-		return s.resilience.Execute(func() error {
-			return s.wal.Write(event)
+		var seq uint64
+		err := s.resilience.Execute(func() error {
+			var err error
+			seq, err = write(event)
+			return err
 		})
+		return seq, err
 	}
-	return s.wal.Write(event)
+	return write(event)
 }
 
 func (s *Sink) handleCriticalFailure(event *core.LogEvent, err error) {
@@ -297,46 +587,6 @@ func (s *Sink) handleCriticalFailure(event *core.LogEvent, err error) {
 	fmt.Fprintf(os.Stderr, "CRITICAL: Failed to write audit event: %v\n", err)
 }
 
-// replicateToBackend replicates events to a specific backend
-func (s *Sink) replicateToBackend(backend backends.Backend, event *core.LogEvent) {
-	// Check if sink is closed before attempting write
-	s.mu.RLock()
-	if s.closed {
-		s.mu.RUnlock()
-		// Silently skip - sink is shutting down
-		return
-	}
-	s.mu.RUnlock()
-
-	// Use resilience manager for backend writes
-	var writeErr error
-	if s.resilience != nil {
-		writeErr = s.resilience.ExecuteWithBreaker(backend.Name(), func() error {
-			return backend.Write(event)
-		})
-	} else {
-		writeErr = backend.Write(event)
-	}
-
-	if writeErr != nil {
-		// Check if error is due to backend being closed
-		if strings.Contains(writeErr.Error(), "backend closed") || strings.Contains(writeErr.Error(), "closed") {
-			// During shutdown, this is expected - don't log
-			return
-		}
-
-		// Record failure in monitoring
-		if s.monitoring != nil {
-			s.monitoring.RecordBackendFailure(backend.Name(), writeErr)
-		}
-		// Log error but don't fail - this is async replication
-		fmt.Fprintf(os.Stderr, "Backend %s replication error: %v\n", backend.Name(), writeErr)
-	} else if s.monitoring != nil {
-		// Record success
-		s.monitoring.RecordBackendSuccess(backend.Name())
-	}
-}
-
 // Replay reads events from the WAL within a time range
 func (s *Sink) Replay(start, end time.Time) ([]*core.LogEvent, error) {
 	reader, err := wal.NewReader(s.config.WALPath)
@@ -344,6 +594,7 @@ func (s *Sink) Replay(start, end time.Time) ([]*core.LogEvent, error) {
 		return nil, fmt.Errorf("failed to create reader: %w", err)
 	}
 	defer func() { _ = reader.Close() }()
+	reader.SetKeyring(s.keys)
 
 	if start.IsZero() && end.IsZero() {
 		return reader.ReadAll()
@@ -1,10 +1,22 @@
 package audit
 
 import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/willibrandon/mtlog-audit/backends"
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/wal"
 	"github.com/willibrandon/mtlog/core"
 )
 
@@ -99,3 +111,1188 @@ func TestSinkMultipleEvents(t *testing.T) {
 		t.Error("Integrity check failed after recovery")
 	}
 }
+
+func TestSinkReplicationCatchUp(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "test.wal")
+	backendPath := filepath.Join(tmpDir, "backend")
+	cursorName := "filesystem[" + backendPath + "]"
+
+	emit := func(sink *Sink, count int) {
+		for i := 0; i < count; i++ {
+			sink.Emit(&core.LogEvent{
+				Timestamp:       time.Now(),
+				Level:           core.InformationLevel,
+				MessageTemplate: "Replicated event {Index}",
+				Properties:      map[string]interface{}{"Index": i},
+			})
+		}
+	}
+
+	// Events written while no backend is configured stay in the WAL
+	sink, err := New(WithWAL(walPath))
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	emit(sink, 20)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	// A backend added on restart catches up from the beginning of the WAL
+	sink, err = New(
+		WithWAL(walPath),
+		WithBackend(backends.FilesystemConfig{Path: backendPath}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to reopen sink: %v", err)
+	}
+	emit(sink, 5)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	if got := sink.ReplicationCursors()[cursorName]; got != 25 {
+		t.Errorf("Expected cursor at 25, got %d", got)
+	}
+
+	// Reopening must not redeliver records behind the persisted cursor
+	sink, err = New(
+		WithWAL(walPath),
+		WithBackend(backends.FilesystemConfig{Path: backendPath}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to reopen sink: %v", err)
+	}
+	if got := sink.ReplicationCursors()[cursorName]; got != 25 {
+		t.Errorf("Expected persisted cursor at 25, got %d", got)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	backend, err := backends.NewFilesystemBackend(backends.FilesystemConfig{Path: backendPath})
+	if err != nil {
+		t.Fatalf("Failed to open backend: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	events, err := backend.Read(time.Now().Add(-time.Hour), time.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to read backend: %v", err)
+	}
+	if len(events) != 25 {
+		t.Errorf("Expected 25 replicated events, got %d", len(events))
+	}
+}
+
+func TestSinkReplicationSkipsCorruptRecords(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "test.wal")
+	backendPath := filepath.Join(tmpDir, "backend")
+
+	var reported []error
+	sink, err := New(WithWAL(walPath), WithFailureHandler(func(_ *core.LogEvent, err error) {
+		reported = append(reported, err)
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+	for i := 0; i < 5; i++ {
+		sink.Emit(&core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Replicated event {Index}",
+			Properties:      map[string]interface{}{"Index": i},
+		})
+	}
+
+	// Damage the payload of record 3 on disk, leaving its framing intact
+	segments, err := filepath.Glob(filepath.Join(tmpDir, "test*.wal"))
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("Expected one WAL segment, got %v (%v)", segments, err)
+	}
+	reader, err := wal.NewReader(segments[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offset int
+	for i := 0; i < 2; i++ {
+		record, err := reader.ReadNextRecord()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := record.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset += len(data)
+	}
+	_ = reader.Close()
+	data, err := os.ReadFile(segments[0]) // #nosec G304 - test file path
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[offset+24+8+32] ^= 0xFF
+	if err := os.WriteFile(segments[0], data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Catch-up passes over the damaged record instead of retrying it forever
+	backend, err := backends.NewFilesystemBackend(backends.FilesystemConfig{Path: backendPath})
+	if err != nil {
+		t.Fatalf("Failed to open backend: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+	rep := newReplicator(sink, backend, backend.Name())
+	defer rep.closeReader()
+	rep.catchUp(make(chan struct{}))
+
+	if got := sink.ReplicationCursors()[backend.Name()]; got != 5 {
+		t.Errorf("Expected the cursor past the damaged record at 5, got %d", got)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], ErrWALCorrupted) || !errors.Is(reported[0], wal.ErrRecordCorrupted) {
+		t.Errorf("Expected the damaged record reported once, got %v", reported)
+	}
+	events, err := backend.Read(time.Now().Add(-time.Hour), time.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to read backend: %v", err)
+	}
+	if len(events) != 4 {
+		t.Errorf("Expected the 4 intact events replicated, got %d", len(events))
+	}
+}
+
+func TestSinkReplicationQueueOverflow(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "test.wal")
+	backendPath := filepath.Join(tmpDir, "backend")
+
+	// A one-slot queue forces most events to spill to WAL catch-up
+	sink, err := New(
+		WithWAL(walPath),
+		WithBackend(backends.FilesystemConfig{Path: backendPath}),
+		WithReplicationQueue(1, OverflowSpill),
+		WithReplicationBatch(10, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		sink.Emit(&core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Overflow event {Index}",
+			Properties:      map[string]interface{}{"Index": i},
+		})
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	if got := sink.ReplicationCursors()["filesystem["+backendPath+"]"]; got != 200 {
+		t.Errorf("Expected cursor at 200, got %d", got)
+	}
+
+	backend, err := backends.NewFilesystemBackend(backends.FilesystemConfig{Path: backendPath})
+	if err != nil {
+		t.Fatalf("Failed to open backend: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	events, err := backend.Read(time.Now().Add(-time.Hour), time.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to read backend: %v", err)
+	}
+
+	// Every event arrives exactly once and in order
+	if len(events) != 200 {
+		t.Fatalf("Expected 200 replicated events, got %d", len(events))
+	}
+	for i, event := range events {
+		if index, _ := event.Properties["Index"].(float64); int(index) != i {
+			t.Fatalf("Event %d out of order: got index %v", i, event.Properties["Index"])
+		}
+	}
+}
+
+func TestSinkReplicationSpilledTail(t *testing.T) {
+	tmpDir := t.TempDir()
+	backendPath := filepath.Join(tmpDir, "backend")
+	cursorName := "filesystem[" + backendPath + "]"
+
+	// Retries are too far apart to deliver the spilled events
+	sink, err := New(
+		WithWAL(filepath.Join(tmpDir, "test.wal")),
+		WithBackend(backends.FilesystemConfig{Path: backendPath}),
+		WithReplicationQueue(1, OverflowSpill),
+		WithReplicationBatch(1, time.Millisecond),
+		WithReplicationRetryInterval(time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	event := func(i int) *core.LogEvent {
+		return &core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Spilled event {Index}",
+			Properties:      map[string]interface{}{"Index": i},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := sink.EmitSync(ctx, event(0), DurabilityOneBackend); err != nil {
+		t.Fatalf("EmitSync failed: %v", err)
+	}
+
+	// Hold the replicator at its cursor so the queue fills and the last
+	// events spill, with nothing emitted after them
+	sink.cursors.mu.Lock()
+	for i := 1; i <= 3; i++ {
+		sink.Emit(event(i))
+	}
+	sink.cursors.mu.Unlock()
+
+	if err := sink.cursors.waitFor(ctx, []string{cursorName}, 4, 1); err != nil {
+		t.Fatalf("Spilled events were not delivered: cursor at %d: %v", sink.ReplicationCursors()[cursorName], err)
+	}
+}
+
+func TestSinkEmitSync(t *testing.T) {
+	tmpDir := t.TempDir()
+	backendPath := filepath.Join(tmpDir, "backend")
+
+	sink, err := New(
+		WithWAL(filepath.Join(tmpDir, "test.wal")),
+		WithBackend(backends.FilesystemConfig{Path: backendPath}),
+		WithReplicationBatch(10, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	levels := []Durability{
+		DurabilityWritten,
+		DurabilitySynced,
+		DurabilityOneBackend,
+		DurabilityAllBackends,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for i, level := range levels {
+		event := &core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Durable event",
+			Properties:      map[string]interface{}{"Level": int(level)},
+		}
+
+		seq, err := sink.EmitSync(ctx, event, level)
+		if err != nil {
+			t.Fatalf("EmitSync(%d) failed: %v", level, err)
+		}
+		if seq != uint64(i+1) {
+			t.Errorf("EmitSync(%d) returned sequence %d, want %d", level, seq, i+1)
+		}
+
+		if level >= DurabilityOneBackend {
+			if got := sink.ReplicationCursors()["filesystem["+backendPath+"]"]; got < seq {
+				t.Errorf("EmitSync(%d) returned before replication: cursor %d < %d", level, got, seq)
+			}
+		}
+	}
+
+	_ = sink.Close()
+	if _, err := sink.EmitSync(ctx, &core.LogEvent{Timestamp: time.Now()}, DurabilityWritten); !errors.Is(err, ErrSinkClosed) {
+		t.Errorf("Expected ErrSinkClosed after close, got %v", err)
+	}
+}
+
+func TestSinkEmitSyncWithoutBackends(t *testing.T) {
+	sink, err := New(WithWAL(filepath.Join(t.TempDir(), "test.wal")))
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	event := &core.LogEvent{Timestamp: time.Now(), MessageTemplate: "No backends"}
+	if _, err := sink.EmitSync(context.Background(), event, DurabilityOneBackend); !errors.Is(err, ErrNoBackends) {
+		t.Errorf("Expected ErrNoBackends, got %v", err)
+	}
+}
+
+func TestSinkGroupCommit(t *testing.T) {
+	sink, err := New(
+		WithWAL(filepath.Join(t.TempDir(), "test.wal")),
+		WithGroupCommit(16, 5*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	var wg sync.WaitGroup
+	seen := make(map[uint64]bool)
+	var mu sync.Mutex
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				seq, err := sink.EmitSync(context.Background(), &core.LogEvent{
+					Timestamp:       time.Now(),
+					Level:           core.InformationLevel,
+					MessageTemplate: "Grouped event",
+					Properties:      map[string]interface{}{"Worker": worker, "Index": j},
+				}, DurabilitySynced)
+				if err != nil {
+					t.Errorf("EmitSync failed: %v", err)
+					return
+				}
+				mu.Lock()
+				seen[seq] = true
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(seen) != 200 {
+		t.Errorf("Expected 200 distinct sequences, got %d", len(seen))
+	}
+
+	report, err := sink.VerifyIntegrity()
+	if err != nil {
+		t.Fatalf("Integrity verification failed: %v", err)
+	}
+	if !report.Valid || report.TotalRecords != 200 {
+		t.Errorf("Expected 200 valid records, got valid=%v total=%d", report.Valid, report.TotalRecords)
+	}
+}
+
+// lazySyncPolicy never asks for a sync and counts the records it sees synced.
+type lazySyncPolicy struct {
+	synced atomic.Int64
+}
+
+func (p *lazySyncPolicy) ShouldSync(wal.SyncState) bool { return false }
+
+func (p *lazySyncPolicy) ObserveSync(records int, _ time.Duration) {
+	p.synced.Add(int64(records))
+}
+
+func TestSinkGroupCommitSyncsBatches(t *testing.T) {
+	policy := &lazySyncPolicy{}
+	sink, err := New(
+		WithWAL(filepath.Join(t.TempDir(), "test.wal")),
+		WithGroupCommit(16, time.Millisecond),
+		WithWALSyncPolicy(policy),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	// Each committed batch is durable even when the policy never syncs
+	for i := 0; i < 5; i++ {
+		sink.Emit(&core.LogEvent{Timestamp: time.Now(), Level: core.InformationLevel, MessageTemplate: "Grouped event"})
+		if synced := policy.synced.Load(); synced != int64(i+1) {
+			t.Fatalf("Expected %d records synced once committed, got %d", i+1, synced)
+		}
+	}
+}
+
+func TestSinkComplianceEncryption(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "test.wal")
+	backendPath := filepath.Join(tmpDir, "backend")
+	key := []byte("test-key-32-bytes-long-exactly!!")
+
+	open := func(opts ...Option) *Sink {
+		sink, err := New(append([]Option{
+			WithWAL(walPath),
+			WithCompliance("HIPAA"),
+			WithComplianceOptions(compliance.WithEncryptionKey(key)),
+			WithComplianceEncryption(),
+		}, opts...)...)
+		if err != nil {
+			t.Fatalf("Failed to create sink: %v", err)
+		}
+		return sink
+	}
+
+	sink := open()
+	for i := 0; i < 10; i++ {
+		sink.Emit(&core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Encrypted event {Index}",
+			Properties:      map[string]interface{}{"Index": i},
+		})
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	data, err := os.ReadFile(walPath) // #nosec G304 - test file path
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "Encrypted event") {
+		t.Fatal("Plaintext event data found in WAL")
+	}
+
+	// After a restart the same key decrypts for replay and backend catch-up
+	sink = open(WithBackend(backends.FilesystemConfig{Path: backendPath}))
+	events, err := sink.Replay(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(events) != 10 {
+		t.Errorf("Expected 10 replayed events, got %d", len(events))
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	backend, err := backends.NewFilesystemBackend(backends.FilesystemConfig{Path: backendPath})
+	if err != nil {
+		t.Fatalf("Failed to open backend: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	replicated, err := backend.Read(time.Now().Add(-time.Hour), time.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to read backend: %v", err)
+	}
+	if len(replicated) != 10 {
+		t.Errorf("Expected 10 replicated events, got %d", len(replicated))
+	}
+}
+
+func TestSinkComplianceEncryptionRequiresProfile(t *testing.T) {
+	_, err := New(
+		WithWAL(filepath.Join(t.TempDir(), "test.wal")),
+		WithComplianceEncryption(),
+	)
+	if err == nil {
+		t.Error("Expected error for compliance encryption without a profile")
+	}
+}
+
+func TestSinkComplianceSigning(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "test.wal")
+	keyPath := filepath.Join(tmpDir, "signing.pem")
+
+	open := func() *Sink {
+		signer, err := compliance.OpenSigner(keyPath, "Ed25519")
+		if err != nil {
+			t.Fatalf("Failed to open signer: %v", err)
+		}
+		sink, err := New(
+			WithWAL(walPath),
+			WithCompliance("HIPAA"),
+			WithComplianceOptions(compliance.WithSigner(signer)),
+			WithComplianceSigning(),
+		)
+		if err != nil {
+			t.Fatalf("Failed to create sink: %v", err)
+		}
+		return sink
+	}
+
+	// Signatures written across restarts form one chain
+	for run := 0; run < 2; run++ {
+		sink := open()
+		for i := 0; i < 5; i++ {
+			sink.Emit(&core.LogEvent{
+				Timestamp:       time.Now(),
+				Level:           core.InformationLevel,
+				MessageTemplate: "Signed event {Index}",
+				Properties:      map[string]interface{}{"Index": i},
+			})
+		}
+		report, err := sink.VerifyIntegrity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		chainReport, ok := report.ComplianceIntegrity.(*compliance.ChainVerificationReport)
+		if !ok || !report.Valid || chainReport.TotalSignatures != 5*(run+1) {
+			t.Errorf("Run %d: expected %d valid signatures, got %+v", run, 5*(run+1), report.ComplianceIntegrity)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Failed to close sink: %v", err)
+		}
+	}
+
+	verifier, err := compliance.LoadPublicKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := wal.VerifySignatures(walPath, walPath+".sig", verifier)
+	if err != nil {
+		t.Fatalf("Offline verification failed: %v", err)
+	}
+	if !report.Valid || report.Signed != 10 {
+		t.Errorf("Expected 10 signed records, got %+v", report)
+	}
+}
+
+func TestSinkComplianceSigningRequiresProfile(t *testing.T) {
+	_, err := New(
+		WithWAL(filepath.Join(t.TempDir(), "test.wal")),
+		WithCompliance("GDPR"),
+		WithComplianceSigning(),
+	)
+	if err == nil {
+		t.Error("Expected error for compliance signing with a profile that does not sign")
+	}
+}
+
+func TestSinkMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "test.wal")
+	backendPath := filepath.Join(tmpDir, "backend")
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := func(profile string) *Sink {
+		sink, err := New(
+			WithWAL(walPath),
+			WithCompliance(profile),
+			WithComplianceOptions(compliance.WithSigner(signer)),
+			WithBackend(backends.FilesystemConfig{Path: backendPath}),
+			WithMetadata("billing"),
+		)
+		if err != nil {
+			t.Fatalf("Failed to create sink: %v", err)
+		}
+		return sink
+	}
+
+	// Reopening with the same configuration records nothing new
+	for run := 0; run < 2; run++ {
+		sink := open("HIPAA")
+		sink.Emit(&core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Identified event {Run}",
+			Properties:      map[string]interface{}{"Run": run},
+		})
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Failed to close sink: %v", err)
+		}
+	}
+	sink := open("SOX")
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	history, err := wal.ReadMetadata(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected genesis and one profile change, got %d metadata records", len(history))
+	}
+	genesis := history[0]
+	fingerprint, err := compliance.LogID(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !genesis.Genesis() || genesis.Application != "billing" || genesis.ComplianceProfile != "HIPAA" {
+		t.Errorf("Unexpected genesis: %+v", genesis)
+	}
+	if genesis.SigningAlgorithm != "Ed25519" || genesis.SigningKey != "SHA256:"+fingerprint {
+		t.Errorf("Expected the genesis to name the signing key, got %+v", genesis)
+	}
+	if history[1].ComplianceProfile != "SOX" {
+		t.Errorf("Expected the profile change to be chained, got %+v", history[1])
+	}
+
+	// Metadata records are not replicated as events
+	backend, err := backends.NewFilesystemBackend(backends.FilesystemConfig{Path: backendPath})
+	if err != nil {
+		t.Fatalf("Failed to open backend: %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+	events, err := backend.Read(time.Now().Add(-time.Hour), time.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to read backend: %v", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("Expected 2 replicated events, got %d", len(events))
+	}
+}
+
+func TestSinkCheckpoints(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "test.wal")
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, err := New(
+		WithWAL(walPath),
+		WithCompliance("HIPAA"),
+		WithComplianceOptions(compliance.WithSigner(signer)),
+		WithCheckpoints(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+
+	emit := func(n int) {
+		for i := 0; i < n; i++ {
+			sink.Emit(&core.LogEvent{
+				Timestamp:       time.Now(),
+				Level:           core.InformationLevel,
+				MessageTemplate: "Checkpointed event {Index}",
+				Properties:      map[string]interface{}{"Index": i},
+			})
+		}
+	}
+
+	emit(5)
+	first, err := sink.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if first.TreeSize != 5 {
+		t.Errorf("Expected checkpoint at size 5, got %d", first.TreeSize)
+	}
+
+	// The ticker checkpoints growth on its own
+	emit(3)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		checkpoints, err := compliance.ReadCheckpoints(walPath + ".checkpoints")
+		if err == nil && checkpoints[len(checkpoints)-1].TreeSize == 8 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("No periodic checkpoint at size 8: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Close commits to the final records
+	emit(4)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+	if _, err := sink.Checkpoint(); err == nil {
+		t.Error("Expected error checkpointing a closed sink")
+	}
+
+	checkpoints, err := compliance.ReadCheckpoints(walPath + ".checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := checkpoints[len(checkpoints)-1]
+	if last.TreeSize != 12 {
+		t.Fatalf("Expected final checkpoint at size 12, got %d", last.TreeSize)
+	}
+
+	proof, err := wal.ProveConsistency(walPath, first, last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proof.Verify(signer); err != nil {
+		t.Errorf("Consistency between sink checkpoints failed: %v", err)
+	}
+}
+
+func TestSinkCheckpointsRequireSigning(t *testing.T) {
+	_, err := New(
+		WithWAL(filepath.Join(t.TempDir(), "test.wal")),
+		WithCompliance("GDPR"),
+		WithCheckpoints(time.Second),
+	)
+	if err == nil {
+		t.Error("Expected error for checkpoints with a profile that does not sign")
+	}
+}
+
+func TestSinkNewReleasesOnFailure(t *testing.T) {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("open files can't be counted on this platform")
+	}
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "test.wal")
+
+	// Fail after the WAL, group committer, engine and backend are created
+	for i := 0; i < 3; i++ {
+		_, err := New(
+			WithWAL(walPath),
+			WithCompliance("HIPAA"),
+			WithComplianceSigning(),
+			WithGroupCommit(16, 5*time.Millisecond),
+			WithBackend(backends.FilesystemConfig{Path: filepath.Join(dir, "backend")}),
+			WithWitnesses(1, "http://127.0.0.1:1"),
+		)
+		if err == nil {
+			t.Fatal("Expected witnesses without checkpoints to fail")
+		}
+	}
+	after, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) > len(fds) {
+		t.Errorf("Expected a failed New to close what it opened, %d files open before, %d after", len(fds), len(after))
+	}
+}
+
+func TestSinkTimestamping(t *testing.T) {
+	tsa, err := compliance.NewLocalTSA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(tsa)
+	defer server.Close()
+
+	walPath := filepath.Join(t.TempDir(), "test.wal")
+	sink, err := New(
+		WithWAL(walPath),
+		WithTimestamping(server.URL, time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+
+	emit := func(n int) {
+		for i := 0; i < n; i++ {
+			sink.Emit(&core.LogEvent{
+				Timestamp:       time.Now(),
+				Level:           core.InformationLevel,
+				MessageTemplate: "Timestamped event {Index}",
+				Properties:      map[string]interface{}{"Index": i},
+			})
+		}
+	}
+
+	emit(5)
+	record, err := sink.Timestamp(context.Background())
+	if err != nil {
+		t.Fatalf("Timestamp failed: %v", err)
+	}
+	if record.TreeSize != 5 {
+		t.Errorf("Expected a token over 5 records, got %d", record.TreeSize)
+	}
+
+	// Close timestamps the records written since
+	emit(3)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(tsa.Certificate())
+	report, err := wal.VerifyTimestamps(walPath, walPath+".timestamps", roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Verified != 2 || report.CoveredRecords != 8 {
+		t.Errorf("Expected 2 tokens covering 8 records, got %+v", report)
+	}
+}
+
+func TestSinkTimestampingAuthorityDown(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	sink, err := New(
+		WithWAL(filepath.Join(t.TempDir(), "test.wal")),
+		WithTimestamping(server.URL, 10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+
+	// An unreachable authority never blocks or fails writes
+	seq, err := sink.EmitSync(context.Background(), &core.LogEvent{
+		Timestamp:       time.Now(),
+		Level:           core.InformationLevel,
+		MessageTemplate: "Written while the TSA is down",
+	}, DurabilitySynced)
+	if err != nil || seq != 1 {
+		t.Errorf("EmitSync = %d, %v", seq, err)
+	}
+	if _, err := sink.Timestamp(context.Background()); err == nil {
+		t.Error("Expected error from an unreachable authority")
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestSinkWitnesses(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "test.wal")
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifiers := make(map[string]compliance.Signer)
+	var urls []string
+	var servers []*httptest.Server
+	for _, name := range []string{"witness-a", "witness-b"} {
+		witnessSigner, err := compliance.NewEd25519Signer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		witness, err := compliance.NewWitness(name, witnessSigner, "", signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := httptest.NewServer(witness)
+		defer server.Close()
+		servers = append(servers, server)
+		urls = append(urls, server.URL)
+		verifiers[name] = witnessSigner
+	}
+
+	open := func() *Sink {
+		t.Helper()
+		sink, err := New(
+			WithWAL(walPath),
+			WithCompliance("HIPAA"),
+			WithComplianceOptions(compliance.WithSigner(signer)),
+			WithCheckpoints(time.Hour),
+			WithWitnesses(2, urls...),
+		)
+		if err != nil {
+			t.Fatalf("Failed to create sink: %v", err)
+		}
+		return sink
+	}
+	emit := func(sink *Sink, n int) {
+		for i := 0; i < n; i++ {
+			sink.Emit(&core.LogEvent{
+				Timestamp:       time.Now(),
+				Level:           core.InformationLevel,
+				MessageTemplate: "Witnessed event {Index}",
+				Properties:      map[string]interface{}{"Index": i},
+			})
+		}
+	}
+
+	sink := open()
+	emit(sink, 5)
+	if _, err := sink.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	emit(sink, 3)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	// A reopened sink catches up with what the witnesses last cosigned
+	sink = open()
+	emit(sink, 2)
+	if _, err := sink.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint after reopen failed: %v", err)
+	}
+
+	// Losing a witness stores the checkpoint but reports the missed quorum
+	servers[1].Close()
+	emit(sink, 1)
+	cp, err := sink.Checkpoint()
+	if err == nil || cp == nil || cp.TreeSize != 11 {
+		t.Errorf("Expected an unwitnessed checkpoint at size 11, got %v, %v", cp, err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+
+	report, err := wal.VerifyCosignatures(walPath, walPath+".cosignatures", verifiers, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Verified != 7 || report.WitnessedRecords != 10 {
+		t.Errorf("Expected 10 records witnessed by both, got %+v", report)
+	}
+	report, err = wal.VerifyCosignatures(walPath, walPath+".cosignatures", verifiers, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.WitnessedRecords != 11 {
+		t.Errorf("Expected 11 records witnessed by one, got %+v", report)
+	}
+}
+
+func TestSinkWitnessesRequireCheckpoints(t *testing.T) {
+	_, err := New(
+		WithWAL(filepath.Join(t.TempDir(), "test.wal")),
+		WithCompliance("HIPAA"),
+		WithWitnesses(1, "http://localhost:8420"),
+	)
+	if err == nil {
+		t.Error("Expected error for witnesses without checkpoints")
+	}
+}
+
+func TestSinkHighWaterMark(t *testing.T) {
+	tmpDir := t.TempDir()
+	walDir := filepath.Join(tmpDir, "wal")
+	walPath := filepath.Join(walDir, "test.wal")
+	markPath := filepath.Join(tmpDir, "test.hwm")
+	backendPath := filepath.Join(tmpDir, "backend")
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := func(localPath string, extra ...Option) (*Sink, error) {
+		return New(append([]Option{
+			WithWAL(walPath),
+			WithCompliance("HIPAA"),
+			WithComplianceOptions(compliance.WithSigner(signer)),
+			WithBackend(backends.FilesystemConfig{Path: backendPath}),
+			WithHighWaterMark(time.Hour, localPath),
+		}, extra...)...)
+	}
+	emit := func(sink *Sink, n int) {
+		for i := 0; i < n; i++ {
+			sink.Emit(&core.LogEvent{
+				Timestamp:       time.Now(),
+				Level:           core.InformationLevel,
+				MessageTemplate: "Marked event {Index}",
+				Properties:      map[string]interface{}{"Index": i},
+			})
+		}
+	}
+
+	sink, err := open(markPath)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	emit(sink, 5)
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The final mark covers every record and is mirrored to the backend
+	mark, err := compliance.ReadHighWaterMark(markPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mark.Sequence != 5 {
+		t.Errorf("Expected a high-water mark at sequence 5, got %d", mark.Sequence)
+	}
+	if err := mark.Verify(signer); err != nil {
+		t.Errorf("High-water mark does not verify: %v", err)
+	}
+	if mark.Log == "" {
+		t.Error("Expected the high-water mark to name its WAL")
+	}
+	backendMark := filepath.Join(backendPath, "metadata", HighWaterMarkObject(walPath, mark.Log))
+	if _, err := os.Stat(backendMark); err != nil {
+		t.Errorf("Expected the high-water mark mirrored to the backend: %v", err)
+	}
+
+	// Keep a copy of the WAL, then let the log grow past it
+	backup := filepath.Join(tmpDir, "backup")
+	copyDir(t, walDir, backup)
+	sink, err = open(markPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen sink: %v", err)
+	}
+	emit(sink, 3)
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restoring the old copy is a rollback
+	if err := os.RemoveAll(walDir); err != nil {
+		t.Fatal(err)
+	}
+	copyDir(t, backup, walDir)
+	if _, err := open(markPath); !errors.Is(err, ErrRollbackDetected) {
+		t.Fatalf("Expected a rollback error, got %v", err)
+	}
+
+	// The backend's mirror alone is enough to catch it
+	if _, err := open(""); !errors.Is(err, ErrRollbackDetected) {
+		t.Fatalf("Expected a rollback error from the backend mark, got %v", err)
+	}
+
+	// A forged local mark is a violation too
+	forged, err := compliance.ReadHighWaterMark(markPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Sequence = 5
+	if err := compliance.WriteHighWaterMark(markPath, forged); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := open(markPath); !errors.Is(err, ErrRollbackDetected) {
+		t.Fatalf("Expected a forged mark to be rejected, got %v", err)
+	}
+
+	// With a FailureHandler the sink opens and reports the violation
+	var reported error
+	sink, err = open(markPath, WithFailureHandler(func(_ *core.LogEvent, err error) {
+		reported = err
+	}))
+	if err != nil {
+		t.Fatalf("Expected the sink to open with a FailureHandler: %v", err)
+	}
+	if !errors.Is(reported, ErrComplianceViolation) || !errors.Is(reported, ErrRollbackDetected) {
+		t.Errorf("Expected a rollback violation, got %v", reported)
+	}
+	emit(sink, 1)
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rolled-back log never lowers the recorded mark
+	kept, err := compliance.ReadHighWaterMark(backendMark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.Sequence != 8 {
+		t.Errorf("Expected the backend to keep the mark at sequence 8, got %d", kept.Sequence)
+	}
+
+	// A different WAL under the same local mark is a rollback too
+	if err := os.RemoveAll(walDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := compliance.WriteHighWaterMark(markPath, kept); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := open(markPath); !errors.Is(err, ErrRollbackDetected) {
+		t.Fatalf("Expected a replaced WAL to be rejected, got %v", err)
+	}
+}
+
+func TestSinkHighWaterMarkRequiresStore(t *testing.T) {
+	_, err := New(
+		WithWAL(filepath.Join(t.TempDir(), "test.wal")),
+		WithCompliance("HIPAA"),
+		WithHighWaterMark(time.Hour, ""),
+	)
+	if err == nil {
+		t.Error("Expected error for a high-water mark with nowhere to store it")
+	}
+}
+
+// copyDir copies the regular files in src to dst.
+func copyDir(t *testing.T, src, dst string) {
+	t.Helper()
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dst, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dst, entry.Name()), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSinkScrubbing(t *testing.T) {
+	tmpDir := t.TempDir()
+	backendPath := filepath.Join(tmpDir, "backend")
+
+	sink, err := New(
+		WithWAL(filepath.Join(tmpDir, "test.wal"), wal.WithSegmentSize(4096)),
+		WithBackend(backends.FilesystemConfig{Path: backendPath, Shadow: true, MaxSize: 1 << 20, MaxAge: time.Hour}),
+		WithReplicationBatch(10, time.Millisecond),
+		WithScrubbing(time.Hour, 0),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for i := 0; i < 40; i++ {
+		event := &core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Scrubbed event {Index}",
+			Properties:      map[string]interface{}{"Index": i, "Padding": strings.Repeat("x", 200)},
+		}
+		if _, err := sink.EmitSync(ctx, event, DurabilityAllBackends); err != nil {
+			t.Fatalf("EmitSync failed: %v", err)
+		}
+	}
+
+	reports, err := sink.Scrub(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) < 2 {
+		t.Fatalf("Expected several sealed segments, got %d", len(reports))
+	}
+	for _, report := range reports {
+		if !report.Intact || report.Damaged() {
+			t.Errorf("Expected intact segments, got %+v", report)
+		}
+	}
+
+	// Damage a sealed segment and lose the backend's primary copy; the
+	// shadow copy still repairs it
+	segment := reports[0].Segment
+	original, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	damaged := append([]byte(nil), original...)
+	damaged[1000] ^= 0xFF
+	if err := os.WriteFile(segment, damaged, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(backendPath); err != nil {
+		t.Fatal(err)
+	}
+
+	reports, err = sink.Scrub(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reports[0].Damaged() || !reports[0].Intact || reports[0].RepairedRecords != 1 {
+		t.Fatalf("Expected the segment to be repaired, got %+v", reports[0])
+	}
+	if repaired, _ := os.ReadFile(segment); string(repaired) != string(original) {
+		t.Error("Repaired segment differs from the original")
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sink.Scrub(ctx); !errors.Is(err, ErrSinkClosed) {
+		t.Errorf("Expected ErrSinkClosed after close, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/wal"
+)
+
+// timestampCloseTimeout bounds the final timestamp request on Close.
+const timestampCloseTimeout = 10 * time.Second
+
+// timestamper periodically obtains RFC 3161 timestamp tokens over the WAL's
+// Merkle tree head.
+type timestamper struct {
+	wal      *wal.WAL
+	client   *compliance.TSAClient
+	stop     chan struct{}
+	done     chan struct{}
+	path     string
+	interval time.Duration
+	lastSize uint64
+	mu       sync.Mutex
+}
+
+// newTimestamper opens the timestamp log at path and resumes from its last
+// token.
+func newTimestamper(w *wal.WAL, client *compliance.TSAClient, path string, interval time.Duration) (*timestamper, error) {
+	t := &timestamper{
+		wal:      w,
+		client:   client,
+		path:     path,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	records, err := compliance.ReadTimestamps(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(records) > 0 {
+		t.lastSize = records[len(records)-1].TreeSize
+	}
+	return t, nil
+}
+
+// run requests a timestamp every interval while the log has grown.
+func (t *timestamper) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			if _, err := t.timestamp(context.Background(), false); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: timestamp failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// timestamp obtains and stores a token over the current tree head. Unless
+// force is set it does nothing when the tree has not grown since the last
+// token.
+func (t *timestamper) timestamp(ctx context.Context, force bool) (*compliance.TimestampRecord, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	root, size, err := t.wal.SyncedMerkleRoot()
+	if err != nil {
+		return nil, err
+	}
+	if !force && size == t.lastSize {
+		return nil, nil
+	}
+
+	record, err := t.client.TimestampTreeHead(ctx, size, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := compliance.AppendTimestamp(t.path, record); err != nil {
+		return nil, err
+	}
+	t.lastSize = size
+	return record, nil
+}
+
+// close stops the ticker and makes a final attempt to timestamp the records
+// written since the last token. An unreachable authority only warns.
+func (t *timestamper) close() {
+	close(t.stop)
+	<-t.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), timestampCloseTimeout)
+	defer cancel()
+	if _, err := t.timestamp(ctx, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: final timestamp failed: %v\n", err)
+	}
+}
+
+// Timestamp obtains an RFC 3161 timestamp token over the WAL's current
+// Merkle tree head and appends it to the timestamp log. It requires
+// WithTimestamping.
+func (s *Sink) Timestamp(ctx context.Context) (*compliance.TimestampRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrSinkClosed
+	}
+	if s.timestamps == nil {
+		return nil, fmt.Errorf("timestamping is not enabled")
+	}
+	return s.timestamps.timestamp(ctx, true)
+}
@@ -0,0 +1,120 @@
+package scenarios
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	audit "github.com/willibrandon/mtlog-audit"
+	"github.com/willibrandon/mtlog-audit/wal"
+	"github.com/willibrandon/mtlog/core"
+)
+
+// ParityRepair corrupts a sealed segment protected by Reed-Solomon parity
+// and expects it to be repaired exactly, with no records lost.
+type ParityRepair struct {
+	Corruption *RandomCorruption
+	Parity     wal.ParityConfig
+	EventCount int
+}
+
+// NewParityRepair creates a new parity repair scenario. Its parity rebuilds
+// the most blocks the bit flip and overwrite corruptions can damage in one
+// stripe; truncation beyond the parity is not repairable.
+func NewParityRepair() *ParityRepair {
+	corruption := NewRandomCorruption()
+	corruption.CorruptionType = "bitflip"
+	return &ParityRepair{
+		Corruption: corruption,
+		Parity: wal.ParityConfig{
+			Blocks:       wal.BlockChecksum{BlockSize: 512, Type: wal.ChecksumXXHash3},
+			DataShards:   10,
+			ParityShards: 5,
+		},
+		EventCount: 200,
+	}
+}
+
+// Name returns the scenario name.
+func (p *ParityRepair) Name() string {
+	return "ParityRepair"
+}
+
+// Execute writes segments with parity and corrupts a sealed one.
+func (p *ParityRepair) Execute(_ *audit.Sink, dir string) error {
+	walPath := filepath.Join(dir, "parity.wal")
+	w, err := wal.New(walPath, wal.WithSegmentSize(16*1024), wal.WithParity(p.Parity))
+	if err != nil {
+		return err
+	}
+
+	events := make([]*core.LogEvent, p.EventCount)
+	for i := range events {
+		events[i] = &core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: fmt.Sprintf("Test event %d", i),
+			Properties: map[string]interface{}{
+				"Index": i,
+				// #nosec G404 - weak random acceptable for test data generation
+				"Random": rand.Int63(),
+			},
+		}
+	}
+	if _, err := w.WriteBatch(events); err != nil {
+		_ = w.Close()
+		return err
+	}
+	sealed := w.GetSegments()
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if len(sealed) < 2 {
+		return fmt.Errorf("expected sealed segments, got %d segment(s)", len(sealed))
+	}
+
+	// #nosec G404 - weak random acceptable for test scenario randomization
+	target := sealed[rand.Intn(len(sealed)-1)]
+	return p.Corruption.corruptWALFile(target.Path)
+}
+
+// Verify repairs every sealed segment and checks that all records survived.
+func (p *ParityRepair) Verify(dir string) error {
+	walPath := filepath.Join(dir, "parity.wal")
+	segments, err := wal.NewSegmentManager(walPath, 16*1024)
+	if err != nil {
+		return err
+	}
+
+	// Sealed segments have parity; the active one doesn't
+	for _, segment := range segments.GetSegments() {
+		if _, err := os.Stat(wal.ParityPath(segment.Path)); err != nil {
+			continue
+		}
+		if _, err := wal.RepairSegment(segment.Path); err != nil {
+			return fmt.Errorf("failed to repair %s: %w", segment.Path, err)
+		}
+	}
+
+	// Rescan now that every segment is whole again
+	segments, err = wal.NewSegmentManager(walPath, 16*1024)
+	if err != nil {
+		return err
+	}
+	records, err := segments.ReadAllSegments()
+	if err != nil {
+		return err
+	}
+	if len(records) != p.EventCount {
+		return fmt.Errorf("expected %d records after repair, got %d", p.EventCount, len(records))
+	}
+
+	w, err := wal.New(walPath, wal.WithSegmentSize(16*1024))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = w.Close() }()
+	return w.VerifyIntegrity()
+}
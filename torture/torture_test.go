@@ -32,6 +32,7 @@ func TestTorture(t *testing.T) {
 
 	// Register scenarios
 	suite.RegisterScenario(scenarios.NewKill9DuringWrite())
+	suite.RegisterScenario(scenarios.NewParityRepair())
 
 	// TODO: Add more scenarios
 	// suite.RegisterScenario(scenarios.NewDiskFull())
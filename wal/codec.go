@@ -0,0 +1,488 @@
+package wal
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/willibrandon/mtlog/core"
+)
+
+// Codec identifies how event payloads are encoded. Each record declares its
+// codec in its Version, and the WAL writes each segment with a single codec:
+// opening a WAL with a different codec starts a new segment.
+type Codec int
+
+const (
+	// CodecJSON encodes events with encoding/json. It is the default and
+	// the encoding of every record written before codecs were selectable.
+	CodecJSON Codec = iota
+	// CodecBinary encodes events with a compact binary encoding of the
+	// timestamp, level, template, exception and properties. Property values
+	// decode to the types CodecJSON gives them: numbers are float64, and
+	// times, byte slices and structs are the strings and maps their JSON
+	// holds. An event decoded from a backend's JSON copy therefore encodes
+	// to the same bytes, which lets scrub, repair and restore rebuild binary
+	// records.
+	CodecBinary
+)
+
+// VersionBinary is the format version of records whose event payloads use
+// CodecBinary.
+const VersionBinary = 2
+
+// String returns the codec name.
+func (c Codec) String() string {
+	switch c {
+	case CodecJSON:
+		return "json"
+	case CodecBinary:
+		return "binary"
+	default:
+		return fmt.Sprintf("Codec(%d)", int(c))
+	}
+}
+
+// version returns the record version that declares c.
+func (c Codec) version() uint16 {
+	if c == CodecBinary {
+		return VersionBinary
+	}
+	return Version
+}
+
+// codecOf returns the codec a record version declares.
+func codecOf(version uint16) (Codec, error) {
+	switch version {
+	case Version:
+		return CodecJSON, nil
+	case VersionBinary:
+		return CodecBinary, nil
+	default:
+		return 0, fmt.Errorf("unsupported record version %d", version)
+	}
+}
+
+// knownVersion reports whether records of version can be read.
+func knownVersion(version uint16) bool {
+	_, err := codecOf(version)
+	return err == nil
+}
+
+// Codec returns the codec the record's event payload is encoded with.
+// Metadata records are always JSON; their version declares the codec of the
+// segment they belong to.
+func (r *Record) Codec() Codec {
+	codec, _ := codecOf(r.Version)
+	return codec
+}
+
+// Codec returns the codec the segment's records declare.
+func (s *Segment) Codec() Codec {
+	codec, _ := codecOf(s.Version)
+	return codec
+}
+
+// WithCodec sets the codec event payloads are written with. Without it, a
+// WAL keeps the codec of its active segment, and a new WAL uses CodecJSON.
+func WithCodec(codec Codec) Option {
+	return func(c *config) error {
+		if codec != CodecJSON && codec != CodecBinary {
+			return fmt.Errorf("unsupported codec %d", int(codec))
+		}
+		c.codec = &codec
+		return nil
+	}
+}
+
+// encodeEvent serializes event with codec.
+func encodeEvent(event *core.LogEvent, codec Codec) ([]byte, error) {
+	if codec == CodecBinary {
+		return marshalBinaryEvent(event)
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return data, nil
+}
+
+// decodeEvent deserializes plaintext event data written by the codec that
+// version declares.
+func decodeEvent(data []byte, version uint16) (*core.LogEvent, error) {
+	codec, err := codecOf(version)
+	if err != nil {
+		return nil, err
+	}
+	if codec == CodecBinary {
+		return unmarshalBinaryEvent(data)
+	}
+	var event core.LogEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	return &event, nil
+}
+
+// eventJSON returns plaintext event data as JSON, transcoding binary
+// payloads for callers that expect JSON.
+func eventJSON(data []byte, version uint16) ([]byte, error) {
+	if version == Version {
+		return data, nil
+	}
+	event, err := decodeEvent(data, version)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(event)
+}
+
+// The binary event encoding, version 1:
+//
+//	version(1) + timestamp + level(varint) + template + exception + properties
+//
+// The timestamp is time.Time's own binary form, in UTC when its offset is
+// zero, and the exception is a value holding its message or nil. Strings and
+// byte slices are a uvarint length followed by their bytes. Properties are a
+// uvarint count followed by key/value pairs sorted by key, and each value is
+// a type tag followed by its data.
+//
+// The encoding is canonical: values are encoded as encoding/json would
+// decode their JSON into an interface{}, and decode as such. Integral
+// numbers are valueInt, or valueUint beyond int64, to keep them compact, and
+// only other numbers are valueFloat; all three hold a float64 value and
+// decode as one. Values JSON
+// encodes in their own way, such as times, byte slices and structs, are
+// encoded as the value their JSON decodes to.
+const binaryEventVersion = 1
+
+// Value tags in the binary event encoding.
+const (
+	valueNil byte = iota
+	valueFalse
+	valueTrue
+	valueInt
+	valueUint
+	valueFloat
+	valueString
+	valueMap
+	valueList
+)
+
+// maxValueDepth bounds how deeply property values may nest.
+const maxValueDepth = 64
+
+var errShortEvent = errors.New("binary event truncated")
+
+func marshalBinaryEvent(event *core.LogEvent) ([]byte, error) {
+	// A zero offset is UTC once the timestamp has been through RFC 3339
+	ts := event.Timestamp
+	if _, offset := ts.Zone(); offset == 0 {
+		ts = ts.UTC()
+	}
+	timestamp, err := ts.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal timestamp: %w", err)
+	}
+
+	buf := make([]byte, 0, 64+len(event.MessageTemplate)+32*len(event.Properties))
+	buf = append(buf, binaryEventVersion)
+	buf = appendBytes(buf, timestamp)
+	buf = binary.AppendVarint(buf, int64(event.Level))
+	buf = appendBytes(buf, []byte(event.MessageTemplate))
+	if event.Exception != nil {
+		buf = append(buf, valueString)
+		buf = appendBytes(buf, []byte(event.Exception.Error()))
+	} else {
+		buf = append(buf, valueNil)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(event.Properties)))
+	for _, key := range sortedKeys(event.Properties) {
+		buf = appendBytes(buf, []byte(key))
+		if buf, err = appendValue(buf, event.Properties[key], 0); err != nil {
+			return nil, fmt.Errorf("property %q: %w", key, err)
+		}
+	}
+	return buf, nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func appendBytes(buf, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func appendValue(buf []byte, value any, depth int) ([]byte, error) {
+	if depth > maxValueDepth {
+		return nil, fmt.Errorf("value nested deeper than %d", maxValueDepth)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return append(buf, valueNil), nil
+	case bool:
+		if v {
+			return append(buf, valueTrue), nil
+		}
+		return append(buf, valueFalse), nil
+	case string:
+		return appendBytes(append(buf, valueString), []byte(v)), nil
+	case json.Number:
+		return appendNumber(buf, v)
+	case error:
+		return appendBytes(append(buf, valueString), []byte(v.Error())), nil
+	case map[string]any:
+		if v == nil {
+			return append(buf, valueNil), nil
+		}
+		buf = binary.AppendUvarint(append(buf, valueMap), uint64(len(v)))
+		for _, key := range sortedKeys(v) {
+			buf = appendBytes(buf, []byte(key))
+			var err error
+			if buf, err = appendValue(buf, v[key], depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case []any:
+		if v == nil {
+			return append(buf, valueNil), nil
+		}
+		buf = binary.AppendUvarint(append(buf, valueList), uint64(len(v)))
+		for _, item := range v {
+			var err error
+			if buf, err = appendValue(buf, item, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case json.Marshaler, encoding.TextMarshaler:
+		return appendJSONValue(buf, value, depth)
+	}
+
+	// Named numeric and string types keep their underlying value
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendFloat(buf, float64(rv.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendFloat(buf, float64(rv.Uint())), nil
+	case reflect.Float32:
+		// As JSON writes it, the shortest decimal that reads back as v
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(rv.Float(), 'g', -1, 32), 64)
+		return appendFloat(buf, f), nil
+	case reflect.Float64:
+		return appendFloat(buf, rv.Float()), nil
+	case reflect.Bool:
+		return appendValue(buf, rv.Bool(), depth)
+	case reflect.String:
+		return appendBytes(append(buf, valueString), []byte(rv.String())), nil
+	}
+	return appendJSONValue(buf, value, depth)
+}
+
+// appendJSONValue encodes value as the value its JSON decodes to.
+func appendJSONValue(buf []byte, value any, depth int) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %T: %w", value, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded any
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %T: %w", value, err)
+	}
+	return appendValue(buf, decoded, depth)
+}
+
+// appendNumber encodes a JSON number by the float64 it decodes to.
+func appendNumber(buf []byte, n json.Number) ([]byte, error) {
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return appendFloat(buf, f), nil
+}
+
+// appendFloat encodes f, as an integer when it is integral. Every number is
+// encoded by its float64 value, so an integer and its JSON copy, which may
+// have rounded it, encode alike.
+func appendFloat(buf []byte, f float64) []byte {
+	if f == math.Trunc(f) && !(f == 0 && math.Signbit(f)) {
+		switch {
+		case f >= math.MinInt64 && f < math.MaxInt64:
+			return binary.AppendVarint(append(buf, valueInt), int64(f))
+		case f >= 0 && f < math.MaxUint64:
+			return binary.AppendUvarint(append(buf, valueUint), uint64(f))
+		}
+	}
+	return binary.LittleEndian.AppendUint64(append(buf, valueFloat), math.Float64bits(f))
+}
+
+// eventDecoder reads a binary event, remembering the first error.
+type eventDecoder struct {
+	err  error
+	data []byte
+}
+
+func unmarshalBinaryEvent(data []byte) (*core.LogEvent, error) {
+	d := &eventDecoder{data: data}
+	if version := d.byte(); d.err == nil && version != binaryEventVersion {
+		return nil, fmt.Errorf("unsupported binary event version %d", version)
+	}
+
+	event := &core.LogEvent{}
+	if timestamp := d.bytes(); d.err == nil {
+		if err := event.Timestamp.UnmarshalBinary(timestamp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal timestamp: %w", err)
+		}
+	}
+	event.Level = core.LogEventLevel(d.varint())
+	event.MessageTemplate = string(d.bytes())
+	if exception, ok := d.value(0).(string); ok {
+		event.Exception = errors.New(exception)
+	}
+
+	count := d.count()
+	if count > 0 {
+		event.Properties = make(map[string]any, count)
+	}
+	for i := 0; i < count && d.err == nil; i++ {
+		key := string(d.bytes())
+		event.Properties[key] = d.value(0)
+	}
+
+	if d.err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", d.err)
+	}
+	if len(d.data) > 0 {
+		return nil, fmt.Errorf("failed to unmarshal event: %d trailing bytes", len(d.data))
+	}
+	return event, nil
+}
+
+func (d *eventDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+	d.data = nil
+}
+
+func (d *eventDecoder) byte() byte {
+	if len(d.data) == 0 {
+		d.fail(errShortEvent)
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *eventDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail(errShortEvent)
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *eventDecoder) varint() int64 {
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail(errShortEvent)
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// count reads a collection length, which can be no larger than the bytes
+// left since every entry takes at least one.
+func (d *eventDecoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.fail(errShortEvent)
+		return 0
+	}
+	// #nosec G115 - bounded by the length of the data
+	return int(n)
+}
+
+func (d *eventDecoder) bytes() []byte {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	b := d.data[:n:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *eventDecoder) value(depth int) any {
+	if depth > maxValueDepth {
+		d.fail(fmt.Errorf("value nested deeper than %d", maxValueDepth))
+		return nil
+	}
+
+	switch tag := d.byte(); tag {
+	case valueNil:
+		return nil
+	case valueFalse:
+		return false
+	case valueTrue:
+		return true
+	case valueInt:
+		return float64(d.varint())
+	case valueUint:
+		return float64(d.uvarint())
+	case valueFloat:
+		if len(d.data) < 8 {
+			d.fail(errShortEvent)
+			return nil
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.data))
+		d.data = d.data[8:]
+		return v
+	case valueString:
+		return string(d.bytes())
+	case valueMap:
+		count := d.count()
+		m := make(map[string]any, count)
+		for i := 0; i < count && d.err == nil; i++ {
+			key := string(d.bytes())
+			m[key] = d.value(depth + 1)
+		}
+		return m
+	case valueList:
+		count := d.count()
+		list := make([]any, 0, count)
+		for i := 0; i < count && d.err == nil; i++ {
+			list = append(list, d.value(depth+1))
+		}
+		return list
+	default:
+		if d.err == nil {
+			d.fail(fmt.Errorf("unknown value tag %d", tag))
+		}
+		return nil
+	}
+}
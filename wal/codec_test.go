@@ -0,0 +1,305 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog/core"
+)
+
+func typedEvent(i int) *core.LogEvent {
+	return &core.LogEvent{
+		Timestamp:       time.Date(2026, 3, 1, 12, 0, 0, i, time.FixedZone("EST", -5*3600)),
+		Level:           core.WarningLevel,
+		MessageTemplate: "User {UserId} paid {Amount} for {Items}",
+		Properties: map[string]interface{}{
+			"UserId":   i,
+			"Amount":   12.5,
+			"Items":    []interface{}{"book", uint64(3), true, nil},
+			"Card":     map[string]interface{}{"Last4": "4242", "Expires": time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC)},
+			"Raw":      []byte{0, 1, 2},
+			"Elapsed":  250 * time.Millisecond,
+			"Customer": struct{ Name string }{"Ada"},
+		},
+	}
+}
+
+func TestBinaryEventRoundTrip(t *testing.T) {
+	event := typedEvent(7)
+	event.Exception = errors.New("card declined")
+	data, err := encodeEvent(event, CodecBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeEvent(data, VersionBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, offset := decoded.Timestamp.Zone(); !decoded.Timestamp.Equal(event.Timestamp) || offset != -5*3600 {
+		t.Errorf("Timestamp: expected %v, got %v", event.Timestamp, decoded.Timestamp)
+	}
+	if decoded.Level != event.Level || decoded.MessageTemplate != event.MessageTemplate {
+		t.Errorf("Unexpected header fields: %+v", decoded)
+	}
+	if decoded.Exception == nil || decoded.Exception.Error() != "card declined" {
+		t.Errorf("Expected the exception message, got %v", decoded.Exception)
+	}
+
+	expected := map[string]interface{}{
+		"UserId":   float64(7),
+		"Amount":   12.5,
+		"Items":    []interface{}{"book", float64(3), true, nil},
+		"Card":     map[string]interface{}{"Last4": "4242", "Expires": "2028-01-01T00:00:00Z"},
+		"Raw":      "AAEC",
+		"Elapsed":  float64(250 * time.Millisecond),
+		"Customer": map[string]interface{}{"Name": "Ada"},
+	}
+	if !reflect.DeepEqual(decoded.Properties, expected) {
+		t.Errorf("Properties:\nexpected %#v\ngot      %#v", expected, decoded.Properties)
+	}
+
+	// A backend's JSON copy of the event encodes to the same bytes
+	event.Exception = nil
+	data, err = encodeEvent(event, CodecBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData, err := encodeEvent(event, CodecJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored core.LogEvent
+	if err := json.Unmarshal(jsonData, &stored); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		rebuilt, err := encodeEvent(&stored, CodecBinary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(rebuilt, data) {
+			t.Fatalf("Expected the JSON copy to encode to the original bytes:\n%x\n%x", data, rebuilt)
+		}
+	}
+
+	// The binary encoding is smaller than JSON
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(jsonData) {
+		t.Errorf("Expected binary (%d bytes) to be smaller than JSON (%d bytes)", len(data), len(jsonData))
+	}
+
+	// Truncated payloads fail rather than decode partially
+	for i := range data {
+		if _, err := decodeEvent(data[:i], VersionBinary); err == nil {
+			t.Fatalf("Expected an error decoding %d of %d bytes", i, len(data))
+		}
+	}
+}
+
+type accountID int32
+
+func TestBinaryEventPropertyTypes(t *testing.T) {
+	when := time.Date(2026, 3, 1, 12, 0, 0, 500, time.FixedZone("EST", -5*3600))
+	values := map[string]interface{}{
+		"nil":         nil,
+		"bool":        true,
+		"int":         -42,
+		"int8":        int8(-8),
+		"int16":       int16(1600),
+		"int32":       int32(-320000),
+		"int64":       int64(1)<<53 + 1,
+		"uint":        uint(42),
+		"uint8":       uint8(255),
+		"uint16":      uint16(65535),
+		"uint32":      uint32(4000000000),
+		"uint64":      uint64(math.MaxUint64),
+		"float32":     float32(0.1),
+		"float64":     -2.5,
+		"whole float": 3.0,
+		"json number": json.Number("12.75"),
+		"string":      "text",
+		"named":       accountID(7),
+		"time":        when,
+		"duration":    90 * time.Second,
+		"bytes":       []byte("raw"),
+		"struct":      struct{ A int }{1},
+		"map":         map[string]interface{}{"nested": int16(2)},
+		"nil map":     map[string]interface{}(nil),
+		"list":        []interface{}{uint8(1), "two", 3.5},
+		"nil list":    []interface{}(nil),
+		"empty list":  []interface{}{},
+		"typed list":  []int{1, 2},
+	}
+
+	for name, value := range values {
+		t.Run(name, func(t *testing.T) {
+			event := &core.LogEvent{
+				Timestamp:       when,
+				MessageTemplate: "{Value}",
+				Properties:      map[string]interface{}{"Value": value},
+			}
+
+			data, err := encodeEvent(event, CodecBinary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := decodeEvent(data, VersionBinary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			jsonData, err := encodeEvent(event, CodecJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := decodeEvent(jsonData, Version)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Both codecs return the same value
+			got, want := decoded.Properties["Value"], stored.Properties["Value"]
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Binary codec returned %#v (%T), JSON codec %#v (%T)", got, got, want, want)
+			}
+
+			// The JSON copy encodes to the original bytes
+			rebuilt, err := encodeEvent(stored, CodecBinary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(rebuilt, data) {
+				t.Errorf("Expected the JSON copy to encode to the original bytes:\n%x\n%x", data, rebuilt)
+			}
+		})
+	}
+}
+
+func TestWALBinaryCodec(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "binary.wal")
+
+	// A JSON segment followed by a binary one
+	for _, codec := range []Codec{CodecJSON, CodecBinary} {
+		w, err := New(walPath, WithCodec(codec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if err := w.Write(typedEvent(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reopening without WithCodec keeps writing binary to the active segment
+	w, err := New(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(typedEvent(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments.GetSegments()) != 2 {
+		t.Fatalf("Expected a JSON and a binary segment, got %d segments", len(segments.GetSegments()))
+	}
+	jsonSegment, binarySegment := segments.GetSegments()[0], segments.GetSegments()[1]
+	if jsonSegment.Codec() != CodecJSON || binarySegment.Codec() != CodecBinary {
+		t.Errorf("Expected json and binary segments, got %s and %s", jsonSegment.Codec(), binarySegment.Codec())
+	}
+
+	// Readers decode both
+	var events []*core.LogEvent
+	for _, segment := range segments.GetSegments() {
+		reader, err := NewReader(segment.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, err := reader.ReadAll()
+		_ = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, read...)
+	}
+	if len(events) != 7 {
+		t.Fatalf("Expected 7 events, got %d", len(events))
+	}
+	for i := 0; i < 3; i++ {
+		if !reflect.DeepEqual(events[i].Properties, events[i+3].Properties) {
+			t.Errorf("Event %d: expected the binary segment to decode as the JSON one:\n%#v\n%#v", i, events[i].Properties, events[i+3].Properties)
+		}
+	}
+
+	// Recovery returns JSON events and repairs binary records as written
+	engine := NewRecoveryEngine(binarySegment.Path)
+	_, records, err := engine.Recover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 recovered records, got %d", len(records))
+	}
+	var recovered core.LogEvent
+	if err := json.Unmarshal(records[0], &recovered); err != nil || recovered.MessageTemplate != typedEvent(0).MessageTemplate {
+		t.Errorf("Expected a JSON event from recovery, got %s (%v)", records[0], err)
+	}
+
+	repairedPath := filepath.Join(dir, "repaired.wal")
+	if err := engine.RepairWAL(repairedPath); err != nil {
+		t.Fatal(err)
+	}
+	repaired, err := NewSegmentManager(repairedPath, 64*1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := repaired.GetSegments()[0].Codec(); got != CodecBinary {
+		t.Errorf("Expected the repaired WAL to stay binary, got %s", got)
+	}
+
+	// The index reads binary segments too
+	index := NewIndex(walPath)
+	if err := index.Build(segments.GetSegments()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := index.FindBySequence(7); err != nil {
+		t.Errorf("Expected the last binary record in the index: %v", err)
+	}
+}
+
+func BenchmarkEventCodec(b *testing.B) {
+	event := typedEvent(1)
+	for _, codec := range []Codec{CodecJSON, CodecBinary} {
+		b.Run(codec.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data, err := encodeEvent(event, codec)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := decodeEvent(data, codec.version()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
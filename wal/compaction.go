@@ -446,7 +446,7 @@ func (c *Compactor) compactSegmentGroup(segments []*Segment) (int64, error) {
 
 // readSegmentRecords reads all records from a segment
 func (c *Compactor) readSegmentRecords(seg *Segment) ([]*Record, error) {
-	file, err := os.Open(seg.Path)
+	file, err := openSegment(seg.Path, false)
 	if err != nil {
 		return nil, err
 	}
@@ -770,6 +770,9 @@ func (c *Compactor) MarkDeleted(sequence uint64) error {
 		_ = os.Remove(tempPath)
 		return fmt.Errorf("failed to replace segment: %w", err)
 	}
+	if err := refreshParity(targetSegment.Path); err != nil {
+		return fmt.Errorf("failed to update segment parity: %w", err)
+	}
 
 	// Update stats
 	c.stats.CompactionsRun++
@@ -0,0 +1,183 @@
+package wal
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression identifies how record payloads are compressed.
+type Compression int
+
+const (
+	// CompressionNone stores payloads as encoded. It is the default.
+	CompressionNone Compression = iota
+	// CompressionZstd compresses payloads with zstd, trading CPU for ratio.
+	CompressionZstd
+	// CompressionSnappy compresses payloads with snappy, trading ratio for
+	// speed.
+	CompressionSnappy
+)
+
+// String returns the compression algorithm name.
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionSnappy:
+		return "snappy"
+	default:
+		return fmt.Sprintf("Compression(%d)", int(c))
+	}
+}
+
+// maxDecompressedSize bounds the payload a record may decompress to.
+const maxDecompressedSize = 16 * 1024 * 1024
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// zstdCodecs returns the shared zstd encoder and decoder, which are safe for
+// concurrent use.
+func zstdCodecs() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil,
+			zstd.WithDecoderConcurrency(0),
+			zstd.WithDecoderMaxMemory(maxDecompressedSize))
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+// Compress replaces the record payload with its compressed form and sets
+// RecordFlagCompressed. Payloads that do not shrink are left as they are. It
+// must be called before Encrypt and before the record is marshaled.
+func (r *Record) Compress(c Compression) error {
+	if r.IsCompressed() || r.IsEncrypted() {
+		return fmt.Errorf("record %d is already compressed or encrypted", r.Sequence)
+	}
+
+	compressed, err := compressPayload(r.EventData, c)
+	if err != nil {
+		return fmt.Errorf("failed to compress record %d: %w", r.Sequence, err)
+	}
+	if compressed == nil {
+		return nil
+	}
+
+	r.EventData = compressed
+	// #nosec G115 - compressed payload is smaller than the original
+	r.Length = uint32(len(compressed))
+	r.Flags |= RecordFlagCompressed
+	return nil
+}
+
+// IsCompressed reports whether the record payload is compressed.
+func (r *Record) IsCompressed() bool {
+	return r.Flags&RecordFlagCompressed != 0
+}
+
+// compressPayload compresses data with c, returning nil when compression
+// does not save space. Compressed payloads start with the algorithm that
+// compressed them:
+//
+//	algorithm(1) + compressed data
+//
+// The payload is compressed before it is encrypted, and the record CRC and
+// hash chain cover the stored bytes, so both can be validated without
+// decompressing.
+func compressPayload(data []byte, c Compression) ([]byte, error) {
+	compressed := make([]byte, 1, len(data)+1)
+	switch c {
+	case CompressionNone:
+		return nil, nil
+	case CompressionZstd:
+		encoder, _, err := zstdCodecs()
+		if err != nil {
+			return nil, err
+		}
+		compressed = encoder.EncodeAll(data, compressed)
+	case CompressionSnappy:
+		compressed = append(compressed, snappy.Encode(nil, data)...)
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", int(c))
+	}
+
+	if len(compressed) >= len(data) {
+		return nil, nil
+	}
+	// #nosec G115 - validated compression fits in a byte
+	compressed[0] = byte(c)
+	return compressed, nil
+}
+
+// decompressPayload returns the uncompressed form of a compressed payload.
+func decompressPayload(data []byte) ([]byte, error) {
+	if len(data) < 1 {
+		return nil, fmt.Errorf("compressed payload too short")
+	}
+
+	switch Compression(data[0]) {
+	case CompressionZstd:
+		_, decoder, err := zstdCodecs()
+		if err != nil {
+			return nil, err
+		}
+		plain, err := decoder.DecodeAll(data[1:], nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress zstd payload: %w", err)
+		}
+		return plain, nil
+	case CompressionSnappy:
+		n, err := snappy.DecodedLen(data[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress snappy payload: %w", err)
+		}
+		if n > maxDecompressedSize {
+			return nil, fmt.Errorf("snappy payload decompresses to %d bytes", n)
+		}
+		plain, err := snappy.Decode(nil, data[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress snappy payload: %w", err)
+		}
+		return plain, nil
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm: %d", data[0])
+	}
+}
+
+// openEventPayload returns the encoded event of a record payload, decrypting
+// and then decompressing it as its flags require.
+func openEventPayload(data []byte, flags uint16, keys Keyring) ([]byte, error) {
+	plaintext, err := decryptPayload(data, flags, keys)
+	if err != nil {
+		return nil, err
+	}
+	if flags&RecordFlagCompressed == 0 {
+		return plaintext, nil
+	}
+	return decompressPayload(plaintext)
+}
+
+// WithCompression compresses record payloads with c. Records written without
+// it remain readable, so compression can be enabled on an existing WAL.
+func WithCompression(c Compression) Option {
+	return func(cfg *config) error {
+		if c < CompressionNone || c > CompressionSnappy {
+			return fmt.Errorf("unsupported compression %d", int(c))
+		}
+		cfg.compression = c
+		return nil
+	}
+}
@@ -0,0 +1,278 @@
+package wal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog/core"
+)
+
+func compressibleEvent(i int) *core.LogEvent {
+	return &core.LogEvent{
+		Timestamp:       time.Now(),
+		Level:           core.InformationLevel,
+		MessageTemplate: "Order {OrderId} shipped to {Address}",
+		Properties: map[string]interface{}{
+			"OrderId": i,
+			"Address": strings.Repeat("221B Baker Street, London ", 8),
+		},
+	}
+}
+
+func TestWALRecordCompression(t *testing.T) {
+	for _, compression := range []Compression{CompressionZstd, CompressionSnappy} {
+		t.Run(compression.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			plainPath := filepath.Join(dir, "plain.wal")
+			walPath := filepath.Join(dir, "compressed.wal")
+			keys := newTestKeyring(t)
+
+			for path, opts := range map[string][]Option{
+				plainPath: nil,
+				walPath:   {WithCompression(compression), WithEncryption(keys)},
+			} {
+				w, err := New(path, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < 10; i++ {
+					if err := w.Write(compressibleEvent(i)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := w.VerifyIntegrity(); err != nil {
+					t.Errorf("Integrity check failed: %v", err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			plain, err := os.Stat(plainPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compressed, err := os.Stat(walPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compressed.Size() >= plain.Size() {
+				t.Errorf("Expected compression to shrink the WAL, got %d bytes from %d", compressed.Size(), plain.Size())
+			}
+
+			// Records are compressed then encrypted, and decode through both
+			segments, err := NewSegmentManager(walPath, 64*1024*1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			records, err := segments.ReadAllSegments()
+			if err != nil {
+				t.Fatal(err)
+			}
+			record, err := UnmarshalRecord(records[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !record.IsCompressed() || !record.IsEncrypted() {
+				t.Fatalf("Expected a compressed, encrypted record, got flags %#x", record.Flags)
+			}
+			event, err := record.DecodeEvent(keys)
+			if err != nil || event.MessageTemplate != compressibleEvent(0).MessageTemplate {
+				t.Errorf("Failed to decode the record: %v", err)
+			}
+
+			reader, err := NewReader(walPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader.SetKeyring(keys)
+			events, err := reader.ReadAll()
+			_ = reader.Close()
+			if err != nil || len(events) != 10 {
+				t.Fatalf("Expected 10 events, got %d (%v)", len(events), err)
+			}
+
+			// Recovery decompresses events and repairs records as written
+			engine := NewRecoveryEngine(walPath, WithRecoveryKeyring(keys))
+			report, recovered, err := engine.Recover()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.RecoveredRecords != 10 || report.CorruptedRecords != 0 {
+				t.Errorf("Expected 10 clean records, got %+v", report)
+			}
+			var decoded core.LogEvent
+			if err := json.Unmarshal(recovered[0], &decoded); err != nil {
+				t.Errorf("Expected a JSON event from recovery, got %q", recovered[0])
+			}
+
+			repairedPath := filepath.Join(dir, "repaired.wal")
+			if err := engine.RepairWAL(repairedPath); err != nil {
+				t.Fatal(err)
+			}
+			repaired, err := NewSegmentManager(repairedPath, 64*1024*1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			records, err = repaired.ReadAllSegments()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record, err := UnmarshalRecord(records[0]); err != nil || !record.IsCompressed() {
+				t.Errorf("Expected the repaired record to stay compressed: %v", err)
+			}
+		})
+	}
+}
+
+func TestWALSegmentCompression(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "seekable.wal")
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(walPath, WithSegmentSize(4096), WithSealing(signer), WithSegmentCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 40; i++ {
+		if err := w.Write(compressibleEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, _ := w.MerkleRoot()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every sealed segment is compressed and the active one is not
+	segments, err := NewSegmentManager(walPath, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := segments.GetSegments()
+	if len(all) < 3 {
+		t.Fatalf("Expected the WAL to rotate, got %d segment(s)", len(all))
+	}
+	for i, segment := range all {
+		if segment.Compressed != (i < len(all)-1) {
+			t.Errorf("Segment %d: compressed %v", i, segment.Compressed)
+		}
+	}
+
+	// Compressed segments read, verify and prove as before
+	records, err := segments.ReadAllSegments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 40 {
+		t.Fatalf("Expected 40 records, got %d", len(records))
+	}
+	reader, err := NewReader(all[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := reader.ReadAll()
+	_ = reader.Close()
+	if err != nil || len(events) == 0 {
+		t.Errorf("Expected events from a compressed segment, got %d (%v)", len(events), err)
+	}
+
+	report, err := VerifySeals(walPath, walPath+".seals", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Sealed != len(all)-1 {
+		t.Errorf("Expected the compressed segments to match their seals, got %+v", report)
+	}
+	proof, err := ProveInclusion(walPath, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.RootHash != hexHash(root) || proof.Verify() != nil || len(proof.Event) == 0 {
+		t.Errorf("Expected a valid proof with the event, got %+v", proof)
+	}
+
+	index := NewIndex(walPath)
+	if err := index.Build(all); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := index.FindBySequence(2); err != nil {
+		t.Errorf("Expected a compressed record in the index: %v", err)
+	}
+
+	recovery, recovered, err := NewRecoveryEngine(all[0].Path).Recover()
+	if err != nil || recovery.CorruptedRecords != 0 || len(recovered) == 0 {
+		t.Errorf("Expected a clean recovery of a compressed segment, got %+v (%v)", recovery, err)
+	}
+
+	// Reopening keeps writing after the compressed history
+	w, err = New(walPath, WithSegmentSize(4096), WithSealing(signer), WithSegmentCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq, err := w.Append(compressibleEvent(40)); err != nil || seq != 41 {
+		t.Errorf("Expected sequence 41, got %d (%v)", seq, err)
+	}
+	if err := w.VerifyIntegrity(); err != nil {
+		t.Errorf("Integrity check failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressedSegmentCorruption(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "damaged.wal")
+	w, err := New(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Enough records for several frames
+	events := make([]*core.LogEvent, 3000)
+	for i := range events {
+		events[i] = compressibleEvent(i)
+	}
+	if _, err := w.WriteBatch(events); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompressSegment(walPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// Damage the first frame
+	data, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/8] ^= 0xFF
+	if err := os.WriteFile(walPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := segments.ReadAllSegments(); err == nil {
+		t.Error("Expected an error reading a damaged frame")
+	}
+
+	// Recovery skips the damaged frame and keeps the records after it
+	report, records, err := NewRecoveryEngine(walPath).Recover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.CorruptedRecords == 0 || len(records) == 0 || len(records) >= 3000 {
+		t.Errorf("Expected recovery around the damaged frame, got %d records and %+v", len(records), report)
+	}
+}
@@ -0,0 +1,133 @@
+package wal
+
+import (
+	"fmt"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+)
+
+// ConsistencyProof shows that the log described by the From checkpoint is a
+// prefix of the log described by the To checkpoint: nothing covered by the
+// earlier checkpoint was changed or removed before the later one.
+type ConsistencyProof struct {
+	From      *compliance.Checkpoint `json:"from"`
+	To        *compliance.Checkpoint `json:"to"`
+	Algorithm string                 `json:"algorithm"`
+	Path      []string               `json:"path"`
+}
+
+// ComputeMerkleRoot reads the WAL at walPath and returns the root hash and
+// size of the Merkle tree over every record.
+func ComputeMerkleRoot(walPath string) ([32]byte, uint64, error) {
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		return [32]byte{}, 0, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	tree, err := buildMerkleTree(segments)
+	if err != nil {
+		return [32]byte{}, 0, err
+	}
+	return tree.Root(), tree.Size(), nil
+}
+
+// ProveConsistency builds a consistency proof between two checkpoints from
+// the WAL at walPath. It fails if the WAL no longer matches either
+// checkpoint, which means records they cover were rewritten.
+func ProveConsistency(walPath string, from, to *compliance.Checkpoint) (*ConsistencyProof, error) {
+	if from.TreeSize > to.TreeSize {
+		return nil, fmt.Errorf("checkpoint at size %d is newer than checkpoint at size %d", from.TreeSize, to.TreeSize)
+	}
+
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	tree, err := buildMerkleTree(segments)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cp := range []*compliance.Checkpoint{from, to} {
+		root, err := tree.RootAt(cp.TreeSize)
+		if err != nil {
+			return nil, fmt.Errorf("WAL is shorter than checkpoint: %w", err)
+		}
+		if hexHash(root) != cp.RootHash {
+			return nil, fmt.Errorf("WAL root at size %d is %s, checkpoint has %s", cp.TreeSize, hexHash(root), cp.RootHash)
+		}
+	}
+
+	path, err := tree.ConsistencyProof(from.TreeSize, to.TreeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConsistencyProof{
+		Algorithm: compliance.MerkleAlgorithm,
+		From:      from,
+		To:        to,
+		Path:      hexHashes(path),
+	}, nil
+}
+
+// Verify checks the proof against the two checkpoints' root hashes. When
+// verifier is non-nil it also checks both checkpoint signatures, which is
+// what makes the roots trustworthy.
+func (p *ConsistencyProof) Verify(verifier compliance.Signer) error {
+	if p.Algorithm != compliance.MerkleAlgorithm {
+		return fmt.Errorf("unsupported proof algorithm: %s", p.Algorithm)
+	}
+	if p.From == nil || p.To == nil {
+		return fmt.Errorf("proof is missing a checkpoint")
+	}
+
+	if verifier != nil {
+		if err := p.From.Verify(verifier); err != nil {
+			return err
+		}
+		if err := p.To.Verify(verifier); err != nil {
+			return err
+		}
+	}
+
+	fromRoot, err := p.From.Root()
+	if err != nil {
+		return err
+	}
+	toRoot, err := p.To.Root()
+	if err != nil {
+		return err
+	}
+	path := make([][32]byte, len(p.Path))
+	for i, h := range p.Path {
+		if path[i], err = parseHash(h); err != nil {
+			return err
+		}
+	}
+	return compliance.VerifyConsistency(p.From.TreeSize, p.To.TreeSize, fromRoot, toRoot, path)
+}
+
+// buildMerkleTree reads every segment into a Merkle tree over all records.
+func buildMerkleTree(segments *SegmentManager) (*compliance.MerkleTree, error) {
+	tree := compliance.NewMerkleTree()
+	for _, segment := range segments.GetSegments() {
+		if !fileExists(segment.Path) {
+			continue
+		}
+		records, err := segments.readSegment(segment.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read segment %s: %w", segment.Path, err)
+		}
+		for _, data := range records {
+			record, err := UnmarshalRecord(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read record in %s: %w", segment.Path, err)
+			}
+			if record.IsGenesis() {
+				continue
+			}
+			tree.Append(recordLeaf(record.ComputeHash()))
+		}
+	}
+	return tree, nil
+}
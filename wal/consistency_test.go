@@ -0,0 +1,127 @@
+package wal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+)
+
+func TestWALConsistencyProof(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "consistency.wal")
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := func() *compliance.Checkpoint {
+		root, size, err := w.SyncedMerkleRoot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cp, err := compliance.SignCheckpoint(signer, size, root, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cp
+	}
+
+	for i := 0; i < 7; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	from := checkpoint()
+	for i := 7; i < 25; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	to := checkpoint()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if root, size, err := ComputeMerkleRoot(walPath); err != nil || size != 25 || hexHash(root) != to.RootHash {
+		t.Fatalf("ComputeMerkleRoot = %x/%d, %v; want checkpoint %s/25", root, size, err, to.RootHash)
+	}
+
+	proof, err := ProveConsistency(walPath, from, to)
+	if err != nil {
+		t.Fatalf("ProveConsistency: %v", err)
+	}
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ConsistencyProof
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Verify(signer); err != nil {
+		t.Errorf("Proof failed to verify: %v", err)
+	}
+
+	other, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Verify(other); err == nil {
+		t.Error("Proof verified checkpoints with the wrong key")
+	}
+	if _, err := ProveConsistency(walPath, to, from); err == nil {
+		t.Error("Expected error proving consistency backwards")
+	}
+
+	// Rewrite history: replace the log with different records of the same length
+	segments, err := filepath.Glob(walPath + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range segments {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err = New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rewrittenFrom *compliance.Checkpoint
+	for i := 100; i < 125; i++ {
+		if i == 107 {
+			rewrittenFrom = checkpoint()
+		}
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rewrittenTo := checkpoint()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ProveConsistency(walPath, from, to); err == nil {
+		t.Error("Rewritten log still matched the original checkpoints")
+	}
+
+	// A proof over the rewritten log does not carry over to the original
+	// checkpoint
+	forged, err := ProveConsistency(walPath, rewrittenFrom, rewrittenTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.From = from
+	if err := forged.Verify(signer); err == nil {
+		t.Error("Consistency proof across a rewrite verified")
+	}
+}
@@ -0,0 +1,198 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog/core"
+)
+
+// ErrRecordEncrypted is returned when an encrypted record is read without a
+// keyring able to decrypt it.
+var ErrRecordEncrypted = errors.New("record is encrypted")
+
+// Keyring encrypts record payloads and decrypts them by key ID.
+// *compliance.KeyManager satisfies it.
+type Keyring interface {
+	Encrypt(plaintext []byte) (*compliance.EncryptedRecord, error)
+	Decrypt(record *compliance.EncryptedRecord) ([]byte, error)
+}
+
+// Encrypted payloads start with an encryption header naming the AEAD
+// algorithm and key ID, followed by the nonce and ciphertext:
+//
+//	algorithm(1) + keyIDLength(1) + keyID + [wrappedKeyLength(2) + wrappedKey] + ciphertext
+//
+// When the keyring wraps its data keys, the algorithm byte has
+// wrappedKeyFlag set and the wrapped key follows the key ID, so a keyring
+// that has lost the key can still unwrap it with its provider.
+//
+// The header sits inside EventData, so the record CRC and the hash chain
+// cover it and can be validated without the key.
+var encryptionAlgorithms = []string{
+	1: "AES-256-GCM",
+	2: "ChaCha20-Poly1305",
+}
+
+// wrappedKeyFlag marks an algorithm byte followed by a wrapped data key.
+const wrappedKeyFlag = 0x80
+
+// Encrypt replaces the record payload with its AEAD-encrypted form and sets
+// RecordFlagEncrypted. It must be called before the record is marshaled.
+func (r *Record) Encrypt(keys Keyring) error {
+	if r.Flags&RecordFlagEncrypted != 0 {
+		return fmt.Errorf("record %d is already encrypted", r.Sequence)
+	}
+
+	encrypted, err := keys.Encrypt(r.EventData)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt record %d: %w", r.Sequence, err)
+	}
+
+	sealed, err := sealPayload(encrypted)
+	if err != nil {
+		return err
+	}
+
+	r.EventData = sealed
+	// #nosec G115 - sealed payload length validated against max record size
+	r.Length = uint32(len(sealed))
+	r.Flags |= RecordFlagEncrypted
+	return nil
+}
+
+// IsEncrypted reports whether the record payload is encrypted.
+func (r *Record) IsEncrypted() bool {
+	return r.Flags&RecordFlagEncrypted != 0
+}
+
+// KeyID returns the ID of the key that encrypted the record, or an empty
+// string for plaintext records.
+func (r *Record) KeyID() string {
+	if !r.IsEncrypted() {
+		return ""
+	}
+	encrypted, err := openPayload(r.EventData)
+	if err != nil {
+		return ""
+	}
+	return encrypted.KeyID
+}
+
+// DecodeEvent deserializes the record's event, decrypting it with keys when
+// the record is encrypted. keys may be nil for plaintext records.
+func (r *Record) DecodeEvent(keys Keyring) (*core.LogEvent, error) {
+	data, err := openEventPayload(r.EventData, r.Flags, keys)
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", r.Sequence, err)
+	}
+	return decodeEvent(data, r.Version)
+}
+
+// decryptPayload returns the plaintext of a record payload.
+func decryptPayload(data []byte, flags uint16, keys Keyring) ([]byte, error) {
+	if flags&RecordFlagEncrypted == 0 {
+		return data, nil
+	}
+	if keys == nil {
+		return nil, ErrRecordEncrypted
+	}
+
+	encrypted, err := openPayload(data)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := keys.Decrypt(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with key %s: %w", encrypted.KeyID, err)
+	}
+	return plaintext, nil
+}
+
+// sealPayload prefixes ciphertext with the encryption header.
+func sealPayload(encrypted *compliance.EncryptedRecord) ([]byte, error) {
+	algorithm := 0
+	for id, name := range encryptionAlgorithms {
+		if name != "" && name == encrypted.Algorithm {
+			algorithm = id
+			break
+		}
+	}
+	if algorithm == 0 {
+		return nil, fmt.Errorf("unsupported encryption algorithm: %s", encrypted.Algorithm)
+	}
+	if len(encrypted.KeyID) == 0 || len(encrypted.KeyID) > 255 {
+		return nil, fmt.Errorf("invalid key ID length: %d", len(encrypted.KeyID))
+	}
+	if len(encrypted.WrappedKey) > 0xFFFF {
+		return nil, fmt.Errorf("invalid wrapped key length: %d", len(encrypted.WrappedKey))
+	}
+
+	sealed := make([]byte, 0, 4+len(encrypted.KeyID)+len(encrypted.WrappedKey)+len(encrypted.Ciphertext))
+	if len(encrypted.WrappedKey) > 0 {
+		algorithm |= wrappedKeyFlag
+	}
+	// #nosec G115 - algorithm index and key ID length bounded above
+	sealed = append(sealed, byte(algorithm), byte(len(encrypted.KeyID)))
+	sealed = append(sealed, encrypted.KeyID...)
+	if len(encrypted.WrappedKey) > 0 {
+		// #nosec G115 - wrapped key length bounded above
+		sealed = binary.LittleEndian.AppendUint16(sealed, uint16(len(encrypted.WrappedKey)))
+		sealed = append(sealed, encrypted.WrappedKey...)
+	}
+	sealed = append(sealed, encrypted.Ciphertext...)
+	return sealed, nil
+}
+
+// openPayload parses the encryption header of a sealed payload.
+func openPayload(data []byte) (*compliance.EncryptedRecord, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("encrypted payload too short")
+	}
+
+	algorithm := int(data[0] &^ wrappedKeyFlag)
+	if algorithm == 0 || algorithm >= len(encryptionAlgorithms) {
+		return nil, fmt.Errorf("unknown encryption algorithm: %d", algorithm)
+	}
+
+	keyIDEnd := 2 + int(data[1])
+	if len(data) < keyIDEnd {
+		return nil, fmt.Errorf("encrypted payload truncated in key ID")
+	}
+
+	encrypted := &compliance.EncryptedRecord{
+		Algorithm: encryptionAlgorithms[algorithm],
+		KeyID:     string(data[2:keyIDEnd]),
+	}
+
+	end := keyIDEnd
+	if data[0]&wrappedKeyFlag != 0 {
+		if len(data) < end+2 {
+			return nil, fmt.Errorf("encrypted payload truncated in wrapped key")
+		}
+		wrappedEnd := end + 2 + int(binary.LittleEndian.Uint16(data[end:]))
+		if len(data) < wrappedEnd {
+			return nil, fmt.Errorf("encrypted payload truncated in wrapped key")
+		}
+		encrypted.WrappedKey = data[end+2 : wrappedEnd]
+		end = wrappedEnd
+	}
+
+	encrypted.Ciphertext = data[end:]
+	return encrypted, nil
+}
+
+// WithEncryption encrypts every record payload with keys. Records written
+// without it remain readable, so encryption can be enabled on an existing WAL.
+func WithEncryption(keys Keyring) Option {
+	return func(c *config) error {
+		if keys == nil {
+			return fmt.Errorf("keyring cannot be nil")
+		}
+		c.keys = keys
+		return nil
+	}
+}
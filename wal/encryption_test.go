@@ -0,0 +1,288 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog/core"
+)
+
+func newTestKeyring(t *testing.T) *compliance.KeyManager {
+	t.Helper()
+	keys, err := compliance.NewKeyManager([]byte("test-key-32-bytes-long-exactly!!"), "AES-256-GCM")
+	if err != nil {
+		t.Fatalf("Failed to create key manager: %v", err)
+	}
+	return keys
+}
+
+// writeEncryptedWAL writes count events to an encrypted WAL and returns its path.
+func writeEncryptedWAL(t *testing.T, keys Keyring, count int) string {
+	t.Helper()
+	walPath := filepath.Join(t.TempDir(), "encrypted.wal")
+
+	w, err := New(walPath, WithEncryption(keys))
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	for i := 0; i < count; i++ {
+		event := &core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Patient {PatientId} record viewed",
+			Properties:      map[string]interface{}{"PatientId": i},
+		}
+		if err := w.Write(event); err != nil {
+			t.Fatalf("Failed to write event: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close WAL: %v", err)
+	}
+	return walPath
+}
+
+func TestWALEncryption(t *testing.T) {
+	keys := newTestKeyring(t)
+	walPath := writeEncryptedWAL(t, keys, 5)
+
+	data, err := os.ReadFile(walPath) // #nosec G304 - test file path
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("record viewed")) {
+		t.Fatal("Plaintext event data found in encrypted WAL")
+	}
+
+	// The CRC and hash chain validate without the key
+	w, err := New(walPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen WAL without key: %v", err)
+	}
+	if err := w.VerifyIntegrity(); err != nil {
+		t.Errorf("Integrity check failed without key: %v", err)
+	}
+	_ = w.Close()
+
+	// Reading without the key fails clearly
+	reader, err := NewReader(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.ReadAll(); !errors.Is(err, ErrRecordEncrypted) {
+		t.Errorf("Expected ErrRecordEncrypted, got %v", err)
+	}
+	_ = reader.Close()
+
+	// Reading with the key returns the events
+	reader, err = NewReader(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader.Close() }()
+	reader.SetKeyring(keys)
+
+	events, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read encrypted WAL: %v", err)
+	}
+	if len(events) != 5 {
+		t.Fatalf("Expected 5 events, got %d", len(events))
+	}
+	if events[0].MessageTemplate != "Patient {PatientId} record viewed" {
+		t.Errorf("Unexpected message template: %s", events[0].MessageTemplate)
+	}
+}
+
+func TestRecordEncryptionHeader(t *testing.T) {
+	keys := newTestKeyring(t)
+	event := &core.LogEvent{
+		Timestamp:       time.Now(),
+		Level:           core.WarningLevel,
+		MessageTemplate: "Card {Pan} charged",
+	}
+
+	record, err := NewRecord(event, 1, [32]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := record.Encrypt(keys); err != nil {
+		t.Fatalf("Failed to encrypt record: %v", err)
+	}
+
+	data, err := record.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalRecord(data)
+	if err != nil {
+		t.Fatalf("Encrypted record failed CRC validation: %v", err)
+	}
+
+	if !decoded.IsEncrypted() {
+		t.Error("Encrypted flag not set")
+	}
+	encrypted, err := keys.Encrypt([]byte("probe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.KeyID() != encrypted.KeyID {
+		t.Errorf("KeyID = %q, want %q", decoded.KeyID(), encrypted.KeyID)
+	}
+
+	if _, err := decoded.GetEvent(); !errors.Is(err, ErrRecordEncrypted) {
+		t.Errorf("GetEvent: expected ErrRecordEncrypted, got %v", err)
+	}
+	got, err := decoded.DecodeEvent(keys)
+	if err != nil {
+		t.Fatalf("DecodeEvent failed: %v", err)
+	}
+	if got.MessageTemplate != event.MessageTemplate || got.Level != event.Level {
+		t.Errorf("Decoded event mismatch: %+v", got)
+	}
+
+	// A different key cannot decrypt it
+	other, err := compliance.NewKeyManager([]byte("another-key-32-bytes-long-xxxxx!"), "AES-256-GCM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decoded.DecodeEvent(other); err == nil {
+		t.Error("Expected decryption with the wrong key to fail")
+	}
+}
+
+func TestRecoveryEngine_EncryptedRecords(t *testing.T) {
+	keys := newTestKeyring(t)
+	walPath := writeEncryptedWAL(t, keys, 3)
+
+	// With the key, recovered records are plaintext events
+	report, records, err := NewRecoveryEngine(walPath, WithRecoveryKeyring(keys)).Recover()
+	if err != nil {
+		t.Fatalf("Recovery failed: %v", err)
+	}
+	if report.DecryptedRecords != 3 || len(records) != 3 {
+		t.Fatalf("Expected 3 decrypted records, got %d of %d", report.DecryptedRecords, len(records))
+	}
+	var event core.LogEvent
+	if err := json.Unmarshal(records[0], &event); err != nil {
+		t.Errorf("Recovered record is not plaintext JSON: %v", err)
+	}
+
+	// Repair keeps records encrypted, with or without the key
+	for name, engine := range map[string]*RecoveryEngine{
+		"without key": NewRecoveryEngine(walPath),
+		"with key":    NewRecoveryEngine(walPath, WithRecoveryKeyring(keys)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			repaired := filepath.Join(t.TempDir(), "repaired.wal")
+			if err := engine.RepairWAL(repaired); err != nil {
+				t.Fatalf("Repair failed: %v", err)
+			}
+
+			reader, err := NewReader(repaired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = reader.Close() }()
+
+			if _, err := reader.ReadNext(); !errors.Is(err, ErrRecordEncrypted) {
+				t.Fatalf("Repaired record should still be encrypted, got %v", err)
+			}
+			if _, err := reader.Seek(0, 0); err != nil {
+				t.Fatal(err)
+			}
+			reader.SetKeyring(keys)
+			events, err := reader.ReadAll()
+			if err != nil || len(events) != 3 {
+				t.Errorf("Expected 3 decrypted events from repaired WAL, got %d (%v)", len(events), err)
+			}
+		})
+	}
+}
+
+func TestWALEncryptionCarriesWrappedKey(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := compliance.NewLocalKMS(filepath.Join(dir, "master.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := compliance.OpenKeyManager(filepath.Join(dir, "keyring.json"), compliance.WithKeyProvider(provider))
+	if err != nil {
+		t.Fatalf("Failed to open keyring: %v", err)
+	}
+	walPath := writeEncryptedWAL(t, keys, 3)
+
+	// A keyring that never held the data key unwraps it from the records
+	other, err := compliance.OpenKeyManager(filepath.Join(dir, "other.json"), compliance.WithKeyProvider(provider))
+	if err != nil {
+		t.Fatalf("Failed to open second keyring: %v", err)
+	}
+
+	reader, err := NewReader(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	reader.SetKeyring(other)
+	events, err := reader.ReadAll()
+	if err != nil || len(events) != 3 {
+		t.Fatalf("Expected 3 events decrypted with the wrapped key, got %d (%v)", len(events), err)
+	}
+	if events[0].MessageTemplate != "Patient {PatientId} record viewed" {
+		t.Errorf("Decoded event mismatch: %+v", events[0])
+	}
+}
+
+// failingKeyring fails the next encryption once failNext is set.
+type failingKeyring struct {
+	*compliance.KeyManager
+	failNext bool
+}
+
+func (k *failingKeyring) Encrypt(plaintext []byte) (*compliance.EncryptedRecord, error) {
+	if k.failNext {
+		k.failNext = false
+		return nil, errors.New("key service unavailable")
+	}
+	return k.KeyManager.Encrypt(plaintext)
+}
+
+func TestWALEncryptionFailureLeavesNoGap(t *testing.T) {
+	keys := &failingKeyring{KeyManager: newTestKeyring(t)}
+	w, err := New(filepath.Join(t.TempDir(), "encrypted.wal"), WithEncryption(keys))
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	event := &core.LogEvent{Timestamp: time.Now(), MessageTemplate: "Record viewed"}
+	if _, err := w.Append(event); err != nil {
+		t.Fatal(err)
+	}
+	keys.failNext = true
+	if _, err := w.Append(event); err == nil {
+		t.Fatal("Expected the append to fail")
+	}
+	seq, err := w.Append(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 2 {
+		t.Errorf("Expected the failed append to leave sequence 2 unused, got %d", seq)
+	}
+
+	report, err := w.VerifyIntegrityReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid {
+		t.Errorf("Expected an intact chain, got %+v", report)
+	}
+}
@@ -0,0 +1,167 @@
+package wal
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/zeebo/blake3"
+)
+
+// HashAlgorithm identifies the hash that chains records together.
+type HashAlgorithm int
+
+const (
+	// HashSHA256 is SHA-256, the chain hash of records written before the
+	// algorithm was configurable
+	HashSHA256 HashAlgorithm = iota
+	// HashSHA512t256 is SHA-512/256, faster than SHA-256 on 64-bit CPUs
+	// without SHA extensions
+	HashSHA512t256
+	// HashBLAKE3 is BLAKE3 with a 256-bit output
+	HashBLAKE3
+)
+
+// String returns the hash algorithm name.
+func (h HashAlgorithm) String() string {
+	switch h {
+	case HashSHA256:
+		return "SHA-256"
+	case HashSHA512t256:
+		return "SHA-512/256"
+	case HashBLAKE3:
+		return "BLAKE3"
+	default:
+		return fmt.Sprintf("HashAlgorithm(%d)", int(h))
+	}
+}
+
+// Sum returns the 32-byte digest of data.
+func (h HashAlgorithm) Sum(data []byte) [32]byte {
+	switch h {
+	case HashSHA512t256:
+		return sha512.Sum512_256(data)
+	case HashBLAKE3:
+		return blake3.Sum256(data)
+	default:
+		return sha256.Sum256(data)
+	}
+}
+
+// Format names the checksum and chain hash records are written with. Every
+// record carries its format in its flags, and the WAL writes each segment in
+// a single format: opening a WAL with a different format starts a new
+// segment. The zero Format is CRC-32 (IEEE) and SHA-256, so records written
+// before the format was configurable read as they always have.
+//
+// Record checksum fields hold 32 bits; wider checksums are truncated.
+type Format struct {
+	Checksum ChecksumType
+	Hash     HashAlgorithm
+}
+
+// Bits 8-11 of the record flags hold the checksum type and bits 12-15 the
+// hash algorithm.
+const (
+	formatChecksumShift = 8
+	formatHashShift     = 12
+	formatFieldMask     = 0xF
+	formatFlagMask      = formatFieldMask<<formatChecksumShift | formatFieldMask<<formatHashShift
+)
+
+// formatOf returns the format declared by record flags.
+func formatOf(flags uint16) Format {
+	return Format{
+		Checksum: ChecksumType(flags >> formatChecksumShift & formatFieldMask),
+		Hash:     HashAlgorithm(flags >> formatHashShift & formatFieldMask),
+	}
+}
+
+// flags returns the record flag bits declaring f.
+func (f Format) flags() uint16 {
+	// #nosec G115 - validated algorithms fit in four bits
+	return uint16(f.Checksum)<<formatChecksumShift | uint16(f.Hash)<<formatHashShift
+}
+
+// validate reports whether f names algorithms this package implements.
+func (f Format) validate() error {
+	switch f.Checksum {
+	case ChecksumCRC32, ChecksumCRC32C, ChecksumCRC64, ChecksumXXHash3:
+	default:
+		return fmt.Errorf("unsupported checksum type %d", int(f.Checksum))
+	}
+	switch f.Hash {
+	case HashSHA256, HashSHA512t256, HashBLAKE3:
+	default:
+		return fmt.Errorf("unsupported hash algorithm %d", int(f.Hash))
+	}
+	return nil
+}
+
+// checksum returns the record checksum of data.
+func (f Format) checksum(data []byte) uint32 {
+	if f.Checksum == ChecksumCRC32 {
+		return crc32.ChecksumIEEE(data)
+	}
+	// #nosec G115 - wider checksums are truncated to the record field
+	return uint32(NewChecksum(f.Checksum).Calculate(data))
+}
+
+// String describes f as its checksum and hash names.
+func (f Format) String() string {
+	return NewChecksum(f.Checksum).Name() + "/" + f.Hash.String()
+}
+
+// Format returns the checksum and chain hash the record is written with.
+func (r *Record) Format() Format {
+	return formatOf(r.Flags)
+}
+
+// WithChecksum sets the checksum records are written with. CRC32C is
+// hardware accelerated on most CPUs. Without it, a WAL keeps the checksum of
+// its active segment, and a new WAL uses CRC32 (IEEE).
+func WithChecksum(typ ChecksumType) Option {
+	return func(c *config) error {
+		if err := (Format{Checksum: typ}).validate(); err != nil {
+			return err
+		}
+		c.checksum = &typ
+		return nil
+	}
+}
+
+// WithHashAlgorithm sets the hash that chains records together. Without it,
+// a WAL keeps the hash of its active segment, and a new WAL uses SHA-256.
+func WithHashAlgorithm(h HashAlgorithm) Option {
+	return func(c *config) error {
+		if err := (Format{Hash: h}).validate(); err != nil {
+			return err
+		}
+		c.hash = &h
+		return nil
+	}
+}
+
+// segmentFormat returns the format and codec to write with: those of the
+// active segment unless the options choose others.
+func (c *config) segmentFormat(active *Segment) (Format, Codec) {
+	var format Format
+	codec := CodecJSON
+	if active != nil && active.Size > 0 {
+		format = active.Format
+		if declared, err := codecOf(active.Version); err == nil {
+			codec = declared
+		}
+	}
+	if c.checksum != nil {
+		format.Checksum = *c.checksum
+	}
+	if c.hash != nil {
+		format.Hash = *c.hash
+	}
+	if c.codec != nil {
+		codec = *c.codec
+	}
+	return format, codec
+}
@@ -0,0 +1,157 @@
+package wal
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWALFormats(t *testing.T) {
+	checksums := []ChecksumType{ChecksumCRC32, ChecksumCRC32C, ChecksumCRC64, ChecksumXXHash3}
+	hashes := []HashAlgorithm{HashSHA256, HashSHA512t256, HashBLAKE3}
+
+	for _, checksum := range checksums {
+		for _, hash := range hashes {
+			format := Format{Checksum: checksum, Hash: hash}
+			t.Run(format.String(), func(t *testing.T) {
+				dir := t.TempDir()
+				walPath := filepath.Join(dir, "formatted.wal")
+
+				w, err := New(walPath, WithChecksum(checksum), WithHashAlgorithm(hash))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < 5; i++ {
+					if err := w.Write(signingEvent(i)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+
+				// Reopening recovers the chain from the declared format
+				w, err = New(walPath, WithChecksum(checksum), WithHashAlgorithm(hash))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := w.VerifyIntegrity(); err != nil {
+					t.Errorf("Integrity check failed: %v", err)
+				}
+				if w.LastSequence() != 5 {
+					t.Errorf("Expected sequence 5, got %d", w.LastSequence())
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+
+				reader, err := NewReader(walPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				events, err := reader.ReadAll()
+				_ = reader.Close()
+				if err != nil || len(events) != 5 {
+					t.Errorf("Expected 5 events, got %d (%v)", len(events), err)
+				}
+
+				// Recovery verifies and keeps the declared format
+				repairedPath := filepath.Join(dir, "repaired.wal")
+				if err := NewRecoveryEngine(walPath).RepairWAL(repairedPath); err != nil {
+					t.Fatalf("Repair failed: %v", err)
+				}
+				report, _, err := NewRecoveryEngine(repairedPath).Recover()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if report.RecoveredRecords != 5 || report.CorruptedRecords != 0 {
+					t.Errorf("Expected 5 clean records after repair, got %+v", report)
+				}
+				segments, err := NewSegmentManager(repairedPath, 1024*1024)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := segments.GetSegments()[0].Format; got != format {
+					t.Errorf("Expected repaired segment in %s, got %s", format, got)
+				}
+			})
+		}
+	}
+}
+
+func TestWALFormatChange(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "mixed.wal")
+
+	formats := []Format{
+		{},
+		{Checksum: ChecksumCRC32C, Hash: HashSHA512t256},
+		{Checksum: ChecksumXXHash3, Hash: HashBLAKE3},
+	}
+	for i, format := range formats {
+		w, err := New(walPath, WithChecksum(format.Checksum), WithHashAlgorithm(format.Hash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 3; j++ {
+			if err := w.Write(signingEvent(i*3 + j)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each format lives in its own segment and the chain runs across them
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(segments.GetSegments()); got != len(formats) {
+		t.Fatalf("Expected %d segments, got %d", len(formats), got)
+	}
+	for i, segment := range segments.GetSegments() {
+		if segment.Format != formats[i] {
+			t.Errorf("Segment %d: expected %s, got %s", i, formats[i], segment.Format)
+		}
+	}
+
+	records, err := segments.ReadAllSegments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 9 {
+		t.Fatalf("Expected 9 records, got %d", len(records))
+	}
+	var prevHash [32]byte
+	for i, data := range records {
+		record, err := UnmarshalRecord(data)
+		if err != nil {
+			t.Fatalf("Record %d: %v", i, err)
+		}
+		if record.PrevHash != prevHash {
+			t.Errorf("Record %d does not chain from its predecessor", record.Sequence)
+		}
+		prevHash = record.ComputeHash()
+	}
+}
+
+func TestWALFormatRejectsUnknownAlgorithms(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "unknown.wal")
+	if _, err := New(walPath, WithHashAlgorithm(HashAlgorithm(9))); err == nil {
+		t.Error("Expected an error for an unknown hash algorithm")
+	}
+
+	// A record declaring an unknown checksum cannot be verified
+	record, err := NewRecord(signingEvent(0), 1, [32]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := record.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[7] |= 0x0F
+	if _, err := UnmarshalRecord(data); err == nil {
+		t.Errorf("Expected an error for flags %x", data[6:8])
+	}
+}
@@ -0,0 +1,70 @@
+package wal
+
+import "fmt"
+
+// SyncedHead syncs the WAL and returns the sequence and chain hash of the
+// last record written, which is then on stable storage.
+func (w *WAL) SyncedHead() (uint64, [32]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil && w.syncedSeq < w.sequence {
+		if err := w.syncLocked(); err != nil {
+			return 0, [32]byte{}, fmt.Errorf("sync failed: %w", err)
+		}
+	}
+	return w.sequence, w.lastHash, nil
+}
+
+// RecordHash returns the chain hash of the record with the given sequence.
+func (w *WAL) RecordHash(sequence uint64) ([32]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, segment := range w.segments.GetSegments() {
+		if segment.StartSeq > sequence || !fileExists(segment.Path) {
+			continue
+		}
+		records, err := w.segments.readSegment(segment.Path)
+		if err != nil {
+			return [32]byte{}, fmt.Errorf("failed to read segment %s: %w", segment.Path, err)
+		}
+		for _, data := range records {
+			record, err := UnmarshalRecord(data)
+			if err != nil {
+				return [32]byte{}, fmt.Errorf("failed to read record in %s: %w", segment.Path, err)
+			}
+			if record.Sequence == sequence {
+				return record.ComputeHash(), nil
+			}
+		}
+	}
+	return [32]byte{}, fmt.Errorf("record %d not found", sequence)
+}
+
+// ID identifies the WAL by the hash of its first record, which is the
+// genesis record of a WAL created with metadata. It is empty until the WAL
+// has a record.
+func (w *WAL) ID() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, segment := range w.segments.GetSegments() {
+		if !fileExists(segment.Path) {
+			continue
+		}
+		records, err := w.segments.readSegment(segment.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read segment %s: %w", segment.Path, err)
+		}
+		if len(records) == 0 {
+			continue
+		}
+		record, err := UnmarshalRecord(records[0])
+		if err != nil {
+			return "", fmt.Errorf("failed to read record in %s: %w", segment.Path, err)
+		}
+		return hexHash(record.ComputeHash()), nil
+	}
+	return "", nil
+}
@@ -83,17 +83,12 @@ func (idx *Index) indexSegment(seg *Segment) (SegmentInfo, error) {
 		Sealed: seg.Sealed,
 	}
 
-	file, err := os.Open(seg.Path)
+	file, err := openSegment(seg.Path, false)
 	if err != nil {
 		return info, err
 	}
 	defer func() { _ = file.Close() }()
-
-	stat, err := file.Stat()
-	if err != nil {
-		return info, err
-	}
-	info.Size = stat.Size()
+	info.Size = file.Size()
 
 	offset := int64(0)
 	recordCount := 0
@@ -125,7 +120,7 @@ func (idx *Index) indexSegment(seg *Segment) (SegmentInfo, error) {
 		crc32Header := binary.LittleEndian.Uint32(headerBuf[20:24])
 
 		// Validate version
-		if version != Version {
+		if !knownVersion(version) {
 			info.Corrupted = true
 			break
 		}
@@ -474,7 +469,7 @@ func (idx *Index) Load() error {
 	idx.entries = make(map[uint64]IndexEntry)
 	for _, seg := range idx.segments {
 		// Re-index the segment to populate entries
-		file, err := os.Open(seg.Path)
+		file, err := openSegment(seg.Path, false)
 		if err != nil {
 			// Skip segments that can't be opened
 			continue
@@ -489,7 +484,7 @@ func (idx *Index) Load() error {
 }
 
 // scanSegmentForEntries scans a segment file and adds entries to the index
-func (idx *Index) scanSegmentForEntries(file *os.File, path string) error {
+func (idx *Index) scanSegmentForEntries(file segmentFile, path string) error {
 	offset := int64(0)
 
 	for {
@@ -510,7 +505,7 @@ func (idx *Index) scanSegmentForEntries(file *os.File, path string) error {
 		}
 
 		version := binary.LittleEndian.Uint16(headerBuf[4:6])
-		if version != Version {
+		if !knownVersion(version) {
 			break // Skip incompatible versions
 		}
 
@@ -0,0 +1,472 @@
+package wal
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+)
+
+// InclusionProof is a self-contained proof that one record belongs to the
+// log. It carries the record itself, so a verifier needs nothing else to
+// recompute the leaf and check it against the root hashes.
+type InclusionProof struct {
+	Event     json.RawMessage   `json:"event,omitempty"`
+	Segment   *SegmentInclusion `json:"segment,omitempty"`
+	Algorithm string            `json:"algorithm"`
+	// RecordHash is the record's chain hash, the data of its Merkle leaf.
+	RecordHash string   `json:"record_hash"`
+	RootHash   string   `json:"root_hash"`
+	AuditPath  []string `json:"audit_path"`
+	// Record is the record exactly as stored in the WAL.
+	Record    []byte `json:"record"`
+	Sequence  uint64 `json:"sequence"`
+	LeafIndex uint64 `json:"leaf_index"`
+	TreeSize  uint64 `json:"tree_size"`
+}
+
+// SegmentInclusion proves the record is in its segment's own Merkle tree.
+type SegmentInclusion struct {
+	Name      string   `json:"name"`
+	RootHash  string   `json:"root_hash"`
+	AuditPath []string `json:"audit_path"`
+	LeafIndex uint64   `json:"leaf_index"`
+	TreeSize  uint64   `json:"tree_size"`
+}
+
+// recordLeaf returns the Merkle leaf hash of a record's chain hash.
+func recordLeaf(hash [32]byte) [32]byte {
+	return compliance.MerkleLeafHash(hash[:])
+}
+
+// appendLeaf adds a written record to the global and segment trees and to
+// the active segment's time range. The caller must hold w.mu.
+func (w *WAL) appendLeaf(hash [32]byte, timestamp int64) {
+	leaf := recordLeaf(hash)
+	w.merkle.Append(leaf)
+	w.segmentMerkle.Append(leaf)
+	w.segmentTimes.add(timestamp)
+}
+
+// merkleState is the global Merkle frontier as of the end of a sealed
+// segment. It is saved whenever a segment is sealed so opening the WAL only
+// reads the segments written since.
+type merkleState struct {
+	LastHash string   `json:"last_hash"`
+	Nodes    []string `json:"nodes"`
+	Sequence uint64   `json:"sequence"`
+	Size     uint64   `json:"size"`
+}
+
+// merkleStatePath returns the path of the saved Merkle frontier.
+func merkleStatePath(walPath string) string {
+	return walPath + ".merkle"
+}
+
+// saveMerkleState atomically replaces the saved Merkle frontier.
+func saveMerkleState(walPath string, state *merkleState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Merkle frontier: %w", err)
+	}
+	tempPath := merkleStatePath(walPath) + ".tmp"
+	_ = os.Remove(tempPath)
+	if err := writeSyncedFile(tempPath, data); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write Merkle frontier: %w", err)
+	}
+	if err := os.Rename(tempPath, merkleStatePath(walPath)); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace Merkle frontier: %w", err)
+	}
+	return nil
+}
+
+// readMerkleState reads the saved Merkle frontier.
+func readMerkleState(walPath string) (*merkleState, error) {
+	data, err := os.ReadFile(merkleStatePath(walPath)) // #nosec G304 - derived from the WAL path
+	if err != nil {
+		return nil, err
+	}
+	var state merkleState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid Merkle frontier: %w", err)
+	}
+	return &state, nil
+}
+
+// sealedMerkleState returns the state of the global tree through the
+// record with sequence seq and hash. The caller must hold w.mu.
+func (w *WAL) sealedMerkleState(seq uint64, hash [32]byte) *merkleState {
+	return &merkleState{
+		Sequence: seq,
+		LastHash: hexHash(hash),
+		Size:     w.merkle.Size(),
+		Nodes:    hexHashes(w.merkle.Nodes()),
+	}
+}
+
+// saveMerkle saves state. A failure only costs a longer scan on the next
+// open, so it is logged.
+func (w *WAL) saveMerkle(state *merkleState) {
+	if err := saveMerkleState(w.path, state); err != nil {
+		logger.Log.Warn("Failed to save the Merkle frontier of {path}: {error}", w.path, err)
+	}
+}
+
+// restoreMerkle loads the saved frontier into the global tree and returns
+// the last sequence it covers, or zero if there is no usable frontier. A
+// frontier beyond every segment on disk belongs to another WAL and is
+// ignored.
+func (w *WAL) restoreMerkle() uint64 {
+	state, err := readMerkleState(w.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Log.Warn("Ignoring the saved Merkle frontier of {path}: {error}", w.path, err)
+		}
+		return 0
+	}
+
+	var highest uint64
+	for _, segment := range w.segments.GetSegments() {
+		if fileExists(segment.Path) && segment.EndSeq > highest {
+			highest = segment.EndSeq
+		}
+	}
+	if state.Sequence == 0 || state.Sequence > highest {
+		return 0
+	}
+
+	nodes := make([][32]byte, len(state.Nodes))
+	for i, node := range state.Nodes {
+		if nodes[i], err = parseHash(node); err != nil {
+			return 0
+		}
+	}
+	lastHash, err := parseHash(state.LastHash)
+	if err != nil {
+		return 0
+	}
+	if err := w.merkle.Restore(state.Size, nodes); err != nil {
+		logger.Log.Warn("Ignoring the saved Merkle frontier of {path}: {error}", w.path, err)
+		return 0
+	}
+	if state.Sequence > w.sequence {
+		w.sequence = state.Sequence
+		w.syncedSeq = state.Sequence
+		w.lastHash = lastHash
+	}
+	return state.Sequence
+}
+
+// loadMerkle rebuilds the global and active segment trees, starting from the
+// saved frontier and reading only the segments written after it. It also
+// resumes the sequence from the last sealed segment when the active segment
+// is still empty. A damaged record's chain hash is the next record's
+// PrevHash, so its leaf is kept and the tree stays the one checkpoints were
+// signed over. When that can't be recovered, because the next record is
+// damaged too or there is none, the WAL fails to open rather than build a
+// different tree or chain new records to the wrong hash.
+func (w *WAL) loadMerkle() error {
+	w.merkle.Reset()
+	w.segmentMerkle.Reset()
+	w.segmentTimes = timeRange{}
+	w.damagedRecords = 0
+	activePath := w.segments.GetActivePath()
+	covered := w.restoreMerkle()
+
+	var (
+		lastSeq  uint64
+		lastHash [32]byte
+		sealed   *merkleState
+		// damaged is the segment of a damaged record awaiting the next
+		// record's PrevHash
+		damaged string
+	)
+	leaf := func(seq uint64, hash [32]byte, timestamp int64, path string) {
+		if seq == 0 {
+			// The genesis record is chained but is not a leaf
+			if w.sequence == 0 {
+				w.lastHash = hash
+			}
+			return
+		}
+		if seq <= covered {
+			return
+		}
+		w.merkle.Append(recordLeaf(hash))
+		if path == activePath {
+			w.segmentMerkle.Append(recordLeaf(hash))
+			if timestamp != 0 {
+				w.segmentTimes.add(timestamp)
+			}
+		}
+		if seq > lastSeq {
+			lastSeq, lastHash = seq, hash
+		}
+	}
+
+	for _, segment := range w.segments.GetSegments() {
+		if !fileExists(segment.Path) {
+			continue
+		}
+		if segment.Path != activePath && segment.EndSeq != 0 && segment.EndSeq <= covered {
+			continue
+		}
+		records, err := w.segments.readSegment(segment.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read segment %s: %w", segment.Path, err)
+		}
+		for _, data := range records {
+			record, err := UnmarshalRecord(data)
+			if err != nil {
+				if damaged != "" {
+					return fmt.Errorf("%w: consecutive damaged records in %s; repair them before opening the WAL", ErrRecordCorrupted, segment.Path)
+				}
+				w.damagedRecords++
+				damaged = segment.Path
+				logger.Log.Warn("Damaged record in {segment}: {error}", segment.Path, err)
+				continue
+			}
+			if damaged != "" {
+				if record.Sequence == 0 {
+					return fmt.Errorf("%w: damaged record before the genesis record in %s", ErrRecordCorrupted, damaged)
+				}
+				leaf(record.Sequence-1, record.PrevHash, 0, damaged)
+				damaged = ""
+			}
+			hash := record.ComputeHash()
+			if record.IsGenesis() {
+				leaf(0, hash, 0, segment.Path)
+				continue
+			}
+			leaf(record.Sequence, hash, record.Timestamp, segment.Path)
+		}
+		if segment.Path != activePath && damaged == "" && lastSeq > covered {
+			sealed = w.sealedMerkleState(lastSeq, lastHash)
+		}
+	}
+	if damaged != "" {
+		return fmt.Errorf("%w: the last record in %s is damaged; repair it before opening the WAL", ErrRecordCorrupted, damaged)
+	}
+
+	if lastSeq > w.sequence {
+		w.sequence = lastSeq
+		w.syncedSeq = lastSeq
+		w.lastHash = lastHash
+	}
+	if w.damagedRecords > 0 {
+		logger.Log.Warn("{count} damaged records in {path} are kept in the Merkle tree from their successors until scrubbed or repaired",
+			w.damagedRecords, w.path)
+	}
+	if sealed != nil {
+		w.saveMerkle(sealed)
+	}
+	return nil
+}
+
+// MerkleRoot returns the root hash and size of the Merkle tree over every
+// record in the log.
+func (w *WAL) MerkleRoot() ([32]byte, uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.merkle.Root(), w.merkle.Size()
+}
+
+// SyncedMerkleRoot syncs the WAL and returns the root hash and size of the
+// Merkle tree over every record, all of which are then on stable storage.
+// This is the state a checkpoint may commit to.
+func (w *WAL) SyncedMerkleRoot() ([32]byte, uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil && w.syncedSeq < w.sequence {
+		if err := w.syncLocked(); err != nil {
+			return [32]byte{}, 0, fmt.Errorf("sync failed: %w", err)
+		}
+	}
+	return w.merkle.Root(), w.merkle.Size(), nil
+}
+
+// SegmentMerkleRoot returns the root hash and size of the Merkle tree over
+// the active segment's records.
+func (w *WAL) SegmentMerkleRoot() ([32]byte, uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.segmentMerkle.Root(), w.segmentMerkle.Size()
+}
+
+// ProveInclusion builds an inclusion proof for the record with the given
+// sequence number against the log's current state.
+func (w *WAL) ProveInclusion(sequence uint64) (*InclusionProof, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return proveInclusion(w.segments, sequence)
+}
+
+// ProveInclusion builds an inclusion proof for the record with the given
+// sequence number in the WAL at walPath.
+func ProveInclusion(walPath string, sequence uint64) (*InclusionProof, error) {
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	return proveInclusion(segments, sequence)
+}
+
+// proveInclusion reads every segment into Merkle trees and proves the record
+// with the given sequence.
+func proveInclusion(segments *SegmentManager, sequence uint64) (*InclusionProof, error) {
+	global := compliance.NewMerkleTree()
+	var (
+		proof        *InclusionProof
+		segmentTree  *compliance.MerkleTree
+		segmentIndex uint64
+		segmentName  string
+	)
+
+	for _, segment := range segments.GetSegments() {
+		if !fileExists(segment.Path) {
+			continue
+		}
+		records, err := segments.readSegment(segment.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read segment %s: %w", segment.Path, err)
+		}
+
+		tree := compliance.NewMerkleTree()
+		for _, data := range records {
+			record, err := UnmarshalRecord(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read record in %s: %w", segment.Path, err)
+			}
+			if record.IsGenesis() {
+				continue
+			}
+			hash := record.ComputeHash()
+			index := global.Append(recordLeaf(hash))
+			localIndex := tree.Append(recordLeaf(hash))
+
+			if record.Sequence == sequence && proof == nil {
+				proof = &InclusionProof{
+					Algorithm:  compliance.MerkleAlgorithm,
+					Sequence:   sequence,
+					LeafIndex:  index,
+					Record:     data,
+					RecordHash: hex.EncodeToString(hash[:]),
+				}
+				if !record.IsEncrypted() {
+					if payload, err := openEventPayload(record.EventData, record.Flags, nil); err == nil && json.Valid(payload) {
+						proof.Event = payload
+					}
+				}
+				segmentTree = tree
+				segmentIndex = localIndex
+				segmentName = filepath.Base(segment.Path)
+			}
+		}
+	}
+
+	if proof == nil {
+		return nil, fmt.Errorf("record %d not found", sequence)
+	}
+
+	path, err := global.InclusionProof(proof.LeafIndex, global.Size())
+	if err != nil {
+		return nil, err
+	}
+	proof.TreeSize = global.Size()
+	proof.RootHash = hexHash(global.Root())
+	proof.AuditPath = hexHashes(path)
+
+	segmentPath, err := segmentTree.InclusionProof(segmentIndex, segmentTree.Size())
+	if err != nil {
+		return nil, err
+	}
+	proof.Segment = &SegmentInclusion{
+		Name:      segmentName,
+		LeafIndex: segmentIndex,
+		TreeSize:  segmentTree.Size(),
+		RootHash:  hexHash(segmentTree.Root()),
+		AuditPath: hexHashes(segmentPath),
+	}
+
+	return proof, nil
+}
+
+// Verify recomputes the record's hash from the embedded record and checks
+// it against the global and segment roots. It establishes that the record
+// belongs to the log with RootHash; whether that root is the authentic one is
+// for the caller to establish.
+func (p *InclusionProof) Verify() error {
+	record, err := UnmarshalRecord(p.Record)
+	if err != nil {
+		return fmt.Errorf("invalid record: %w", err)
+	}
+	if record.Sequence != p.Sequence {
+		return fmt.Errorf("record sequence %d does not match proof sequence %d", record.Sequence, p.Sequence)
+	}
+	if p.Algorithm != compliance.MerkleAlgorithm {
+		return fmt.Errorf("unsupported proof algorithm: %s", p.Algorithm)
+	}
+
+	hash := record.ComputeHash()
+	if hexHash(hash) != p.RecordHash {
+		return fmt.Errorf("record hash does not match the embedded record")
+	}
+	leaf := recordLeaf(hash)
+
+	if err := verifyPath(leaf, p.LeafIndex, p.TreeSize, p.AuditPath, p.RootHash); err != nil {
+		return fmt.Errorf("global tree: %w", err)
+	}
+	if p.Segment != nil {
+		s := p.Segment
+		if err := verifyPath(leaf, s.LeafIndex, s.TreeSize, s.AuditPath, s.RootHash); err != nil {
+			return fmt.Errorf("segment tree: %w", err)
+		}
+	}
+	return nil
+}
+
+// verifyPath decodes a hex audit path and root and verifies inclusion.
+func verifyPath(leaf [32]byte, index, size uint64, auditPath []string, rootHex string) error {
+	root, err := parseHash(rootHex)
+	if err != nil {
+		return err
+	}
+	path := make([][32]byte, len(auditPath))
+	for i, h := range auditPath {
+		if path[i], err = parseHash(h); err != nil {
+			return err
+		}
+	}
+	return compliance.VerifyInclusion(leaf, index, size, path, root)
+}
+
+func hexHash(hash [32]byte) string {
+	return hex.EncodeToString(hash[:])
+}
+
+func hexHashes(hashes [][32]byte) []string {
+	out := make([]string, len(hashes))
+	for i, h := range hashes {
+		out[i] = hexHash(h)
+	}
+	return out
+}
+
+// parseHash decodes a hex-encoded SHA-256 hash.
+func parseHash(s string) ([32]byte, error) {
+	var hash [32]byte
+	decoded, err := hex.DecodeString(s)
+	if err != nil || len(decoded) != len(hash) {
+		return hash, fmt.Errorf("invalid hash %q", s)
+	}
+	copy(hash[:], decoded)
+	return hash, nil
+}
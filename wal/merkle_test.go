@@ -0,0 +1,213 @@
+package wal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWALInclusionProof(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "merkle.wal")
+
+	w, err := New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(w.GetSegments()) < 2 {
+		t.Fatalf("Expected the WAL to rotate, got %d segment(s)", len(w.GetSegments()))
+	}
+	root, size := w.MerkleRoot()
+	if size != 20 {
+		t.Fatalf("Expected 20 leaves, got %d", size)
+	}
+
+	for _, seq := range []uint64{1, 7, 20} {
+		proof, err := w.ProveInclusion(seq)
+		if err != nil {
+			t.Fatalf("ProveInclusion(%d): %v", seq, err)
+		}
+		if proof.RootHash != hexHash(root) || proof.TreeSize != 20 {
+			t.Errorf("Record %d: proof root %s/%d, WAL root %s/%d", seq, proof.RootHash, proof.TreeSize, hexHash(root), size)
+		}
+		if len(proof.Event) == 0 {
+			t.Errorf("Record %d: expected the plaintext event in the proof", seq)
+		}
+
+		// The proof survives a JSON round trip and verifies on its own
+		data, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded InclusionProof
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if err := decoded.Verify(); err != nil {
+			t.Errorf("Record %d: proof failed to verify: %v", seq, err)
+		}
+
+		// Altering the embedded record breaks the proof
+		decoded.Record[len(decoded.Record)/2] ^= 0xFF
+		if err := decoded.Verify(); err == nil {
+			t.Errorf("Record %d: tampered proof verified", seq)
+		}
+	}
+
+	if _, err := w.ProveInclusion(99); err == nil {
+		t.Error("Expected error proving a missing record")
+	}
+	segmentRoot, segmentSize := w.SegmentMerkleRoot()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The trees are rebuilt when the WAL reopens
+	w, err = New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+	if reopened, n := w.MerkleRoot(); reopened != root || n != size {
+		t.Errorf("Global root changed on reopen: %x/%d != %x/%d", reopened, n, root, size)
+	}
+	if reopened, n := w.SegmentMerkleRoot(); reopened != segmentRoot || n != segmentSize {
+		t.Errorf("Segment root changed on reopen: %x/%d != %x/%d", reopened, n, segmentRoot, segmentSize)
+	}
+
+	seq, err := w.Append(signingEvent(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 21 {
+		t.Errorf("Expected sequence 21 after reopen, got %d", seq)
+	}
+	proof, err := ProveInclusion(walPath, 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proof.Verify(); err != nil {
+		t.Errorf("Proof for appended record failed: %v", err)
+	}
+}
+
+// writeSegmentedWAL writes n records across several segments, closes the
+// WAL and returns its Merkle root and segments.
+func writeSegmentedWAL(t *testing.T, walPath string, n int) ([32]byte, []*Segment) {
+	t.Helper()
+	w, err := New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segments := w.GetSegments()
+	if len(segments) < 3 {
+		t.Fatalf("Expected the WAL to rotate, got %d segment(s)", len(segments))
+	}
+	root, _ := w.MerkleRoot()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return root, segments
+}
+
+func TestWALMerkleFrontierSaved(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "merkle.wal")
+	root, segments := writeSegmentedWAL(t, walPath, 20)
+	if _, err := os.Stat(merkleStatePath(walPath)); err != nil {
+		t.Fatalf("Expected the Merkle frontier to be saved: %v", err)
+	}
+
+	// Sealed segments the frontier covers are not read again
+	if err := os.Remove(segments[0].Path); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+
+	reopened, size := w.MerkleRoot()
+	if reopened != root || size != 20 {
+		t.Errorf("Expected the root of 20 records back, got %s/%d", hexHash(reopened), size)
+	}
+	if seq, err := w.Append(signingEvent(20)); err != nil || seq != 21 {
+		t.Errorf("Expected the next record to be 21, got %d: %v", seq, err)
+	}
+}
+
+func TestWALOpenWithDamagedSealedRecord(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "merkle.wal")
+	root, segments := writeSegmentedWAL(t, walPath, 20)
+
+	// Flip a byte inside the second record of the first sealed segment
+	sm, err := NewSegmentManager(walPath, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := sm.readSegment(segments[0].Path)
+	if err != nil || len(records) < 2 {
+		t.Fatalf("Expected records in %s: %v", segments[0].Path, err)
+	}
+	data, err := os.ReadFile(segments[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(records[0])+len(records[1])/2] ^= 0xFF
+	if err := os.WriteFile(segments[0].Path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The saved frontier still holds the record's leaf
+	w, err := New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatalf("Failed to open a WAL with a damaged sealed record: %v", err)
+	}
+	if reopened, _ := w.MerkleRoot(); reopened != root {
+		t.Error("Expected the saved frontier to keep the damaged record's leaf")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without it the record's leaf comes from the next record's PrevHash
+	if err := os.Remove(merkleStatePath(walPath)); err != nil {
+		t.Fatal(err)
+	}
+	w, err = New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatalf("Failed to open a WAL with a damaged sealed record: %v", err)
+	}
+	if reopened, size := w.MerkleRoot(); reopened != root || size != 20 {
+		t.Errorf("Expected the original root of 20 records, got %s/%d", hexHash(reopened), size)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(merkleStatePath(walPath)); err != nil {
+		t.Errorf("Expected the rebuilt frontier saved: %v", err)
+	}
+
+	// With the next record damaged too, no tree can be rebuilt
+	if err := os.Remove(merkleStatePath(walPath)); err != nil {
+		t.Fatal(err)
+	}
+	data[len(records[0])+len(records[1])+len(records[2])/2] ^= 0xFF
+	if err := os.WriteFile(segments[0].Path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(walPath, WithSegmentSize(1024)); !errors.Is(err, ErrRecordCorrupted) {
+		t.Errorf("Expected consecutive damaged records to fail the open, got %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package wal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+)
+
+// Metadata describes where and how a WAL is written, so logs gathered from
+// many hosts can be told apart and verified. The genesis record at sequence 0
+// carries the metadata a WAL was created with, and each later change is
+// appended to the chain as a metadata record.
+type Metadata struct {
+	Host              string `json:"host,omitempty"`
+	Application       string `json:"application,omitempty"`
+	ComplianceProfile string `json:"compliance_profile,omitempty"`
+	HashAlgorithm     string `json:"hash_algorithm"`
+	ChecksumAlgorithm string `json:"checksum_algorithm"`
+	SigningAlgorithm  string `json:"signing_algorithm,omitempty"`
+	// SigningKey is the fingerprint of the key records are signed with.
+	SigningKey string `json:"signing_key,omitempty"`
+	// EncryptionKeys are the fingerprints of the keys records are encrypted
+	// with, oldest first.
+	EncryptionKeys []string `json:"encryption_keys,omitempty"`
+	FormatVersion  int      `json:"format_version"`
+}
+
+// MetadataRecord is metadata read back from a WAL with the sequence and time
+// of the record that carried it.
+type MetadataRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Metadata
+	Sequence uint64 `json:"sequence"`
+}
+
+// Genesis reports whether the metadata came from the WAL's genesis record.
+func (m *MetadataRecord) Genesis() bool {
+	return m.Sequence == 0
+}
+
+// WithMetadata writes m to the genesis record when the WAL is created, and
+// appends a metadata record whenever the WAL is opened with different
+// metadata or its keyring rotates to a new encryption key. A WAL created
+// before metadata was enabled has no genesis record; its first metadata
+// record follows the existing records instead. The WAL fills in its own
+// format version, algorithms and, when its keyring lists them, encryption
+// key fingerprints.
+func WithMetadata(m Metadata) Option {
+	return func(c *config) error {
+		c.metadata = &m
+		return nil
+	}
+}
+
+// keyLister is a keyring that can list its keys, as *compliance.KeyManager
+// does. Only such a keyring can name its keys in the metadata.
+type keyLister interface {
+	Fingerprints() []compliance.KeyInfo
+}
+
+// IsMetadata reports whether the record carries Metadata rather than an
+// event.
+func (r *Record) IsMetadata() bool {
+	return r.Flags&RecordFlagMetadata != 0
+}
+
+// IsGenesis reports whether the record is the WAL's genesis record. It is
+// chained before the first event but is neither a Merkle leaf nor signed;
+// the first record's hash covers it through PrevHash.
+func (r *Record) IsGenesis() bool {
+	return r.Sequence == 0 && r.IsMetadata()
+}
+
+// GetMetadata decodes the metadata carried by a metadata record.
+func (r *Record) GetMetadata() (*Metadata, error) {
+	if !r.IsMetadata() {
+		return nil, fmt.Errorf("record %d is not a metadata record", r.Sequence)
+	}
+	var m Metadata
+	if err := json.Unmarshal(r.EventData, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	return &m, nil
+}
+
+// newMetadataRecord builds a metadata record in format, versioned for the
+// segment's codec. Metadata is always JSON and never encrypted so the log can
+// be identified without its keys.
+func newMetadataRecord(m *Metadata, format Format, codec Codec, sequence uint64, prevHash [32]byte) (*Record, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return &Record{
+		Magic:   MagicHeader,
+		Version: codec.version(),
+		Flags:   RecordFlagMetadata | format.flags(),
+		// #nosec G115 - metadata is a few hundred bytes
+		Length:    uint32(len(data)),
+		Timestamp: time.Now().UnixNano(),
+		Sequence:  sequence,
+		PrevHash:  prevHash,
+		EventData: data,
+		MagicEnd:  MagicFooter,
+	}, nil
+}
+
+// recordMetadata writes m as the genesis record of an empty WAL, or appends
+// it as a metadata record when it differs from the WAL's current metadata.
+func (w *WAL) recordMetadata(m Metadata) error {
+	m.HashAlgorithm = w.format.Hash.String()
+	m.ChecksumAlgorithm = NewChecksum(w.format.Checksum).Name()
+	m.FormatVersion = int(w.codec.version())
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	history, err := readMetadata(w.segments)
+	if err != nil {
+		return err
+	}
+	if len(history) > 0 {
+		w.metadata = &history[len(history)-1].Metadata
+	}
+	return w.appendMetadata(m)
+}
+
+// appendMetadata chains m, with the keyring's current keys, unless it matches
+// the metadata last recorded. The caller must hold w.mu.
+func (w *WAL) appendMetadata(m Metadata) error {
+	m.EncryptionKeys = nil
+	if keys, ok := w.keys.(keyLister); ok {
+		for _, info := range keys.Fingerprints() {
+			m.EncryptionKeys = append(m.EncryptionKeys, info.Fingerprint)
+			if info.Active {
+				w.keyID = info.ID
+			}
+		}
+	}
+	if w.metadata != nil && reflect.DeepEqual(*w.metadata, m) {
+		return nil
+	}
+
+	sequence := w.sequence
+	if sequence > 0 || w.metadata != nil {
+		sequence++
+	}
+	record, err := newMetadataRecord(&m, w.format, w.codec, sequence, w.lastHash)
+	if err != nil {
+		return err
+	}
+	if err := w.appendRecord(record, true); err != nil {
+		return fmt.Errorf("failed to write metadata record: %w", err)
+	}
+	w.metadata = &m
+	return nil
+}
+
+// noteKey chains a metadata record naming the new key once a record is
+// encrypted with a key other than the last one recorded. The event is
+// already written, so a failure only warns and is retried with the next
+// record. The caller must hold w.mu.
+func (w *WAL) noteKey(record *Record) {
+	if w.metadata == nil || !record.IsEncrypted() {
+		return
+	}
+	keyID := record.KeyID()
+	if keyID == w.keyID {
+		return
+	}
+	if err := w.appendMetadata(*w.metadata); err != nil {
+		logger.Log.Warn("Failed to record the key rotation in {path}: {error}", w.path, err)
+		return
+	}
+	w.keyID = keyID
+}
+
+// ReadMetadata returns every metadata record in the WAL at walPath, oldest
+// first. The last one describes how the WAL is currently written.
+func ReadMetadata(walPath string) ([]*MetadataRecord, error) {
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	return readMetadata(segments)
+}
+
+func readMetadata(segments *SegmentManager) ([]*MetadataRecord, error) {
+	var history []*MetadataRecord
+	for _, segment := range segments.GetSegments() {
+		if !fileExists(segment.Path) {
+			continue
+		}
+		records, err := segments.readSegment(segment.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read segment %s: %w", segment.Path, err)
+		}
+		for _, data := range records {
+			record, err := UnmarshalRecord(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read record in %s: %w", segment.Path, err)
+			}
+			if !record.IsMetadata() {
+				continue
+			}
+			m, err := record.GetMetadata()
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", record.Sequence, err)
+			}
+			history = append(history, &MetadataRecord{
+				Timestamp: time.Unix(0, record.Timestamp).UTC(),
+				Metadata:  *m,
+				Sequence:  record.Sequence,
+			})
+		}
+	}
+	return history, nil
+}
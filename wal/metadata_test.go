@@ -0,0 +1,236 @@
+package wal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog/core"
+)
+
+func TestWALMetadata(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "identified.wal")
+	sigPath := walPath + ".sig"
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := Metadata{Host: "host-a", Application: "billing", ComplianceProfile: "HIPAA"}
+
+	open := func(m Metadata) (*WAL, *compliance.SignatureChain) {
+		t.Helper()
+		chain, err := compliance.OpenSignatureChain(sigPath, signer)
+		if err != nil {
+			t.Fatalf("Failed to open signature log: %v", err)
+		}
+		w, err := New(walPath, WithSegmentSize(1024), WithSigning(chain), WithSealing(signer), WithMetadata(m))
+		if err != nil {
+			t.Fatalf("Failed to open WAL: %v", err)
+		}
+		return w, chain
+	}
+	closeAll := func(w *WAL, chain *compliance.SignatureChain) {
+		t.Helper()
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := chain.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A new WAL starts with its genesis record; events still start at 1
+	w, chain := open(metadata)
+	if w.LastSequence() != 0 {
+		t.Fatalf("Expected the genesis record at sequence 0, got %d", w.LastSequence())
+	}
+	seq, err := w.Append(signingEvent(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 1 {
+		t.Errorf("Expected the first event at sequence 1, got %d", seq)
+	}
+	for i := 1; i < 10; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, size := w.MerkleRoot(); size != 10 {
+		t.Errorf("Expected the genesis record outside the Merkle tree, got %d leaves", size)
+	}
+	closeAll(w, chain)
+
+	// Reopening unchanged adds nothing; a new profile is chained
+	w, chain = open(metadata)
+	if w.LastSequence() != 10 {
+		t.Errorf("Expected unchanged metadata to add no record, got sequence %d", w.LastSequence())
+	}
+	closeAll(w, chain)
+
+	changed := metadata
+	changed.ComplianceProfile = "SOX"
+	w, chain = open(changed)
+	if err := w.Write(signingEvent(10)); err != nil {
+		t.Fatal(err)
+	}
+	integrity, err := w.VerifyIntegrityReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !integrity.Valid {
+		t.Errorf("Expected a valid WAL with metadata records, got %+v", integrity)
+	}
+	closeAll(w, chain)
+
+	history, err := ReadMetadata(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected genesis and one change, got %d metadata records", len(history))
+	}
+	genesis, change := history[0], history[1]
+	if !genesis.Genesis() || genesis.Host != "host-a" || genesis.ComplianceProfile != "HIPAA" {
+		t.Errorf("Unexpected genesis: %+v", genesis)
+	}
+	if genesis.HashAlgorithm != "SHA-256" || genesis.ChecksumAlgorithm != "CRC32-IEEE" || genesis.FormatVersion != Version {
+		t.Errorf("Expected the WAL to fill in its algorithms, got %+v", genesis)
+	}
+	if change.Genesis() || change.Sequence != 11 || change.ComplianceProfile != "SOX" {
+		t.Errorf("Unexpected metadata change: %+v", change)
+	}
+
+	// Metadata records are signed and sealed like events; genesis is covered by record 1
+	signatures, err := VerifySignatures(walPath, sigPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signatures.Valid || signatures.Signed != 12 {
+		t.Errorf("Expected every record but genesis signed, got %+v", signatures)
+	}
+	seals, err := VerifySeals(walPath, sealPath(walPath), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !seals.Valid || seals.Sealed == 0 {
+		t.Errorf("Expected valid seals, got %+v", seals)
+	}
+
+	// Readers see only events
+	var events int
+	segments, err := NewSegmentManager(walPath, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, segment := range segments.GetSegments() {
+		reader, err := NewReader(segment.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, err := reader.ReadAll()
+		_ = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		events += len(read)
+	}
+	if events != 11 {
+		t.Errorf("Expected 11 events, got %d", events)
+	}
+}
+
+func TestWALMetadataAfterCreation(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "legacy.wal")
+
+	w, err := New(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(signingEvent(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A WAL written without metadata has no genesis to add
+	w, err = New(walPath, WithMetadata(Metadata{Application: "billing"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.VerifyIntegrity(); err != nil {
+		t.Errorf("Integrity check failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := ReadMetadata(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Genesis() || history[0].Sequence != 2 {
+		t.Errorf("Expected one metadata record after the existing event, got %+v", history)
+	}
+}
+
+func TestWALMetadataKeyRotation(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "identified.wal")
+
+	keys, err := compliance.NewKeyManager([]byte("test-key-32-bytes-long-exactly!!"), "AES-256-GCM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(walPath, WithEncryption(keys), WithMetadata(Metadata{Application: "billing"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first record under a new key is followed by a metadata record
+	if err := keys.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteBatch([]*core.LogEvent{signingEvent(3), signingEvent(4)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(signingEvent(5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.VerifyIntegrity(); err != nil {
+		t.Errorf("Integrity check failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := ReadMetadata(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected genesis and one rotation, got %d metadata records", len(history))
+	}
+	if len(history[0].EncryptionKeys) != 1 || len(history[1].EncryptionKeys) != 2 {
+		t.Errorf("Expected the rotation to add a key, got %v then %v", history[0].EncryptionKeys, history[1].EncryptionKeys)
+	}
+	if history[1].Sequence != 6 {
+		t.Errorf("Expected the rotation recorded after the batch, at 6, got %d", history[1].Sequence)
+	}
+
+	// Reopening with the same keys records nothing new
+	w, err = New(walPath, WithEncryption(keys), WithMetadata(Metadata{Application: "billing"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.LastSequence() != 7 {
+		t.Errorf("Expected no new metadata on reopen, got sequence %d", w.LastSequence())
+	}
+	_ = w.Close()
+}
@@ -0,0 +1,412 @@
+package wal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// ParityConfig describes the Reed-Solomon parity kept for a sealed segment.
+// The segment is divided into the blocks of Blocks, and every stripe of
+// DataShards blocks gets ParityShards parity blocks, so any ParityShards
+// damaged blocks in a stripe can be rebuilt exactly.
+type ParityConfig struct {
+	Blocks       BlockChecksum
+	DataShards   int
+	ParityShards int
+}
+
+// DefaultParityConfig returns 4KiB blocks checksummed with XXHash64 in
+// stripes of 16 data and 2 parity blocks, a 12.5% overhead.
+func DefaultParityConfig() ParityConfig {
+	return ParityConfig{
+		Blocks:       BlockChecksum{BlockSize: 4096, Type: ChecksumXXHash3},
+		DataShards:   16,
+		ParityShards: 2,
+	}
+}
+
+// validate reports whether the configuration can be encoded.
+func (c ParityConfig) validate() error {
+	if c.Blocks.BlockSize <= 0 || c.Blocks.BlockSize > maxDecompressedSize {
+		return fmt.Errorf("invalid parity block size %d", c.Blocks.BlockSize)
+	}
+	if err := (Format{Checksum: c.Blocks.Type}).validate(); err != nil {
+		return err
+	}
+	if c.DataShards <= 0 || c.ParityShards <= 0 || c.DataShards+c.ParityShards > 256 {
+		return fmt.Errorf("invalid parity shards %d+%d", c.DataShards, c.ParityShards)
+	}
+	return nil
+}
+
+// ErrParityUnrecoverable is returned when a segment has more damaged blocks
+// in a stripe than its parity can rebuild.
+var ErrParityUnrecoverable = errors.New("segment damage exceeds its parity")
+
+// ParityReport describes the state of a segment checked against its parity.
+type ParityReport struct {
+	Segment string
+	// Blocks counts the data blocks of the segment.
+	Blocks int
+	// CorruptBlocks counts data blocks that failed their checksum.
+	CorruptBlocks int
+	// RepairedBlocks counts data blocks rebuilt from parity.
+	RepairedBlocks int
+	// CorruptParity counts parity blocks that failed their checksum.
+	CorruptParity int
+}
+
+// Intact reports whether neither the segment nor its parity was damaged.
+func (r *ParityReport) Intact() bool {
+	return r.CorruptBlocks == 0 && r.CorruptParity == 0
+}
+
+// A parity sidecar holds a header, the checksum of every data and parity
+// block, a CRC32C over both, and then the parity blocks:
+//
+//	magic(4) + version(2) + checksum type(1) + data shards(1) +
+//	  parity shards(1) + reserved(1) + block size(4) + segment size(8) +
+//	  segment SHA-256(32) + block checksums(8 each) + crc(4) + parity blocks
+//
+// Shard counts are stored less one so 256 fits in a byte. The final data
+// block is zero-padded for encoding.
+const (
+	parityMagic      = 0x5950544D // "MTPY"
+	parityVersion    = 1
+	parityHeaderSize = 4 + 2 + 1 + 1 + 1 + 1 + 4 + 8 + sha256.Size
+)
+
+// parityFile is a parsed parity sidecar.
+type parityFile struct {
+	config      ParityConfig
+	checksums   []uint64
+	parity      [][]byte
+	segmentSize int64
+	segmentHash [32]byte
+}
+
+// ParityPath returns the path of the parity sidecar of a segment.
+func ParityPath(segmentPath string) string {
+	return segmentPath + ".parity"
+}
+
+// stripes returns the number of stripes covering size bytes.
+func (c ParityConfig) stripes(size int64) int {
+	blocks := c.blocks(size)
+	return (blocks + c.DataShards - 1) / c.DataShards
+}
+
+// blocks returns the number of data blocks covering size bytes.
+func (c ParityConfig) blocks(size int64) int {
+	return int((size + int64(c.Blocks.BlockSize) - 1) / int64(c.Blocks.BlockSize))
+}
+
+// WriteParity writes the parity sidecar of a sealed segment, replacing any
+// existing one. The segment must not change afterwards.
+func WriteParity(segmentPath string, config ParityConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(segmentPath) // #nosec G304 - controlled path
+	if err != nil {
+		return fmt.Errorf("failed to read segment: %w", err)
+	}
+
+	parity, err := encodeParity(data, config)
+	if err != nil {
+		return err
+	}
+
+	tempPath := ParityPath(segmentPath) + ".tmp"
+	if err := writeSyncedFile(tempPath, parity.marshal()); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write parity: %w", err)
+	}
+	if err := os.Rename(tempPath, ParityPath(segmentPath)); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace parity: %w", err)
+	}
+	return nil
+}
+
+// encodeParity computes the parity of segment data.
+func encodeParity(data []byte, config ParityConfig) (*parityFile, error) {
+	encoder, err := reedsolomon.New(config.DataShards, config.ParityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	parity := &parityFile{
+		config:      config,
+		segmentSize: int64(len(data)),
+		segmentHash: sha256.Sum256(data),
+	}
+	checksum := NewChecksum(config.Blocks.Type)
+	for _, shards := range config.shardStripes(data) {
+		for i := config.DataShards; i < len(shards); i++ {
+			shards[i] = make([]byte, config.Blocks.BlockSize)
+		}
+		if err := encoder.Encode(shards); err != nil {
+			return nil, fmt.Errorf("failed to encode parity: %w", err)
+		}
+		parity.parity = append(parity.parity, shards[config.DataShards:]...)
+	}
+
+	parity.checksums = config.Blocks.CalculateBlocks(data)
+	for _, block := range parity.parity {
+		parity.checksums = append(parity.checksums, checksum.Calculate(block))
+	}
+	return parity, nil
+}
+
+// shardStripes splits data into stripes of zero-padded data blocks, leaving
+// room for the parity blocks.
+func (c ParityConfig) shardStripes(data []byte) [][][]byte {
+	stripes := make([][][]byte, c.stripes(int64(len(data))))
+	for s := range stripes {
+		shards := make([][]byte, c.DataShards+c.ParityShards)
+		for i := 0; i < c.DataShards; i++ {
+			shards[i] = make([]byte, c.Blocks.BlockSize)
+			start := (s*c.DataShards + i) * c.Blocks.BlockSize
+			if start < len(data) {
+				copy(shards[i], data[start:])
+			}
+		}
+		stripes[s] = shards
+	}
+	return stripes
+}
+
+// marshal encodes the sidecar.
+func (p *parityFile) marshal() []byte {
+	var buf bytes.Buffer
+	header := make([]byte, parityHeaderSize)
+	binary.LittleEndian.PutUint32(header, parityMagic)
+	binary.LittleEndian.PutUint16(header[4:], parityVersion)
+	// #nosec G115 - validated checksum types and shard counts fit in a byte
+	header[6], header[7], header[8] = byte(p.config.Blocks.Type), byte(p.config.DataShards-1), byte(p.config.ParityShards-1)
+	// #nosec G115 - validated block size fits in 32 bits
+	binary.LittleEndian.PutUint32(header[10:], uint32(p.config.Blocks.BlockSize))
+	// #nosec G115 - segment sizes are non-negative
+	binary.LittleEndian.PutUint64(header[14:], uint64(p.segmentSize))
+	copy(header[22:], p.segmentHash[:])
+	buf.Write(header)
+
+	for _, checksum := range p.checksums {
+		_ = binary.Write(&buf, binary.LittleEndian, checksum)
+	}
+	_ = binary.Write(&buf, binary.LittleEndian, crc32.Checksum(buf.Bytes(), crc32.MakeTable(crc32.Castagnoli)))
+
+	for _, block := range p.parity {
+		buf.Write(block)
+	}
+	return buf.Bytes()
+}
+
+// readParity reads and validates the parity sidecar of a segment. Damaged
+// parity blocks are caught later by their checksums.
+func readParity(segmentPath string) (*parityFile, error) {
+	data, err := os.ReadFile(ParityPath(segmentPath)) // #nosec G304 - controlled path
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < parityHeaderSize || binary.LittleEndian.Uint32(data) != parityMagic {
+		return nil, fmt.Errorf("not a parity file")
+	}
+	if version := binary.LittleEndian.Uint16(data[4:]); version != parityVersion {
+		return nil, fmt.Errorf("unsupported parity version %d", version)
+	}
+
+	parity := &parityFile{
+		config: ParityConfig{
+			Blocks: BlockChecksum{
+				BlockSize: int(binary.LittleEndian.Uint32(data[10:])),
+				Type:      ChecksumType(data[6]),
+			},
+			DataShards:   int(data[7]) + 1,
+			ParityShards: int(data[8]) + 1,
+		},
+		// #nosec G115 - validated against the file size below
+		segmentSize: int64(binary.LittleEndian.Uint64(data[14:])),
+	}
+	copy(parity.segmentHash[:], data[22:parityHeaderSize])
+	if err := parity.config.validate(); err != nil {
+		return nil, err
+	}
+	if parity.segmentSize < 0 || parity.segmentSize > maxSegmentSize {
+		return nil, fmt.Errorf("invalid segment size %d", parity.segmentSize)
+	}
+
+	stripes := parity.config.stripes(parity.segmentSize)
+	parityBlocks := stripes * parity.config.ParityShards
+	count := parity.config.blocks(parity.segmentSize) + parityBlocks
+	tableEnd := parityHeaderSize + count*8
+	if len(data) != tableEnd+4+parityBlocks*parity.config.Blocks.BlockSize {
+		return nil, fmt.Errorf("parity file size %d does not match its header", len(data))
+	}
+	if crc32.Checksum(data[:tableEnd], crc32.MakeTable(crc32.Castagnoli)) != binary.LittleEndian.Uint32(data[tableEnd:]) {
+		return nil, fmt.Errorf("parity header checksum mismatch")
+	}
+
+	parity.checksums = make([]uint64, count)
+	for i := range parity.checksums {
+		parity.checksums[i] = binary.LittleEndian.Uint64(data[parityHeaderSize+i*8:])
+	}
+	blocks := data[tableEnd+4:]
+	for i := 0; i < parityBlocks; i++ {
+		size := parity.config.Blocks.BlockSize
+		parity.parity = append(parity.parity, blocks[i*size:(i+1)*size])
+	}
+	return parity, nil
+}
+
+// reconstructSegment checks a segment against its parity sidecar and returns
+// its original bytes, rebuilding damaged blocks. The result is only returned
+// when it matches the SHA-256 recorded when the parity was written.
+func reconstructSegment(segmentPath string) ([]byte, *ParityReport, error) {
+	report := &ParityReport{Segment: segmentPath}
+	parity, err := readParity(segmentPath)
+	if err != nil {
+		return nil, report, fmt.Errorf("failed to read parity: %w", err)
+	}
+	data, err := os.ReadFile(segmentPath) // #nosec G304 - controlled path
+	if err != nil {
+		return nil, report, fmt.Errorf("failed to read segment: %w", err)
+	}
+	if int64(len(data)) > parity.segmentSize {
+		return nil, report, fmt.Errorf("segment grew to %d bytes after its parity was written at %d", len(data), parity.segmentSize)
+	}
+
+	config := parity.config
+	report.Blocks = config.blocks(parity.segmentSize)
+	checksum := NewChecksum(config.Blocks.Type)
+
+	// A truncated segment is missing its final blocks
+	stripes := config.shardStripes(data)
+	for len(stripes) < config.stripes(parity.segmentSize) {
+		shards := make([][]byte, config.DataShards+config.ParityShards)
+		stripes = append(stripes, shards)
+	}
+
+	for block := 0; block < report.Blocks; block++ {
+		shards := stripes[block/config.DataShards]
+		i := block % config.DataShards
+		start := block * config.Blocks.BlockSize
+		end := min(start+config.Blocks.BlockSize, int(parity.segmentSize))
+		if end > len(data) || !checksum.Verify(data[start:end], parity.checksums[block]) {
+			shards[i] = nil
+			report.CorruptBlocks++
+		}
+	}
+
+	for i, block := range parity.parity {
+		shards := stripes[i/config.ParityShards]
+		if checksum.Verify(block, parity.checksums[report.Blocks+i]) {
+			shards[config.DataShards+i%config.ParityShards] = block
+		} else {
+			report.CorruptParity++
+		}
+	}
+	// Padding past the end of the segment is known to be zero
+	for block := report.Blocks; block < len(stripes)*config.DataShards; block++ {
+		stripes[block/config.DataShards][block%config.DataShards] = make([]byte, config.Blocks.BlockSize)
+	}
+
+	if report.CorruptBlocks > 0 {
+		encoder, err := reedsolomon.New(config.DataShards, config.ParityShards)
+		if err != nil {
+			return nil, report, err
+		}
+		for s, shards := range stripes {
+			if err := encoder.ReconstructData(shards); err != nil {
+				return nil, report, fmt.Errorf("stripe %d: %w: %v", s, ErrParityUnrecoverable, err)
+			}
+		}
+	}
+
+	repaired := make([]byte, 0, parity.segmentSize)
+	for _, shards := range stripes {
+		for _, shard := range shards[:config.DataShards] {
+			repaired = append(repaired, shard...)
+		}
+	}
+	repaired = repaired[:parity.segmentSize]
+	if sha256.Sum256(repaired) != parity.segmentHash {
+		return nil, report, fmt.Errorf("segment does not match the hash recorded with its parity: %w", ErrParityUnrecoverable)
+	}
+	return repaired, report, nil
+}
+
+// VerifyParity checks a segment and its parity sidecar without changing
+// either. It fails with ErrParityUnrecoverable when the segment cannot be
+// rebuilt.
+func VerifyParity(segmentPath string) (*ParityReport, error) {
+	_, report, err := reconstructSegment(segmentPath)
+	return report, err
+}
+
+// RepairSegment rebuilds the damaged blocks of a segment from its parity
+// sidecar in place, and rewrites the sidecar if its own blocks are damaged.
+func RepairSegment(segmentPath string) (*ParityReport, error) {
+	data, report, err := reconstructSegment(segmentPath)
+	if err != nil {
+		return report, err
+	}
+
+	if report.CorruptBlocks > 0 {
+		tempPath := segmentPath + ".repair.tmp"
+		if err := writeSyncedFile(tempPath, data); err != nil {
+			_ = os.Remove(tempPath)
+			return report, fmt.Errorf("failed to write repaired segment: %w", err)
+		}
+		if err := os.Rename(tempPath, segmentPath); err != nil {
+			_ = os.Remove(tempPath)
+			return report, fmt.Errorf("failed to replace segment: %w", err)
+		}
+		report.RepairedBlocks = report.CorruptBlocks
+	}
+	if report.CorruptParity > 0 {
+		parity, err := readParity(segmentPath)
+		if err != nil {
+			return report, err
+		}
+		if err := WriteParity(segmentPath, parity.config); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// refreshParity rewrites the parity sidecar of a segment that was rewritten,
+// keeping its configuration. Segments without parity are left without it.
+func refreshParity(segmentPath string) error {
+	parity, err := readParity(segmentPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	config := DefaultParityConfig()
+	if err == nil {
+		config = parity.config
+	}
+	return WriteParity(segmentPath, config)
+}
+
+// WithParity keeps a Reed-Solomon parity sidecar for every sealed segment,
+// written in the background after rotation, so RepairSegment and recovery
+// can rebuild damaged blocks exactly.
+func WithParity(parity ParityConfig) Option {
+	return func(c *config) error {
+		if err := parity.validate(); err != nil {
+			return err
+		}
+		c.parity = &parity
+		return nil
+	}
+}
@@ -0,0 +1,180 @@
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testParityConfig() ParityConfig {
+	return ParityConfig{
+		Blocks:       BlockChecksum{BlockSize: 256, Type: ChecksumCRC32C},
+		DataShards:   8,
+		ParityShards: 2,
+	}
+}
+
+// writeParityWAL writes a WAL whose sealed segments have parity and returns
+// its segments.
+func writeParityWAL(t *testing.T, walPath string, opts ...Option) []*Segment {
+	t.Helper()
+	opts = append([]Option{WithSegmentSize(4096), WithParity(testParityConfig())}, opts...)
+	w, err := New(walPath, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 40; i++ {
+		if err := w.Write(compressibleEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := NewSegmentManager(walPath, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := segments.GetSegments()
+	if len(all) < 3 {
+		t.Fatalf("Expected the WAL to rotate, got %d segment(s)", len(all))
+	}
+	for i, segment := range all {
+		if fileExists(ParityPath(segment.Path)) != (i < len(all)-1) {
+			t.Errorf("Segment %d: expected parity only for sealed segments", i)
+		}
+	}
+	return all
+}
+
+// damageBlocks flips a byte in each of the given blocks of path.
+func damageBlocks(t *testing.T, path string, blocks ...int) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range blocks {
+		data[block*testParityConfig().Blocks.BlockSize+17] ^= 0xFF
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParityRepair(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "parity.wal")
+	segment := writeParityWAL(t, walPath)[0].Path
+
+	original, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := VerifyParity(segment)
+	if err != nil || !report.Intact() || report.Blocks != (len(original)+255)/256 {
+		t.Fatalf("Expected an intact segment, got %+v (%v)", report, err)
+	}
+
+	// Two damaged blocks in one stripe are within the parity
+	damageBlocks(t, segment, 1, 6)
+	report, err = VerifyParity(segment)
+	if err != nil || report.CorruptBlocks != 2 {
+		t.Fatalf("Expected 2 repairable blocks, got %+v (%v)", report, err)
+	}
+
+	// Recovery rebuilds them instead of skipping records
+	recovery, records, err := NewRecoveryEngine(segment).Recover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovery.RepairedBlocks != 2 || recovery.CorruptedRecords != 0 || len(records) == 0 {
+		t.Errorf("Expected recovery through parity, got %+v", recovery)
+	}
+	if damaged, _ := os.ReadFile(segment); bytes.Equal(damaged, original) {
+		t.Error("Recovery should leave the damaged file as it is")
+	}
+
+	report, err = RepairSegment(segment)
+	if err != nil || report.RepairedBlocks != 2 {
+		t.Fatalf("Expected 2 repaired blocks, got %+v (%v)", report, err)
+	}
+	if repaired, _ := os.ReadFile(segment); !bytes.Equal(repaired, original) {
+		t.Fatal("Repaired segment differs from the original")
+	}
+
+	// A damaged parity block is rewritten
+	parity, err := os.ReadFile(ParityPath(segment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parity[len(parity)-1] ^= 0xFF
+	if err := os.WriteFile(ParityPath(segment), parity, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := RepairSegment(segment); err != nil || report.CorruptParity != 1 {
+		t.Fatalf("Expected a damaged parity block, got %+v (%v)", report, err)
+	}
+	if report, err := VerifyParity(segment); err != nil || !report.Intact() {
+		t.Errorf("Expected intact parity after repair, got %+v (%v)", report, err)
+	}
+
+	// Three damaged blocks in one stripe are not
+	damageBlocks(t, segment, 0, 2, 4)
+	if _, err := RepairSegment(segment); !errors.Is(err, ErrParityUnrecoverable) {
+		t.Errorf("Expected ErrParityUnrecoverable, got %v", err)
+	}
+
+	// Truncation loses whole blocks, which parity rebuilds too
+	segment = writeParityWAL(t, filepath.Join(t.TempDir(), "truncated.wal"))[1].Path
+	original, err = os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(segment, int64(len(original)-200)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RepairSegment(segment); err != nil {
+		t.Fatal(err)
+	}
+	if repaired, _ := os.ReadFile(segment); !bytes.Equal(repaired, original) {
+		t.Error("Repaired segment differs from the original")
+	}
+}
+
+func TestParityCompressedSegments(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "compressed.wal")
+	segments := writeParityWAL(t, walPath, WithSegmentCompression())
+	segment := segments[0].Path
+	if !segments[0].Compressed {
+		t.Fatal("Expected a compressed sealed segment")
+	}
+
+	// Parity covers the compressed bytes
+	if report, err := VerifyParity(segment); err != nil || !report.Intact() {
+		t.Fatalf("Expected intact parity, got %+v (%v)", report, err)
+	}
+	damageBlocks(t, segment, 0)
+	if _, err := readSegmentFile(segment); err == nil {
+		t.Fatal("Expected the damaged compressed segment to fail to read")
+	}
+
+	_, records, err := NewRecoveryEngine(segment).Recover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RepairSegment(segment); err != nil {
+		t.Fatal(err)
+	}
+	repaired, err := readSegmentFile(segment)
+	if err != nil || len(repaired) != len(records) {
+		t.Errorf("Expected %d records after repair, got %d (%v)", len(records), len(repaired), err)
+	}
+}
+
+// readSegmentFile reads the records of a single segment file.
+func readSegmentFile(path string) ([][]byte, error) {
+	return (&SegmentManager{}).readSegment(path)
+}
@@ -2,25 +2,28 @@ package wal
 
 import (
 	"encoding/binary"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"hash/crc32"
 	"io"
-	"os"
 	"time"
 
 	"github.com/willibrandon/mtlog/core"
 )
 
+// ErrRecordCorrupted is returned by ReadNextRecord for a complete record that
+// fails its checks. The reader has moved past it, so reading can continue.
+var ErrRecordCorrupted = errors.New("corrupted record")
+
 // Reader reads events from a WAL file
 type Reader struct {
-	file   *os.File
+	keys   Keyring
+	file   segmentFile
 	offset int64
 }
 
 // NewReader creates a new WAL reader
 func NewReader(path string) (*Reader, error) {
-	file, err := os.Open(path) // #nosec G304 - controlled path
+	file, err := openSegment(path, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open WAL: %w", err)
 	}
@@ -30,6 +33,12 @@ func NewReader(path string) (*Reader, error) {
 	}, nil
 }
 
+// SetKeyring sets the keys used to decrypt encrypted records. Without one,
+// encrypted records fail with ErrRecordEncrypted.
+func (r *Reader) SetKeyring(keys Keyring) {
+	r.keys = keys
+}
+
 // ReadAll reads all events from the WAL
 func (r *Reader) ReadAll() ([]*core.LogEvent, error) {
 	var events []*core.LogEvent
@@ -40,6 +49,9 @@ func (r *Reader) ReadAll() ([]*core.LogEvent, error) {
 		if err == io.EOF {
 			break
 		}
+		if errors.Is(err, ErrRecordEncrypted) {
+			return nil, err
+		}
 		if err != nil {
 			// Skip corrupted records but count them
 			skippedCount++
@@ -55,7 +67,7 @@ func (r *Reader) ReadAll() ([]*core.LogEvent, error) {
 	return events, nil
 }
 
-// ReadNext reads the next event from the WAL
+// ReadNext reads the next event from the WAL, skipping metadata records
 func (r *Reader) ReadNext() (*core.LogEvent, error) {
 	// Read magic number
 	var magic uint32
@@ -168,8 +180,12 @@ func (r *Reader) ReadNext() (*core.LogEvent, error) {
 	// EventData
 	crcBuf = append(crcBuf, eventData...)
 
-	// Verify data CRC
-	calculatedCRC := crc32.ChecksumIEEE(crcBuf)
+	// Verify data CRC with the checksum the flags declare
+	format := formatOf(flags)
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+	calculatedCRC := format.checksum(crcBuf)
 	if calculatedCRC != crc32Data {
 		return nil, fmt.Errorf("data CRC mismatch: expected %x, got %x", crc32Data, calculatedCRC)
 	}
@@ -179,18 +195,70 @@ func (r *Reader) ReadNext() (*core.LogEvent, error) {
 		return nil, fmt.Errorf("invalid magic footer: %x", magicEnd)
 	}
 
-	// Parse event
-	var event core.LogEvent
-	if err := json.Unmarshal(eventData, &event); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
-	}
-
 	// Update offset
 	headerSize := 4 + 2 + 2 + 4 + 8 + 4         // magic + version + flags + length + timestamp + crc32
 	payloadSize := 8 + 32 + int(length) + 4 + 4 // sequence + prevHash + eventData + crc32Data + magicEnd
 	r.offset += int64(headerSize + payloadSize)
 
-	return &event, nil
+	// Metadata records describe the log rather than an event
+	if flags&RecordFlagMetadata != 0 {
+		return r.ReadNext()
+	}
+
+	// Decrypt and parse event
+	plaintext, err := openEventPayload(eventData, flags, r.keys)
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", sequence, err)
+	}
+	return decodeEvent(plaintext, version)
+}
+
+// ReadNextRecord reads the next complete record, including its sequence
+// number and chain hash. A record that is still being appended is left in
+// place and reported as io.EOF so the caller can retry once it is complete.
+func (r *Reader) ReadNextRecord() (*Record, error) {
+	start := r.offset
+
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r.file, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			_, _ = r.file.Seek(start, io.SeekStart)
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	r.offset = start + int64(len(header))
+
+	magic := binary.LittleEndian.Uint32(header)
+	if magic != MagicHeader {
+		return nil, fmt.Errorf("invalid magic number: %x", magic)
+	}
+
+	length := binary.LittleEndian.Uint32(header[8:12])
+	if length > 1024*1024 { // Max 1MB per record
+		return nil, fmt.Errorf("invalid record length: %d", length)
+	}
+
+	// sequence + prevHash + eventData + crc32Data + magicEnd
+	rest := make([]byte, 8+32+int(length)+4+4)
+	if _, err := io.ReadFull(r.file, rest); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			r.offset = start
+			_, _ = r.file.Seek(start, io.SeekStart)
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read record: %w", err)
+	}
+
+	r.offset = start + int64(len(header)+len(rest))
+
+	record, err := UnmarshalRecord(append(header, rest...))
+	if err != nil {
+		return nil, fmt.Errorf("%w at offset %d: %w", ErrRecordCorrupted, start, err)
+	}
+
+	return record, nil
 }
 
 // ReadRange reads events within a time range
@@ -202,6 +270,9 @@ func (r *Reader) ReadRange(start, end time.Time) ([]*core.LogEvent, error) {
 		if err == io.EOF {
 			break
 		}
+		if errors.Is(err, ErrRecordEncrypted) {
+			return nil, err
+		}
 		if err != nil {
 			continue // Skip bad records
 		}
@@ -269,3 +269,74 @@ func TestWALReaderSeek(t *testing.T) {
 
 	_ = firstEvent // silence unused variable warning
 }
+
+func TestWALReaderReadNextRecordTail(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "test.wal")
+
+	w, err := New(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+
+	event := &core.LogEvent{
+		Timestamp:       time.Now(),
+		Level:           core.InformationLevel,
+		MessageTemplate: "Tail event",
+		Properties:      map[string]interface{}{"id": 1},
+	}
+
+	seq, err := w.Append(event)
+	if err != nil {
+		t.Fatalf("Failed to append event: %v", err)
+	}
+	if seq != 1 {
+		t.Errorf("Expected sequence 1, got %d", seq)
+	}
+
+	// Simulate a record that is only partially on disk
+	record, err := NewRecord(event, 2, w.lastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := record.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := len(data) / 2
+	if _, err := w.file.Write(data[:half]); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(walPath)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	first, err := reader.ReadNextRecord()
+	if err != nil {
+		t.Fatalf("Failed to read first record: %v", err)
+	}
+	if first.Sequence != 1 {
+		t.Errorf("Expected sequence 1, got %d", first.Sequence)
+	}
+
+	if _, err := reader.ReadNextRecord(); err != io.EOF {
+		t.Fatalf("Expected io.EOF for partial record, got %v", err)
+	}
+
+	// Once the rest of the record lands the reader picks it up
+	if _, err := w.file.Write(data[half:]); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := reader.ReadNextRecord()
+	if err != nil {
+		t.Fatalf("Failed to read completed record: %v", err)
+	}
+	if second.Sequence != 2 {
+		t.Errorf("Expected sequence 2, got %d", second.Sequence)
+	}
+}
@@ -3,11 +3,8 @@ package wal
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/binary"
-	"encoding/json"
 	"fmt"
-	"hash/crc32"
 
 	"github.com/willibrandon/mtlog/core"
 )
@@ -17,11 +14,19 @@ const (
 	MagicHeader = 0x4D544C47 // "MTLG" in hex
 	// MagicFooter identifies the end of a WAL record
 	MagicFooter = 0x454E4452 // "ENDR" in hex
-	// Version is the WAL format version
+	// Version is the WAL format version of records with JSON event payloads;
+	// see VersionBinary
 	Version = 1
 
 	// RecordFlagDeleted marks a record as deleted.
 	RecordFlagDeleted = 1 << 0 // Record has been marked for deletion
+	// RecordFlagEncrypted marks a record whose payload is AEAD-encrypted.
+	RecordFlagEncrypted = 1 << 2
+	// RecordFlagMetadata marks a record whose payload is a Metadata document
+	// rather than an event.
+	RecordFlagMetadata = 1 << 3
+	// RecordFlagCompressed marks a record whose payload is compressed.
+	RecordFlagCompressed = 1 << 4
 )
 
 // Record represents a single entry in the WAL.
@@ -39,17 +44,21 @@ type Record struct {
 	PrevHash    [32]byte
 }
 
-// NewRecord creates a new WAL record from a log event.
+// NewRecord creates a new WAL record from a log event, encoded as JSON.
 func NewRecord(event *core.LogEvent, sequence uint64, prevHash [32]byte) (*Record, error) {
-	// Serialize the event to JSON
-	eventData, err := json.Marshal(event)
+	return newEventRecord(event, CodecJSON, sequence, prevHash)
+}
+
+// newEventRecord creates a WAL record from a log event encoded with codec.
+func newEventRecord(event *core.LogEvent, codec Codec, sequence uint64, prevHash [32]byte) (*Record, error) {
+	eventData, err := encodeEvent(event, codec)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal event: %w", err)
+		return nil, err
 	}
 
 	r := &Record{
 		Magic:   MagicHeader,
-		Version: Version,
+		Version: codec.version(),
 		Flags:   0,
 		// #nosec G115 - event data length validated against max record size
 		Length:    uint32(len(eventData)),
@@ -63,8 +72,13 @@ func NewRecord(event *core.LogEvent, sequence uint64, prevHash [32]byte) (*Recor
 	return r, nil
 }
 
-// Marshal serializes the record to bytes with CRC protection.
+// Marshal serializes the record to bytes with CRC protection, using the
+// checksum its flags declare.
 func (r *Record) Marshal() ([]byte, error) {
+	format := r.Format()
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
 	buf := new(bytes.Buffer)
 
 	// Write header
@@ -86,7 +100,7 @@ func (r *Record) Marshal() ([]byte, error) {
 
 	// Calculate header CRC (excluding the CRC field itself)
 	headerBytes := buf.Bytes()
-	r.CRC32Header = crc32.ChecksumIEEE(headerBytes)
+	r.CRC32Header = format.checksum(headerBytes)
 	if err := binary.Write(buf, binary.LittleEndian, r.CRC32Header); err != nil {
 		return nil, err
 	}
@@ -104,7 +118,7 @@ func (r *Record) Marshal() ([]byte, error) {
 
 	// Calculate data CRC (entire record so far)
 	allBytes := buf.Bytes()
-	r.CRC32Data = crc32.ChecksumIEEE(allBytes)
+	r.CRC32Data = format.checksum(allBytes)
 	if err := binary.Write(buf, binary.LittleEndian, r.CRC32Data); err != nil {
 		return nil, err
 	}
@@ -137,6 +151,9 @@ func UnmarshalRecord(data []byte) (*Record, error) {
 	if err := binary.Read(buf, binary.LittleEndian, &r.Version); err != nil {
 		return nil, err
 	}
+	if !knownVersion(r.Version) {
+		return nil, fmt.Errorf("unsupported record version %d", r.Version)
+	}
 	if err := binary.Read(buf, binary.LittleEndian, &r.Flags); err != nil {
 		return nil, err
 	}
@@ -150,9 +167,15 @@ func UnmarshalRecord(data []byte) (*Record, error) {
 		return nil, err
 	}
 
+	// The flags declare which checksum covers the record
+	format := r.Format()
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+
 	// Verify header CRC
 	headerBytes := data[:20] // Header without CRC field
-	expectedCRC := crc32.ChecksumIEEE(headerBytes)
+	expectedCRC := format.checksum(headerBytes)
 	if r.CRC32Header != expectedCRC {
 		return nil, fmt.Errorf("header CRC mismatch: expected %x, got %x", expectedCRC, r.CRC32Header)
 	}
@@ -185,7 +208,7 @@ func UnmarshalRecord(data []byte) (*Record, error) {
 	// Verify data CRC
 	endOfData := len(data) - 8 // Exclude CRC32Data and MagicEnd
 	dataBytes := data[:endOfData]
-	expectedDataCRC := crc32.ChecksumIEEE(dataBytes)
+	expectedDataCRC := format.checksum(dataBytes)
 	if r.CRC32Data != expectedDataCRC {
 		return nil, fmt.Errorf("data CRC mismatch: expected %x, got %x", expectedDataCRC, r.CRC32Data)
 	}
@@ -193,19 +216,24 @@ func UnmarshalRecord(data []byte) (*Record, error) {
 	return r, nil
 }
 
-// ComputeHash calculates the SHA256 hash of the record for chaining.
+// ComputeHash calculates the hash of the record for chaining, using the hash
+// algorithm its flags declare.
 func (r *Record) ComputeHash() [32]byte {
 	data, _ := r.Marshal()
-	return sha256.Sum256(data)
+	return r.Format().Hash.Sum(data)
 }
 
-// GetEvent deserializes the event data back to a LogEvent.
+// GetEvent deserializes the event data back to a LogEvent. Encrypted records
+// return ErrRecordEncrypted; use DecodeEvent for those.
 func (r *Record) GetEvent() (*core.LogEvent, error) {
-	var event core.LogEvent
-	if err := json.Unmarshal(r.EventData, &event); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	if r.IsEncrypted() {
+		return nil, ErrRecordEncrypted
+	}
+	data, err := openEventPayload(r.EventData, r.Flags, nil)
+	if err != nil {
+		return nil, err
 	}
-	return &event, nil
+	return decodeEvent(data, r.Version)
 }
 
 // UnmarshalRecordFromBytes unmarshals a record from bytes and returns bytes read
@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"hash/crc32"
 	"io"
 	"os"
 	"time"
@@ -18,8 +17,12 @@ import (
 
 // RecoveryEngine handles WAL recovery after corruption or crashes.
 type RecoveryEngine struct {
+	keys           Keyring
 	hashChains     map[uint64][32]byte
+	sealed         map[int]*RecoveredRecord
+	repaired       []byte
 	path           string
+	format         Format
 	maxRecordSize  int64
 	skipCorrupted  bool
 	verifyChecksum bool
@@ -32,6 +35,8 @@ type RecoveredRecord struct {
 	Sequence       uint64
 	Timestamp      uint64
 	BytesRead      int
+	Flags          uint16
+	Version        uint16
 	HashChainValid bool
 }
 
@@ -48,6 +53,9 @@ type RecoveryReport struct {
 	HashChainBreaks     int
 	ReconstructedChains int
 	PartialRecords      int
+	DecryptedRecords    int
+	EncryptedRecords    int
+	RepairedBlocks      int
 }
 
 // RecoveryOption configures the recovery engine.
@@ -67,7 +75,8 @@ func WithSkipCorrupted(skip bool) RecoveryOption {
 	}
 }
 
-// WithChecksumVerification enables CRC32 verification during recovery.
+// WithChecksumVerification enables verification of each record's declared
+// checksum during recovery.
 func WithChecksumVerification(verify bool) RecoveryOption {
 	return func(r *RecoveryEngine) {
 		r.verifyChecksum = verify
@@ -84,6 +93,15 @@ func WithForensicRecovery(enable bool) RecoveryOption {
 	}
 }
 
+// WithRecoveryKeyring decrypts encrypted records during recovery so their
+// plaintext events are returned. Without it, encrypted records are recovered
+// as ciphertext. RepairWAL keeps them encrypted either way.
+func WithRecoveryKeyring(keys Keyring) RecoveryOption {
+	return func(r *RecoveryEngine) {
+		r.keys = keys
+	}
+}
+
 // NewRecoveryEngine creates a new recovery engine for a WAL file.
 func NewRecoveryEngine(path string, opts ...RecoveryOption) *RecoveryEngine {
 	engine := &RecoveryEngine{
@@ -106,7 +124,8 @@ func (r *RecoveryEngine) Recover() (*RecoveryReport, [][]byte, error) {
 		Errors: make([]error, 0),
 	}
 
-	file, err := os.Open(r.path)
+	r.repairFromParity(report)
+	file, err := r.open()
 	if err != nil {
 		return report, nil, fmt.Errorf("failed to open WAL for recovery: %w", err)
 	}
@@ -114,13 +133,10 @@ func (r *RecoveryEngine) Recover() (*RecoveryReport, [][]byte, error) {
 
 	var records [][]byte
 	offset := int64(0)
+	r.sealed = make(map[int]*RecoveredRecord)
 
-	// Get file size
-	stat, err := file.Stat()
-	if err != nil {
-		return report, nil, err
-	}
-	fileSize := stat.Size()
+	// Get the uncompressed size
+	fileSize := file.Size()
 
 	// Debug: log file size
 	if fileSize == 0 {
@@ -154,7 +170,9 @@ func (r *RecoveryEngine) Recover() (*RecoveryReport, [][]byte, error) {
 		}
 
 		if record != nil {
-			records = append(records, record.EventData)
+			r.format = formatOf(record.Flags)
+			data := r.openRecord(record, len(records), report)
+			records = append(records, data)
 			report.RecoveredRecords++
 			report.LastGoodSequence = record.Sequence
 		}
@@ -169,9 +187,87 @@ func (r *RecoveryEngine) Recover() (*RecoveryReport, [][]byte, error) {
 	return report, records, nil
 }
 
+// repairFromParity rebuilds damaged blocks of the WAL from its parity
+// sidecar, if it has one, so they are recovered exactly rather than skipped.
+// The file itself is left as it is.
+func (r *RecoveryEngine) repairFromParity(report *RecoveryReport) {
+	r.repaired = nil
+	if !fileExists(ParityPath(r.path)) {
+		return
+	}
+	data, parity, err := reconstructSegment(r.path)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("parity: %w", err))
+		logger.Log.Warn("Failed to repair {path} from parity: {error}", r.path, err)
+		return
+	}
+	if parity.CorruptBlocks > 0 {
+		r.repaired = data
+		report.RepairedBlocks = parity.CorruptBlocks
+		report.RecoveryMethods = append(report.RecoveryMethods, "parity")
+		logger.Log.Info("Rebuilt {count} damaged blocks of {path} from parity", parity.CorruptBlocks, r.path)
+	}
+}
+
+// open opens the WAL for scanning, using the blocks rebuilt from parity when
+// there are any.
+func (r *RecoveryEngine) open() (segmentFile, error) {
+	if r.repaired != nil {
+		return openSegmentData(r.repaired, r.path, true)
+	}
+	return openSegment(r.path, true)
+}
+
+// openRecord returns the event data of a recovered record as JSON, decrypting
+// it when keys are available, decompressing it and transcoding binary events.
+// Encrypted, compressed, binary and metadata records are remembered by index
+// so RepairWAL writes them back as they were stored rather than as JSON
+// events.
+func (r *RecoveryEngine) openRecord(record *RecoveredRecord, index int, report *RecoveryReport) []byte {
+	if record.Flags&RecordFlagMetadata != 0 {
+		r.sealed[index] = record
+		return record.EventData
+	}
+	if record.Flags&(RecordFlagEncrypted|RecordFlagCompressed) == 0 && record.Version == Version {
+		return record.EventData
+	}
+	r.sealed[index] = record
+
+	plaintext := record.EventData
+	if record.Flags&RecordFlagEncrypted != 0 {
+		if r.keys == nil {
+			report.EncryptedRecords++
+			return record.EventData
+		}
+		var err error
+		plaintext, err = decryptPayload(record.EventData, record.Flags, r.keys)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("record %d: %w", record.Sequence, err))
+			report.EncryptedRecords++
+			return record.EventData
+		}
+		report.DecryptedRecords++
+	}
+	if record.Flags&RecordFlagCompressed != 0 {
+		var err error
+		plaintext, err = decompressPayload(plaintext)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("record %d: %w", record.Sequence, err))
+			return record.EventData
+		}
+	}
+
+	data, err := eventJSON(plaintext, record.Version)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("record %d: %w", record.Sequence, err))
+		return plaintext
+	}
+	return data
+}
+
 // readNextRecord reads and validates the next record from the file.
 // Returns a RecoveredRecord with EventData, sequence, and bytes read.
-func (r *RecoveryEngine) readNextRecord(file *os.File, offset int64) (*RecoveredRecord, error) {
+func (r *RecoveryEngine) readNextRecord(file segmentFile, offset int64) (*RecoveredRecord, error) {
 	// Seek to offset
 	if _, err := file.Seek(offset, 0); err != nil {
 		return nil, err
@@ -202,8 +298,8 @@ func (r *RecoveryEngine) readNextRecord(file *os.File, offset int64) (*Recovered
 	if err := binary.Read(buf, binary.LittleEndian, &version); err != nil {
 		return nil, err
 	}
-	if version != Version {
-		logger.Log.Warn("Unexpected version {version} at offset {offset}", version, offset)
+	if !knownVersion(version) {
+		return nil, fmt.Errorf("unsupported record version %d at offset %d", version, offset)
 	}
 
 	// 3. Flags (2 bytes)
@@ -236,11 +332,17 @@ func (r *RecoveryEngine) readNextRecord(file *os.File, offset int64) (*Recovered
 		return nil, err
 	}
 
+	// The flags declare the record's checksum and chain hash
+	format := formatOf(flags)
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+
 	// Verify header CRC if requested
 	if r.verifyChecksum {
 		// CRC is calculated over the first 20 bytes (Magic through Timestamp)
 		headerBytes := header[:20]
-		expectedCRC := crc32.ChecksumIEEE(headerBytes)
+		expectedCRC := format.checksum(headerBytes)
 		if crc32Header != expectedCRC {
 			return nil, fmt.Errorf("header CRC mismatch: expected %x, got %x", expectedCRC, crc32Header)
 		}
@@ -298,7 +400,7 @@ func (r *RecoveryEngine) readNextRecord(file *os.File, offset int64) (*Recovered
 		fullRecord = append(fullRecord, header...)
 		fullRecord = append(fullRecord, remaining[:len(remaining)-8]...) // Exclude CRC32Data and MagicEnd
 
-		expectedDataCRC := crc32.ChecksumIEEE(fullRecord)
+		expectedDataCRC := format.checksum(fullRecord)
 		if crc32Data != expectedDataCRC {
 			return nil, fmt.Errorf("data CRC mismatch: expected %x, got %x", expectedDataCRC, crc32Data)
 		}
@@ -310,21 +412,21 @@ func (r *RecoveryEngine) readNextRecord(file *os.File, offset int64) (*Recovered
 	return &RecoveredRecord{
 		EventData: eventData,
 		Sequence:  sequence,
+		// #nosec G115 - timestamp conversion always valid
+		Timestamp: uint64(timestamp),
 		BytesRead: totalBytesRead,
+		Flags:     flags,
+		Version:   version,
 	}, nil
 }
 
 // findNextRecord attempts to find the next valid record by scanning for magic header.
-func (r *RecoveryEngine) findNextRecord(file *os.File, startOffset int64) int64 {
+func (r *RecoveryEngine) findNextRecord(file segmentFile, startOffset int64) int64 {
 	const scanWindow = 4096 // Scan in 4KB chunks
 	buffer := make([]byte, scanWindow)
 
 	// Get file size to avoid infinite loop
-	stat, err := file.Stat()
-	if err != nil {
-		return 0
-	}
-	fileSize := stat.Size()
+	fileSize := file.Size()
 
 	offset := startOffset + 1 // Start from next byte
 
@@ -377,6 +479,7 @@ func (r *RecoveryEngine) RecoverSegments(segments []*Segment) (*RecoveryReport,
 			WithSkipCorrupted(r.skipCorrupted),
 			WithChecksumVerification(r.verifyChecksum),
 			WithMaxRecordSize(r.maxRecordSize),
+			WithRecoveryKeyring(r.keys),
 		)
 
 		segReport, records, err := engine.Recover()
@@ -393,6 +496,9 @@ func (r *RecoveryEngine) RecoverSegments(segments []*Segment) (*RecoveryReport,
 		report.RecoveredRecords += segReport.RecoveredRecords
 		report.CorruptedRecords += segReport.CorruptedRecords
 		report.SkippedBytes += segReport.SkippedBytes
+		report.DecryptedRecords += segReport.DecryptedRecords
+		report.EncryptedRecords += segReport.EncryptedRecords
+		report.RepairedBlocks += segReport.RepairedBlocks
 
 		if segReport.LastGoodSequence > report.LastGoodSequence {
 			report.LastGoodSequence = segReport.LastGoodSequence
@@ -434,30 +540,43 @@ func (r *RecoveryEngine) RepairWAL(outputPath string) error {
 	}
 	defer func() { _ = output.Close() }()
 
-	// Write recovered records to new WAL
+	// Write recovered records to new WAL, keeping a genesis record at sequence 0
+	first := uint64(1)
+	if genesis, ok := r.sealed[0]; ok && genesis.Sequence == 0 && genesis.Flags&RecordFlagMetadata != 0 {
+		first = 0
+	}
 	var lastHash [32]byte
 	for i, recordData := range records {
 		// Deserialize the event to get its original timestamp
 		var event core.LogEvent
 		timestamp := time.Now().UnixNano() // Default to now if deserialization fails
-
-		if err := json.Unmarshal(recordData, &event); err == nil && event.Timestamp.Unix() > 0 {
+		flags := r.format.flags()          // Reset flags but keep the segment's format
+		version := uint16(Version)
+
+		if sealed, ok := r.sealed[i]; ok {
+			// Never write decrypted or transcoded data back to disk
+			recordData = sealed.EventData
+			// #nosec G115 - timestamp read from the record header
+			timestamp = int64(sealed.Timestamp)
+			flags |= sealed.Flags & (RecordFlagEncrypted | RecordFlagMetadata | RecordFlagCompressed)
+			version = sealed.Version
+		} else if err := json.Unmarshal(recordData, &event); err == nil && event.Timestamp.Unix() > 0 {
 			timestamp = event.Timestamp.UnixNano()
 		}
 
 		// Properly reconstruct the record with correct metadata
 		record := &Record{
 			Magic:   MagicHeader,
-			Version: Version,
+			Version: version,
 			// #nosec G115 - loop index bounded
-			Sequence:  uint64(i + 1),
+			Sequence:  first + uint64(i),
 			PrevHash:  lastHash,
 			EventData: recordData,
 			MagicEnd:  MagicFooter,
 			// #nosec G115 - record data length validated
 			Length:    uint32(len(recordData)),
 			Timestamp: timestamp,
-			Flags:     0, // Reset flags for repaired records
+			Flags:     flags,
 		}
 
 		// Marshal and write
@@ -549,15 +668,14 @@ func (r *RecoveryEngine) reconstructHashChains(report *RecoveryReport) [][]byte
 	var reconstructed [][]byte
 
 	// Scan for hash chain breaks
-	file, err := os.Open(r.path)
+	file, err := r.open()
 	if err != nil {
 		return reconstructed
 	}
 	defer func() { _ = file.Close() }()
 
 	offset := int64(0)
-	stat, _ := file.Stat()
-	fileSize := stat.Size()
+	fileSize := file.Size()
 
 	var lastGoodHash [32]byte
 	chainBroken := false
@@ -594,7 +712,7 @@ func (r *RecoveryEngine) reconstructHashChains(report *RecoveryReport) [][]byte
 }
 
 // readRecordWithHashRecovery attempts to read a record with hash chain recovery
-func (r *RecoveryEngine) readRecordWithHashRecovery(file *os.File, offset int64, lastGoodHash [32]byte) (*RecoveredRecord, error) {
+func (r *RecoveryEngine) readRecordWithHashRecovery(file segmentFile, offset int64, lastGoodHash [32]byte) (*RecoveredRecord, error) {
 	// First try normal read
 	record, err := r.readNextRecord(file, offset)
 	if err == nil {
@@ -613,7 +731,7 @@ func (r *RecoveryEngine) readRecordWithHashRecovery(file *os.File, offset int64,
 }
 
 // attemptHashReconstruction tries to reconstruct a record using hash chain forensics
-func (r *RecoveryEngine) attemptHashReconstruction(file *os.File, offset int64, lastGoodHash [32]byte) *RecoveredRecord {
+func (r *RecoveryEngine) attemptHashReconstruction(file segmentFile, offset int64, lastGoodHash [32]byte) *RecoveredRecord {
 	_, err := file.Seek(offset, 0)
 	if err != nil {
 		return nil
@@ -646,7 +764,7 @@ func (r *RecoveryEngine) attemptHashReconstruction(file *os.File, offset int64,
 
 		// Validate header structure
 		// #nosec G115 - validated max size
-		if version != Version || length > uint32(r.maxRecordSize) {
+		if !knownVersion(version) || length > uint32(r.maxRecordSize) {
 			continue
 		}
 
@@ -756,9 +874,9 @@ func (r *RecoveryEngine) attemptCRCReconstruction(data []byte, expectedHeaderCRC
 		return false
 	}
 
-	// Verify header CRC
+	// Verify header CRC with the checksum the flags declare
 	headerBytes := data[:20]
-	actualCRC := crc32.ChecksumIEEE(headerBytes)
+	actualCRC := formatOf(binary.LittleEndian.Uint16(headerBytes[6:])).checksum(headerBytes)
 
 	if actualCRC != expectedHeaderCRC {
 		// Try to fix single-bit errors
@@ -769,7 +887,8 @@ func (r *RecoveryEngine) attemptCRCReconstruction(data []byte, expectedHeaderCRC
 				copy(testData, headerBytes)
 				testData[i] ^= (1 << bit)
 
-				if crc32.ChecksumIEEE(testData) == expectedHeaderCRC {
+				format := formatOf(binary.LittleEndian.Uint16(testData[6:]))
+				if format.validate() == nil && format.checksum(testData) == expectedHeaderCRC {
 					// Found the error! Fix it
 					copy(data[:20], testData)
 					return true
@@ -810,8 +929,9 @@ func (r *RecoveryEngine) attemptPatternReconstruction(data []byte, timestamp uin
 	}
 
 	// Check flags are valid
-	validFlags := RecordFlagDeleted | RecordFlagCompacted
-	if flags & ^uint16(validFlags) != 0 {
+	validFlags := RecordFlagDeleted | RecordFlagCompacted | RecordFlagEncrypted | RecordFlagMetadata |
+		RecordFlagCompressed | formatFlagMask
+	if flags & ^uint16(validFlags) != 0 || formatOf(flags).validate() != nil {
 		return false
 	}
 
@@ -821,6 +941,16 @@ func (r *RecoveryEngine) attemptPatternReconstruction(data []byte, timestamp uin
 		eventStart := 24 + 8 + 32
 		if eventStart+int(length) <= len(data) {
 			eventData := data[eventStart : eventStart+int(length)]
+			if flags&RecordFlagEncrypted != 0 {
+				// Ciphertext can't be inspected; a well-formed encryption header will do
+				_, err := openPayload(eventData)
+				return err == nil
+			}
+			if flags&RecordFlagCompressed != 0 {
+				// Compressed payloads are checked by decompressing them
+				_, err := decompressPayload(eventData)
+				return err == nil
+			}
 			if len(eventData) > 0 {
 				// Check for JSON structure
 				firstChar := eventData[0]
@@ -890,15 +1020,14 @@ func (r *RecoveryEngine) attemptDeepForensicRecovery(buffer []byte, n int) *Reco
 func (r *RecoveryEngine) recoverPartialRecords() [][]byte {
 	var partialRecords [][]byte
 
-	file, err := os.Open(r.path)
+	file, err := r.open()
 	if err != nil {
 		return partialRecords
 	}
 	defer func() { _ = file.Close() }()
 
 	// Read file in chunks and look for partial records at boundaries
-	stat, _ := file.Stat()
-	fileSize := stat.Size()
+	fileSize := file.Size()
 
 	// Check last 10KB for partial records
 	if fileSize > 10240 {
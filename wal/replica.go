@@ -0,0 +1,223 @@
+package wal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Replica is a named copy of a WAL's events kept elsewhere, such as a
+// backend, that damaged records can be rebuilt from.
+type Replica struct {
+	Read EventSource
+	Name string
+}
+
+// RebuiltRange is a run of damaged or missing records and where they were
+// rebuilt from.
+type RebuiltRange struct {
+	// Err is why the records could not be rebuilt; nil when they were.
+	Err     error
+	Segment string
+	// Source names the replica the records came from.
+	Source string
+	// First and Last are the sequences of the run, or 0 where the damage
+	// leaves them unknown.
+	First uint64
+	Last  uint64
+}
+
+// ReplicaRepairReport describes a WAL repaired from replicas.
+type ReplicaRepairReport struct {
+	Ranges           []RebuiltRange
+	Segments         int
+	RepairedSegments int
+	// LocalRecords are intact records kept from the damaged WAL.
+	LocalRecords   int
+	RebuiltRecords int
+	// DamagedRecords estimates the records found damaged or missing.
+	DamagedRecords int
+	ChainBreaks    int
+}
+
+// Complete reports whether every damaged record was rebuilt.
+func (r *ReplicaRepairReport) Complete() bool {
+	for _, rebuilt := range r.Ranges {
+		if rebuilt.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// RepairFromReplicas rebuilds the damaged and missing records of the WAL at
+// walPath from replicas, tried in order for each run of damage, and writes
+// the repaired segments into outputDir under their original names. Intact
+// segments are copied as they are, with their parity sidecars. Rebuilt
+// records reproduce the original hash chain exactly, so a replica holding
+// different events is never used. Runs no replica holds are left out and
+// reported in the ranges. Encrypted records cannot be rebuilt.
+func RepairFromReplicas(walPath, outputDir string, replicas ...Replica) (*ReplicaRepairReport, error) {
+	manager, err := NewSegmentManager(walPath, math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+	report := &ReplicaRepairReport{}
+
+	var segments []Segment
+	var scans [][]scrubPiece
+	var compressed []bool
+	for _, segment := range manager.GetSegments() {
+		// Offline, an empty segment can't be told from a fresh active one
+		segment.Sealed = segment.Size > 0
+		scrub := &ScrubReport{}
+		pieces, isCompressed, err := scanSegment(context.Background(), segment, 0, scrub)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan segment %s: %w", segment.Path, err)
+		}
+		segments = append(segments, *segment)
+		scans = append(scans, pieces)
+		compressed = append(compressed, isCompressed)
+		report.LocalRecords += scrub.Records
+		report.DamagedRecords += scrub.DamagedRecords
+		report.ChainBreaks += scrub.ChainBreaks
+	}
+	report.Segments = len(segments)
+
+	// A segment whose first record is damaged may be out of order; order by
+	// the first intact record instead
+	order := make([]int, len(segments))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return firstSequence(scans[order[a]]) < firstSequence(scans[order[b]])
+	})
+	sortedSegments := make([]Segment, len(segments))
+	sortedScans := make([][]scrubPiece, len(segments))
+	sortedCompressed := make([]bool, len(segments))
+	for i, j := range order {
+		sortedSegments[i], sortedScans[i], sortedCompressed[i] = segments[j], scans[j], compressed[j]
+	}
+	segments, scans, compressed = sortedSegments, sortedScans, sortedCompressed
+
+	sources := make([]EventSource, len(replicas))
+	for i, replica := range replicas {
+		sources[i] = replica.Read
+	}
+
+	// Rebuild every gap against the scans as they were found, so one
+	// rebuilt gap never anchors another
+	rebuilt := make([][][]byte, len(segments))
+	for i := range segments {
+		rebuilt[i] = make([][]byte, len(scans[i]))
+		neighbours := &scrubNeighbours{segments: segments, scans: scans, index: i}
+		for j, piece := range scans[i] {
+			if piece.record != nil {
+				continue
+			}
+			span := RebuiltRange{Segment: segments[i].Path}
+			if j > 0 && scans[i][j-1].record != nil {
+				span.First = scans[i][j-1].record.Sequence + 1
+			}
+			if j < len(scans[i])-1 && scans[i][j+1].record != nil {
+				span.Last = scans[i][j+1].record.Sequence - 1
+			}
+
+			before, after, err := neighbours.anchors(scans[i], j)
+			if err == nil {
+				span.First, span.Last = 1, after.Sequence-1
+				if before != nil {
+					span.First = before.Sequence + 1
+				}
+				var source int
+				rebuilt[i][j], source, err = rebuildGap(before, after, sources)
+				if err == nil {
+					span.Source = replicas[source].Name
+					report.RebuiltRecords += countRecords(rebuilt[i][j])
+				}
+			}
+			span.Err = err
+			report.Ranges = append(report.Ranges, span)
+		}
+	}
+
+	if err := os.MkdirAll(outputDir, 0o750); err != nil {
+		return report, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	for i, segment := range segments {
+		output := filepath.Join(outputDir, filepath.Base(segment.Path))
+		var data []byte
+		damaged := false
+		for j, piece := range scans[i] {
+			if piece.record == nil {
+				damaged = true
+				data = append(data, rebuilt[i][j]...)
+				continue
+			}
+			data = append(data, piece.data...)
+		}
+
+		if !damaged {
+			if err := copySegmentFile(segment.Path, output); err != nil {
+				return report, err
+			}
+			if fileExists(ParityPath(segment.Path)) {
+				if err := copySegmentFile(ParityPath(segment.Path), ParityPath(output)); err != nil {
+					return report, err
+				}
+			}
+			continue
+		}
+
+		if len(data) == 0 {
+			continue
+		}
+		if err := writeSyncedFile(output, data); err != nil {
+			return report, fmt.Errorf("failed to write repaired segment: %w", err)
+		}
+		// Parity is rewritten for the repaired bytes with the original
+		// configuration
+		if fileExists(ParityPath(segment.Path)) {
+			if err := copySegmentFile(ParityPath(segment.Path), ParityPath(output)); err != nil {
+				return report, err
+			}
+		}
+		if compressed[i] {
+			err = CompressSegment(output)
+		} else {
+			err = refreshParity(output)
+		}
+		if err != nil {
+			return report, err
+		}
+		report.RepairedSegments++
+	}
+	return report, nil
+}
+
+// firstSequence returns the sequence of the first intact record in pieces,
+// or the largest sequence when there is none.
+func firstSequence(pieces []scrubPiece) uint64 {
+	for _, piece := range pieces {
+		if piece.record != nil {
+			return piece.record.Sequence
+		}
+	}
+	return math.MaxUint64
+}
+
+// copySegmentFile copies the file at src to a new file at dst.
+func copySegmentFile(src, dst string) error {
+	data, err := os.ReadFile(src) // #nosec G304 - controlled segment path
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	if err := writeSyncedFile(dst, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,627 @@
+package wal
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog/core"
+)
+
+// Kinds of RestoreCheck.
+const (
+	RestoreCheckSeal          = "seal"
+	RestoreCheckCheckpoint    = "checkpoint"
+	RestoreCheckHighWaterMark = "high-water mark"
+)
+
+// RestoreReport describes a WAL rebuilt from a replica, for the incident
+// record.
+type RestoreReport struct {
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	Since       time.Time `json:"since"`
+	Until       time.Time `json:"until"`
+	Source      string    `json:"source"`
+	Output      string    `json:"output"`
+	// Format is the checksum, chain hash, codec and compression the
+	// records were rebuilt with.
+	Format   string            `json:"format"`
+	LastHash string            `json:"last_hash,omitempty"`
+	Errors   []string          `json:"errors,omitempty"`
+	Segments []RestoredSegment `json:"segments"`
+	Checks   []RestoreCheck    `json:"checks"`
+	// Gaps are sequences missing between the restored records, which the
+	// replica did not hold. The original's metadata records are never
+	// replicated, so each leaves a gap.
+	Gaps   []SequenceRange `json:"gaps,omitempty"`
+	Events uint64          `json:"events"`
+	// Duplicates counts events the replica held more than once, including
+	// stamped events at a sequence already restored.
+	Duplicates uint64 `json:"duplicates"`
+	FirstSeq   uint64 `json:"first_seq"`
+	LastSeq    uint64 `json:"last_seq"`
+	// Verified is set when there was something to verify the restored
+	// chain against and all of it matched.
+	Verified bool `json:"verified"`
+}
+
+// SequenceRange is an inclusive range of WAL sequences.
+type SequenceRange struct {
+	First uint64 `json:"first"`
+	Last  uint64 `json:"last"`
+}
+
+// RestoredSegment is one segment of a restored WAL.
+type RestoredSegment struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Path      string    `json:"path"`
+	// Seal names the original segment whose seal the restored one matches.
+	Seal     string `json:"seal,omitempty"`
+	FirstSeq uint64 `json:"first_seq"`
+	LastSeq  uint64 `json:"last_seq"`
+	Records  uint64 `json:"records"`
+}
+
+// RestoreCheck compares the restored chain with a seal, checkpoint or
+// high-water mark signed while the original WAL was written.
+type RestoreCheck struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+	// Sequence is the last record the check covers.
+	Sequence uint64 `json:"sequence"`
+	Matched  bool   `json:"matched"`
+}
+
+// RestoreOption configures Restore.
+type RestoreOption func(*restoreConfig)
+
+type restoreConfig struct {
+	since       time.Time
+	until       time.Time
+	verifier    compliance.Signer
+	mark        *compliance.HighWaterMark
+	seals       []*compliance.SegmentSeal
+	checkpoints []*compliance.Checkpoint
+	walOptions  []Option
+	window      time.Duration
+}
+
+// WithRestoreRange limits the restore to events timestamped from since up to
+// and including until. Without it every event up to now is restored.
+func WithRestoreRange(since, until time.Time) RestoreOption {
+	return func(c *restoreConfig) {
+		c.since = since
+		c.until = until
+	}
+}
+
+// WithRestoreWindow sets how much time each read from the replica covers
+// once the restore has a start time. The default is one hour.
+func WithRestoreWindow(window time.Duration) RestoreOption {
+	return func(c *restoreConfig) {
+		c.window = window
+	}
+}
+
+// WithRestoreSeals verifies the restored chain against the original WAL's
+// segment seals and re-creates its segments at the sealed boundaries.
+func WithRestoreSeals(seals []*compliance.SegmentSeal) RestoreOption {
+	return func(c *restoreConfig) {
+		c.seals = seals
+	}
+}
+
+// WithRestoreCheckpoints verifies the restored chain against signed Merkle
+// tree checkpoints of the original WAL.
+func WithRestoreCheckpoints(checkpoints []*compliance.Checkpoint) RestoreOption {
+	return func(c *restoreConfig) {
+		c.checkpoints = checkpoints
+	}
+}
+
+// WithRestoreHighWaterMark verifies the restored chain against the original
+// WAL's signed high-water mark.
+func WithRestoreHighWaterMark(mark *compliance.HighWaterMark) RestoreOption {
+	return func(c *restoreConfig) {
+		c.mark = mark
+	}
+}
+
+// WithRestoreVerifier checks the signatures of seals, checkpoints and the
+// high-water mark before the restored chain is compared with them.
+func WithRestoreVerifier(verifier compliance.Signer) RestoreOption {
+	return func(c *restoreConfig) {
+		c.verifier = verifier
+	}
+}
+
+// WithRestoreWALOptions sets the options the restored WAL is written with.
+// The checksum, hash algorithm, codec and compression must be those of the
+// original WAL for the chain to match.
+func WithRestoreWALOptions(opts ...Option) RestoreOption {
+	return func(c *restoreConfig) {
+		c.walOptions = append(c.walOptions, opts...)
+	}
+}
+
+// restoreTarget is a seal, checkpoint or high-water mark the restored chain
+// is compared with, and the index of its check in the report.
+type restoreTarget struct {
+	seal       *compliance.SegmentSeal
+	checkpoint *compliance.Checkpoint
+	mark       *compliance.HighWaterMark
+	check      int
+}
+
+// restorePoint is what happens once the restored WAL reaches a sequence.
+type restorePoint struct {
+	targets []restoreTarget
+	rotate  bool
+}
+
+// restorer rebuilds a WAL from a replica.
+type restorer struct {
+	wal    *WAL
+	report *RestoreReport
+	points map[uint64]*restorePoint
+	sealed map[uint64]*compliance.SegmentSeal
+	// pending holds stamped events until the chain reaches their sequence
+	pending map[uint64]*core.LogEvent
+	cfg     *restoreConfig
+	matched []*compliance.Checkpoint
+	stops   []uint64
+	// anchored is set when the restore starts at the original's first
+	// record, so anything missing before the first event is a gap
+	anchored bool
+}
+
+// Restore rebuilds a WAL at walPath from the events a replica holds, such as
+// a backend, after the original was lost. Events are read a window at a time.
+// Those the sink stamped are restored at their stamped sequences, in sequence
+// order across windows; sequences the replica lacks are left out and reported
+// as gaps. Events without a stamp follow the restored head in timestamp
+// order. With seals the segments are cut where the original's were, and the
+// restored chain is compared with every seal, checkpoint and high-water mark
+// given. Those that match are kept with the restored WAL so it verifies as
+// the original did.
+//
+// A missing sequence holds back the stamped events after it until the
+// replica has been read to the end. The restored chain only matches from
+// the first record the replica lacks, such as a metadata record, and never
+// when the original WAL was encrypted; the report says where it diverged.
+func Restore(walPath string, replica Replica, opts ...RestoreOption) (*RestoreReport, error) {
+	cfg := &restoreConfig{window: time.Hour}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.until.IsZero() {
+		cfg.until = time.Now()
+	}
+	if cfg.window <= 0 {
+		return nil, fmt.Errorf("restore window must be positive")
+	}
+
+	existing, err := NewSegmentManager(walPath, math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range existing.GetSegments() {
+		if fileExists(segment.Path) {
+			return nil, fmt.Errorf("%s already holds a WAL", filepath.Dir(walPath))
+		}
+	}
+
+	// Nothing precedes the first sealed segment, so reading can start there
+	anchored := cfg.since.IsZero()
+	cfg.seals = append([]*compliance.SegmentSeal(nil), cfg.seals...)
+	sort.Slice(cfg.seals, func(i, j int) bool { return cfg.seals[i].FirstSeq < cfg.seals[j].FirstSeq })
+	if cfg.since.IsZero() && len(cfg.seals) > 0 && cfg.seals[0].FirstSeq == 1 {
+		cfg.since = cfg.seals[0].StartTime
+	}
+
+	walOptions := cfg.walOptions
+	if len(cfg.seals) > 0 {
+		// Segments end where their seals do
+		walOptions = append(walOptions, WithSegmentSize(math.MaxInt64))
+	}
+	w, err := New(walPath, walOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WAL: %w", err)
+	}
+
+	r := &restorer{
+		wal: w,
+		report: &RestoreReport{
+			StartedAt: time.Now().UTC(),
+			Since:     cfg.since,
+			Until:     cfg.until,
+			Source:    replica.Name,
+			Output:    walPath,
+			Format:    fmt.Sprintf("%s/%s/%s", w.format, w.codec, w.compression),
+		},
+		points:   make(map[uint64]*restorePoint),
+		sealed:   make(map[uint64]*compliance.SegmentSeal),
+		pending:  make(map[uint64]*core.LogEvent),
+		cfg:      cfg,
+		anchored: anchored,
+	}
+	r.plan()
+
+	err = r.stream(replica.Read)
+	if closeErr := w.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close restored WAL: %w", closeErr)
+	}
+	if err != nil {
+		return r.report, err
+	}
+	return r.report, r.finish(walPath)
+}
+
+// plan registers a check for every seal, checkpoint and high-water mark
+// whose signature holds, and the segment boundaries the seals fix.
+func (r *restorer) plan() {
+	point := func(seq uint64) *restorePoint {
+		p, ok := r.points[seq]
+		if !ok {
+			p = &restorePoint{}
+			r.points[seq] = p
+			r.stops = append(r.stops, seq)
+		}
+		return p
+	}
+	check := func(kind, name string, seq uint64, signature error, target restoreTarget) bool {
+		r.report.Checks = append(r.report.Checks, RestoreCheck{Kind: kind, Name: name, Sequence: seq})
+		if signature != nil {
+			r.report.Checks[len(r.report.Checks)-1].Error = signature.Error()
+			return false
+		}
+		target.check = len(r.report.Checks) - 1
+		p := point(seq)
+		p.targets = append(p.targets, target)
+		return true
+	}
+	verify := func(v interface{ Verify(compliance.Signer) error }) error {
+		if r.cfg.verifier == nil {
+			return nil
+		}
+		return v.Verify(r.cfg.verifier)
+	}
+
+	for _, seal := range r.cfg.seals {
+		if !check(RestoreCheckSeal, seal.Segment, seal.LastSeq, verify(seal), restoreTarget{seal: seal}) {
+			continue
+		}
+		point(seal.LastSeq).rotate = true
+		if seal.FirstSeq > 1 {
+			point(seal.FirstSeq - 1).rotate = true
+		}
+	}
+	for _, cp := range r.cfg.checkpoints {
+		check(RestoreCheckCheckpoint, fmt.Sprintf("size %d", cp.TreeSize), cp.TreeSize, verify(cp), restoreTarget{checkpoint: cp})
+	}
+	if mark := r.cfg.mark; mark != nil {
+		check(RestoreCheckHighWaterMark, fmt.Sprintf("sequence %d", mark.Sequence), mark.Sequence, verify(mark), restoreTarget{mark: mark})
+	}
+	sort.Slice(r.stops, func(i, j int) bool { return r.stops[i] < r.stops[j] })
+}
+
+// stream reads the replica a window at a time and writes its events.
+func (r *restorer) stream(read EventSource) error {
+	start, end := r.cfg.since, r.cfg.until.Add(time.Nanosecond)
+	for !start.After(r.cfg.until) {
+		// Read everything at once when the start is unknown
+		next := end
+		if !start.IsZero() && start.Add(r.cfg.window).Before(end) {
+			next = start.Add(r.cfg.window)
+		}
+
+		// Backends exclude both ends of a range; keep [start, next)
+		events, err := read(start.Add(-time.Nanosecond), next)
+		if err != nil {
+			return fmt.Errorf("failed to read events from %s to %s: %w", start.Format(time.RFC3339), next.Format(time.RFC3339), err)
+		}
+		window := events[:0]
+		for _, event := range events {
+			if !event.Timestamp.Before(start) && event.Timestamp.Before(next) {
+				window = append(window, event)
+			}
+		}
+		if err := r.add(order(window)); err != nil {
+			return err
+		}
+		start = next
+	}
+
+	// The rest of the stamped events follow the gaps nothing filled
+	if err := r.drain(true); err != nil {
+		return err
+	}
+
+	// Checks the restore never reached fail
+	for i := range r.report.Checks {
+		check := &r.report.Checks[i]
+		if !check.Matched && check.Error == "" {
+			check.Error = fmt.Sprintf("restored WAL ends at sequence %d", r.wal.sequence)
+		}
+	}
+	return nil
+}
+
+// replicaEvent is an event read back from a replica, without its stamp.
+type replicaEvent struct {
+	event *core.LogEvent
+	// sequence is the WAL sequence the event was stamped with, if stamped
+	sequence uint64
+	stamped  bool
+}
+
+// order strips the stamps from events and sorts them by time, which is the
+// order the sink wrote them.
+func order(events []*core.LogEvent) []replicaEvent {
+	ordered := make([]replicaEvent, len(events))
+	for i, event := range events {
+		e := &ordered[i]
+		e.event, e.sequence, _, e.stamped = UnstampEvent(event)
+	}
+	sort.SliceStable(ordered, func(a, b int) bool {
+		return ordered[a].event.Timestamp.Before(ordered[b].event.Timestamp)
+	})
+	return ordered
+}
+
+// add queues a window's stamped events by sequence and writes its unstamped
+// ones after the restored head, dropping events the replica holds more than
+// once, which replication retries can leave behind. Stamped copies share a
+// sequence; unstamped ones can only be told apart by content, and copies of
+// one are adjacent in events.
+func (r *restorer) add(events []replicaEvent) error {
+	var (
+		unstamped []*core.LogEvent
+		// run holds the unstamped events sharing the last one's timestamp
+		run []*core.LogEvent
+	)
+	for _, e := range events {
+		if e.stamped {
+			if _, queued := r.pending[e.sequence]; queued || e.sequence <= r.wal.sequence {
+				r.report.Duplicates++
+				continue
+			}
+			r.pending[e.sequence] = e.event
+			continue
+		}
+
+		if len(run) > 0 && !e.event.Timestamp.Equal(run[0].Timestamp) {
+			run = nil
+		}
+		duplicate := false
+		for _, earlier := range run {
+			if reflect.DeepEqual(earlier, e.event) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			r.report.Duplicates++
+			continue
+		}
+		run = append(run, e.event)
+		unstamped = append(unstamped, e.event)
+	}
+
+	if err := r.drain(false); err != nil {
+		return err
+	}
+	if err := r.write(unstamped); err != nil {
+		return err
+	}
+	return r.drain(false)
+}
+
+// drain writes the queued events that continue the restored chain. At the
+// end of the replica it writes the rest too, each run after a gap. A restore
+// that doesn't start at the original's first record starts at the lowest
+// sequence queued.
+func (r *restorer) drain(final bool) error {
+	for len(r.pending) > 0 {
+		next := r.wal.sequence + 1
+		if _, ok := r.pending[next]; !ok {
+			started := r.anchored || r.report.Events > 0
+			if started && !final {
+				return nil
+			}
+			lowest := uint64(math.MaxUint64)
+			for seq := range r.pending {
+				lowest = min(lowest, seq)
+			}
+			if started {
+				r.report.Gaps = append(r.report.Gaps, SequenceRange{First: next, Last: lowest - 1})
+			}
+			if err := r.skipTo(lowest); err != nil {
+				return err
+			}
+			next = lowest
+		}
+
+		var run []*core.LogEvent
+		for event, ok := r.pending[next]; ok; event, ok = r.pending[next] {
+			run = append(run, event)
+			delete(r.pending, next)
+			next++
+		}
+		if err := r.write(run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipTo moves the restored head to just before seq, leaving the records in
+// between out. Checks at the skipped sequences fail, and segments still end
+// at the boundaries among them.
+func (r *restorer) skipTo(seq uint64) error {
+	w := r.wal
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	first := w.sequence + 1
+	for _, stop := range r.stops {
+		if stop < first || stop >= seq {
+			continue
+		}
+		p := r.points[stop]
+		for _, target := range p.targets {
+			r.report.Checks[target.check].Error = fmt.Sprintf("records %d-%d were not restored", first, seq-1)
+		}
+		if p.rotate && w.segmentMerkle.Size() > 0 {
+			w.sequence = stop
+			if err := w.rotate(); err != nil {
+				return fmt.Errorf("failed to start a new segment: %w", err)
+			}
+		}
+	}
+	w.sequence = seq - 1
+	return nil
+}
+
+// write appends events, stopping at every sequence with checks or a segment
+// boundary.
+func (r *restorer) write(events []*core.LogEvent) error {
+	for len(events) > 0 {
+		n := uint64(len(events))
+		for _, stop := range r.stops {
+			if stop > r.wal.sequence {
+				n = min(n, stop-r.wal.sequence)
+				break
+			}
+		}
+		sequences, err := r.wal.WriteBatch(events[:n])
+		if err != nil {
+			return fmt.Errorf("failed to write restored records: %w", err)
+		}
+		if r.report.Events == 0 {
+			r.report.FirstSeq = sequences[0]
+		}
+		r.report.Events += n
+		events = events[n:]
+
+		if p, ok := r.points[r.wal.sequence]; ok {
+			if err := r.reach(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reach runs a point's checks against the restored WAL's state and starts a
+// new segment where the point ends one.
+func (r *restorer) reach(p *restorePoint) error {
+	w := r.wal
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, target := range p.targets {
+		check := &r.report.Checks[target.check]
+		switch {
+		case target.seal != nil:
+			seal, actual := target.seal, w.activeSeal()
+			if seal.Matches(actual) {
+				check.Matched = true
+				r.sealed[seal.FirstSeq] = seal
+			} else {
+				check.Error = fmt.Sprintf("restored records %d-%d end at %s with root %s, seal has %d-%d ending at %s with root %s",
+					actual.FirstSeq, actual.LastSeq, actual.LastHash, actual.MerkleRoot,
+					seal.FirstSeq, seal.LastSeq, seal.LastHash, seal.MerkleRoot)
+			}
+		case target.checkpoint != nil:
+			if root := hexHash(w.merkle.Root()); root == target.checkpoint.RootHash {
+				check.Matched = true
+				r.matched = append(r.matched, target.checkpoint)
+			} else {
+				check.Error = fmt.Sprintf("restored root is %s, checkpoint has %s", root, target.checkpoint.RootHash)
+			}
+		case target.mark != nil:
+			if hash := hexHash(w.lastHash); hash == target.mark.Hash {
+				check.Matched = true
+			} else {
+				check.Error = fmt.Sprintf("restored record hash is %s, mark has %s", hash, target.mark.Hash)
+			}
+		}
+	}
+
+	if p.rotate && w.segmentMerkle.Size() > 0 {
+		if err := w.rotate(); err != nil {
+			return fmt.Errorf("failed to start a new segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// finish describes the restored segments and keeps the seals and
+// checkpoints that match the restored chain beside it.
+func (r *restorer) finish(walPath string) error {
+	report := r.report
+	segments, err := NewSegmentManager(walPath, math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments.GetSegments() {
+		if !fileExists(segment.Path) {
+			continue
+		}
+		actual, damaged, err := readSeal(segments, segment.Path)
+		if err != nil {
+			return err
+		}
+		if damaged > 0 {
+			return fmt.Errorf("restored segment %s holds %d damaged records", segment.Path, damaged)
+		}
+		if actual.Records == 0 {
+			continue
+		}
+		restored := RestoredSegment{
+			Path:      segment.Path,
+			FirstSeq:  actual.FirstSeq,
+			LastSeq:   actual.LastSeq,
+			Records:   actual.Records,
+			StartTime: actual.StartTime,
+			EndTime:   actual.EndTime,
+		}
+		if seal, ok := r.sealed[actual.FirstSeq]; ok {
+			restored.Seal = seal.Segment
+			if err := compliance.AppendSeal(sealPath(walPath), seal); err != nil {
+				return err
+			}
+		}
+		report.Segments = append(report.Segments, restored)
+	}
+	for _, cp := range r.matched {
+		if err := compliance.AppendCheckpoint(walPath+".checkpoints", cp); err != nil {
+			return err
+		}
+	}
+
+	if report.Events > 0 {
+		report.LastSeq = r.wal.sequence
+		report.LastHash = hexHash(r.wal.lastHash)
+	}
+	for _, gap := range report.Gaps {
+		report.Errors = append(report.Errors, fmt.Sprintf("records %d-%d are missing from %s", gap.First, gap.Last, report.Source))
+	}
+	report.Verified = len(report.Checks) > 0 && len(report.Gaps) == 0
+	for _, check := range report.Checks {
+		if !check.Matched {
+			report.Verified = false
+			report.Errors = append(report.Errors, fmt.Sprintf("%s %s: %s", check.Kind, check.Name, check.Error))
+		}
+	}
+	report.CompletedAt = time.Now().UTC()
+	return nil
+}
@@ -0,0 +1,251 @@
+package wal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog/core"
+)
+
+func TestRestore(t *testing.T) {
+	forEachCodec(t, testRestore)
+}
+
+func testRestore(t *testing.T, codec Codec) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "original", "audit.wal")
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(walPath, WithSegmentSize(4096), WithSealing(signer), WithCompression(CompressionSnappy), WithCodec(codec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make([]*core.LogEvent, 40)
+	var checkpoint *compliance.Checkpoint
+	for i := range events {
+		events[i] = replicatedEvent(i)
+		if err := w.Write(events[i]); err != nil {
+			t.Fatal(err)
+		}
+		if i == 24 {
+			root, size := w.MerkleRoot()
+			if checkpoint, err = compliance.SignCheckpoint(signer, size, root, time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	seq, hash, err := w.SyncedHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := w.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mark, err := compliance.SignHighWaterMark(signer, id, seq, hash, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := w.SegmentsSnapshot()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	seals, err := compliance.ReadSeals(sealPath(walPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The backend holds one event twice, as a replication retry leaves it
+	held := append([]*core.LogEvent{events[7]}, events...)
+	opts := []RestoreOption{
+		WithRestoreWindow(time.Millisecond),
+		WithRestoreSeals(seals),
+		WithRestoreCheckpoints([]*compliance.Checkpoint{checkpoint}),
+		WithRestoreHighWaterMark(mark),
+		WithRestoreVerifier(signer),
+		WithRestoreWALOptions(WithCompression(CompressionSnappy), WithCodec(codec)),
+	}
+
+	restoredPath := filepath.Join(dir, "restored", "audit.wal")
+	report, err := Restore(restoredPath, Replica{Name: "backend", Read: replica(t, held)}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Verified || report.Events != 40 || report.Duplicates != 1 || report.LastSeq != 40 {
+		t.Fatalf("Expected a verified restore of 40 events, got %+v", report)
+	}
+	if len(report.Checks) != len(seals)+2 {
+		t.Errorf("Expected a check per seal, checkpoint and mark, got %+v", report.Checks)
+	}
+
+	// Sealed segments come back byte for byte, and verify with their seals
+	if len(report.Segments) != len(segments) {
+		t.Fatalf("Expected %d segments, got %+v", len(segments), report.Segments)
+	}
+	for i, restored := range report.Segments[:len(seals)] {
+		original, err := os.ReadFile(segments[i].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(restored.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, original) || restored.Seal != filepath.Base(segments[i].Path) {
+			t.Errorf("Segment %d differs from the original %s", i, restored.Seal)
+		}
+	}
+	sealReport, err := VerifySeals(restoredPath, sealPath(restoredPath), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sealReport.Valid || sealReport.Sealed != len(seals) {
+		t.Errorf("Expected the restored WAL to verify against its seals, got %+v", sealReport)
+	}
+
+	// A restore never overwrites a WAL
+	if _, err := Restore(restoredPath, Replica{Name: "backend", Read: replica(t, held)}, opts...); err == nil {
+		t.Error("Expected an existing WAL to be refused")
+	}
+
+	// A lost event breaks the chain from its segment on
+	missing := append(append([]*core.LogEvent{}, events[:20]...), events[21:]...)
+	report, err = Restore(filepath.Join(dir, "missing", "audit.wal"), Replica{Name: "backend", Read: replica(t, missing)}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Verified || len(report.Errors) == 0 || !report.Checks[0].Matched {
+		t.Errorf("Expected the checks after the lost event to fail, got %+v", report)
+	}
+	for _, check := range report.Checks {
+		if check.Matched == (check.Sequence >= 21) {
+			t.Errorf("Expected only checks covering record 21 on to fail, got %+v", check)
+		}
+	}
+}
+
+func TestRestoreStampedEvents(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(filepath.Join(dir, "original", "audit.wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two records of identical events, as a retried write can leave
+	event := compressibleEvent(1)
+	var held []*core.LogEvent
+	for i := 0; i < 3; i++ {
+		seq, err := w.Append(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stamped, err := StampEvent(event, seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		held = append(held, stamped)
+	}
+	head, _, err := w.SyncedHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The backend holds record 2 twice
+	held = append(held, held[1])
+	report, err := Restore(filepath.Join(dir, "restored", "audit.wal"), Replica{Name: "backend", Read: replica(t, held)},
+		WithRestoreRange(event.Timestamp, event.Timestamp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Events != 3 || report.Duplicates != 1 || report.LastSeq != head {
+		t.Errorf("Expected 3 distinct records and one duplicate, got %+v", report)
+	}
+}
+
+func TestRestoreKeepsStampedSequences(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(filepath.Join(dir, "original", "audit.wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record 2 is timestamped after the others, so it is read last
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var held []*core.LogEvent
+	for i := 1; i <= 8; i++ {
+		event := &core.LogEvent{
+			Timestamp:       base.Add(time.Duration(i) * time.Minute),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Restored event {Index}",
+			Properties:      map[string]interface{}{"Index": i},
+		}
+		if i == 2 {
+			event.Timestamp = base.Add(3 * time.Hour)
+		}
+		seq, err := w.Append(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stamped, err := StampEvent(event, seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The backend lost records 5 and 6
+		if seq != 5 && seq != 6 {
+			held = append(held, stamped)
+		}
+	}
+	original, err := w.RecordHash(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restoredPath := filepath.Join(dir, "restored", "audit.wal")
+	report, err := Restore(restoredPath, Replica{Name: "backend", Read: replica(t, held)},
+		WithRestoreRange(base, base.Add(4*time.Hour)), WithRestoreWindow(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Events != 6 || report.FirstSeq != 1 || report.LastSeq != 8 {
+		t.Errorf("Expected records 1-8 less two restored, got %+v", report)
+	}
+	if len(report.Gaps) != 1 || report.Gaps[0] != (SequenceRange{First: 5, Last: 6}) {
+		t.Errorf("Expected a gap at 5-6, got %+v", report.Gaps)
+	}
+	if report.Verified || len(report.Errors) != 1 {
+		t.Errorf("Expected the gap reported as an error, got %+v", report.Errors)
+	}
+
+	// Records before the gap chain exactly as the original did
+	restored, err := New(restoredPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = restored.Close() }()
+	hash, err := restored.RecordHash(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != original {
+		t.Error("Expected record 4 to match the original")
+	}
+	if _, err := restored.RecordHash(5); err == nil {
+		t.Error("Expected no record at the gap")
+	}
+	if restored.LastSequence() != 8 {
+		t.Errorf("Expected the restored WAL to end at 8, got %d", restored.LastSequence())
+	}
+}
@@ -0,0 +1,518 @@
+package wal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/willibrandon/mtlog/core"
+)
+
+const (
+	// scrubChunkSize is how much of a segment a scrub reads at a time.
+	scrubChunkSize = 64 * 1024
+	// scrubSourceMargin widens the time range asked of an EventSource around
+	// a damaged span, so events written out of timestamp order are included.
+	scrubSourceMargin = time.Minute
+)
+
+// EventSource returns copies of the events held outside the WAL with
+// timestamps between start and end, in the order they were written, such as
+// a backend's replica. backends.Backend's Read is an EventSource.
+type EventSource func(start, end time.Time) ([]*core.LogEvent, error)
+
+// ScrubReport describes a scrub of one sealed segment.
+type ScrubReport struct {
+	Segment string
+	// Errors are repair attempts that failed; the scrub itself went on.
+	Errors []error
+	// Bytes is the number of bytes read from disk.
+	Bytes   int64
+	Records int
+	// DamagedRecords failed their checksums or could not be read.
+	DamagedRecords int
+	// ChainBreaks are intact records that don't chain to the one before.
+	ChainBreaks     int
+	RepairedBlocks  int
+	RepairedRecords int
+	// Intact reports whether the segment is whole, either as found or after
+	// a repair.
+	Intact bool
+}
+
+// Damaged reports whether the scrub found any damage.
+func (r *ScrubReport) Damaged() bool {
+	return r.DamagedRecords > 0 || r.ChainBreaks > 0
+}
+
+// scrubPiece is an intact record of a scanned segment, or a gap left by
+// damaged ones when record is nil. A broken gap marks intact records that
+// don't chain rather than damage.
+type scrubPiece struct {
+	record *Record
+	data   []byte
+	broken bool
+}
+
+// ScrubSegment re-reads a sealed segment at no more than bytesPerSecond, or
+// unthrottled when it is 0, checking every record's checksums and the hash
+// chain between them. Damage is repaired from the segment's parity sidecar
+// when it has one, otherwise from the first source that holds the damaged
+// events. A rebuilt record must chain exactly to the intact records around
+// it, so a source can never change what the WAL says. Records of encrypted
+// WALs cannot be rebuilt from sources.
+func (w *WAL) ScrubSegment(ctx context.Context, path string, bytesPerSecond int64, sources ...EventSource) (*ScrubReport, error) {
+	segments := w.SegmentsSnapshot()
+	index := -1
+	for i := range segments {
+		if segments[i].Path == path {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("segment %s not found", path)
+	}
+	if index == len(segments)-1 {
+		return nil, fmt.Errorf("segment %s is active", path)
+	}
+
+	report := &ScrubReport{Segment: path}
+	pieces, compressed, err := scanSegment(ctx, &segments[index], bytesPerSecond, report)
+	if err != nil {
+		return report, err
+	}
+	if !report.Damaged() {
+		report.Intact = true
+		return report, nil
+	}
+
+	// Keep background compression from replacing the repaired segment
+	w.rewriting.Lock()
+	defer w.rewriting.Unlock()
+
+	if fileExists(ParityPath(path)) {
+		parity, err := RepairSegment(path)
+		switch {
+		case err != nil:
+			report.Errors = append(report.Errors, fmt.Errorf("parity: %w", err))
+		case parity.RepairedBlocks > 0:
+			report.RepairedBlocks = parity.RepairedBlocks
+			// The repair matches the segment as sealed; make sure of it
+			rescan := &ScrubReport{}
+			if pieces, compressed, err = scanSegment(ctx, &segments[index], bytesPerSecond, rescan); err != nil {
+				return report, err
+			}
+			if !rescan.Damaged() {
+				report.Intact = true
+				return report, nil
+			}
+		}
+	}
+
+	if len(sources) == 0 {
+		return report, nil
+	}
+	if w.keys != nil {
+		report.Errors = append(report.Errors, errors.New("encrypted records cannot be rebuilt from sources"))
+		return report, nil
+	}
+
+	neighbours := &scrubNeighbours{segments: segments, index: index}
+	for i := range pieces {
+		if pieces[i].record != nil {
+			continue
+		}
+		before, after, err := neighbours.anchors(pieces, i)
+		if err == nil {
+			pieces[i].data, _, err = rebuildGap(before, after, sources)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, err)
+			return report, nil
+		}
+	}
+
+	var data []byte
+	for _, piece := range pieces {
+		data = append(data, piece.data...)
+		if piece.record == nil {
+			report.RepairedRecords += countRecords(piece.data)
+		}
+	}
+	if err := replaceSegment(path, data, compressed); err != nil {
+		report.Errors = append(report.Errors, err)
+		report.RepairedRecords = 0
+		return report, nil
+	}
+	report.Intact = true
+	return report, nil
+}
+
+// scanSegment reads a segment and splits it into intact records and the
+// gaps between them, counting the damage into report.
+func scanSegment(ctx context.Context, segment *Segment, bytesPerSecond int64, report *ScrubReport) ([]scrubPiece, bool, error) {
+	stored, err := readThrottled(ctx, segment.Path, bytesPerSecond, report)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, err
+		}
+		// An unreadable segment is lost whole, as far as the scrub can tell
+		report.DamagedRecords += gapSize(nil, nil, segment)
+		return []scrubPiece{{}}, false, nil
+	}
+
+	var data []byte
+	file, err := openSegmentData(stored, segment.Path, true)
+	if err == nil {
+		data, err = io.ReadAll(file)
+		_ = file.Close()
+	}
+	if err != nil {
+		report.DamagedRecords += gapSize(nil, nil, segment)
+		return []scrubPiece{{}}, hasZstdMagic(bytes.NewReader(stored)), nil
+	}
+	compressed := hasZstdMagic(bytes.NewReader(stored))
+
+	var pieces []scrubPiece
+	gap := func() {
+		if len(pieces) == 0 || pieces[len(pieces)-1].record != nil {
+			pieces = append(pieces, scrubPiece{})
+		}
+	}
+
+	engine := NewRecoveryEngine(segment.Path)
+	scan := memorySegment{Reader: bytes.NewReader(data)}
+	var last *Record
+	offset := 0
+	for offset < len(data) {
+		record, size, err := UnmarshalRecordFromBytes(data[offset:])
+		if err != nil {
+			gap()
+			skip := engine.findNextRecord(scan, int64(offset))
+			if skip == 0 {
+				break
+			}
+			offset += int(skip)
+			continue
+		}
+
+		switch {
+		case last == nil && segment.StartSeq > 0 && record.Sequence > segment.StartSeq:
+			gap()
+		case last != nil && record.Sequence != last.Sequence+1:
+			gap()
+		case last != nil && pieces[len(pieces)-1].record != nil && record.PrevHash != last.ComputeHash():
+			report.ChainBreaks++
+			pieces = append(pieces, scrubPiece{broken: true})
+		}
+
+		pieces = append(pieces, scrubPiece{record: record, data: data[offset : offset+size]})
+		report.Records++
+		last = record
+		offset += size
+	}
+
+	// Records lost from the end; only the active segment may be empty
+	switch {
+	case last == nil && len(data) == 0 && !segment.Sealed:
+	case last == nil, segment.EndSeq > last.Sequence:
+		gap()
+	}
+
+	for i, piece := range pieces {
+		if piece.record != nil || piece.broken {
+			continue
+		}
+		var before, after *Record
+		if i > 0 {
+			before = pieces[i-1].record
+		}
+		if i < len(pieces)-1 {
+			after = pieces[i+1].record
+		}
+		report.DamagedRecords += gapSize(before, after, segment)
+	}
+	return pieces, compressed, nil
+}
+
+// gapSize estimates the number of records lost between before and after,
+// either of which may be nil, within segment. Spans without a known extent
+// count as one record.
+func gapSize(before, after *Record, segment *Segment) int {
+	first, last := segment.StartSeq, segment.EndSeq
+	if before != nil {
+		first = before.Sequence + 1
+	}
+	if after != nil {
+		last = after.Sequence - 1
+	}
+	if first == 0 || last < first {
+		return 1
+	}
+	// #nosec G115 - bounded by the records in one segment
+	return int(last - first + 1)
+}
+
+// readThrottled reads the file at path at no more than bytesPerSecond, or
+// as fast as it can when bytesPerSecond is 0.
+func readThrottled(ctx context.Context, path string, bytesPerSecond int64, report *ScrubReport) ([]byte, error) {
+	file, err := os.Open(path) // #nosec G304 - controlled segment path
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var data bytes.Buffer
+	chunk := make([]byte, scrubChunkSize)
+	started := time.Now()
+	for {
+		n, err := file.Read(chunk)
+		data.Write(chunk[:n])
+		report.Bytes += int64(n)
+		if err == io.EOF {
+			return data.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if bytesPerSecond > 0 {
+			due := started.Add(time.Duration(float64(report.Bytes) / float64(bytesPerSecond) * float64(time.Second)))
+			timer := time.NewTimer(time.Until(due))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// scrubNeighbours finds the records either side of a scrubbed segment, for
+// damage at its edges. When the neighbouring segments were scanned too their
+// intact records are used; otherwise they are read from disk.
+type scrubNeighbours struct {
+	segments []Segment
+	scans    [][]scrubPiece
+	index    int
+}
+
+// before returns the last record of the previous segment, or nil for the
+// first segment.
+func (n *scrubNeighbours) before() (*Record, error) {
+	if n.index == 0 {
+		// The chain starts from a zero hash at the first record
+		if n.segments[0].StartSeq > 1 {
+			return nil, fmt.Errorf("records before segment %s were removed", n.segments[0].Path)
+		}
+		return nil, nil
+	}
+	if n.scans != nil {
+		pieces := n.scans[n.index-1]
+		if len(pieces) == 0 || pieces[len(pieces)-1].record == nil {
+			return nil, fmt.Errorf("damage continues from the segment before %s", n.segments[n.index].Path)
+		}
+		return pieces[len(pieces)-1].record, nil
+	}
+
+	records, err := (&SegmentManager{}).readSegment(n.segments[n.index-1].Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the segment before %s: %w", n.segments[n.index].Path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no record before segment %s", n.segments[n.index].Path)
+	}
+	return UnmarshalRecord(records[len(records)-1])
+}
+
+// after returns the first record of the next segment.
+func (n *scrubNeighbours) after() (*Record, error) {
+	if n.index == len(n.segments)-1 {
+		return nil, fmt.Errorf("no record after segment %s to verify a repair against", n.segments[n.index].Path)
+	}
+	if n.scans != nil {
+		pieces := n.scans[n.index+1]
+		if len(pieces) == 0 || pieces[0].record == nil {
+			return nil, fmt.Errorf("damage continues into the segment after %s", n.segments[n.index].Path)
+		}
+		return pieces[0].record, nil
+	}
+
+	records, err := (&SegmentManager{}).readSegment(n.segments[n.index+1].Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the segment after %s: %w", n.segments[n.index].Path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no record after segment %s to verify a repair against", n.segments[n.index].Path)
+	}
+	return UnmarshalRecord(records[0])
+}
+
+// anchors returns the intact records either side of the gap at pieces[i],
+// looking into the neighbouring segments at the segment's edges. before is
+// nil at the start of the WAL.
+func (n *scrubNeighbours) anchors(pieces []scrubPiece, i int) (before, after *Record, err error) {
+	if pieces[i].broken {
+		return nil, nil, errors.New("intact records that don't chain can't be rebuilt")
+	}
+	if i > 0 {
+		before = pieces[i-1].record
+	} else if before, err = n.before(); err != nil {
+		return nil, nil, err
+	}
+	if i < len(pieces)-1 {
+		after = pieces[i+1].record
+	} else if after, err = n.after(); err != nil {
+		return nil, nil, err
+	}
+	if (i > 0 && before == nil) || after == nil {
+		return nil, nil, errors.New("records next to one another that don't chain can't be rebuilt")
+	}
+	return before, after, nil
+}
+
+// rebuildGap rebuilds the records between before and after, either side of
+// a gap, from the first source holding them and returns their bytes and the
+// index of that source. A nil before is the start of the WAL. The events of
+// before or after locate the gap in a source; the rebuilt records must chain
+// from before to after exactly.
+func rebuildGap(before, after *Record, sources []EventSource) ([]byte, int, error) {
+	first, prevHash := uint64(1), [32]byte{}
+	start := time.Time{}
+	if before != nil {
+		first, prevHash = before.Sequence+1, before.ComputeHash()
+		start = time.Unix(0, before.Timestamp).Add(-scrubSourceMargin)
+	}
+	if after.IsEncrypted() || (before != nil && before.IsEncrypted()) {
+		return nil, -1, errors.New("encrypted records cannot be rebuilt from sources")
+	}
+	if after.Sequence <= first {
+		return nil, -1, fmt.Errorf("records %d-%d don't chain and can't be rebuilt", first-1, after.Sequence)
+	}
+	count := int(after.Sequence - first) // #nosec G115 - bounded by the records in one segment
+	end := time.Unix(0, after.Timestamp).Add(scrubSourceMargin)
+
+	for i, source := range sources {
+		events, err := source(start, end)
+		if err != nil {
+			continue
+		}
+		events, _ = unstampEvents(events)
+		if data := matchGap(events, before, after, first, count, prevHash); data != nil {
+			return data, i, nil
+		}
+	}
+	return nil, -1, fmt.Errorf("no source holds records %d-%d", first, after.Sequence-1)
+}
+
+// matchGap finds count events in events that rebuild into records chaining
+// from prevHash to after, anchored on the events of before or after. The
+// records are compressed as the anchor was, which the WAL doesn't record, so
+// each compression is tried in turn.
+func matchGap(events []*core.LogEvent, before, after *Record, first uint64, count int, prevHash [32]byte) []byte {
+	for _, compression := range []Compression{CompressionNone, CompressionZstd, CompressionSnappy} {
+		var starts []int
+		if before != nil && !before.IsMetadata() {
+			hash := before.ComputeHash()
+			for i, event := range events {
+				record, err := rebuildRecord(event, before, before.Sequence, before.PrevHash, compression)
+				if err == nil && record.ComputeHash() == hash {
+					starts = append(starts, i+1)
+				}
+			}
+		}
+		if len(starts) == 0 && !after.IsMetadata() {
+			hash := after.ComputeHash()
+			for i, event := range events {
+				record, err := rebuildRecord(event, after, after.Sequence, after.PrevHash, compression)
+				if err == nil && record.ComputeHash() == hash {
+					starts = append(starts, i-count)
+				}
+			}
+		}
+
+		for _, start := range starts {
+			if start < 0 || start+count > len(events) {
+				continue
+			}
+			var data []byte
+			hash := prevHash
+			for k, event := range events[start : start+count] {
+				record, err := rebuildRecord(event, after, first+uint64(k), hash, compression) // #nosec G115 - k is non-negative
+				if err != nil {
+					break
+				}
+				marshaled, err := record.Marshal()
+				if err != nil {
+					break
+				}
+				data = append(data, marshaled...)
+				hash = record.ComputeHash()
+			}
+			if hash == after.PrevHash {
+				return data
+			}
+		}
+	}
+	return nil
+}
+
+// rebuildRecord builds the record for event at sequence in the format and
+// codec of like, compressed with compression.
+func rebuildRecord(event *core.LogEvent, like *Record, sequence uint64, prevHash [32]byte, compression Compression) (*Record, error) {
+	codec, err := codecOf(like.Version)
+	if err != nil {
+		return nil, err
+	}
+	record, err := newEventRecord(event, codec, sequence, prevHash)
+	if err != nil {
+		return nil, err
+	}
+	record.Flags |= like.Format().flags()
+	if compression != CompressionNone {
+		if err := record.Compress(compression); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// countRecords counts the records in data, which holds whole records.
+func countRecords(data []byte) int {
+	count := 0
+	for len(data) > 0 {
+		_, size, err := UnmarshalRecordFromBytes(data)
+		if err != nil {
+			break
+		}
+		data = data[size:]
+		count++
+	}
+	return count
+}
+
+// replaceSegment atomically replaces the segment at path with data,
+// compressing it again if it was compressed, and refreshes its parity.
+func replaceSegment(path string, data []byte, compressed bool) error {
+	tempPath := path + ".scrub.tmp"
+	if err := writeSyncedFile(tempPath, data); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write repaired segment: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace segment: %w", err)
+	}
+	if compressed {
+		return CompressSegment(path)
+	}
+	return refreshParity(path)
+}
@@ -0,0 +1,265 @@
+package wal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog/core"
+)
+
+// replica returns an EventSource holding copies of events as a backend
+// stores them.
+func replica(t *testing.T, events []*core.LogEvent) EventSource {
+	t.Helper()
+	var copies []*core.LogEvent
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored core.LogEvent
+		if err := json.Unmarshal(data, &stored); err != nil {
+			t.Fatal(err)
+		}
+		copies = append(copies, &stored)
+	}
+	return func(start, end time.Time) ([]*core.LogEvent, error) {
+		var found []*core.LogEvent
+		for _, event := range copies {
+			if event.Timestamp.After(start) && event.Timestamp.Before(end) {
+				found = append(found, event)
+			}
+		}
+		return found, nil
+	}
+}
+
+// replicatedEvent returns an event holding the property types a record must
+// be rebuilt with exactly from a backend's JSON copy.
+func replicatedEvent(i int) *core.LogEvent {
+	event := compressibleEvent(i)
+	event.Properties["Address"] = strings.Repeat("221B Baker Street, London ", 2)
+	for key, value := range typedEvent(i).Properties {
+		event.Properties[key] = value
+	}
+	return event
+}
+
+// forEachCodec runs test once per codec.
+func forEachCodec(t *testing.T, test func(t *testing.T, codec Codec)) {
+	for _, codec := range []Codec{CodecJSON, CodecBinary} {
+		t.Run(codec.String(), func(t *testing.T) { test(t, codec) })
+	}
+}
+
+func TestScrubSegment(t *testing.T) {
+	forEachCodec(t, testScrubSegment)
+}
+
+func testScrubSegment(t *testing.T, codec Codec) {
+	walPath := filepath.Join(t.TempDir(), "scrub.wal")
+	w, err := New(walPath, WithSegmentSize(4096), WithCodec(codec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+
+	events := make([]*core.LogEvent, 40)
+	for i := range events {
+		events[i] = replicatedEvent(i)
+		if err := w.Write(events[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segments := w.SegmentsSnapshot()
+	if len(segments) < 3 {
+		t.Fatalf("Expected the WAL to rotate, got %d segment(s)", len(segments))
+	}
+	ctx := context.Background()
+
+	// Intact segments read at the requested rate
+	stat, err := os.Stat(segments[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	report, err := w.ScrubSegment(ctx, segments[0].Path, 8*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Intact || report.Damaged() || report.Bytes != stat.Size() {
+		t.Errorf("Expected an intact segment, got %+v", report)
+	}
+	if elapsed := time.Since(started); elapsed < time.Duration(stat.Size())*time.Second/(8*1024)/2 {
+		t.Errorf("Expected a throttled read, took %v", elapsed)
+	}
+	if _, err := w.ScrubSegment(ctx, segments[len(segments)-1].Path, 0); err == nil {
+		t.Error("Expected the active segment to be refused")
+	}
+
+	for _, offset := range []int{0, 1000} {
+		path := segments[1].Path
+		original, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		damaged := bytes.Clone(original)
+		damaged[offset+40] ^= 0xFF
+		if err := os.WriteFile(path, damaged, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		// Without a source the damage is only reported
+		report, err := w.ScrubSegment(ctx, path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Intact || report.DamagedRecords != 1 {
+			t.Fatalf("Offset %d: expected one damaged record, got %+v", offset, report)
+		}
+
+		// A source missing the events can't repair it
+		report, err = w.ScrubSegment(ctx, path, 0, replica(t, events[:5]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Intact || len(report.Errors) == 0 {
+			t.Errorf("Offset %d: expected a failed repair, got %+v", offset, report)
+		}
+
+		// A replica rebuilds the record exactly
+		report, err = w.ScrubSegment(ctx, path, 0, replica(t, events))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Intact || report.RepairedRecords != 1 {
+			t.Fatalf("Offset %d: expected a repaired record, got %+v", offset, report)
+		}
+		if repaired, _ := os.ReadFile(path); !bytes.Equal(repaired, original) {
+			t.Errorf("Offset %d: repaired segment differs from the original", offset)
+		}
+	}
+
+	if err := w.VerifyIntegrity(); err != nil {
+		t.Errorf("Integrity check failed: %v", err)
+	}
+}
+
+func TestScrubSegmentParity(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "parity.wal")
+	writeParityWAL(t, walPath, WithSegmentCompression())
+
+	w, err := New(walPath, WithSegmentSize(4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+
+	segment := w.SegmentsSnapshot()[0].Path
+	original, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	damageBlocks(t, segment, 0)
+
+	// Parity comes first and needs no source
+	report, err := w.ScrubSegment(context.Background(), segment, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Damaged() || !report.Intact || report.RepairedBlocks != 1 {
+		t.Fatalf("Expected a repair from parity, got %+v", report)
+	}
+	if repaired, _ := os.ReadFile(segment); !bytes.Equal(repaired, original) {
+		t.Error("Repaired segment differs from the original")
+	}
+}
+
+func TestRepairFromReplicas(t *testing.T) {
+	forEachCodec(t, testRepairFromReplicas)
+}
+
+func testRepairFromReplicas(t *testing.T, codec Codec) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "damaged.wal")
+	w, err := New(walPath, WithSegmentSize(4096), WithCodec(codec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make([]*core.LogEvent, 40)
+	for i := range events {
+		events[i] = replicatedEvent(i)
+		if err := w.Write(events[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segments := w.SegmentsSnapshot()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	originals := make(map[string][]byte)
+	for _, segment := range segments {
+		data, err := os.ReadFile(segment.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		originals[filepath.Base(segment.Path)] = data
+	}
+
+	// Damage a record inside the first segment and the first record of the
+	// second, which also hides where that segment starts
+	for _, damage := range []struct {
+		path   string
+		offset int
+	}{{segments[0].Path, 1000}, {segments[1].Path, 40}} {
+		data := bytes.Clone(originals[filepath.Base(damage.path)])
+		data[damage.offset] ^= 0xFF
+		if err := os.WriteFile(damage.path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A replica without the events can't rebuild them
+	report, err := RepairFromReplicas(walPath, filepath.Join(dir, "partial"), Replica{Name: "partial", Read: replica(t, events[:2])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Complete() || report.RebuiltRecords != 0 || len(report.Ranges) != 2 {
+		t.Errorf("Expected two unrepaired ranges, got %+v", report)
+	}
+
+	output := filepath.Join(dir, "repaired")
+	report, err = RepairFromReplicas(walPath, output,
+		Replica{Name: "empty", Read: replica(t, nil)},
+		Replica{Name: "backend", Read: replica(t, events)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Complete() || report.RebuiltRecords != 2 || report.RepairedSegments != 2 {
+		t.Fatalf("Expected two rebuilt records, got %+v", report)
+	}
+	for _, rebuilt := range report.Ranges {
+		if rebuilt.Source != "backend" || rebuilt.First != rebuilt.Last || rebuilt.First == 0 {
+			t.Errorf("Expected one record from the backend, got %+v", rebuilt)
+		}
+	}
+
+	// The repaired WAL is the original, byte for byte
+	for name, original := range originals {
+		repaired, err := os.ReadFile(filepath.Join(output, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(repaired, original) {
+			t.Errorf("Segment %s differs from the original", name)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+)
+
+// SealReport describes how a WAL's segments match their seals.
+type SealReport struct {
+	Error  string
+	Seals  int
+	Sealed int
+	// Unsealed counts completed segments without a seal.
+	Unsealed int
+	// Resealed counts segments sealed more than once.
+	Resealed int
+	// Missing counts sealed segments that no longer exist.
+	Missing int
+	// Invalid counts seals that do not verify or no longer match their
+	// segment.
+	Invalid int
+	Valid   bool
+}
+
+// timeRange is the earliest and latest record timestamp in a segment, in
+// Unix nanoseconds.
+type timeRange struct {
+	start, end int64
+	set        bool
+}
+
+func (r *timeRange) add(timestamp int64) {
+	if !r.set || timestamp < r.start {
+		r.start = timestamp
+	}
+	if !r.set || timestamp > r.end {
+		r.end = timestamp
+	}
+	r.set = true
+}
+
+// WithSealing appends a seal signed by signer to <wal>.seals whenever the WAL
+// rotates away from a segment. Segments completed before sealing was enabled,
+// or whose seal was lost, are never sealed later; they are reported as
+// unsealed by VerifySeals and VerifyIntegrityReport.
+func WithSealing(signer compliance.Signer) Option {
+	return func(c *config) error {
+		if signer == nil {
+			return fmt.Errorf("signer cannot be nil")
+		}
+		c.sealer = signer
+		return nil
+	}
+}
+
+// sealPath returns the path of the WAL's seal log.
+func sealPath(walPath string) string {
+	return walPath + ".seals"
+}
+
+// activeSeal describes the active segment from the WAL's running state. The
+// caller must hold w.mu.
+func (w *WAL) activeSeal() *compliance.SegmentSeal {
+	size := w.segmentMerkle.Size()
+	return &compliance.SegmentSeal{
+		Segment:    filepath.Base(w.segments.GetActivePath()),
+		FirstSeq:   w.sequence - size + 1,
+		LastSeq:    w.sequence,
+		Records:    size,
+		StartTime:  time.Unix(0, w.segmentTimes.start).UTC(),
+		EndTime:    time.Unix(0, w.segmentTimes.end).UTC(),
+		LastHash:   hexHash(w.lastHash),
+		MerkleRoot: hexHash(w.segmentMerkle.Root()),
+	}
+}
+
+// appendSeal signs seal and appends it to the seal log.
+func (w *WAL) appendSeal(seal *compliance.SegmentSeal) error {
+	seal.SealedAt = time.Now().UTC()
+	if err := seal.Sign(w.sealer); err != nil {
+		return err
+	}
+	return compliance.AppendSeal(sealPath(w.path), seal)
+}
+
+// checkSealed warns about completed segments the seal log does not cover.
+// They are left unsealed: a seal made now would vouch for whatever the
+// segment holds, so verification reports them instead.
+func (w *WAL) checkSealed() error {
+	seals, err := compliance.ReadSeals(sealPath(w.path))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	sealed := make(map[string]bool, len(seals))
+	for _, seal := range seals {
+		sealed[seal.Segment] = true
+	}
+
+	unsealed := 0
+	activePath := w.segments.GetActivePath()
+	for _, segment := range w.segments.GetSegments() {
+		if segment.Path != activePath && !sealed[filepath.Base(segment.Path)] && fileExists(segment.Path) {
+			unsealed++
+		}
+	}
+	if unsealed > 0 {
+		logger.Log.Warn("{count} completed segments of {path} are not in its seal log", unsealed, w.path)
+	}
+	return nil
+}
+
+// readSeal describes a segment's contents on disk as an unsigned seal,
+// skipping records that don't decode and returning how many there were.
+func readSeal(segments *SegmentManager, path string) (*compliance.SegmentSeal, int, error) {
+	records, err := segments.readSegment(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read segment %s: %w", path, err)
+	}
+
+	seal := &compliance.SegmentSeal{Segment: filepath.Base(path)}
+	tree := compliance.NewMerkleTree()
+	var (
+		times   timeRange
+		damaged int
+	)
+	for _, data := range records {
+		record, err := UnmarshalRecord(data)
+		if err != nil {
+			damaged++
+			continue
+		}
+		if record.IsGenesis() {
+			continue
+		}
+		hash := record.ComputeHash()
+		tree.Append(recordLeaf(hash))
+		times.add(record.Timestamp)
+		if seal.Records == 0 {
+			seal.FirstSeq = record.Sequence
+		}
+		seal.Records++
+		seal.LastSeq = record.Sequence
+		seal.LastHash = hexHash(hash)
+	}
+	seal.StartTime = time.Unix(0, times.start).UTC()
+	seal.EndTime = time.Unix(0, times.end).UTC()
+	seal.MerkleRoot = hexHash(tree.Root())
+	return seal, damaged, nil
+}
+
+// VerifySeals checks the WAL at walPath against its seal log at path. Every
+// completed segment must have exactly one seal that matches its contents,
+// and every seal must have its segment. When verifier is non-nil the seal
+// signatures are checked too.
+func VerifySeals(walPath, path string, verifier compliance.Signer) (*SealReport, error) {
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	return verifySeals(segments, path, verifier)
+}
+
+func verifySeals(segments *SegmentManager, path string, verifier compliance.Signer) (*SealReport, error) {
+	report := &SealReport{}
+	seals, err := compliance.ReadSeals(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		report.Error = err.Error()
+	}
+	report.Seals = len(seals)
+
+	byStart := make(map[uint64][]*compliance.SegmentSeal)
+	for _, seal := range seals {
+		byStart[seal.FirstSeq] = append(byStart[seal.FirstSeq], seal)
+	}
+
+	activePath := segments.GetActivePath()
+	for _, segment := range segments.GetSegments() {
+		if !fileExists(segment.Path) {
+			continue
+		}
+		actual, damaged, err := readSeal(segments, segment.Path)
+		if err != nil || damaged > 0 {
+			// A segment that can't be read can't match its seal
+			report.Invalid++
+			if err != nil {
+				report.noteError("%v", err)
+			} else {
+				report.noteError("segment %s holds %d damaged records", filepath.Base(segment.Path), damaged)
+			}
+			for start, matching := range byStart {
+				if (actual != nil && start == actual.FirstSeq) || matching[0].Segment == filepath.Base(segment.Path) {
+					delete(byStart, start)
+				}
+			}
+			continue
+		}
+		if actual.Records == 0 {
+			continue
+		}
+
+		matching := byStart[actual.FirstSeq]
+		delete(byStart, actual.FirstSeq)
+		switch {
+		case len(matching) == 0:
+			// The active segment is still being written
+			if segment.Path != activePath {
+				report.Unsealed++
+				report.noteError("segment %s is not sealed", actual.Segment)
+			}
+			continue
+		case len(matching) > 1:
+			report.Resealed++
+			report.noteError("segment %s was sealed %d times", actual.Segment, len(matching))
+		}
+
+		valid := true
+		for _, seal := range matching {
+			if verifier != nil {
+				if err := seal.Verify(verifier); err != nil {
+					valid = false
+					report.noteError("%v", err)
+					continue
+				}
+			}
+			if !seal.Matches(actual) {
+				valid = false
+				report.noteError("segment %s no longer matches its seal", actual.Segment)
+			}
+		}
+		if !valid {
+			report.Invalid++
+		} else if len(matching) == 1 {
+			report.Sealed++
+		}
+	}
+
+	for _, orphaned := range byStart {
+		report.Missing++
+		report.noteError("sealed segment %s is missing", orphaned[0].Segment)
+	}
+
+	report.Valid = report.Error == "" && report.Unsealed == 0 && report.Resealed == 0 &&
+		report.Missing == 0 && report.Invalid == 0
+	return report, nil
+}
+
+// noteError records the first problem found.
+func (r *SealReport) noteError(format string, args ...interface{}) {
+	if r.Error == "" {
+		r.Error = fmt.Sprintf(format, args...)
+	}
+}
@@ -0,0 +1,202 @@
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+)
+
+func TestWALSealing(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "sealed.wal")
+	sealsPath := walPath + ".seals"
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(walPath, WithSegmentSize(1024), WithSealing(signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 24; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	integrity, err := w.VerifyIntegrityReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !integrity.Valid || integrity.SealedSegments < 3 {
+		t.Errorf("Expected a valid report with sealed segments, got %+v", integrity)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := VerifySeals(walPath, sealsPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Sealed != report.Seals || report.Sealed != integrity.SealedSegments {
+		t.Fatalf("Expected every completed segment sealed once, got %+v", report)
+	}
+
+	seals, err := compliance.ReadSeals(sealsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var covered uint64
+	for _, seal := range seals {
+		if seal.FirstSeq != covered+1 || seal.Records != seal.LastSeq-seal.FirstSeq+1 || seal.StartTime.After(seal.EndTime) {
+			t.Errorf("Unexpected seal: %+v", seal)
+		}
+		covered = seal.LastSeq
+	}
+
+	// Seals only verify with the signer's key
+	other, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := VerifySeals(walPath, sealsPath, other); report.Valid || report.Invalid != report.Seals {
+		t.Errorf("Expected every seal to fail under another key, got %+v", report)
+	}
+
+	original, err := os.ReadFile(sealsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore := func() {
+		if err := os.WriteFile(sealsPath, original, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A segment sealed twice is flagged
+	resealed := seals[0]
+	if err := resealed.Sign(signer); err != nil {
+		t.Fatal(err)
+	}
+	if err := compliance.AppendSeal(sealsPath, resealed); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := VerifySeals(walPath, sealsPath, signer); report.Valid || report.Resealed != 1 {
+		t.Errorf("Expected a resealed segment, got %+v", report)
+	}
+	restore()
+
+	// Without the seal log every completed segment is unsealed
+	if err := os.Remove(sealsPath); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := VerifySeals(walPath, sealsPath, signer); report.Valid || report.Unsealed != len(seals) {
+		t.Errorf("Expected %d unsealed segments, got %+v", len(seals), report)
+	}
+	restore()
+
+	// A truncated segment no longer matches its seal
+	truncated := filepath.Join(filepath.Dir(walPath), seals[1].Segment)
+	records, err := dropLastRecord(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(truncated, records, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := VerifySeals(walPath, sealsPath, signer); report.Valid || report.Invalid != 1 {
+		t.Errorf("Expected a truncated segment to fail its seal, got %+v", report)
+	}
+
+	// A damaged record, here the one that starts its segment, fails only
+	// that segment's seal
+	damaged := filepath.Join(filepath.Dir(walPath), seals[2].Segment)
+	data, err := os.ReadFile(damaged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[40] ^= 0xFF
+	if err := os.WriteFile(damaged, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	report, err = VerifySeals(walPath, sealsPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Invalid != 2 || report.Missing != 0 || report.Sealed != len(seals)-2 {
+		t.Errorf("Expected a damaged segment to fail its seal, got %+v", report)
+	}
+
+	// A removed segment leaves its seal behind
+	if err := os.Remove(filepath.Join(filepath.Dir(walPath), seals[0].Segment)); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := VerifySeals(walPath, sealsPath, signer); report.Valid || report.Missing != 1 {
+		t.Errorf("Expected a missing segment, got %+v", report)
+	}
+}
+
+func TestWALSealingLeavesEarlierSegmentsUnsealed(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "sealed.wal")
+	sealsPath := walPath + ".seals"
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Segments completed before sealing was enabled, or whose seals were
+	// lost, could have been rewritten; they are reported, not sealed
+	w, err := New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	completed := len(w.GetSegments()) - 1
+	if completed < 1 {
+		t.Fatalf("Expected the WAL to rotate, got %d segment(s)", completed+1)
+	}
+
+	w, err = New(walPath, WithSegmentSize(1024), WithSealing(signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+	if _, err := os.Stat(sealsPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no seals written on open, got %v", err)
+	}
+	integrity, err := w.VerifyIntegrityReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if integrity.Valid || integrity.UnsealedSegments != completed {
+		t.Errorf("Expected %d unsealed segments reported, got %+v", completed, integrity)
+	}
+}
+
+// dropLastRecord returns the segment at path without its last record.
+func dropLastRecord(path string) ([]byte, error) {
+	segments, err := NewSegmentManager(path, 64*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	records, err := segments.readSegment(path)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for _, record := range records[:len(records)-1] {
+		data = append(data, record...)
+	}
+	return data, nil
+}
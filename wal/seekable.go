@@ -0,0 +1,481 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+)
+
+// Sealed segments can be rewritten in the zstd seekable format: the records
+// are compressed in independent zstd frames that end on record boundaries,
+// followed by a seek table in a skippable frame:
+//
+//	frame... + skippable magic(4) + table size(4) +
+//	  (compressed size(4) + decompressed size(4) + checksum(4))... +
+//	  frame count(4) + descriptor(1) + seekable magic(4)
+//
+// Checksums are the low 32 bits of the XXH64 of each frame's decompressed
+// bytes. A compressed segment keeps its name and decompresses to exactly the
+// bytes it held before, so record offsets, CRCs, the hash chain, seals and
+// Merkle roots are unchanged.
+const (
+	seekableFrameSize   = 256 * 1024
+	seekableFooterSize  = 9
+	seekableEntrySize   = 12
+	seekableChecksums   = 0x80
+	seekableMagic       = 0x8F92EAB1
+	seekTableFrameMagic = 0x184D2A5E
+	zstdFrameMagic      = 0xFD2FB528
+)
+
+// segmentFile is an open segment, compressed or not, read as its
+// uncompressed bytes.
+type segmentFile interface {
+	io.ReadSeekCloser
+	// Size returns the uncompressed size of the segment.
+	Size() int64
+}
+
+// rawSegment is an uncompressed segment file.
+type rawSegment struct {
+	*os.File
+	size int64
+}
+
+// Size returns the size of the segment file.
+func (s *rawSegment) Size() int64 {
+	return s.size
+}
+
+// seekFrame locates one compressed frame of a seekable segment.
+type seekFrame struct {
+	offset           int64
+	start            int64
+	compressedSize   uint32
+	decompressedSize uint32
+	checksum         uint32
+}
+
+// seekableSegment reads a compressed segment, decompressing frames as they
+// are reached.
+type seekableSegment struct {
+	file      io.ReaderAt
+	closer    io.Closer
+	frames    []seekFrame
+	cached    []byte
+	size      int64
+	pos       int64
+	cachedIdx int
+	checksums bool
+	lenient   bool
+}
+
+// memorySegment is a segment held in memory: rebuilt from parity, or
+// decompressed in full when a damaged seek table leaves no other way to read
+// it.
+type memorySegment struct {
+	*bytes.Reader
+}
+
+// Close does nothing.
+func (memorySegment) Close() error {
+	return nil
+}
+
+// Size returns the number of bytes recovered from the segment.
+func (s memorySegment) Size() int64 {
+	return s.Reader.Size()
+}
+
+// openSegment opens a segment for reading, decompressing it transparently.
+// In lenient mode, used by recovery, frames that fail to decompress read as
+// zeros and a damaged seek table falls back to decompressing what it can, so
+// the records around the damage can still be scanned.
+func openSegment(path string, lenient bool) (segmentFile, error) {
+	file, err := os.Open(path) // #nosec G304 - controlled path
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if !hasZstdMagic(file) {
+		return &rawSegment{File: file, size: stat.Size()}, nil
+	}
+
+	segment, err := decompressSegment(file, file, stat.Size(), path, lenient)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return segment, nil
+}
+
+// openSegmentData reads segment bytes already in memory, decompressing them
+// like openSegment.
+func openSegmentData(data []byte, name string, lenient bool) (segmentFile, error) {
+	stored := bytes.NewReader(data)
+	if !hasZstdMagic(stored) {
+		return memorySegment{Reader: stored}, nil
+	}
+	return decompressSegment(stored, nil, stored.Size(), name, lenient)
+}
+
+// decompressSegment reads a compressed segment through its seek table. On
+// success the segment owns closer, which may be nil.
+func decompressSegment(stored io.ReaderAt, closer io.Closer, size int64, name string, lenient bool) (segmentFile, error) {
+	segment, err := readSeekTable(stored, size)
+	if err == nil {
+		segment.closer = closer
+		segment.lenient = lenient
+		return segment, nil
+	}
+	if !lenient {
+		return nil, fmt.Errorf("failed to read seek table of %s: %w", name, err)
+	}
+
+	logger.Log.Warn("Seek table of {segment} is damaged, decompressing sequentially: {error}", name, err)
+	decoder, err := zstd.NewReader(io.NewSectionReader(stored, 0, size), zstd.WithDecoderMaxMemory(maxSegmentSize))
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	// Keep everything decoded before the damage
+	data, _ := io.ReadAll(decoder)
+	if closer != nil {
+		_ = closer.Close()
+	}
+	return memorySegment{Reader: bytes.NewReader(data)}, nil
+}
+
+// maxSegmentSize bounds the size a compressed segment may decompress to.
+const maxSegmentSize = 4 << 30
+
+// readSeekTable parses the seek table at the end of a seekable segment.
+func readSeekTable(file io.ReaderAt, fileSize int64) (*seekableSegment, error) {
+	if fileSize < seekableFooterSize+8 {
+		return nil, fmt.Errorf("file too small for a seek table")
+	}
+	footer := make([]byte, seekableFooterSize)
+	if _, err := file.ReadAt(footer, fileSize-seekableFooterSize); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic {
+		return nil, fmt.Errorf("missing seekable magic")
+	}
+	count := int64(binary.LittleEndian.Uint32(footer))
+	descriptor := footer[4]
+	if descriptor&^seekableChecksums != 0 {
+		return nil, fmt.Errorf("unsupported seek table descriptor %#x", descriptor)
+	}
+	entrySize := int64(8)
+	if descriptor&seekableChecksums != 0 {
+		entrySize = seekableEntrySize
+	}
+
+	tableSize := count*entrySize + seekableFooterSize
+	if tableSize+8 > fileSize {
+		return nil, fmt.Errorf("seek table of %d frames exceeds the file", count)
+	}
+	table := make([]byte, tableSize+8)
+	if _, err := file.ReadAt(table, fileSize-int64(len(table))); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(table) != seekTableFrameMagic ||
+		int64(binary.LittleEndian.Uint32(table[4:])) != tableSize {
+		return nil, fmt.Errorf("malformed seek table frame")
+	}
+
+	segment := &seekableSegment{
+		file:      file,
+		frames:    make([]seekFrame, count),
+		cachedIdx: -1,
+		checksums: descriptor&seekableChecksums != 0,
+	}
+	var offset int64
+	entries := table[8:]
+	for i := range segment.frames {
+		entry := entries[int64(i)*entrySize:]
+		frame := seekFrame{
+			offset:           offset,
+			start:            segment.size,
+			compressedSize:   binary.LittleEndian.Uint32(entry),
+			decompressedSize: binary.LittleEndian.Uint32(entry[4:]),
+		}
+		if segment.checksums {
+			frame.checksum = binary.LittleEndian.Uint32(entry[8:])
+		}
+		segment.frames[i] = frame
+		offset += int64(frame.compressedSize)
+		segment.size += int64(frame.decompressedSize)
+	}
+	if offset != fileSize-int64(len(table)) {
+		return nil, fmt.Errorf("seek table covers %d bytes of %d", offset, fileSize-int64(len(table)))
+	}
+	return segment, nil
+}
+
+// Read reads decompressed bytes, crossing frame boundaries as needed.
+func (s *seekableSegment) Read(p []byte) (int, error) {
+	total := 0
+	for total < len(p) {
+		if s.pos >= s.size {
+			if total == 0 {
+				return 0, io.EOF
+			}
+			break
+		}
+		index := sort.Search(len(s.frames), func(i int) bool {
+			return s.frames[i].start+int64(s.frames[i].decompressedSize) > s.pos
+		})
+		data, err := s.frame(index)
+		if err != nil {
+			return total, err
+		}
+		n := copy(p[total:], data[s.pos-s.frames[index].start:])
+		total += n
+		s.pos += int64(n)
+	}
+	return total, nil
+}
+
+// frame returns the decompressed bytes of frame index.
+func (s *seekableSegment) frame(index int) ([]byte, error) {
+	if index == s.cachedIdx {
+		return s.cached, nil
+	}
+	frame := s.frames[index]
+	data, err := s.decodeFrame(frame)
+	if err != nil {
+		if !s.lenient {
+			return nil, fmt.Errorf("frame %d: %w", index, err)
+		}
+		logger.Log.Warn("Compressed frame {frame} is corrupted: {error}", index, err)
+		data = make([]byte, frame.decompressedSize)
+	}
+	s.cached, s.cachedIdx = data, index
+	return data, nil
+}
+
+// decodeFrame decompresses a frame and checks it against the seek table.
+func (s *seekableSegment) decodeFrame(frame seekFrame) ([]byte, error) {
+	compressed := make([]byte, frame.compressedSize)
+	if _, err := s.file.ReadAt(compressed, frame.offset); err != nil {
+		return nil, err
+	}
+	_, decoder, err := zstdCodecs()
+	if err != nil {
+		return nil, err
+	}
+	data, err := decoder.DecodeAll(compressed, make([]byte, 0, frame.decompressedSize))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != int(frame.decompressedSize) {
+		return nil, fmt.Errorf("decompressed %d bytes, expected %d", len(data), frame.decompressedSize)
+	}
+	// #nosec G115 - the seek table stores the low 32 bits
+	if s.checksums && uint32(xxhash.Sum64(data)) != frame.checksum {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	return data, nil
+}
+
+// Seek sets the offset in the decompressed segment.
+func (s *seekableSegment) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+// Size returns the decompressed size of the segment.
+func (s *seekableSegment) Size() int64 {
+	return s.size
+}
+
+// Close closes the underlying file.
+func (s *seekableSegment) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// isCompressedSegment reports whether the segment at path is compressed.
+func isCompressedSegment(path string) bool {
+	file, err := os.Open(path) // #nosec G304 - controlled path
+	if err != nil {
+		return false
+	}
+	defer func() { _ = file.Close() }()
+	return hasZstdMagic(file)
+}
+
+// hasZstdMagic reports whether file starts with a zstd frame. Uncompressed
+// segments start with the record magic instead.
+func hasZstdMagic(file io.ReaderAt) bool {
+	var magic [4]byte
+	n, _ := file.ReadAt(magic[:], 0)
+	return n == len(magic) && binary.LittleEndian.Uint32(magic[:]) == zstdFrameMagic
+}
+
+// CompressSegment rewrites a sealed segment in the zstd seekable format. The
+// compressed copy is verified against the original before it replaces it;
+// segments that are already compressed are left as they are. The active
+// segment must not be compressed.
+func CompressSegment(path string) error {
+	if isCompressedSegment(path) {
+		return nil
+	}
+	data, err := os.ReadFile(path) // #nosec G304 - controlled path
+	if err != nil {
+		return fmt.Errorf("failed to read segment: %w", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	encoder, _, err := zstdCodecs()
+	if err != nil {
+		return err
+	}
+	var out, table bytes.Buffer
+	for _, chunk := range frameChunks(data) {
+		start := out.Len()
+		out.Write(encoder.EncodeAll(chunk, nil))
+		var entry [seekableEntrySize]byte
+		// #nosec G115 - frames are bounded by the segment size
+		binary.LittleEndian.PutUint32(entry[:], uint32(out.Len()-start))
+		// #nosec G115 - frames are bounded by the segment size
+		binary.LittleEndian.PutUint32(entry[4:], uint32(len(chunk)))
+		// #nosec G115 - the seek table stores the low 32 bits
+		binary.LittleEndian.PutUint32(entry[8:], uint32(xxhash.Sum64(chunk)))
+		table.Write(entry[:])
+	}
+	count := table.Len() / seekableEntrySize
+	var header, footer [8]byte
+	binary.LittleEndian.PutUint32(header[:], seekTableFrameMagic)
+	// #nosec G115 - seek table size is bounded by the segment size
+	binary.LittleEndian.PutUint32(header[4:], uint32(table.Len()+seekableFooterSize))
+	// #nosec G115 - frame count is bounded by the segment size
+	binary.LittleEndian.PutUint32(footer[:], uint32(count))
+	out.Write(header[:])
+	out.Write(table.Bytes())
+	out.Write(footer[:4])
+	out.WriteByte(seekableChecksums)
+	binary.LittleEndian.PutUint32(footer[4:], seekableMagic)
+	out.Write(footer[4:])
+
+	tempPath := path + ".zst.tmp"
+	if err := writeSyncedFile(tempPath, out.Bytes()); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write compressed segment: %w", err)
+	}
+
+	// Never replace a segment with a copy that doesn't read back identically
+	if err := verifyCompressedSegment(tempPath, data); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("compressed segment failed verification: %w", err)
+	}
+	// A segment removed meanwhile stays removed
+	if !fileExists(path) {
+		_ = os.Remove(tempPath)
+		return nil
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace segment: %w", err)
+	}
+	return refreshParity(path)
+}
+
+// frameChunks splits segment data into frames of about seekableFrameSize
+// that end on record boundaries. Bytes after the last parseable record go
+// into the final frame untouched.
+func frameChunks(data []byte) [][]byte {
+	var chunks [][]byte
+	start, offset := 0, 0
+	for offset+24 <= len(data) && binary.LittleEndian.Uint32(data[offset:]) == MagicHeader {
+		size := 24 + 8 + 32 + int(binary.LittleEndian.Uint32(data[offset+8:offset+12])) + 4 + 4
+		if offset+size > len(data) {
+			break
+		}
+		offset += size
+		if offset-start >= seekableFrameSize {
+			chunks = append(chunks, data[start:offset])
+			start = offset
+		}
+	}
+	if start < len(data) {
+		chunks = append(chunks, data[start:])
+	}
+	return chunks
+}
+
+// writeSyncedFile writes data to a new file and syncs it.
+func writeSyncedFile(path string, data []byte) error {
+	// #nosec G304 - path derived from a controlled segment path
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// verifyCompressedSegment checks that the compressed segment at path reads
+// back as expected.
+func verifyCompressedSegment(path string, expected []byte) error {
+	segment, err := openSegment(path, false)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = segment.Close() }()
+	data, err := io.ReadAll(segment)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, expected) {
+		return errors.New("decompressed segment differs from the original")
+	}
+	return nil
+}
+
+// WithSegmentCompression compresses segments in the zstd seekable format
+// once they are sealed. Compression runs in the background after rotation;
+// a segment that fails to compress is left uncompressed.
+func WithSegmentCompression() Option {
+	return func(c *config) error {
+		c.zstdSegments = true
+		return nil
+	}
+}
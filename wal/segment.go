@@ -20,9 +20,14 @@ type Segment struct {
 	StartSeq  uint64
 	EndSeq    uint64
 	Size      int64
+	// Format is the checksum and chain hash the segment's records declare.
+	Format    Format
 	Version   uint16
 	Sealed    bool
 	Corrupted bool
+	// Compressed reports whether the segment was in the zstd seekable
+	// format when it was scanned.
+	Compressed bool
 }
 
 // SegmentManager handles multiple WAL segments.
@@ -73,6 +78,14 @@ func NewSegmentManager(walPath string, maxSize int64) (*SegmentManager, error) {
 	return sm, nil
 }
 
+// activeSegment returns the segment being written, or nil if there is none.
+func (sm *SegmentManager) activeSegment() *Segment {
+	if sm.activeIndex >= 0 && sm.activeIndex < len(sm.segments) {
+		return sm.segments[sm.activeIndex]
+	}
+	return nil
+}
+
 // GetActivePath returns the path to the active segment.
 func (sm *SegmentManager) GetActivePath() string {
 	if sm.activeIndex >= 0 && sm.activeIndex < len(sm.segments) {
@@ -143,9 +156,10 @@ func (sm *SegmentManager) scanSegments() error {
 		}
 
 		segment := &Segment{
-			Path:      path,
-			Size:      stat.Size(),
-			CreatedAt: stat.ModTime(),
+			Path:       path,
+			Size:       stat.Size(),
+			CreatedAt:  stat.ModTime(),
+			Compressed: isCompressedSegment(path),
 		}
 
 		// Don't parse sequence from name - will be determined from actual records
@@ -153,9 +167,20 @@ func (sm *SegmentManager) scanSegments() error {
 		sm.segments = append(sm.segments, segment)
 	}
 
-	// Sort segments by creation time
-	sort.Slice(sm.segments, func(i, j int) bool {
-		return sm.segments[i].CreatedAt.Before(sm.segments[j].CreatedAt)
+	// Read sequence numbers from actual segment contents
+	sm.updateSequenceNumbers()
+
+	// Order segments by their first record; modification times can tie on
+	// coarse-grained filesystems. Empty segments are newest.
+	sort.SliceStable(sm.segments, func(i, j int) bool {
+		a, b := sm.segments[i], sm.segments[j]
+		if (a.StartSeq == 0) != (b.StartSeq == 0) {
+			return b.StartSeq == 0
+		}
+		if a.StartSeq != b.StartSeq {
+			return a.StartSeq < b.StartSeq
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
 	})
 
 	// Set the last segment as active if it exists and is not sealed
@@ -167,9 +192,6 @@ func (sm *SegmentManager) scanSegments() error {
 		}
 	}
 
-	// Read sequence numbers from actual segment contents
-	sm.updateSequenceNumbers()
-
 	return nil
 }
 
@@ -187,6 +209,7 @@ func (sm *SegmentManager) cleanupOldSegments() error {
 			if err := os.Remove(sm.segments[i].Path); err != nil {
 				return fmt.Errorf("failed to remove old segment %s: %w", sm.segments[i].Path, err)
 			}
+			_ = os.Remove(ParityPath(sm.segments[i].Path))
 		}
 	}
 
@@ -219,7 +242,7 @@ func (sm *SegmentManager) ReadAllSegments() ([][]byte, error) {
 
 // readSegment reads all records from a single segment file.
 func (sm *SegmentManager) readSegment(path string) ([][]byte, error) {
-	file, err := os.Open(path) // #nosec G304 - controlled path
+	file, err := openSegment(path, false)
 	if err != nil {
 		return nil, err
 	}
@@ -305,7 +328,8 @@ func (sm *SegmentManager) RemoveSegment(seg *Segment) error {
 			} else if i < sm.activeIndex {
 				sm.activeIndex--
 			}
-			// Delete the file
+			// Delete the file and its parity
+			_ = os.Remove(ParityPath(seg.Path))
 			return os.Remove(seg.Path)
 		}
 	}
@@ -351,9 +375,15 @@ func (sm *SegmentManager) updateSequenceNumbers() {
 			continue
 		}
 
-		// Parse first record for start sequence
-		if firstRecord, err := UnmarshalRecord(records[0]); err == nil {
+		// Parse first record for start sequence; the genesis record precedes it
+		first := records[0]
+		if record, err := UnmarshalRecord(first); err == nil && record.IsGenesis() && len(records) > 1 {
+			first = records[1]
+		}
+		if firstRecord, err := UnmarshalRecord(first); err == nil {
 			segment.StartSeq = firstRecord.Sequence
+			segment.Format = firstRecord.Format()
+			segment.Version = firstRecord.Version
 		}
 
 		// Parse last record for end sequence
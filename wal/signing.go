@@ -0,0 +1,223 @@
+package wal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
+)
+
+// RecordSigner signs each record's chain hash as it is written.
+// compliance.SignatureChain opened with compliance.OpenSignatureChain
+// implements it.
+type RecordSigner interface {
+	Sign(sequence uint64, data []byte) (*compliance.ChainedSignature, error)
+	LastSequence() uint64
+	Sync() error
+}
+
+// SignatureReport describes how a WAL matches its signature log.
+type SignatureReport struct {
+	Error      string
+	Signatures int
+	Signed     int
+	Unsigned   int
+	Mismatched int
+	Orphaned   int
+	// FirstInvalidSequence is the first record that is unsigned or whose
+	// signature does not match, or 0.
+	FirstInvalidSequence uint64
+	Valid                bool
+}
+
+// WithSigning signs every record with signer. Records the signature log
+// does not cover when the WAL opens, written before the signer was attached
+// or lost with the log, stay unsigned and are reported by VerifySignatures
+// and VerifyIntegrityReport.
+func WithSigning(signer RecordSigner) Option {
+	return func(c *config) error {
+		if signer == nil {
+			return fmt.Errorf("signer cannot be nil")
+		}
+		c.signer = signer
+		return nil
+	}
+}
+
+// signRecord signs a written record's hash. The caller must hold w.mu.
+func (w *WAL) signRecord(sequence uint64, hash [32]byte) error {
+	if w.signer == nil {
+		return nil
+	}
+	if _, err := w.signer.Sign(sequence, hash[:]); err != nil {
+		return fmt.Errorf("record %d written but not signed: %w", sequence, err)
+	}
+	return nil
+}
+
+// checkSigned notes the records the signature log does not cover. They are
+// never signed on open: a lost or truncated log must not let records written,
+// or rewritten, while it was gone gain valid signatures. VerifySignatures and
+// VerifyIntegrityReport report them as unsigned.
+func (w *WAL) checkSigned() error {
+	last := w.signer.LastSequence()
+	if last > w.sequence {
+		return fmt.Errorf("signature log is ahead of the WAL (sequence %d > %d)", last, w.sequence)
+	}
+	if last < w.sequence {
+		w.unsignedRecords = int(w.sequence - last) // #nosec G115 - bounded by the records on disk
+		logger.Log.Warn("Records {first} to {last} of {path} are not in its signature log",
+			last+1, w.sequence, w.path)
+	}
+	return nil
+}
+
+// VerifySignatures checks the WAL at walPath against the signature log at
+// sigPath using only the signer's public key. Every record must carry a valid
+// signature over its chain hash, and every signature must match a record.
+// Records stay verifiable without decryption keys because the chain hash
+// covers the stored ciphertext. The log and the records are read side by
+// side in sequence order, so neither is held in memory.
+func VerifySignatures(walPath, sigPath string, verifier compliance.Signer) (*SignatureReport, error) {
+	report := &SignatureReport{}
+
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	records := newRecordCursor(segments)
+	defer records.close()
+
+	// Records before each signature's sequence are unsigned
+	var walErr error
+	signatures, err := compliance.WalkSignatureLog(sigPath, verifier, func(sig *compliance.ChainedSignature) error {
+		for {
+			record, err := records.peek()
+			if err != nil {
+				walErr = err
+				return err
+			}
+			if record == nil || record.Sequence > sig.Sequence {
+				report.Orphaned++
+				return nil
+			}
+			records.skip()
+			if record.Sequence < sig.Sequence {
+				report.unsigned(record.Sequence)
+				continue
+			}
+			hash := record.ComputeHash()
+			dataHash := sha256.Sum256(hash[:])
+			if bytes.Equal(sig.DataHash, dataHash[:]) {
+				report.Signed++
+			} else {
+				report.mismatched(record.Sequence)
+			}
+			return nil
+		}
+	})
+	if walErr != nil {
+		return nil, walErr
+	}
+	report.Signatures = signatures
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	// Records after the last verified signature are unsigned
+	for {
+		record, err := records.peek()
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			break
+		}
+		records.skip()
+		report.unsigned(record.Sequence)
+	}
+
+	report.Valid = report.Error == "" && report.Unsigned == 0 && report.Mismatched == 0 && report.Orphaned == 0
+	return report, nil
+}
+
+// unsigned counts a record without a signature.
+func (r *SignatureReport) unsigned(sequence uint64) {
+	r.Unsigned++
+	r.noteInvalid(sequence)
+}
+
+// mismatched counts a record whose signature covers other content.
+func (r *SignatureReport) mismatched(sequence uint64) {
+	r.Mismatched++
+	r.noteInvalid(sequence)
+}
+
+// noteInvalid records the first invalid sequence.
+func (r *SignatureReport) noteInvalid(sequence uint64) {
+	if r.FirstInvalidSequence == 0 {
+		r.FirstInvalidSequence = sequence
+	}
+}
+
+// recordCursor reads a WAL's records one at a time across its segments, in
+// sequence order, skipping the genesis record.
+type recordCursor struct {
+	reader   *Reader
+	next     *Record
+	segments []*Segment
+}
+
+func newRecordCursor(segments *SegmentManager) *recordCursor {
+	return &recordCursor{segments: segments.GetSegments()}
+}
+
+// peek returns the next record without consuming it, or nil at the end.
+func (c *recordCursor) peek() (*Record, error) {
+	for c.next == nil {
+		if c.reader == nil {
+			if len(c.segments) == 0 {
+				return nil, nil
+			}
+			segment := c.segments[0]
+			c.segments = c.segments[1:]
+			if !fileExists(segment.Path) {
+				continue
+			}
+			reader, err := NewReader(segment.Path)
+			if err != nil {
+				return nil, err
+			}
+			c.reader = reader
+		}
+
+		record, err := c.reader.ReadNextRecord()
+		if err == io.EOF {
+			_ = c.reader.Close()
+			c.reader = nil
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WAL: %w", err)
+		}
+		if !record.IsGenesis() {
+			c.next = record
+		}
+	}
+	return c.next, nil
+}
+
+// skip consumes the record returned by peek.
+func (c *recordCursor) skip() {
+	c.next = nil
+}
+
+// close releases the segment being read.
+func (c *recordCursor) close() {
+	if c.reader != nil {
+		_ = c.reader.Close()
+	}
+}
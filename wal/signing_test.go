@@ -0,0 +1,196 @@
+package wal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog/core"
+)
+
+// openSignedWAL opens the WAL at walPath signing into sigPath.
+func openSignedWAL(t *testing.T, walPath, sigPath string, signer compliance.Signer) (*WAL, *compliance.SignatureChain) {
+	t.Helper()
+	chain, err := compliance.OpenSignatureChain(sigPath, signer)
+	if err != nil {
+		t.Fatalf("Failed to open signature log: %v", err)
+	}
+	w, err := New(walPath, WithSegmentSize(1024), WithSigning(chain))
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	return w, chain
+}
+
+func signingEvent(i int) *core.LogEvent {
+	return &core.LogEvent{
+		Timestamp:       time.Now(),
+		Level:           core.InformationLevel,
+		MessageTemplate: "Order {OrderId} approved",
+		Properties:      map[string]interface{}{"OrderId": i},
+	}
+}
+
+func TestWALSigning(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "signed.wal")
+	sigPath := walPath + ".sig"
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, chain := openSignedWAL(t, walPath, sigPath, signer)
+	for i := 0; i < 10; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.WriteBatch([]*core.LogEvent{signingEvent(10), signingEvent(11)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.GetSegments()) < 2 {
+		t.Fatalf("Expected the WAL to rotate, got %d segment(s)", len(w.GetSegments()))
+	}
+
+	report, err := VerifySignatures(walPath, sigPath, signer)
+	if err != nil {
+		t.Fatalf("VerifySignatures failed: %v", err)
+	}
+	if !report.Valid || report.Signed != 12 {
+		t.Fatalf("Expected 12 valid signatures, got %+v", report)
+	}
+
+	// Losing the tail of the signature log leaves records unsigned
+	data, err := os.ReadFile(sigPath) // #nosec G304 - test file path
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if err := os.WriteFile(sigPath, bytes.Join(lines[:len(lines)-2], nil), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = VerifySignatures(walPath, sigPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Unsigned != 1 || report.FirstInvalidSequence != 12 {
+		t.Errorf("Expected record 12 reported unsigned, got %+v", report)
+	}
+
+	// Reopening never signs it again, and the integrity report flags it
+	w, chain = openSignedWAL(t, walPath, sigPath, signer)
+	if chain.LastSequence() != 11 {
+		t.Errorf("Expected the signature log to stay at 11, got %d", chain.LastSequence())
+	}
+	integrity, err := w.VerifyIntegrityReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if integrity.Valid || integrity.UnsignedRecords != 1 {
+		t.Errorf("Expected one unsigned record reported, got %+v", integrity)
+	}
+	if err := w.Write(signingEvent(12)); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	_ = chain.Close()
+
+	report, err = VerifySignatures(walPath, sigPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Signed != 12 || report.Unsigned != 1 || report.FirstInvalidSequence != 12 {
+		t.Errorf("Expected only record 12 unsigned, got %+v", report)
+	}
+}
+
+func TestWALSigningLeavesEarlierRecordsUnsigned(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "signed.wal")
+	sigPath := walPath + ".sig"
+
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Records written before signing was enabled, or while the log was
+	// missing, could have been rewritten; they are reported, not signed
+	w, err := New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, chain := openSignedWAL(t, walPath, sigPath, signer)
+	if chain.LastSequence() != 0 {
+		t.Errorf("Expected no records signed on open, got up to %d", chain.LastSequence())
+	}
+	if err := w.Write(signingEvent(3)); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	_ = chain.Close()
+
+	report, err := VerifySignatures(walPath, sigPath, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Signed != 1 || report.Unsigned != 3 || report.FirstInvalidSequence != 1 {
+		t.Errorf("Expected records 1-3 unsigned, got %+v", report)
+	}
+}
+
+func TestWALSigningDetectsRewrittenRecords(t *testing.T) {
+	dir := t.TempDir()
+	signer, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two logs with identical sequences but different content
+	original := filepath.Join(dir, "original.wal")
+	w, chain := openSignedWAL(t, original, original+".sig", signer)
+	if err := w.Write(signingEvent(1)); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	_ = chain.Close()
+
+	forged := filepath.Join(dir, "forged.wal")
+	w, err = New(forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(signingEvent(2)); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	report, err := VerifySignatures(forged, original+".sig", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Mismatched != 1 {
+		t.Errorf("Expected a mismatched record, got %+v", report)
+	}
+}
@@ -0,0 +1,130 @@
+package wal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/willibrandon/mtlog/core"
+)
+
+// Properties that stamp an event delivered to a backend with the WAL record
+// it came from, so backend copies can be reconciled with the WAL exactly.
+const (
+	SequenceProperty = "_wal_sequence"
+	HashProperty     = "_wal_hash"
+)
+
+// EventHash returns the content hash of event: SHA-256 over its timestamp,
+// level, template and properties, leaving out any stamp. It is the same for
+// an event and its copy read back from a JSON backend.
+func EventHash(event *core.LogEvent) ([32]byte, error) {
+	properties := event.Properties
+	if stamped(properties) {
+		properties = unstamped(properties)
+	}
+
+	data, err := json.Marshal(struct {
+		Properties      map[string]any     `json:"properties"`
+		MessageTemplate string             `json:"template"`
+		Timestamp       int64              `json:"timestamp"`
+		Level           core.LogEventLevel `json:"level"`
+	}{properties, event.MessageTemplate, event.Timestamp.UnixNano(), event.Level})
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("failed to encode event: %w", err)
+	}
+	return sha256.Sum256(data), nil
+}
+
+// StampEvent returns a copy of event stamped with the sequence of its WAL
+// record and its content hash. The event itself is not changed.
+func StampEvent(event *core.LogEvent, sequence uint64) (*core.LogEvent, error) {
+	hash, err := EventHash(event)
+	if err != nil {
+		return nil, err
+	}
+	copied := *event
+	copied.Properties = make(map[string]any, len(event.Properties)+2)
+	for name, value := range event.Properties {
+		copied.Properties[name] = value
+	}
+	copied.Properties[SequenceProperty] = sequence
+	copied.Properties[HashProperty] = hex.EncodeToString(hash[:])
+	return &copied, nil
+}
+
+// UnstampEvent returns event without its stamp, and the sequence and
+// content hash it was stamped with. ok is false for an event that was not
+// stamped, which is returned as it is.
+func UnstampEvent(event *core.LogEvent) (unstampedEvent *core.LogEvent, sequence uint64, hash string, ok bool) {
+	if !stamped(event.Properties) {
+		return event, 0, "", false
+	}
+	value := event.Properties[SequenceProperty]
+	hash, hasHash := event.Properties[HashProperty].(string)
+
+	copied := *event
+	copied.Properties = unstamped(event.Properties)
+	sequence, ok = stampSequence(value)
+	return &copied, sequence, hash, ok && hasHash
+}
+
+// unstampEvents strips the stamps from events read from a backend and
+// returns the sequences they carried, or nil unless every event carried one.
+func unstampEvents(events []*core.LogEvent) ([]*core.LogEvent, []uint64) {
+	plain := make([]*core.LogEvent, len(events))
+	sequences := make([]uint64, len(events))
+	all := true
+	for i, event := range events {
+		var ok bool
+		plain[i], sequences[i], _, ok = UnstampEvent(event)
+		all = all && ok
+	}
+	if !all {
+		sequences = nil
+	}
+	return plain, sequences
+}
+
+// stamped reports whether properties carry any part of a stamp.
+func stamped(properties map[string]any) bool {
+	_, sequence := properties[SequenceProperty]
+	_, hash := properties[HashProperty]
+	return sequence || hash
+}
+
+// unstamped returns a copy of properties without the stamp.
+func unstamped(properties map[string]any) map[string]any {
+	copied := make(map[string]any, len(properties))
+	for name, value := range properties {
+		if name != SequenceProperty && name != HashProperty {
+			copied[name] = value
+		}
+	}
+	return copied
+}
+
+// stampSequence reads a stamped sequence back as whatever type the backend
+// decoded it to.
+func stampSequence(value any) (uint64, bool) {
+	switch v := value.(type) {
+	case uint64:
+		return v, true
+	case int64:
+		return uint64(v), v >= 0 // #nosec G115 - checked non-negative
+	case int:
+		return uint64(v), v >= 0 // #nosec G115 - checked non-negative
+	case float64:
+		return uint64(v), v >= 0 && v == float64(uint64(v))
+	case json.Number:
+		n, err := strconv.ParseUint(string(v), 10, 64)
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseUint(v, 10, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,55 @@
+package wal
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog/core"
+)
+
+func TestStampEvent(t *testing.T) {
+	event := &core.LogEvent{
+		Timestamp:       time.Now(),
+		Level:           core.WarningLevel,
+		MessageTemplate: "Order {id} shipped",
+		Properties:      map[string]any{"id": 42},
+	}
+	want, err := EventHash(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stamped, err := StampEvent(event, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := event.Properties[SequenceProperty]; ok {
+		t.Error("Stamping changed the original event")
+	}
+
+	// A JSON backend hands the stamp back as a float
+	data, err := json.Marshal(stamped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var copied core.LogEvent
+	if err := json.Unmarshal(data, &copied); err != nil {
+		t.Fatal(err)
+	}
+	plain, seq, hash, ok := UnstampEvent(&copied)
+	if !ok || seq != 7 {
+		t.Fatalf("Expected sequence 7, got %d (ok=%v)", seq, ok)
+	}
+	got, err := EventHash(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want || hash != hex.EncodeToString(want[:]) {
+		t.Errorf("Expected the copy to hash as the original")
+	}
+	if _, _, _, ok := UnstampEvent(event); ok {
+		t.Error("Expected an unstamped event to report no stamp")
+	}
+}
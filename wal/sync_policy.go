@@ -0,0 +1,186 @@
+package wal
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/willibrandon/mtlog/core"
+)
+
+// CriticalProperty is the event property that, when true, forces an
+// immediate sync under priority sync.
+const CriticalProperty = "AuditCritical"
+
+// SyncState describes the unsynced data in the active segment.
+type SyncState struct {
+	OldestUnsynced time.Time
+	PendingBytes   int64
+	PendingRecords int
+}
+
+// SyncPolicy decides when the WAL fsyncs. It is consulted after every write
+// and told how long each sync took so it can adapt.
+type SyncPolicy interface {
+	// ShouldSync reports whether the unsynced data must be synced now.
+	ShouldSync(state SyncState) bool
+	// ObserveSync records the latency of a completed sync covering records.
+	ObserveSync(records int, latency time.Duration)
+}
+
+// recordCountPolicy syncs after a fixed number of records.
+type recordCountPolicy struct {
+	records int
+}
+
+// SyncEveryRecords returns a policy that syncs once n records are unsynced.
+func SyncEveryRecords(n int) SyncPolicy {
+	if n < 1 {
+		n = 1
+	}
+	return &recordCountPolicy{records: n}
+}
+
+// ShouldSync implements SyncPolicy.
+func (p *recordCountPolicy) ShouldSync(state SyncState) bool {
+	return state.PendingRecords >= p.records
+}
+
+// ObserveSync implements SyncPolicy.
+func (p *recordCountPolicy) ObserveSync(int, time.Duration) {}
+
+// byteCountPolicy syncs after a fixed number of bytes.
+type byteCountPolicy struct {
+	bytes int64
+}
+
+// SyncEveryBytes returns a policy that syncs once n bytes are unsynced.
+func SyncEveryBytes(n int64) SyncPolicy {
+	if n < 1 {
+		n = 1
+	}
+	return &byteCountPolicy{bytes: n}
+}
+
+// ShouldSync implements SyncPolicy.
+func (p *byteCountPolicy) ShouldSync(state SyncState) bool {
+	return state.PendingBytes >= p.bytes
+}
+
+// ObserveSync implements SyncPolicy.
+func (p *byteCountPolicy) ObserveSync(int, time.Duration) {}
+
+// neverPolicy leaves syncing to Flush or the interval ticker.
+type neverPolicy struct{}
+
+// ShouldSync implements SyncPolicy.
+func (neverPolicy) ShouldSync(SyncState) bool { return false }
+
+// ObserveSync implements SyncPolicy.
+func (neverPolicy) ObserveSync(int, time.Duration) {}
+
+// LatencyTargetPolicy bounds how long a record may stay unsynced while
+// tuning the batch size to the observed fsync latency: slow fsyncs grow the
+// batch so their cost is amortized, fast fsyncs shrink it so records become
+// durable sooner. The age bound is checked on each write, so pair it with
+// SyncInterval to cover idle periods.
+type LatencyTargetPolicy struct {
+	target   time.Duration
+	avgSync  time.Duration
+	batch    int
+	maxBatch int
+	mu       sync.Mutex
+}
+
+// SyncLatencyTarget returns a policy that keeps records unsynced for at most
+// roughly target, auto-tuning the batch size between 1 and maxBatch.
+func SyncLatencyTarget(target time.Duration, maxBatch int) *LatencyTargetPolicy {
+	if maxBatch < 1 {
+		maxBatch = 1
+	}
+	return &LatencyTargetPolicy{
+		target:   target,
+		batch:    1,
+		maxBatch: maxBatch,
+	}
+}
+
+// ShouldSync implements SyncPolicy.
+func (p *LatencyTargetPolicy) ShouldSync(state SyncState) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if state.PendingRecords >= p.batch {
+		return true
+	}
+	return !state.OldestUnsynced.IsZero() && time.Since(state.OldestUnsynced) >= p.target-p.avgSync
+}
+
+// ObserveSync implements SyncPolicy.
+func (p *LatencyTargetPolicy) ObserveSync(_ int, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Exponentially weighted moving average of fsync latency
+	if p.avgSync == 0 {
+		p.avgSync = latency
+	} else {
+		p.avgSync = (p.avgSync*4 + latency) / 5
+	}
+
+	switch {
+	case p.avgSync > p.target/2 && p.batch < p.maxBatch:
+		p.batch = min(p.batch*2, p.maxBatch)
+	case p.avgSync < p.target/8 && p.batch > 1:
+		p.batch /= 2
+	}
+}
+
+// BatchSize returns the current tuned batch size.
+func (p *LatencyTargetPolicy) BatchSize() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.batch
+}
+
+// defaultSyncPolicy maps a SyncMode to the equivalent policy.
+func defaultSyncPolicy(mode SyncMode) SyncPolicy {
+	switch mode {
+	case SyncBatch:
+		return SyncEveryRecords(10)
+	case SyncInterval:
+		return neverPolicy{}
+	default:
+		return SyncEveryRecords(1)
+	}
+}
+
+// isPriority reports whether an event forces an immediate sync.
+func isPriority(event *core.LogEvent, level core.LogEventLevel) bool {
+	if event.Level >= level {
+		return true
+	}
+	critical, _ := event.Properties[CriticalProperty].(bool)
+	return critical
+}
+
+// WithSyncPolicy replaces the sync decision of the sync mode with policy.
+// SyncInterval still runs its background ticker alongside the policy.
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(c *config) error {
+		if policy == nil {
+			return fmt.Errorf("sync policy cannot be nil")
+		}
+		c.syncPolicy = policy
+		return nil
+	}
+}
+
+// WithPrioritySync forces an immediate sync for events at or above level,
+// and for events whose CriticalProperty is true, regardless of policy.
+func WithPrioritySync(level core.LogEventLevel) Option {
+	return func(c *config) error {
+		c.priorityLevel = &level
+		return nil
+	}
+}
@@ -0,0 +1,157 @@
+package wal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog/core"
+)
+
+// countingPolicy wraps a policy and counts the syncs it observes.
+type countingPolicy struct {
+	SyncPolicy
+	syncs int
+}
+
+func (p *countingPolicy) ObserveSync(records int, latency time.Duration) {
+	p.syncs++
+	p.SyncPolicy.ObserveSync(records, latency)
+}
+
+func TestSyncPolicyThresholds(t *testing.T) {
+	tests := []struct {
+		policy SyncPolicy
+		name   string
+		state  SyncState
+		want   bool
+	}{
+		{name: "records below", policy: SyncEveryRecords(5), state: SyncState{PendingRecords: 4}, want: false},
+		{name: "records reached", policy: SyncEveryRecords(5), state: SyncState{PendingRecords: 5}, want: true},
+		{name: "bytes below", policy: SyncEveryBytes(1024), state: SyncState{PendingBytes: 1023}, want: false},
+		{name: "bytes reached", policy: SyncEveryBytes(1024), state: SyncState{PendingBytes: 4096}, want: true},
+		{name: "latency fresh", policy: SyncLatencyTarget(time.Hour, 1), state: SyncState{PendingRecords: 0, OldestUnsynced: time.Now()}, want: false},
+		{name: "latency aged", policy: SyncLatencyTarget(time.Millisecond, 100), state: SyncState{OldestUnsynced: time.Now().Add(-time.Second)}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.ShouldSync(tt.state); got != tt.want {
+				t.Errorf("ShouldSync() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyTargetPolicyTuning(t *testing.T) {
+	policy := SyncLatencyTarget(10*time.Millisecond, 64)
+
+	// Slow fsyncs grow the batch
+	for i := 0; i < 10; i++ {
+		policy.ObserveSync(policy.BatchSize(), 20*time.Millisecond)
+	}
+	grown := policy.BatchSize()
+	if grown <= 1 || grown > 64 {
+		t.Fatalf("Expected batch to grow within bounds, got %d", grown)
+	}
+
+	// Fast fsyncs shrink it again
+	for i := 0; i < 50; i++ {
+		policy.ObserveSync(policy.BatchSize(), 100*time.Microsecond)
+	}
+	if shrunk := policy.BatchSize(); shrunk >= grown {
+		t.Errorf("Expected batch to shrink below %d, got %d", grown, shrunk)
+	}
+}
+
+func TestWALSyncPolicy(t *testing.T) {
+	policy := &countingPolicy{SyncPolicy: SyncEveryRecords(5)}
+
+	w, err := New(filepath.Join(t.TempDir(), "test.wal"),
+		WithSyncMode(SyncInterval),
+		WithSyncInterval(time.Hour),
+		WithSyncPolicy(policy),
+		WithPrioritySync(core.ErrorLevel),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+
+	write := func(level core.LogEventLevel, props map[string]interface{}) uint64 {
+		seq, err := w.Append(&core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           level,
+			MessageTemplate: "Policy event",
+			Properties:      props,
+		})
+		if err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+		return seq
+	}
+
+	for i := 0; i < 12; i++ {
+		write(core.InformationLevel, nil)
+	}
+	if policy.syncs != 2 {
+		t.Errorf("Expected 2 syncs after 12 records, got %d", policy.syncs)
+	}
+
+	// Error-level and critical events are synced immediately
+	seq := write(core.ErrorLevel, nil)
+	if w.syncedSeq != seq {
+		t.Errorf("Error event not synced: synced %d, want %d", w.syncedSeq, seq)
+	}
+
+	seq = write(core.InformationLevel, nil)
+	if w.syncedSeq == seq {
+		t.Errorf("Ordinary event synced early")
+	}
+
+	seq = write(core.InformationLevel, map[string]interface{}{CriticalProperty: true})
+	if w.syncedSeq != seq {
+		t.Errorf("Critical event not synced: synced %d, want %d", w.syncedSeq, seq)
+	}
+}
+
+func TestWALBatchSyncPolicy(t *testing.T) {
+	for _, mode := range []SyncMode{SyncImmediate, SyncBatch, SyncInterval} {
+		w, err := New(filepath.Join(t.TempDir(), "test.wal"),
+			WithSyncMode(mode),
+			WithSyncInterval(time.Hour),
+			WithSyncPolicy(SyncEveryRecords(5)),
+			WithPrioritySync(core.ErrorLevel),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := func(levels ...core.LogEventLevel) uint64 {
+			events := make([]*core.LogEvent, len(levels))
+			for i, level := range levels {
+				events[i] = &core.LogEvent{Timestamp: time.Now(), Level: level, MessageTemplate: "Batch event"}
+			}
+			sequences, err := w.WriteBatch(events)
+			if err != nil {
+				t.Fatalf("Failed to write batch: %v", err)
+			}
+			return sequences[len(sequences)-1]
+		}
+
+		// Batches count toward the policy in every mode
+		if seq := batch(core.InformationLevel, core.InformationLevel); w.syncedSeq == seq {
+			t.Errorf("Mode %d: batch below the policy threshold synced", mode)
+		}
+		if seq := batch(core.InformationLevel, core.InformationLevel, core.InformationLevel); w.syncedSeq != seq {
+			t.Errorf("Mode %d: batch reaching the policy threshold not synced: synced %d, want %d", mode, w.syncedSeq, seq)
+		}
+
+		// A priority event syncs its whole batch
+		if seq := batch(core.InformationLevel, core.ErrorLevel, core.InformationLevel); w.syncedSeq != seq {
+			t.Errorf("Mode %d: batch with an error event not synced: synced %d, want %d", mode, w.syncedSeq, seq)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
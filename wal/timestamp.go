@@ -0,0 +1,77 @@
+package wal
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+)
+
+// TimestampReport describes how a WAL matches its RFC 3161 timestamp log.
+type TimestampReport struct {
+	// LatestTime is when the authority saw the largest verified tree head.
+	LatestTime time.Time
+	Error      string
+	Timestamps int
+	Verified   int
+	// Invalid counts tokens that do not verify.
+	Invalid int
+	// Mismatched counts valid tokens over a tree head the WAL no longer has.
+	Mismatched int
+	// CoveredRecords is the size of the largest verified tree head: that
+	// many records provably existed by LatestTime.
+	CoveredRecords uint64
+	TotalRecords   uint64
+	Valid          bool
+}
+
+// VerifyTimestamps checks every token in the timestamp log at path against
+// the WAL at walPath. See compliance.VerifyTimestampToken for roots.
+func VerifyTimestamps(walPath, path string, roots *x509.CertPool) (*TimestampReport, error) {
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	tree, err := buildMerkleTree(segments)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TimestampReport{TotalRecords: tree.Size()}
+	records, err := compliance.ReadTimestamps(path)
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	report.Timestamps = len(records)
+
+	for _, record := range records {
+		if _, err := record.Verify(roots); err != nil {
+			report.Invalid++
+			if report.Error == "" {
+				report.Error = err.Error()
+			}
+			continue
+		}
+
+		root, err := tree.RootAt(record.TreeSize)
+		if err != nil || hexHash(root) != record.RootHash {
+			report.Mismatched++
+			if report.Error == "" {
+				report.Error = fmt.Sprintf("WAL does not match the tree head timestamped at %s (size %d)",
+					record.Time.Format(time.RFC3339), record.TreeSize)
+			}
+			continue
+		}
+
+		report.Verified++
+		if record.TreeSize >= report.CoveredRecords {
+			report.CoveredRecords = record.TreeSize
+			report.LatestTime = record.Time
+		}
+	}
+
+	report.Valid = report.Invalid == 0 && report.Mismatched == 0
+	return report, nil
+}
@@ -0,0 +1,106 @@
+package wal
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+)
+
+func TestVerifyTimestamps(t *testing.T) {
+	tsa, err := compliance.NewLocalTSA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(tsa)
+	defer server.Close()
+	client := compliance.NewTSAClient(server.URL)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(tsa.Certificate())
+
+	walPath := filepath.Join(t.TempDir(), "timestamped.wal")
+	tsPath := walPath + ".timestamps"
+
+	w, err := New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 12; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+		if i == 4 || i == 11 {
+			root, size, err := w.SyncedMerkleRoot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			record, err := client.TimestampTreeHead(context.Background(), size, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := compliance.AppendTimestamp(tsPath, record); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := VerifyTimestamps(walPath, tsPath, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Verified != 2 || report.CoveredRecords != 12 || report.TotalRecords != 12 {
+		t.Errorf("Expected 2 valid tokens covering 12 records, got %+v", report)
+	}
+
+	// A different authority is not trusted
+	other, err := compliance.NewLocalTSA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(other.Certificate())
+	if report, _ := VerifyTimestamps(walPath, tsPath, otherRoots); report.Valid || report.Invalid != 2 {
+		t.Errorf("Expected tokens from an untrusted authority to fail, got %+v", report)
+	}
+
+	// A log rebuilt after the fact no longer matches its timestamps
+	segments, err := filepath.Glob(walPath + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range segments {
+		if path != tsPath {
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	w, err = New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 100; i < 112; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = VerifyTimestamps(walPath, tsPath, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Mismatched != 2 {
+		t.Errorf("Expected the rewrite to mismatch both tokens, got %+v", report)
+	}
+}
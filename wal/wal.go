@@ -10,6 +10,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/internal/logger"
 	"github.com/willibrandon/mtlog/core"
 )
 
@@ -19,8 +21,17 @@ type IntegrityReport struct {
 	TotalRecords      int
 	CorruptedSegments int
 	RecoveredRecords  int
-	LastSequence      uint64
-	Valid             bool
+	// Seal checks run when the WAL was opened WithSealing.
+	SealedSegments   int
+	UnsealedSegments int
+	ResealedSegments int
+	MissingSegments  int
+	InvalidSeals     int
+	// UnsignedRecords counts records a signing WAL found outside its
+	// signature log when it opened.
+	UnsignedRecords int
+	LastSequence    uint64
+	Valid           bool
 }
 
 // WAL implements a Write-Ahead Log with guaranteed durability.
@@ -31,15 +42,42 @@ type WAL struct {
 	doubleWrite *DoubleWriteBuffer
 	flushStop   chan struct{}
 	flushTicker *time.Ticker
+	syncPolicy  SyncPolicy
+	keys        Keyring
+	signer      RecordSigner
+	sealer      compliance.Signer
+	priority    *core.LogEventLevel
+	oldestDirty time.Time
 	path        string
 	buffer      []byte
 	sequence    uint64
-	syncMode    SyncMode
-	currentSize int64
-	segmentSize int64
-	mu          sync.Mutex
-	closed      atomic.Bool
-	lastHash    [32]byte
+	syncedSeq   uint64
+	dirtyBytes  int64
+	dirtyCount  int
+	// damagedRecords counts records found damaged on open
+	damagedRecords int
+	// unsignedRecords counts records outside the signature log on open
+	unsignedRecords int
+	// metadata is the metadata last recorded, when the WAL records it
+	metadata *Metadata
+	// keyID is the encryption key the metadata last named as active
+	keyID         string
+	syncMode      SyncMode
+	currentSize   int64
+	segmentSize   int64
+	merkle        compliance.MerkleFrontier
+	segmentMerkle compliance.MerkleFrontier
+	segmentTimes  timeRange
+	format        Format
+	codec         Codec
+	compression   Compression
+	background    sync.WaitGroup
+	mu            sync.Mutex
+	rewriting     sync.Mutex
+	closed        atomic.Bool
+	zstdSegments  bool
+	parity        *ParityConfig
+	lastHash      [32]byte
 }
 
 // SyncMode defines when the WAL syncs to disk.
@@ -58,6 +96,18 @@ const (
 type Option func(*config) error
 
 type config struct {
+	syncPolicy    SyncPolicy
+	keys          Keyring
+	signer        RecordSigner
+	sealer        compliance.Signer
+	metadata      *Metadata
+	priorityLevel *core.LogEventLevel
+	checksum      *ChecksumType
+	hash          *HashAlgorithm
+	codec         *Codec
+	compression   Compression
+	zstdSegments  bool
+	parity        *ParityConfig
 	segmentSize   int64
 	syncMode      SyncMode
 	syncInterval  time.Duration
@@ -81,6 +131,10 @@ func New(path string, opts ...Option) (*WAL, error) {
 		}
 	}
 
+	if cfg.syncPolicy == nil {
+		cfg.syncPolicy = defaultSyncPolicy(cfg.syncMode)
+	}
+
 	// Ensure directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, cfg.createDirPerm); err != nil {
@@ -93,6 +147,13 @@ func New(path string, opts ...Option) (*WAL, error) {
 		return nil, fmt.Errorf("failed to create segment manager: %w", err)
 	}
 
+	// Compressed segments are read-only; write to a new one
+	if active := segments.activeSegment(); active != nil && active.Compressed {
+		if _, err := segments.Rotate(active.EndSeq); err != nil {
+			return nil, fmt.Errorf("failed to start a new segment: %w", err)
+		}
+	}
+
 	// Get the active segment path
 	activePath := segments.GetActivePath()
 
@@ -136,10 +197,18 @@ func New(path string, opts ...Option) (*WAL, error) {
 		segmentSize: cfg.segmentSize,
 		currentSize: stat.Size(),
 		syncMode:    cfg.syncMode,
+		syncPolicy:  cfg.syncPolicy,
+		keys:        cfg.keys,
+		signer:      cfg.signer,
+		sealer:      cfg.sealer,
+		priority:    cfg.priorityLevel,
+		compression: cfg.compression,
 		buffer:      make([]byte, 0, cfg.bufferSize),
 		doubleWrite: doubleWrite,
 		journalFile: journalFile,
 	}
+	w.format, w.codec = cfg.segmentFormat(segments.activeSegment())
+	w.zstdSegments, w.parity = cfg.zstdSegments, cfg.parity
 
 	// Recover from journal first (for torn-write protection)
 	if err := w.recoverFromJournal(); err != nil {
@@ -157,6 +226,50 @@ func New(path string, opts ...Option) (*WAL, error) {
 		}
 	}
 
+	// Rebuild the Merkle trees over existing records
+	if err := w.loadMerkle(); err != nil {
+		_ = file.Close()
+		_ = journalFile.Close()
+		return nil, fmt.Errorf("failed to load Merkle tree: %w", err)
+	}
+
+	// Note records the signature log doesn't cover; they are not signed now
+	if w.signer != nil {
+		if err := w.checkSigned(); err != nil {
+			_ = file.Close()
+			_ = journalFile.Close()
+			return nil, fmt.Errorf("failed to check WAL signatures: %w", err)
+		}
+	}
+
+	// Note segments the seal log doesn't cover; they are not sealed now
+	if w.sealer != nil {
+		if err := w.checkSealed(); err != nil {
+			_ = file.Close()
+			_ = journalFile.Close()
+			return nil, fmt.Errorf("failed to check WAL seals: %w", err)
+		}
+	}
+
+	// Keep each segment in one format and codec; a change starts a new segment
+	if active := segments.activeSegment(); active != nil && w.currentSize > 0 &&
+		(active.Format != w.format || active.Version != w.codec.version()) {
+		if err := w.rotate(); err != nil {
+			_ = w.file.Close()
+			_ = journalFile.Close()
+			return nil, fmt.Errorf("failed to start a %s %s segment: %w", w.codec, w.format, err)
+		}
+	}
+
+	// Identify a new WAL with its genesis record and chain any changes since
+	if cfg.metadata != nil {
+		if err := w.recordMetadata(*cfg.metadata); err != nil {
+			_ = w.file.Close()
+			_ = journalFile.Close()
+			return nil, err
+		}
+	}
+
 	// Start flush ticker for interval sync mode
 	if cfg.syncMode == SyncInterval {
 		w.flushStop = make(chan struct{})
@@ -169,38 +282,49 @@ func New(path string, opts ...Option) (*WAL, error) {
 
 // Write appends a log event to the WAL with guaranteed durability.
 func (w *WAL) Write(event *core.LogEvent) error {
+	_, err := w.Append(event)
+	return err
+}
+
+// Append writes a log event to the WAL and returns the sequence number
+// assigned to its record.
+func (w *WAL) Append(event *core.LogEvent) (uint64, error) {
 	if w.closed.Load() {
-		return fmt.Errorf("WAL is closed")
+		return 0, fmt.Errorf("WAL is closed")
 	}
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	// Create record with sequence number and hash chain
-	w.sequence++
-	record, err := NewRecord(event, w.sequence, w.lastHash)
+	// Create record with sequence number and hash chain. The sequence is
+	// only taken once the record is written, so a failure leaves no gap.
+	seq := w.sequence + 1
+	record, err := w.newRecord(event, seq, w.lastHash)
 	if err != nil {
-		return fmt.Errorf("failed to create record: %w", err)
+		return 0, err
+	}
+
+	if err := w.appendRecord(record, w.isPriority(event)); err != nil {
+		return 0, err
 	}
+	w.noteKey(record)
+
+	return seq, nil
+}
 
+// appendRecord writes record through the journal to the active segment, then
+// chains, signs and syncs it as the sync policy or forceSync requires. The
+// caller must hold w.mu. w.sequence advances to the record's once it is
+// written, so a failed write does not consume the sequence.
+func (w *WAL) appendRecord(record *Record, forceSync bool) error {
 	// Marshal record
 	data, err := record.Marshal()
 	if err != nil {
 		return fmt.Errorf("failed to marshal record: %w", err)
 	}
 
-	// Determine if we need to sync based on mode
-	needsSync := false
-	switch w.syncMode {
-	case SyncImmediate:
-		needsSync = true
-	case SyncBatch:
-		// For batch mode, sync every 10 writes
-		needsSync = (w.sequence % 10) == 0
-	case SyncInterval:
-		// Interval sync is handled by background ticker
-		needsSync = false
-	}
+	// Ask the sync policy, counting this record as already written
+	needsSync := w.needsSync(1, int64(len(data))) || forceSync
 
 	// Use double-write buffer for torn-write protection
 	// 1. First write to journal (sync only if needed)
@@ -227,12 +351,22 @@ func (w *WAL) Write(event *core.LogEvent) error {
 	}
 
 	// Update state
+	w.sequence = record.Sequence
 	w.currentSize += int64(n)
 	w.lastHash = record.ComputeHash()
+	w.markDirty(1, int64(n))
+
+	// The genesis record is covered through the first record's PrevHash
+	if !record.IsGenesis() {
+		w.appendLeaf(w.lastHash, record.Timestamp)
+		if err := w.signRecord(record.Sequence, w.lastHash); err != nil {
+			return err
+		}
+	}
 
 	// Sync main file if needed
 	if needsSync {
-		if err := w.file.Sync(); err != nil {
+		if err := w.syncLocked(); err != nil {
 			return fmt.Errorf("sync failed: %w", err)
 		}
 	}
@@ -247,6 +381,135 @@ func (w *WAL) Write(event *core.LogEvent) error {
 	return nil
 }
 
+// WriteBatch appends several events as one journal entry and one file write,
+// syncing at most once for the whole batch, when the sync policy asks or an
+// event has priority. Records are chained exactly as if
+// they had been written individually. Either every event is written or none
+// is, and the returned slice holds the sequence assigned to each event.
+func (w *WAL) WriteBatch(events []*core.LogEvent) ([]uint64, error) {
+	if w.closed.Load() {
+		return nil, fmt.Errorf("WAL is closed")
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Build the chained records without touching WAL state until the write succeeds
+	var last *Record
+	sequences := make([]uint64, len(events))
+	hashes := make([][32]byte, len(events))
+	timestamps := make([]int64, len(events))
+	seq := w.sequence
+	lastHash := w.lastHash
+	var data []byte
+
+	for i, event := range events {
+		seq++
+		record, err := w.newRecord(event, seq, lastHash)
+		if err != nil {
+			return nil, err
+		}
+
+		recordData, err := record.Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal record: %w", err)
+		}
+
+		data = append(data, recordData...)
+		last = record
+		lastHash = record.ComputeHash()
+		sequences[i] = seq
+		hashes[i] = lastHash
+		timestamps[i] = record.Timestamp
+	}
+
+	// Ask the sync policy, counting the batch as already written; a priority
+	// event syncs the whole batch
+	needsSync := w.needsSync(len(events), int64(len(data)))
+	for _, event := range events {
+		needsSync = needsSync || w.isPriority(event)
+	}
+
+	// One journal entry covers the whole batch for torn-write protection
+	if err := w.doubleWrite.WriteToJournal(data, w.currentSize, needsSync); err != nil {
+		return nil, fmt.Errorf("journal write failed: %w", err)
+	}
+
+	n, err := w.file.Write(data)
+	if err != nil {
+		_ = w.doubleWrite.MarkIncomplete()
+		return nil, fmt.Errorf("write failed: %w", err)
+	}
+	if n != len(data) {
+		_ = w.doubleWrite.MarkIncomplete()
+		return nil, fmt.Errorf("incomplete write: wrote %d of %d bytes", n, len(data))
+	}
+
+	if err := w.doubleWrite.MarkComplete(needsSync); err != nil {
+		return nil, fmt.Errorf("failed to mark journal complete: %w", err)
+	}
+
+	w.sequence = seq
+	w.lastHash = lastHash
+	w.currentSize += int64(n)
+	w.markDirty(len(events), int64(n))
+
+	for i, hash := range hashes {
+		w.appendLeaf(hash, timestamps[i])
+	}
+	for i, seq := range sequences {
+		if err := w.signRecord(seq, hashes[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if needsSync {
+		if err := w.syncLocked(); err != nil {
+			return nil, fmt.Errorf("sync failed: %w", err)
+		}
+	}
+
+	if w.segments.ShouldRotate(w.currentSize) {
+		if err := w.rotate(); err != nil {
+			return nil, fmt.Errorf("rotation failed: %w", err)
+		}
+	}
+	w.noteKey(last)
+
+	return sequences, nil
+}
+
+// newRecord builds the record for event in the WAL's format and codec,
+// compressing and encrypting it as configured.
+func (w *WAL) newRecord(event *core.LogEvent, sequence uint64, prevHash [32]byte) (*Record, error) {
+	record, err := newEventRecord(event, w.codec, sequence, prevHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create record: %w", err)
+	}
+	record.Flags |= w.format.flags()
+	if w.compression != CompressionNone {
+		if err := record.Compress(w.compression); err != nil {
+			return nil, err
+		}
+	}
+	if w.keys != nil {
+		if err := record.Encrypt(w.keys); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// LastSequence returns the sequence number of the most recently written record.
+func (w *WAL) LastSequence() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sequence
+}
+
 // Flush forces any buffered data to disk.
 func (w *WAL) Flush() error {
 	w.mu.Lock()
@@ -256,7 +519,74 @@ func (w *WAL) Flush() error {
 		return nil
 	}
 
-	return w.file.Sync()
+	return w.syncLocked()
+}
+
+// SyncTo ensures every record up to and including seq is on stable storage,
+// skipping the fsync if an earlier sync already covered it.
+func (w *WAL) SyncTo(seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.syncedSeq >= seq || w.file == nil {
+		return nil
+	}
+
+	if err := w.syncLocked(); err != nil {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+	return nil
+}
+
+// needsSync asks the sync policy whether to sync once records more records
+// totalling bytes have been written.
+func (w *WAL) needsSync(records int, bytes int64) bool {
+	oldest := w.oldestDirty
+	if oldest.IsZero() {
+		oldest = time.Now()
+	}
+	return w.syncPolicy.ShouldSync(SyncState{
+		OldestUnsynced: oldest,
+		PendingRecords: w.dirtyCount + records,
+		PendingBytes:   w.dirtyBytes + bytes,
+	})
+}
+
+// isPriority reports whether priority sync applies to event.
+func (w *WAL) isPriority(event *core.LogEvent) bool {
+	return w.priority != nil && isPriority(event, *w.priority)
+}
+
+// markDirty records data written but not yet synced.
+func (w *WAL) markDirty(records int, bytes int64) {
+	if w.dirtyCount == 0 {
+		w.oldestDirty = time.Now()
+	}
+	w.dirtyCount += records
+	w.dirtyBytes += bytes
+}
+
+// syncLocked fsyncs the active segment and reports its latency to the sync
+// policy. The caller must hold w.mu.
+func (w *WAL) syncLocked() error {
+	start := time.Now()
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.syncPolicy.ObserveSync(w.dirtyCount, time.Since(start))
+
+	// Signatures are only durable once their records are
+	if w.signer != nil {
+		if err := w.signer.Sync(); err != nil {
+			return err
+		}
+	}
+
+	w.syncedSeq = w.sequence
+	w.dirtyCount = 0
+	w.dirtyBytes = 0
+	w.oldestDirty = time.Time{}
+	return nil
 }
 
 // Close gracefully shuts down the WAL.
@@ -271,6 +601,9 @@ func (w *WAL) Close() error {
 		close(w.flushStop)
 	}
 
+	// Let sealed segments finish compressing and protecting
+	w.background.Wait()
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
@@ -280,6 +613,11 @@ func (w *WAL) Close() error {
 		if err := w.file.Sync(); err != nil {
 			errs = append(errs, fmt.Errorf("failed to sync WAL: %w", err))
 		}
+		if w.signer != nil {
+			if err := w.signer.Sync(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to sync signatures: %w", err))
+			}
+		}
 		if err := w.file.Close(); err != nil {
 			errs = append(errs, fmt.Errorf("failed to close WAL: %w", err))
 		}
@@ -350,11 +688,6 @@ func (w *WAL) VerifyIntegrityReport() (*IntegrityReport, error) {
 		return report, fmt.Errorf("failed to read records: %w", err)
 	}
 
-	// Empty WAL is valid
-	if len(records) == 0 {
-		return report, nil
-	}
-
 	// Verify each record and hash chain
 	var prevHash [32]byte
 	var lastSeq uint64
@@ -384,6 +717,27 @@ func (w *WAL) VerifyIntegrityReport() (*IntegrityReport, error) {
 	report.LastSequence = lastSeq
 	report.LastTimestamp = lastTime
 
+	if w.unsignedRecords > 0 {
+		report.UnsignedRecords = w.unsignedRecords
+		report.Valid = false
+	}
+
+	if w.sealer != nil {
+		seals, err := verifySeals(w.segments, sealPath(w.path), w.sealer)
+		if err != nil {
+			report.Valid = false
+			return report, err
+		}
+		report.SealedSegments = seals.Sealed
+		report.UnsealedSegments = seals.Unsealed
+		report.ResealedSegments = seals.Resealed
+		report.MissingSegments = seals.Missing
+		report.InvalidSeals = seals.Invalid
+		if !seals.Valid {
+			report.Valid = false
+		}
+	}
+
 	return report, nil
 }
 
@@ -472,6 +826,7 @@ func (w *WAL) recover() error {
 		}
 
 		w.sequence = lastRecord.Sequence
+		w.syncedSeq = lastRecord.Sequence
 		w.lastHash = lastRecord.ComputeHash()
 	}
 
@@ -517,16 +872,30 @@ func (w *WAL) recoverFromJournal() error {
 }
 
 func (w *WAL) rotate() error {
+	// Sealed segments must be durable before they are closed
+	if err := w.syncLocked(); err != nil {
+		return err
+	}
+
 	// Close current file
 	if err := w.file.Close(); err != nil {
 		return err
 	}
 
+	// Capture the seal before the segment state moves on
+	var seal *compliance.SegmentSeal
+	if w.sealer != nil && w.segmentMerkle.Size() > 0 {
+		seal = w.activeSeal()
+	}
+
 	// Use segment manager to rotate
+	sealedPath := w.segments.GetActivePath()
 	newPath, err := w.segments.Rotate(w.sequence)
 	if err != nil {
 		return err
 	}
+	w.segments.activeSegment().Format = w.format
+	w.segments.activeSegment().Version = w.codec.version()
 
 	// Open new file
 	flags := os.O_CREATE | os.O_RDWR | os.O_APPEND
@@ -542,10 +911,45 @@ func (w *WAL) rotate() error {
 
 	w.file = file
 	w.currentSize = 0
+	w.saveMerkle(w.sealedMerkleState(w.sequence, w.lastHash))
+	w.segmentMerkle.Reset()
+	w.segmentTimes = timeRange{}
+
+	// Seal once the next segment exists; a crash before this leaves it unsealed
+	if seal != nil {
+		if err := w.appendSeal(seal); err != nil {
+			return err
+		}
+	}
+
+	if w.zstdSegments || w.parity != nil {
+		w.finishSegment(sealedPath)
+	}
 
 	return nil
 }
 
+// finishSegment compresses a sealed segment and writes its parity in the
+// background. Compression comes first so the parity covers the stored bytes.
+func (w *WAL) finishSegment(path string) {
+	w.background.Add(1)
+	go func() {
+		defer w.background.Done()
+		w.rewriting.Lock()
+		defer w.rewriting.Unlock()
+		if w.zstdSegments {
+			if err := CompressSegment(path); err != nil {
+				logger.Log.Warn("Failed to compress sealed segment {segment}: {error}", path, err)
+			}
+		}
+		if w.parity != nil {
+			if err := WriteParity(path, *w.parity); err != nil {
+				logger.Log.Warn("Failed to write parity for segment {segment}: {error}", path, err)
+			}
+		}
+	}()
+}
+
 func (w *WAL) flushLoop() {
 	for {
 		select {
@@ -598,3 +1002,17 @@ func (w *WAL) GetSegments() []*Segment {
 	_ = w.segments.UpdateSegmentSizes()
 	return w.segments.GetSegments()
 }
+
+// SegmentsSnapshot returns a copy of the segment list that remains safe to
+// use while the WAL continues to write and rotate.
+func (w *WAL) SegmentsSnapshot() []Segment {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	segments := w.segments.GetSegments()
+	snapshot := make([]Segment, len(segments))
+	for i, seg := range segments {
+		snapshot[i] = *seg
+	}
+	return snapshot
+}
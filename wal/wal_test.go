@@ -134,3 +134,64 @@ func TestReadAllRecords(t *testing.T) {
 		}
 	}
 }
+
+func TestWALWriteBatch(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "test.wal")
+
+	w, err := New(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mix single writes and batches to check the chain stays continuous
+	single := &core.LogEvent{
+		Timestamp:       time.Now(),
+		Level:           core.InformationLevel,
+		MessageTemplate: "Single event",
+	}
+	if err := w.Write(single); err != nil {
+		t.Fatalf("Failed to write event: %v", err)
+	}
+
+	events := make([]*core.LogEvent, 25)
+	for i := range events {
+		events[i] = &core.LogEvent{
+			Timestamp:       time.Now(),
+			Level:           core.InformationLevel,
+			MessageTemplate: "Batched event",
+			Properties:      map[string]interface{}{"Index": i},
+		}
+	}
+
+	sequences, err := w.WriteBatch(events)
+	if err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+	if len(sequences) != len(events) {
+		t.Fatalf("Expected %d sequences, got %d", len(events), len(sequences))
+	}
+	for i, seq := range sequences {
+		if seq != uint64(i+2) {
+			t.Errorf("Event %d got sequence %d, want %d", i, seq, i+2)
+		}
+	}
+
+	if err := w.Write(single); err != nil {
+		t.Fatalf("Failed to write event: %v", err)
+	}
+
+	if err := w.VerifyIntegrity(); err != nil {
+		t.Fatalf("Integrity verification failed: %v", err)
+	}
+	_ = w.Close()
+
+	w2, err := New(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w2.Close() }()
+
+	if got := w2.LastSequence(); got != 27 {
+		t.Errorf("Expected sequence 27 after recovery, got %d", got)
+	}
+}
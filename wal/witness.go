@@ -0,0 +1,125 @@
+package wal
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+)
+
+// WitnessReport describes how a WAL matches the cosignatures its witnesses
+// returned.
+type WitnessReport struct {
+	Error        string
+	Witnesses    []string
+	Cosignatures int
+	Verified     int
+	// Invalid counts cosignatures by a known witness that do not verify.
+	Invalid int
+	// Mismatched counts valid cosignatures over a tree head the WAL no
+	// longer has.
+	Mismatched int
+	// Untrusted counts cosignatures by witnesses the verifier has no key for.
+	Untrusted int
+	Quorum    int
+	// WitnessedRecords is the largest tree size cosigned by at least Quorum
+	// distinct witnesses.
+	WitnessedRecords uint64
+	TotalRecords     uint64
+	Valid            bool
+}
+
+// ConsistencyPath returns the consistency proof between the log's tree at
+// sizes from and to, which must not exceed the records written.
+func (w *WAL) ConsistencyPath(from, to uint64) ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	tree, err := buildMerkleTree(w.segments)
+	if err != nil {
+		return nil, err
+	}
+	path, err := tree.ConsistencyProof(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return hexHashes(path), nil
+}
+
+// VerifyCosignatures checks every cosignature in the log at path against the
+// WAL at walPath. witnesses maps each trusted witness name to its
+// verifier. The WAL is witnessed when at least quorum of them cosigned the
+// same tree head and no trusted witness vouches for a head the WAL does not
+// have.
+func VerifyCosignatures(walPath, path string, witnesses map[string]compliance.Signer, quorum int) (*WitnessReport, error) {
+	if quorum < 1 || quorum > len(witnesses) {
+		return nil, fmt.Errorf("quorum must be between 1 and %d, got %d", len(witnesses), quorum)
+	}
+
+	segments, err := NewSegmentManager(walPath, 64*1024*1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	tree, err := buildMerkleTree(segments)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &WitnessReport{TotalRecords: tree.Size(), Quorum: quorum}
+	for name := range witnesses {
+		report.Witnesses = append(report.Witnesses, name)
+	}
+	sort.Strings(report.Witnesses)
+
+	cosigs, err := compliance.ReadCosignatures(path)
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+	report.Cosignatures = len(cosigs)
+
+	// Distinct witnesses that cosigned each tree size
+	cosigners := make(map[uint64]map[string]bool)
+	for _, cosig := range cosigs {
+		verifier, ok := witnesses[cosig.Witness]
+		if !ok {
+			report.Untrusted++
+			continue
+		}
+		if err := cosig.Verify(verifier); err != nil {
+			report.Invalid++
+			if report.Error == "" {
+				report.Error = err.Error()
+			}
+			continue
+		}
+
+		root, err := tree.RootAt(cosig.TreeSize)
+		if err != nil || hexHash(root) != cosig.RootHash {
+			report.Mismatched++
+			if report.Error == "" {
+				report.Error = fmt.Sprintf("WAL does not match the tree head %s cosigned at size %d",
+					cosig.Witness, cosig.TreeSize)
+			}
+			continue
+		}
+
+		report.Verified++
+		if cosigners[cosig.TreeSize] == nil {
+			cosigners[cosig.TreeSize] = make(map[string]bool)
+		}
+		cosigners[cosig.TreeSize][cosig.Witness] = true
+	}
+
+	for size, names := range cosigners {
+		if len(names) >= quorum && size > report.WitnessedRecords {
+			report.WitnessedRecords = size
+		}
+	}
+	if report.Error == "" && report.WitnessedRecords == 0 {
+		report.Error = fmt.Sprintf("no tree head was cosigned by %d of %d witnesses", quorum, len(witnesses))
+	}
+
+	report.Valid = report.Invalid == 0 && report.Mismatched == 0 && report.WitnessedRecords > 0
+	return report, nil
+}
@@ -0,0 +1,120 @@
+package wal
+
+import (
+	"crypto/sha256"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+)
+
+func TestVerifyCosignatures(t *testing.T) {
+	logSigner, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnessSigner, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	witness, err := compliance.NewWitness("witness-a", witnessSigner, "", logSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID, err := compliance.LogID(logSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	walPath := filepath.Join(t.TempDir(), "witnessed.wal")
+	cosigPath := walPath + ".cosignatures"
+	w, err := New(walPath, WithSegmentSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var witnessed uint64
+	for i := 0; i < 12; i++ {
+		if err := w.Write(signingEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+		if i != 4 && i != 11 {
+			continue
+		}
+		root, size, err := w.SyncedMerkleRoot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cp, err := compliance.SignCheckpoint(logSigner, size, root, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := w.ConsistencyPath(witnessed, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cosig, err := witness.Cosign(&compliance.WitnessRequest{LogID: logID, Checkpoint: cp, OldSize: witnessed, Proof: proof})
+		if err != nil {
+			t.Fatalf("Cosign at size %d: %v", size, err)
+		}
+		if err := compliance.AppendCosignature(cosigPath, cosig); err != nil {
+			t.Fatal(err)
+		}
+		witnessed = size
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	witnesses := map[string]compliance.Signer{"witness-a": witnessSigner}
+	report, err := VerifyCosignatures(walPath, cosigPath, witnesses, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Verified != 2 || report.WitnessedRecords != 12 {
+		t.Errorf("Expected 12 witnessed records, got %+v", report)
+	}
+
+	// A quorum the cosignatures cannot reach fails
+	other, err := compliance.NewEd25519Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	witnesses["witness-b"] = other
+	if report, _ := VerifyCosignatures(walPath, cosigPath, witnesses, 2); report.Valid {
+		t.Errorf("Expected a missed quorum to fail, got %+v", report)
+	}
+	if _, err := VerifyCosignatures(walPath, cosigPath, witnesses, 3); err == nil {
+		t.Error("Expected error for a quorum larger than the witnesses")
+	}
+
+	// The wrong key for a witness invalidates its cosignatures
+	if report, _ := VerifyCosignatures(walPath, cosigPath, map[string]compliance.Signer{"witness-a": other}, 1); report.Valid || report.Invalid != 2 {
+		t.Errorf("Expected cosignatures under the wrong key to fail, got %+v", report)
+	}
+
+	// A witness that was shown a forked log vouches for a head the WAL
+	// does not have
+	fresh, err := compliance.NewWitness("witness-a", witnessSigner, "", logSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := compliance.SignCheckpoint(logSigner, 12, sha256.Sum256([]byte("fork")), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	forked, err := fresh.Cosign(&compliance.WitnessRequest{LogID: logID, Checkpoint: cp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compliance.AppendCosignature(cosigPath, forked); err != nil {
+		t.Fatal(err)
+	}
+	report, err = VerifyCosignatures(walPath, cosigPath, map[string]compliance.Signer{"witness-a": witnessSigner}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Mismatched != 1 {
+		t.Errorf("Expected a mismatched cosignature, got %+v", report)
+	}
+}
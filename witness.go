@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/willibrandon/mtlog-audit/compliance"
+	"github.com/willibrandon/mtlog-audit/wal"
+)
+
+// witnessTimeout bounds a round of cosigning requests.
+const witnessTimeout = 15 * time.Second
+
+// witnessPublisher submits checkpoints to witnesses for cosigning.
+type witnessPublisher struct {
+	wal       *wal.WAL
+	logID     string
+	path      string
+	witnesses []*witnessPeer
+	quorum    int
+}
+
+// witnessPeer is a witness and the tree size it last cosigned.
+type witnessPeer struct {
+	client *compliance.WitnessClient
+	size   uint64
+}
+
+// newWitnessPublisher creates a publisher that appends cosignatures to the
+// log at path.
+func newWitnessPublisher(w *wal.WAL, signer compliance.Signer, path string, quorum int, urls []string) (*witnessPublisher, error) {
+	logID, err := compliance.LogID(signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive log ID: %w", err)
+	}
+	p := &witnessPublisher{wal: w, logID: logID, path: path, quorum: quorum}
+	for _, url := range urls {
+		p.witnesses = append(p.witnesses, &witnessPeer{client: compliance.NewWitnessClient(url)})
+	}
+	return p, nil
+}
+
+// publish asks every witness to cosign cp and stores the cosignatures. It
+// fails if fewer than the quorum cosigned. The caller must serialize calls.
+func (p *witnessPublisher) publish(cp *compliance.Checkpoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), witnessTimeout)
+	defer cancel()
+
+	cosigs := make([]*compliance.Cosignature, len(p.witnesses))
+	errs := make([]error, len(p.witnesses))
+	var wg sync.WaitGroup
+	for i, peer := range p.witnesses {
+		wg.Add(1)
+		go func(i int, peer *witnessPeer) {
+			defer wg.Done()
+			cosigs[i], errs[i] = p.cosign(ctx, peer, cp)
+		}(i, peer)
+	}
+	wg.Wait()
+
+	cosigned := 0
+	var firstErr error
+	for i, cosig := range cosigs {
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", p.witnesses[i].client.URL(), errs[i])
+			}
+			continue
+		}
+		if err := compliance.AppendCosignature(p.path, cosig); err != nil {
+			return err
+		}
+		cosigned++
+	}
+
+	if cosigned < p.quorum {
+		return fmt.Errorf("only %d of %d witnesses cosigned tree size %d, need %d: %w",
+			cosigned, len(p.witnesses), cp.TreeSize, p.quorum, firstErr)
+	}
+	return nil
+}
+
+// cosign submits cp to one witness. A witness that last cosigned a different
+// size than expected, such as after a restart, is retried once with a proof
+// from the size it reports.
+func (p *witnessPublisher) cosign(ctx context.Context, peer *witnessPeer, cp *compliance.Checkpoint) (*compliance.Cosignature, error) {
+	for attempt := 0; ; attempt++ {
+		if peer.size > cp.TreeSize {
+			return nil, fmt.Errorf("witness has cosigned tree size %d, beyond checkpoint size %d", peer.size, cp.TreeSize)
+		}
+		proof, err := p.wal.ConsistencyPath(peer.size, cp.TreeSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build consistency proof: %w", err)
+		}
+
+		cosig, err := peer.client.Cosign(ctx, &compliance.WitnessRequest{
+			LogID:      p.logID,
+			Checkpoint: cp,
+			OldSize:    peer.size,
+			Proof:      proof,
+		})
+		var conflict *compliance.WitnessConflictError
+		if errors.As(err, &conflict) && attempt == 0 {
+			peer.size = conflict.Size
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		peer.size = cp.TreeSize
+		return cosig, nil
+	}
+}